@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+type claimsKey struct{}
+
+// WithClaims returns a copy of ctx carrying c.
+func WithClaims(ctx context.Context, c *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, c)
+}
+
+// ClaimsFrom returns the claims Authenticate or Require stored in ctx.
+func ClaimsFrom(ctx context.Context) (*Claims, bool) {
+	c, ok := ctx.Value(claimsKey{}).(*Claims)
+	return c, ok
+}
+
+// Authenticate verifies the request's bearer token. Callers answer 401 on
+// error.
+func Authenticate(secret []byte, r *http.Request) (*Claims, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("token verification not configured")
+	}
+	token, err := BearerToken(r)
+	if err != nil {
+		return nil, err
+	}
+	return Verify(secret, token)
+}
+
+// RequireUser lets a request through only with a valid token; the claims are
+// then available through ClaimsFrom. A missing or invalid token is answered
+// with 401.
+func RequireUser(secret []byte, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, err := Authenticate(secret, r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+			http.Error(w, "authentication required", http.StatusUnauthorized)
+			return
+		}
+		next(w, r.WithContext(WithClaims(r.Context(), c)))
+	}
+}
+
+// Require lets a request through only with a valid token carrying
+// permission; the claims are then available through ClaimsFrom. A missing or
+// invalid token is answered with 401, a missing permission with 403. An empty
+// secret rejects every request.
+func Require(secret []byte, permission string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, err := Authenticate(secret, r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+			http.Error(w, "authentication required", http.StatusUnauthorized)
+			return
+		}
+		if !c.Can(permission) {
+			http.Error(w, "missing permission "+permission, http.StatusForbidden)
+			return
+		}
+		next(w, r.WithContext(WithClaims(r.Context(), c)))
+	}
+}
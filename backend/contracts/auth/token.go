@@ -0,0 +1,150 @@
+// Package auth defines the access token issued by auth_service and accepted by
+// every other service: its claims, the roles and permissions it carries and
+// how it is signed and verified. Tokens are HS256 JWTs signed with a secret
+// shared through JWT_SECRET.
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Issuer is written into and required from every token.
+const Issuer = "auth_service"
+
+// Roles stored in auth_service. Every user has RoleUser.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Permissions granted through roles. Admin endpoints check these rather than
+// role names, so a role can be given a subset of the admin API.
+const (
+	// PermSubscriptionsAssign allows assigning plans without payment.
+	PermSubscriptionsAssign = "subscriptions:assign"
+	// PermPlansManage allows editing the plan catalogue.
+	PermPlansManage = "plans:manage"
+	// PermRolesManage allows granting and revoking user roles.
+	PermRolesManage = "roles:manage"
+	// PermAuditRead allows reading the security audit log.
+	PermAuditRead = "audit:read"
+)
+
+// API key scopes. A request made with an API key only reaches the gateway
+// routes of the key's scopes. A key can also carry permissions its owner
+// holds, which admin routes check as usual.
+const (
+	// ScopeChat allows chatting and reading chat history.
+	ScopeChat = "chat"
+	// ScopeDocuments allows uploading, searching and sharing documents.
+	ScopeDocuments = "documents"
+	// ScopeSubscription allows reading and managing the subscription.
+	ScopeSubscription = "subscription"
+	// ScopeNotifications allows reading notifications.
+	ScopeNotifications = "notifications"
+)
+
+// APIKeyScopes are the scopes an API key can be created with, besides
+// permissions.
+var APIKeyScopes = []string{ScopeChat, ScopeDocuments, ScopeSubscription, ScopeNotifications}
+
+// APIKeyPrefix starts every API key, so it can be told apart from an access
+// token.
+const APIKeyPrefix = "ak_"
+
+var (
+	// ErrMissingToken means the request has no bearer token.
+	ErrMissingToken = errors.New("missing bearer token")
+	// ErrInvalidToken means the token is malformed, expired or not signed
+	// with the shared secret.
+	ErrInvalidToken = errors.New("invalid token")
+)
+
+// Claims is the payload of an access token. The user id is the subject.
+type Claims struct {
+	Username    string   `json:"username"`
+	Email       string   `json:"email"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+	// APIKeyID and Scopes are set on tokens the gateway obtained for an API
+	// key; Permissions are then limited to those among the key's scopes.
+	APIKeyID string   `json:"api_key_id,omitempty"`
+	Scopes   []string `json:"scopes,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// UserID returns the id of the user the token was issued to.
+func (c *Claims) UserID() string {
+	return c.Subject
+}
+
+// HasRole reports whether the token carries role.
+func (c *Claims) HasRole(role string) bool {
+	return slices.Contains(c.Roles, role)
+}
+
+// Can reports whether the token carries permission.
+func (c *Claims) Can(permission string) bool {
+	return slices.Contains(c.Permissions, permission)
+}
+
+// ViaAPIKey reports whether the token was issued for an API key.
+func (c *Claims) ViaAPIKey() bool {
+	return c.APIKeyID != ""
+}
+
+// Sign issues a token for c valid for ttl and returns it with its expiry.
+func Sign(secret []byte, c Claims, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now().UTC()
+	expires := now.Add(ttl)
+	c.Issuer = Issuer
+	c.IssuedAt = jwt.NewNumericDate(now)
+	c.ExpiresAt = jwt.NewNumericDate(expires)
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &c).SignedString(secret)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("sign token: %w", err)
+	}
+	return token, expires, nil
+}
+
+// Verify parses token and checks its signature, issuer and expiry.
+func Verify(secret []byte, token string) (*Claims, error) {
+	var c Claims
+	_, err := jwt.ParseWithClaims(token, &c, func(*jwt.Token) (any, error) {
+		return secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(Issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if c.Subject == "" {
+		return nil, fmt.Errorf("%w: no subject", ErrInvalidToken)
+	}
+	return &c, nil
+}
+
+// BearerToken returns the token of the request's Authorization header.
+func BearerToken(r *http.Request) (string, error) {
+	return ParseBearer(r.Header.Get("Authorization"))
+}
+
+// ParseBearer returns the token of an Authorization header value, for
+// servers that don't use net/http.
+func ParseBearer(header string) (string, error) {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", ErrMissingToken
+	}
+	return strings.TrimSpace(token), nil
+}
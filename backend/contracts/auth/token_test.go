@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestVerify(t *testing.T) {
+	secret := []byte("test-secret")
+	now := time.Now()
+
+	sign := func(method jwt.SigningMethod, key any, c Claims) string {
+		t.Helper()
+		s, err := jwt.NewWithClaims(method, &c).SignedString(key)
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		return s
+	}
+	claims := func(mod func(*Claims)) Claims {
+		c := Claims{
+			Username: "ada",
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   "59d09c4a-9873-49bd-9508-2cadb8a52393",
+				Issuer:    Issuer,
+				IssuedAt:  jwt.NewNumericDate(now),
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+			},
+		}
+		if mod != nil {
+			mod(&c)
+		}
+		return c
+	}
+
+	issued, _, err := Sign(secret, claims(nil), time.Hour)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantSub string
+	}{
+		{"issued by Sign", issued, "59d09c4a-9873-49bd-9508-2cadb8a52393"},
+		{"valid", sign(jwt.SigningMethodHS256, secret, claims(nil)), "59d09c4a-9873-49bd-9508-2cadb8a52393"},
+		{"other secret", sign(jwt.SigningMethodHS256, []byte("other"), claims(nil)), ""},
+		{"HS512", sign(jwt.SigningMethodHS512, secret, claims(nil)), ""},
+		{"alg none", sign(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, claims(nil)), ""},
+		{"expired", sign(jwt.SigningMethodHS256, secret, claims(func(c *Claims) {
+			c.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Minute))
+		})), ""},
+		{"no expiry", sign(jwt.SigningMethodHS256, secret, claims(func(c *Claims) {
+			c.ExpiresAt = nil
+		})), ""},
+		{"other issuer", sign(jwt.SigningMethodHS256, secret, claims(func(c *Claims) {
+			c.Issuer = "someone_else"
+		})), ""},
+		{"no subject", sign(jwt.SigningMethodHS256, secret, claims(func(c *Claims) {
+			c.Subject = ""
+		})), ""},
+		{"malformed", "not.a.token", ""},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := Verify(secret, tt.token)
+			if tt.wantSub == "" {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Fatalf("Verify() error = %v, want ErrInvalidToken", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if c.UserID() != tt.wantSub {
+				t.Errorf("UserID() = %q, want %q", c.UserID(), tt.wantSub)
+			}
+		})
+	}
+}
+
+func TestParseBearer(t *testing.T) {
+	tests := []struct {
+		header  string
+		want    string
+		wantErr bool
+	}{
+		{"Bearer abc", "abc", false},
+		{"bearer abc", "abc", false},
+		{"Bearer  abc ", "abc", false},
+		{"", "", true},
+		{"Bearer", "", true},
+		{"Bearer   ", "", true},
+		{"Basic abc", "", true},
+		{"abc", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseBearer(tt.header)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseBearer(%q) error = %v, wantErr %v", tt.header, err, tt.wantErr)
+			continue
+		}
+		if err != nil && !errors.Is(err, ErrMissingToken) {
+			t.Errorf("ParseBearer(%q) error = %v, want ErrMissingToken", tt.header, err)
+		}
+		if got != tt.want {
+			t.Errorf("ParseBearer(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+// Command schemagen writes the JSON Schema of the event envelope and of every
+// registered event payload into the schemas directory.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"contracts/events"
+	"contracts/schema"
+)
+
+func main() {
+	out := flag.String("out", "schemas", "output directory")
+	flag.Parse()
+
+	if err := os.MkdirAll(*out, 0o755); err != nil {
+		log.Fatalf("create %s: %v", *out, err)
+	}
+
+	env := *events.EnvelopeSchema()
+	env.Title = "Event envelope"
+	write(*out, "envelope.schema.json", &env)
+
+	for _, def := range events.Definitions() {
+		s := *def.Schema
+		s.Title = fmt.Sprintf("%s v%d", def.Type, def.Version)
+		write(*out, fmt.Sprintf("%s.v%d.schema.json", def.Type, def.Version), &s)
+	}
+}
+
+func write(dir, name string, s *schema.Schema) {
+	s.Schema = schema.Draft
+	s.ID = name
+
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		log.Fatalf("marshal %s: %v", name, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), append(b, '\n'), 0o644); err != nil {
+		log.Fatalf("write %s: %v", name, err)
+	}
+	log.Printf("wrote %s", name)
+}
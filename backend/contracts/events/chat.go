@@ -0,0 +1,45 @@
+package events
+
+// Event types published on the chat topic.
+const (
+	TypeChatCompleted = "chat_completed"
+	TypeFileAttached  = "file_attached"
+	TypeQuotaChanged  = "quota_changed"
+)
+
+// ChatCompleted is published once the assistant has answered a message.
+// UsageID identifies the chat turn for quota accounting. It is optional to
+// keep v1 backward compatible; consumers fall back to the envelope id.
+// PromptTokens and CompletionTokens are the usage reported by the LLM
+// provider, zero when it didn't report any.
+type ChatCompleted struct {
+	UserID           string `json:"user_id" schema:"format=uuid"`
+	ConversationID   string `json:"conversation_id"`
+	Message          string `json:"message"`
+	Response         string `json:"response" schema:"allowEmpty"`
+	FileID           string `json:"file_id,omitempty" schema:"format=uuid"`
+	UsageID          string `json:"usage_id,omitempty" schema:"format=uuid,desc=Idempotency key of the chat turn for quota accounting"`
+	PromptTokens     int    `json:"prompt_tokens,omitempty" schema:"minimum=0"`
+	CompletionTokens int    `json:"completion_tokens,omitempty" schema:"minimum=0"`
+}
+
+func (*ChatCompleted) EventType() string { return TypeChatCompleted }
+
+// FileAttached records a document upload in the user's chat history.
+type FileAttached struct {
+	UserID         string `json:"user_id" schema:"format=uuid"`
+	ConversationID string `json:"conversation_id"`
+	Message        string `json:"message" schema:"desc=Chat line shown for the upload such as 📎 report.pdf"`
+	FileID         string `json:"file_id" schema:"format=uuid"`
+	FileName       string `json:"file_name"`
+}
+
+func (*FileAttached) EventType() string { return TypeFileAttached }
+
+// QuotaChanged is published when a user's remaining quota changes.
+type QuotaChanged struct {
+	UserID         string `json:"user_id" schema:"format=uuid"`
+	RemainingQuota int    `json:"remaining_quota" schema:"minimum=0"`
+}
+
+func (*QuotaChanged) EventType() string { return TypeQuotaChanged }
@@ -0,0 +1,120 @@
+// Package events defines every event exchanged over Kafka, wrapped in a
+// versioned envelope. Producers build messages with Marshal and consumers read
+// them with Decode; both sides validate against the generated JSON Schema so a
+// malformed event is rejected where it is produced and where it is consumed.
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"contracts/schema"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalidEvent is returned when a message is not valid JSON or does not
+	// match the envelope or payload schema.
+	ErrInvalidEvent = errors.New("invalid event")
+	// ErrUnknownEvent is returned for a type/version pair with no registered
+	// payload.
+	ErrUnknownEvent = errors.New("unknown event")
+)
+
+// Producer names written into Envelope.Producer.
+const (
+	ProducerAuth         = "auth_service"
+	ProducerChat         = "chat_service"
+	ProducerChatData     = "chat_data_service"
+	ProducerSubscription = "subscription_service"
+	ProducerOCR          = "ocr_service"
+	ProducerEmbedding    = "embedding_service"
+)
+
+// Envelope is the wire format of every event.
+type Envelope struct {
+	ID            string          `json:"id" schema:"format=uuid,desc=Unique event id; consumers may use it for deduplication"`
+	Type          string          `json:"type" schema:"desc=Event type in snake_case"`
+	Version       int             `json:"version" schema:"minimum=1,desc=Version of the data schema for this type"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	Producer      string          `json:"producer" schema:"desc=Name of the service that emitted the event"`
+	CorrelationID string          `json:"correlation_id,omitempty" schema:"desc=Id shared by all events of one flow"`
+	Data          json.RawMessage `json:"data"`
+}
+
+// Payload is implemented by every event body.
+type Payload interface {
+	EventType() string
+}
+
+var envelopeSchema = schema.Generate(Envelope{})
+
+// New wraps p in an envelope after validating it against its schema.
+func New(producer, correlationID string, p Payload) (*Envelope, error) {
+	def, ok := current[p.EventType()]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEvent, p.EventType())
+	}
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		return nil, fmt.Errorf("marshal %s: %w", p.EventType(), err)
+	}
+	if err := schema.ValidateJSON(def.schema, data); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidEvent, p.EventType(), err)
+	}
+
+	return &Envelope{
+		ID:            uuid.New().String(),
+		Type:          p.EventType(),
+		Version:       def.version,
+		OccurredAt:    time.Now().UTC(),
+		Producer:      producer,
+		CorrelationID: correlationID,
+		Data:          data,
+	}, nil
+}
+
+// Marshal builds an envelope for p and encodes it as JSON.
+func Marshal(producer, correlationID string, p Payload) ([]byte, error) {
+	env, err := New(producer, correlationID, p)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(env)
+}
+
+// Decode validates a raw message and returns its envelope together with the
+// typed payload. Use a type switch on the payload to dispatch.
+func Decode(b []byte) (*Envelope, Payload, error) {
+	if err := schema.ValidateJSON(envelopeSchema, b); err != nil {
+		return nil, nil, fmt.Errorf("%w: envelope: %v", ErrInvalidEvent, err)
+	}
+
+	var env Envelope
+	if err := json.Unmarshal(b, &env); err != nil {
+		return nil, nil, fmt.Errorf("%w: envelope: %v", ErrInvalidEvent, err)
+	}
+
+	def, ok := registry[key{env.Type, env.Version}]
+	if !ok {
+		return &env, nil, fmt.Errorf("%w: %s v%d", ErrUnknownEvent, env.Type, env.Version)
+	}
+	if err := schema.ValidateJSON(def.schema, env.Data); err != nil {
+		return &env, nil, fmt.Errorf("%w: %s v%d: %v", ErrInvalidEvent, env.Type, env.Version, err)
+	}
+
+	p := def.newPayload()
+	if err := json.Unmarshal(env.Data, p); err != nil {
+		return &env, nil, fmt.Errorf("%w: %s v%d: %v", ErrInvalidEvent, env.Type, env.Version, err)
+	}
+	return &env, p, nil
+}
+
+// EnvelopeSchema returns the JSON Schema of the envelope.
+func EnvelopeSchema() *schema.Schema {
+	return envelopeSchema
+}
@@ -0,0 +1,85 @@
+package events
+
+// Event types of the document pipeline (upload → OCR → embedding). Each type
+// is also the name of the topic it is published on.
+const (
+	TypeFileUploaded    = "file_uploaded"
+	TypeOCRProcessed    = "ocr_processed"
+	TypeOCRFailed       = "ocr_failed"
+	TypeEmbeddingStored = "embedding_stored"
+	TypeEmbeddingFailed = "embedding_failed"
+)
+
+// FileUploaded is published by ocr_service after a file is saved to disk.
+type FileUploaded struct {
+	FileID      string `json:"file_id" schema:"format=uuid"`
+	FileName    string `json:"file_name"`
+	FilePath    string `json:"file_path"`
+	ContentType string `json:"content_type" schema:"allowEmpty"`
+	FileSize    int64  `json:"file_size" schema:"minimum=0"`
+	UploaderID  string `json:"uploader_id,omitempty" schema:"format=uuid"`
+}
+
+func (*FileUploaded) EventType() string { return TypeFileUploaded }
+
+// Chunk is a piece of extracted text.
+type Chunk struct {
+	ChunkID     string                 `json:"chunk_id" schema:"format=uuid"`
+	Text        string                 `json:"text"`
+	Page        int                    `json:"page,omitempty" schema:"minimum=0"`
+	StartOffset int                    `json:"start_offset,omitempty" schema:"minimum=0"`
+	EndOffset   int                    `json:"end_offset,omitempty" schema:"minimum=0"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// OCRProcessed carries the text chunks extracted from an uploaded file.
+// Pages is the number of pages that went through OCR.
+type OCRProcessed struct {
+	FileID      string  `json:"file_id" schema:"format=uuid"`
+	FileName    string  `json:"file_name"`
+	ContentType string  `json:"content_type" schema:"allowEmpty"`
+	Chunks      []Chunk `json:"chunks"`
+	Language    string  `json:"language,omitempty"`
+	UploaderID  string  `json:"uploader_id,omitempty" schema:"format=uuid"`
+	Pages       int     `json:"pages,omitempty" schema:"minimum=0"`
+}
+
+func (*OCRProcessed) EventType() string { return TypeOCRProcessed }
+
+// OCRFailed is published when no text could be extracted from a file.
+type OCRFailed struct {
+	FileID string `json:"file_id" schema:"format=uuid"`
+	Error  string `json:"error"`
+}
+
+func (*OCRFailed) EventType() string { return TypeOCRFailed }
+
+// StoredChunk points from an OCR chunk to its vector in Qdrant.
+type StoredChunk struct {
+	ChunkID  string `json:"chunk_id" schema:"format=uuid"`
+	VectorID string `json:"vector_id"`
+	Page     int    `json:"page" schema:"minimum=0"`
+}
+
+// EmbeddingStored is published after a file's chunks are written to Qdrant.
+// EmbeddedTokens and Pages are metered against the uploader's plan.
+type EmbeddingStored struct {
+	FileID         string        `json:"file_id" schema:"format=uuid"`
+	FileName       string        `json:"file_name"`
+	ContentType    string        `json:"content_type" schema:"allowEmpty"`
+	Chunks         []StoredChunk `json:"chunks"`
+	TotalChunks    int           `json:"total_chunks" schema:"minimum=0"`
+	UploaderID     string        `json:"uploader_id,omitempty" schema:"format=uuid"`
+	EmbeddedTokens int           `json:"embedded_tokens,omitempty" schema:"minimum=0"`
+	Pages          int           `json:"pages,omitempty" schema:"minimum=0"`
+}
+
+func (*EmbeddingStored) EventType() string { return TypeEmbeddingStored }
+
+// EmbeddingFailed is published when a file could not be embedded.
+type EmbeddingFailed struct {
+	FileID string `json:"file_id" schema:"format=uuid"`
+	Error  string `json:"error"`
+}
+
+func (*EmbeddingFailed) EventType() string { return TypeEmbeddingFailed }
@@ -0,0 +1,43 @@
+package events
+
+// Event types published by auth_service for organisations. Both go to the
+// same topic, keyed by organisation id, so consumers see them in order.
+const (
+	TypeOrgMemberChanged    = "org_member_changed"
+	TypeOrganisationDeleted = "organisation_deleted"
+)
+
+// Organisation member roles.
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+// Membership changes.
+const (
+	MemberAdded       = "added"
+	MemberRoleChanged = "role_changed"
+	MemberRemoved     = "removed"
+)
+
+// OrgMemberChanged is published when a user joins or leaves an organisation
+// or gets another role in it. Role is the role after the change; for a
+// removal it is the role the user had.
+type OrgMemberChanged struct {
+	OrganisationID string `json:"organisation_id" schema:"format=uuid"`
+	UserID         string `json:"user_id" schema:"format=uuid"`
+	Role           string `json:"role" schema:"enum=owner|admin|member"`
+	Action         string `json:"action" schema:"enum=added|role_changed|removed"`
+}
+
+func (*OrgMemberChanged) EventType() string { return TypeOrgMemberChanged }
+
+// OrganisationDeleted is published after an organisation is deleted. No
+// removal is published per member; consumers drop all memberships of the
+// organisation.
+type OrganisationDeleted struct {
+	OrganisationID string `json:"organisation_id" schema:"format=uuid"`
+}
+
+func (*OrganisationDeleted) EventType() string { return TypeOrganisationDeleted }
@@ -0,0 +1,89 @@
+package events
+
+import (
+	"reflect"
+	"sort"
+
+	"contracts/schema"
+)
+
+type key struct {
+	eventType string
+	version   int
+}
+
+type definition struct {
+	version    int
+	newPayload func() Payload
+	schema     *schema.Schema
+}
+
+var (
+	// registry holds every known type/version pair for decoding.
+	registry = map[key]definition{}
+	// current holds the version producers emit for each type.
+	current = map[string]definition{}
+)
+
+// register adds p as the current version of its event type. Older versions
+// that consumers must still read can be registered before the newer one.
+func register(version int, p Payload) {
+	t := reflect.TypeOf(p)
+	def := definition{
+		version: version,
+		newPayload: func() Payload {
+			return reflect.New(t.Elem()).Interface().(Payload)
+		},
+		schema: schema.Generate(p),
+	}
+
+	k := key{p.EventType(), version}
+	if _, dup := registry[k]; dup {
+		panic("events: duplicate registration of " + p.EventType())
+	}
+	registry[k] = def
+	current[p.EventType()] = def
+}
+
+// Definition describes one registered event for schema generation.
+type Definition struct {
+	Type    string
+	Version int
+	Schema  *schema.Schema
+}
+
+// Definitions lists all registered events ordered by type and version.
+func Definitions() []Definition {
+	defs := make([]Definition, 0, len(registry))
+	for k, def := range registry {
+		defs = append(defs, Definition{Type: k.eventType, Version: k.version, Schema: def.schema})
+	}
+	sort.Slice(defs, func(i, j int) bool {
+		if defs[i].Type != defs[j].Type {
+			return defs[i].Type < defs[j].Type
+		}
+		return defs[i].Version < defs[j].Version
+	})
+	return defs
+}
+
+func init() {
+	register(1, &UserRegistered{})
+	register(1, &UserDeleted{})
+	register(1, &UserDataPurged{})
+	register(1, &OrgMemberChanged{})
+	register(1, &OrganisationDeleted{})
+
+	register(1, &ChatCompleted{})
+	register(1, &FileAttached{})
+	register(1, &QuotaChanged{})
+
+	register(1, &FileUploaded{})
+	register(1, &OCRProcessed{})
+	register(1, &OCRFailed{})
+	register(1, &EmbeddingStored{})
+	register(1, &EmbeddingFailed{})
+
+	register(1, &SubscriptionChanged{})
+	register(1, &QuotaAlert{})
+}
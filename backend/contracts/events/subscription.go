@@ -0,0 +1,67 @@
+package events
+
+// Event types published by subscription_service.
+const (
+	TypeSubscriptionChanged = "subscription_changed"
+	TypeQuotaAlert          = "quota_alert"
+)
+
+// Reasons a subscription changed.
+const (
+	ChangeCreated         = "created"
+	ChangePlanChanged     = "plan_changed"
+	ChangeCancelScheduled = "cancel_scheduled"
+	ChangeCancelRevoked   = "cancel_revoked"
+	ChangeCancelled       = "cancelled"
+	ChangePastDue         = "past_due"
+	ChangeExpired         = "expired"
+	ChangeRenewed         = "renewed"
+)
+
+// SubscriptionChanged is published whenever a subscription's plan, status
+// or scheduled cancellation changes. ProrationCents is the price difference
+// for the rest of the period on a plan change; negative is a credit.
+type SubscriptionChanged struct {
+	UserID            string `json:"user_id" schema:"format=uuid"`
+	SubscriptionID    string `json:"subscription_id" schema:"format=uuid"`
+	PlanID            string `json:"plan_id" schema:"format=uuid"`
+	PlanName          string `json:"plan_name"`
+	PreviousPlanID    string `json:"previous_plan_id,omitempty" schema:"format=uuid"`
+	Status            string `json:"status" schema:"enum=trialing|active|past_due|cancelled|expired"`
+	PreviousStatus    string `json:"previous_status,omitempty" schema:"enum=trialing|active|past_due|cancelled|expired"`
+	Reason            string `json:"reason" schema:"enum=created|plan_changed|cancel_scheduled|cancel_revoked|cancelled|past_due|expired|renewed"`
+	CurrentPeriodEnd  string `json:"current_period_end" schema:"format=date-time"`
+	CancelAtPeriodEnd bool   `json:"cancel_at_period_end"`
+	RemainingQuota    int    `json:"remaining_quota" schema:"minimum=0"`
+	ProrationCents    int64  `json:"proration_cents,omitempty"`
+	Currency          string `json:"currency,omitempty"`
+}
+
+func (*SubscriptionChanged) EventType() string { return TypeSubscriptionChanged }
+
+// Quota alert kinds.
+const (
+	AlertThreshold = "threshold"
+	AlertExpired   = "expired"
+)
+
+// QuotaAlert is published once per quota period when a meter's usage
+// crosses one of the configured thresholds (ThresholdPercent, 100 meaning
+// exhausted), and once when a subscription expires unpaid. Used and Limit
+// are the meter's values at the time; for messages Limit includes rolled
+// over quota. Alerts of an organisation's subscription go to each of its
+// owners and admins, with OrganisationID set.
+type QuotaAlert struct {
+	UserID           string `json:"user_id" schema:"format=uuid"`
+	OrganisationID   string `json:"organisation_id,omitempty" schema:"format=uuid"`
+	SubscriptionID   string `json:"subscription_id" schema:"format=uuid"`
+	PlanName         string `json:"plan_name"`
+	Kind             string `json:"kind" schema:"enum=threshold|expired"`
+	Meter            string `json:"meter,omitempty"`
+	ThresholdPercent int    `json:"threshold_percent,omitempty" schema:"minimum=0"`
+	Used             int64  `json:"used,omitempty" schema:"minimum=0"`
+	Limit            int64  `json:"limit,omitempty" schema:"minimum=0"`
+	PeriodEnd        string `json:"period_end" schema:"format=date-time,desc=End of the quota period or when the subscription ended"`
+}
+
+func (*QuotaAlert) EventType() string { return TypeQuotaAlert }
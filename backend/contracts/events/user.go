@@ -0,0 +1,43 @@
+package events
+
+// Event types published by auth_service.
+const (
+	TypeUserRegistered = "user_registered"
+	TypeUserDeleted    = "user_deleted"
+)
+
+// TypeUserDataPurged is published by every service that holds user data,
+// in answer to user_deleted.
+const TypeUserDataPurged = "user_data_purged"
+
+// UserRegistered is published after a new account is created.
+type UserRegistered struct {
+	UserID   string `json:"user_id" schema:"format=uuid"`
+	Email    string `json:"email" schema:"format=email"`
+	Username string `json:"username"`
+}
+
+func (*UserRegistered) EventType() string { return TypeUserRegistered }
+
+// UserDeleted is published after an account is deleted. Services holding the
+// user's data erase it and answer with UserDataPurged for the same
+// DeletionID. It may be delivered more than once.
+type UserDeleted struct {
+	UserID     string `json:"user_id" schema:"format=uuid"`
+	DeletionID string `json:"deletion_id" schema:"format=uuid"`
+}
+
+func (*UserDeleted) EventType() string { return TypeUserDeleted }
+
+// UserDataPurged reports that Service erased what it held of a deleted
+// user. ItemsDeleted counts what was removed in the service's own unit
+// (messages, points, files, subscriptions).
+type UserDataPurged struct {
+	DeletionID   string `json:"deletion_id" schema:"format=uuid"`
+	UserID       string `json:"user_id" schema:"format=uuid"`
+	Service      string `json:"service" schema:"desc=Producer name of the reporting service"`
+	ItemsDeleted int    `json:"items_deleted" schema:"minimum=0"`
+	Detail       string `json:"detail,omitempty"`
+}
+
+func (*UserDataPurged) EventType() string { return TypeUserDataPurged }
@@ -0,0 +1,4 @@
+// Package contracts holds the event definitions shared by all services.
+package contracts
+
+//go:generate go run ./cmd/schemagen -out schemas
@@ -0,0 +1,220 @@
+// Package kafka is the Kafka consumer group loop shared by the services:
+// at-least-once delivery, with offsets marked once a message was handled.
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// ErrInvalidMessage marks a message that can never be processed (bad JSON,
+// unknown shape). Such messages are committed and skipped instead of retried.
+var ErrInvalidMessage = errors.New("invalid kafka message")
+
+// MessageHandler processes a single Kafka message. A nil return commits the
+// offset; any other error (except ErrInvalidMessage) is retried with backoff,
+// up to MaxAttempts times.
+type MessageHandler func(msg *sarama.ConsumerMessage) error
+
+// MaxAttempts is how many times a message is handled before it is given up
+// on and moved to its dead letter topic, so one bad message can't stall its
+// partition.
+const MaxAttempts = 8
+
+// DeadLetterSuffix names the dead letter topic of a topic: user_deleted goes
+// to user_deleted.dlq. Dead letters keep the original key and value, and
+// carry where they came from and why in dlq.* headers.
+const DeadLetterSuffix = ".dlq"
+
+// ParseInitialOffset maps "oldest"/"newest" to sarama offsets. It is only used
+// when the consumer group has no committed offset yet.
+func ParseInitialOffset(s string) int64 {
+	if s == "oldest" {
+		return sarama.OffsetOldest
+	}
+	return sarama.OffsetNewest
+}
+
+// RunConsumerGroup joins the given consumer group and consumes all partitions
+// of the topics assigned to this instance until ctx is cancelled. Offsets are
+// marked only after the handler succeeds or the message was dead-lettered,
+// which gives at-least-once delivery.
+func RunConsumerGroup(ctx context.Context, brokers []string, groupID string, topics []string, initialOffset int64, handle MessageHandler) error {
+	cfg := sarama.NewConfig()
+	cfg.Version = sarama.V2_8_0_0
+	cfg.Consumer.Return.Errors = true
+	cfg.Consumer.Offsets.Initial = initialOffset
+	cfg.Consumer.Offsets.AutoCommit.Enable = true
+	cfg.Consumer.Offsets.AutoCommit.Interval = time.Second
+	cfg.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{sarama.NewBalanceStrategySticky()}
+	cfg.Producer.Return.Successes = true
+	cfg.Producer.RequiredAcks = sarama.WaitForAll
+
+	client, err := sarama.NewClient(brokers, cfg)
+	if err != nil {
+		return fmt.Errorf("connect kafka for group %s: %w", groupID, err)
+	}
+	defer client.Close()
+
+	group, err := sarama.NewConsumerGroupFromClient(groupID, client)
+	if err != nil {
+		return fmt.Errorf("create consumer group %s: %w", groupID, err)
+	}
+	defer group.Close()
+
+	// Messages that keep failing can only be committed once they are in
+	// their dead letter topic, so the group doesn't start without a producer.
+	dlq, err := sarama.NewSyncProducerFromClient(client)
+	if err != nil {
+		return fmt.Errorf("create dead letter producer for group %s: %w", groupID, err)
+	}
+	defer dlq.Close()
+
+	go func() {
+		for err := range group.Errors() {
+			log.Printf("⚠️ Kafka consumer group %s error: %v", groupID, err)
+		}
+	}()
+
+	log.Printf("🎧 Kafka consumer group %s joined (topics=%v)", groupID, topics)
+
+	h := &groupHandler{groupID: groupID, handle: handle, dlq: dlq}
+	for {
+		// Consume blocks for the lifetime of one group session and returns
+		// when a rebalance happens, so it has to be called in a loop.
+		if err := group.Consume(ctx, topics, h); err != nil {
+			if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+				return nil
+			}
+			log.Printf("⚠️ Kafka consume error (group=%s): %v", groupID, err)
+			time.Sleep(2 * time.Second)
+		}
+		if ctx.Err() != nil {
+			log.Printf("🛑 Kafka consumer group %s stopped", groupID)
+			return nil
+		}
+	}
+}
+
+type groupHandler struct {
+	groupID string
+	handle  MessageHandler
+	dlq     sarama.SyncProducer
+}
+
+func (h *groupHandler) Setup(sess sarama.ConsumerGroupSession) error {
+	log.Printf("🔄 Kafka rebalance (group=%s, generation=%d): assigned %v",
+		h.groupID, sess.GenerationID(), sess.Claims())
+	return nil
+}
+
+func (h *groupHandler) Cleanup(sess sarama.ConsumerGroupSession) error {
+	log.Printf("🔄 Kafka rebalance (group=%s, generation=%d): releasing %v",
+		h.groupID, sess.GenerationID(), sess.Claims())
+	return nil
+}
+
+func (h *groupHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for {
+		select {
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			if !h.process(sess.Context(), msg) {
+				// Session ended while retrying; the message stays uncommitted
+				// and will be redelivered to whoever owns the partition next.
+				return nil
+			}
+			sess.MarkMessage(msg, "")
+		case <-sess.Context().Done():
+			return nil
+		}
+	}
+}
+
+// process runs the handler until it succeeds, the message is rejected as
+// invalid, or MaxAttempts is reached and the message is in its dead letter
+// topic. A dead letter that can't be written is retried with backoff: the
+// message is never committed without being handled or dead-lettered. It
+// reports whether the message may be committed, which is false if the
+// session context ended first.
+func (h *groupHandler) process(ctx context.Context, msg *sarama.ConsumerMessage) bool {
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		err := h.handle(msg)
+		if err == nil {
+			return true
+		}
+		if errors.Is(err, ErrInvalidMessage) {
+			log.Printf("⚠️ Skipping invalid message %s[%d]@%d: %v", msg.Topic, msg.Partition, msg.Offset, err)
+			return true
+		}
+		if attempt >= MaxAttempts {
+			return h.deadLetter(ctx, msg, err)
+		}
+
+		log.Printf("⚠️ Handler failed for %s[%d]@%d (attempt %d), retrying in %s: %v",
+			msg.Topic, msg.Partition, msg.Offset, attempt, backoff, err)
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(backoff):
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+// deadLetter moves msg, which failed MaxAttempts times with err, to its dead
+// letter topic, retrying with backoff until the write succeeds. It reports
+// whether it did, which is false if ctx ended first.
+func (h *groupHandler) deadLetter(ctx context.Context, msg *sarama.ConsumerMessage, err error) bool {
+	headers := make([]sarama.RecordHeader, 0, len(msg.Headers)+4)
+	for _, hdr := range msg.Headers {
+		if hdr != nil {
+			headers = append(headers, *hdr)
+		}
+	}
+	headers = append(headers,
+		sarama.RecordHeader{Key: []byte("dlq.topic"), Value: []byte(msg.Topic)},
+		sarama.RecordHeader{Key: []byte("dlq.partition"), Value: []byte(strconv.Itoa(int(msg.Partition)))},
+		sarama.RecordHeader{Key: []byte("dlq.offset"), Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+		sarama.RecordHeader{Key: []byte("dlq.error"), Value: []byte(err.Error())},
+	)
+	topic := msg.Topic + DeadLetterSuffix
+
+	backoff := time.Second
+	for {
+		_, _, perr := h.dlq.SendMessage(&sarama.ProducerMessage{
+			Topic:   topic,
+			Key:     sarama.ByteEncoder(msg.Key),
+			Value:   sarama.ByteEncoder(msg.Value),
+			Headers: headers,
+		})
+		if perr == nil {
+			log.Printf("☠️ Moved %s[%d]@%d to %s after %d attempts: %v",
+				msg.Topic, msg.Partition, msg.Offset, topic, MaxAttempts, err)
+			return true
+		}
+		log.Printf("❌ Dead letter for %s[%d]@%d failed, retrying in %s: %v (handler: %v)",
+			msg.Topic, msg.Partition, msg.Offset, backoff, perr, err)
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(backoff):
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
@@ -0,0 +1,81 @@
+// Package mailer sends plain text emails over SMTP.
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Mailer delivers one email.
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// SMTPMailer sends through an SMTP server (MailHog locally). STARTTLS is
+// used when the server offers it; credentials are only sent when set.
+type SMTPMailer struct {
+	addr string
+	from *mail.Address
+	auth smtp.Auth
+}
+
+func NewSMTPMailer(host string, port int, username, password, from string) (*SMTPMailer, error) {
+	addr, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid from address %q: %w", from, err)
+	}
+	m := &SMTPMailer{addr: net.JoinHostPort(host, strconv.Itoa(port)), from: addr}
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m, nil
+}
+
+// Send delivers a UTF-8 plain text email to one address.
+func (m *SMTPMailer) Send(to, subject, body string) error {
+	rcpt, err := mail.ParseAddress(to)
+	if err != nil {
+		return fmt.Errorf("invalid recipient %q: %w", to, err)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", m.from.String())
+	fmt.Fprintf(&msg, "To: %s\r\n", rcpt.String())
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Message-ID: <%s@%s>\r\n", uuid.NewString(), domain(m.from.Address))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	qp := quotedprintable.NewWriter(&msg)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return fmt.Errorf("encode body: %w", err)
+	}
+	if err := qp.Close(); err != nil {
+		return fmt.Errorf("encode body: %w", err)
+	}
+
+	if err := smtp.SendMail(m.addr, m.auth, m.from.Address, []string{rcpt.Address}, msg.Bytes()); err != nil {
+		return fmt.Errorf("smtp send to %s: %w", rcpt.Address, err)
+	}
+	return nil
+}
+
+func domain(address string) string {
+	for i := len(address) - 1; i >= 0; i-- {
+		if address[i] == '@' {
+			return address[i+1:]
+		}
+	}
+	return "localhost"
+}
@@ -0,0 +1,296 @@
+// Package outbox is the transactional outbox of the services: events are
+// written to the outbox table in the transaction of the change that caused
+// them and published to Kafka by a Relay.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/jmoiron/sqlx"
+
+	"contracts/events"
+)
+
+// Message is an event waiting in the outbox table to be published.
+type Message struct {
+	Topic    string
+	Key      string
+	Envelope *events.Envelope
+}
+
+// Insert writes msg into the outbox. Pass the transaction of the business
+// change so that the event is stored if and only if the change commits.
+func Insert(exec sqlx.Execer, msg Message) error {
+	payload, err := json.Marshal(msg.Envelope)
+	if err != nil {
+		return fmt.Errorf("marshal outbox event: %w", err)
+	}
+
+	query := `
+		INSERT INTO outbox (id, topic, message_key, payload, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	if _, err := exec.Exec(query, msg.Envelope.ID, msg.Topic, msg.Key, payload, msg.Envelope.OccurredAt); err != nil {
+		return fmt.Errorf("insert outbox event: %w", err)
+	}
+	return nil
+}
+
+type row struct {
+	ID         string `db:"id"`
+	Topic      string `db:"topic"`
+	MessageKey string `db:"message_key"`
+	Payload    []byte `db:"payload"`
+	Attempts   int    `db:"attempts"`
+}
+
+// Relay polls the outbox table and publishes pending rows to Kafka, marking
+// each row once the broker has acknowledged it. A crash between publish and
+// mark re-sends the row, so consumers must tolerate duplicates (the envelope
+// id stays the same).
+//
+// One relay publishes the rows of a key in creation order and holds back the
+// rest of a key whose row failed. Rows are claimed with SKIP LOCKED, though,
+// so with several service instances relaying, a later row of a key can go
+// out while another instance still holds an earlier one: consumers get
+// per-key order only as long as a single relay runs.
+type Relay struct {
+	db        *sqlx.DB
+	brokers   []string
+	producer  sarama.SyncProducer
+	interval  time.Duration
+	batchSize int
+
+	// MaxAttempts is how many times a row the broker keeps failing is tried
+	// before it is parked (failed_at set, with last_error) so it stops
+	// holding back its key. Parked rows are left for an operator and never
+	// deleted by the retention sweep.
+	MaxAttempts int
+
+	// Retention is how long published rows are kept before Run deletes
+	// them; zero keeps them forever. Pending rows are never deleted.
+	Retention time.Duration
+	lastSweep time.Time
+}
+
+// sweepInterval is how often Run deletes the published rows past Retention.
+const sweepInterval = time.Hour
+
+// sweepBatch bounds the rows deleted by one statement, so a large backlog
+// doesn't hold locks for long.
+const sweepBatch = 1000
+
+// NewRelay creates a relay. The Kafka connection is opened by Run, so the
+// service can start (and keep accepting writes) while Kafka is unavailable.
+func NewRelay(db *sqlx.DB, brokers []string, interval time.Duration, batchSize int) *Relay {
+	return &Relay{db: db, brokers: brokers, interval: interval, batchSize: batchSize, MaxAttempts: 10}
+}
+
+// Run publishes pending events until ctx is cancelled.
+func (r *Relay) Run(ctx context.Context) {
+	log.Printf("📮 Outbox relay started (interval=%s, batch=%d, max attempts=%d, retention=%s)",
+		r.interval, r.batchSize, r.MaxAttempts, r.Retention)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		if r.producer == nil {
+			if err := r.connect(); err != nil {
+				log.Printf("⚠️ Outbox relay cannot reach Kafka: %v", err)
+			}
+		}
+
+		// Drain the backlog before waiting for the next tick.
+		for r.producer != nil {
+			n, err := r.publishBatch()
+			if err != nil {
+				log.Printf("⚠️ Outbox relay error: %v", err)
+				break
+			}
+			if n < r.batchSize {
+				break
+			}
+		}
+
+		if r.Retention > 0 && time.Since(r.lastSweep) >= sweepInterval {
+			r.lastSweep = time.Now()
+			if err := r.sweep(ctx); err != nil {
+				log.Printf("⚠️ Outbox retention sweep error: %v", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if r.producer != nil {
+				r.producer.Close()
+			}
+			log.Println("🛑 Outbox relay stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// connect creates an idempotent producer so broker-side retries never
+// duplicate a message within one relay session.
+func (r *Relay) connect() error {
+	cfg := sarama.NewConfig()
+	cfg.Version = sarama.V2_8_0_0
+	cfg.Producer.Return.Successes = true
+	cfg.Producer.RequiredAcks = sarama.WaitForAll
+	cfg.Producer.Idempotent = true
+	cfg.Net.MaxOpenRequests = 1
+
+	producer, err := sarama.NewSyncProducer(r.brokers, cfg)
+	if err != nil {
+		return err
+	}
+	r.producer = producer
+	return nil
+}
+
+// publishBatch sends up to batchSize pending rows in creation order. Rows are
+// locked with SKIP LOCKED so several service instances can relay in parallel.
+// After a failure the later rows of the same key are skipped, the others
+// still go out.
+//
+// A failure counts as an attempt only when the broker is evidently up:
+// another row of the batch was published, or the broker rejected this very
+// message. A Kafka outage therefore never parks rows.
+func (r *Relay) publishBatch() (int, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return 0, fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	var rows []row
+	query := `
+		SELECT id, topic, message_key, payload, attempts
+		FROM outbox
+		WHERE published_at IS NULL AND failed_at IS NULL
+		ORDER BY created_at, id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`
+	if err := tx.Select(&rows, query, r.batchSize); err != nil {
+		return 0, fmt.Errorf("select pending: %w", err)
+	}
+
+	type failure struct {
+		row row
+		err error
+	}
+	var failures []failure
+	blocked := map[string]bool{}
+	published := 0
+	for _, m := range rows {
+		if m.MessageKey != "" && blocked[m.MessageKey] {
+			continue
+		}
+		msg := &sarama.ProducerMessage{
+			Topic: m.Topic,
+			Value: sarama.ByteEncoder(m.Payload),
+		}
+		if m.MessageKey != "" {
+			msg.Key = sarama.StringEncoder(m.MessageKey)
+		}
+
+		if _, _, err := r.producer.SendMessage(msg); err != nil {
+			failures = append(failures, failure{m, err})
+			if m.MessageKey != "" {
+				blocked[m.MessageKey] = true
+			}
+			continue
+		}
+
+		if _, err := tx.Exec(`UPDATE outbox SET published_at = NOW(), attempts = attempts + 1, last_error = NULL WHERE id = $1`, m.ID); err != nil {
+			return published, fmt.Errorf("mark published: %w", err)
+		}
+		published++
+	}
+
+	var sendErr error
+	for _, f := range failures {
+		if sendErr == nil {
+			sendErr = fmt.Errorf("publish %s to %s: %w", f.row.ID, f.row.Topic, f.err)
+		}
+		if published == 0 && !rejected(f.err) {
+			if _, err := tx.Exec(`UPDATE outbox SET last_error = $2 WHERE id = $1`, f.row.ID, f.err.Error()); err != nil {
+				return published, fmt.Errorf("record failure: %w", err)
+			}
+			continue
+		}
+		park := r.MaxAttempts > 0 && f.row.Attempts+1 >= r.MaxAttempts
+		if _, err := tx.Exec(`
+			UPDATE outbox
+			SET attempts = attempts + 1, last_error = $2, failed_at = CASE WHEN $3 THEN NOW() END
+			WHERE id = $1
+		`, f.row.ID, f.err.Error(), park); err != nil {
+			return published, fmt.Errorf("record failure: %w", err)
+		}
+		if park {
+			log.Printf("❌ Outbox event %s to %s parked after %d attempts: %v", f.row.ID, f.row.Topic, f.row.Attempts+1, f.err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit: %w", err)
+	}
+	if published > 0 {
+		log.Printf("📤 Outbox relay published %d event(s)", published)
+	}
+	return published, sendErr
+}
+
+// rejected reports whether the broker refused the message itself, which
+// retrying won't change.
+func rejected(err error) bool {
+	var cerr sarama.ConfigurationError
+	return errors.As(err, &cerr) ||
+		errors.Is(err, sarama.ErrMessageSizeTooLarge) ||
+		errors.Is(err, sarama.ErrMessageSetSizeTooLarge) ||
+		errors.Is(err, sarama.ErrInvalidMessage) ||
+		errors.Is(err, sarama.ErrInvalidRecord) ||
+		errors.Is(err, sarama.ErrInvalidTopic)
+}
+
+// sweep deletes the rows published more than Retention ago, in batches of
+// sweepBatch, until none is left or ctx is cancelled.
+func (r *Relay) sweep(ctx context.Context) error {
+	// published_at is set with NOW(), so the cutoff is computed in SQL too
+	secs := r.Retention.Seconds()
+	total := int64(0)
+	for ctx.Err() == nil {
+		res, err := r.db.ExecContext(ctx, `
+			DELETE FROM outbox
+			WHERE id IN (
+				SELECT id FROM outbox
+				WHERE published_at IS NOT NULL AND published_at < NOW() - make_interval(secs => $1)
+				LIMIT $2
+			)
+		`, secs, sweepBatch)
+		if err != nil {
+			return fmt.Errorf("delete published: %w", err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("delete published: %w", err)
+		}
+		total += n
+		if n < sweepBatch {
+			break
+		}
+	}
+	if total > 0 {
+		log.Printf("🧹 Outbox retention sweep deleted %d event(s) published more than %s ago", total, r.Retention)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Draft is the JSON Schema dialect written into generated documents.
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is the subset of JSON Schema used by the event contracts.
+type Schema struct {
+	Schema      string             `json:"$schema,omitempty"`
+	ID          string             `json:"$id,omitempty"`
+	Title       string             `json:"title,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Type        string             `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Enum        []string           `json:"enum,omitempty"`
+	Const       interface{}        `json:"const,omitempty"`
+	MinLength   *int               `json:"minLength,omitempty"`
+	Minimum     *float64           `json:"minimum,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	rawJSONType = reflect.TypeOf(json.RawMessage{})
+)
+
+// Generate builds a schema for a Go value from its json tags.
+//
+// Fields without `omitempty` are required; required strings must also be
+// non-empty unless tagged `schema:"allowEmpty"`. Extra constraints come from
+// the `schema` tag, e.g. `schema:"format=uuid,minimum=0,enum=a|b"`.
+func Generate(v interface{}) *Schema {
+	return generate(reflect.TypeOf(v))
+}
+
+func generate(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawJSONType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: generate(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object"}
+	case reflect.Struct:
+		return generateStruct(t)
+	default:
+		// interface{} and anything else: accept any JSON value.
+		return &Schema{}
+	}
+}
+
+func generateStruct(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name, omitEmpty := jsonName(f)
+		if name == "-" {
+			continue
+		}
+
+		prop := generate(f.Type)
+		allowEmpty := applyTag(prop, f.Tag.Get("schema"))
+
+		if !omitEmpty && f.Type.Kind() != reflect.Pointer {
+			s.Required = append(s.Required, name)
+			if prop.Type == "string" && !allowEmpty && prop.MinLength == nil {
+				minLength := 1
+				prop.MinLength = &minLength
+			}
+		}
+		s.Properties[name] = prop
+	}
+	return s
+}
+
+func jsonName(f reflect.StructField) (string, bool) {
+	tag := f.Tag.Get("json")
+	if tag == "" {
+		return f.Name, false
+	}
+	parts := strings.Split(tag, ",")
+	name := parts[0]
+	if name == "" {
+		name = f.Name
+	}
+	omitEmpty := false
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitEmpty = true
+		}
+	}
+	return name, omitEmpty
+}
+
+// applyTag copies `schema` tag options onto s and reports whether the field
+// is allowed to be an empty string.
+func applyTag(s *Schema, tag string) bool {
+	if tag == "" {
+		return false
+	}
+	allowEmpty := false
+	for _, opt := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(opt, "=")
+		switch key {
+		case "allowEmpty":
+			allowEmpty = true
+		case "format":
+			s.Format = value
+		case "enum":
+			s.Enum = strings.Split(value, "|")
+		case "desc":
+			s.Description = value
+		case "minLength":
+			if n, err := strconv.Atoi(value); err == nil {
+				s.MinLength = &n
+			}
+		case "minimum":
+			if n, err := strconv.ParseFloat(value, 64); err == nil {
+				s.Minimum = &n
+			}
+		default:
+			panic(fmt.Sprintf("schema: unknown tag option %q", key))
+		}
+	}
+	return allowEmpty
+}
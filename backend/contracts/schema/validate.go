@@ -0,0 +1,138 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// ValidationError describes the first schema violation found in a document.
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// ValidateJSON decodes raw JSON and validates it against s.
+func ValidateJSON(s *Schema, raw []byte) error {
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return &ValidationError{Path: "$", Message: fmt.Sprintf("invalid JSON: %v", err)}
+	}
+	return Validate(s, doc)
+}
+
+// Validate checks a decoded JSON value (as produced by encoding/json into
+// interface{}) against s.
+func Validate(s *Schema, doc interface{}) error {
+	return validate(s, doc, "$")
+}
+
+func validate(s *Schema, v interface{}, path string) error {
+	if s == nil {
+		return nil
+	}
+
+	fail := func(format string, args ...interface{}) error {
+		return &ValidationError{Path: path, Message: fmt.Sprintf(format, args...)}
+	}
+
+	switch s.Type {
+	case "":
+		// no type constraint
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return fail("expected object")
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return &ValidationError{Path: path + "." + name, Message: "is required"}
+			}
+		}
+		for name, prop := range s.Properties {
+			if value, ok := obj[name]; ok {
+				if err := validate(prop, value, path+"."+name); err != nil {
+					return err
+				}
+			}
+		}
+	case "array":
+		if v == nil {
+			// encoding/json writes nil slices as null; treat as empty.
+			return nil
+		}
+		items, ok := v.([]interface{})
+		if !ok {
+			return fail("expected array")
+		}
+		for i, item := range items {
+			if err := validate(s.Items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fail("expected string")
+		}
+		if s.MinLength != nil && utf8.RuneCountInString(str) < *s.MinLength {
+			return fail("must be at least %d characters", *s.MinLength)
+		}
+		if len(s.Enum) > 0 && !contains(s.Enum, str) {
+			return fail("must be one of %v", s.Enum)
+		}
+		switch s.Format {
+		case "uuid":
+			if !uuidPattern.MatchString(str) {
+				return fail("must be a UUID")
+			}
+		case "email":
+			if at := strings.LastIndex(str, "@"); at < 1 || at == len(str)-1 {
+				return fail("must be an email address")
+			}
+		case "date-time":
+			if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+				return fail("must be an RFC 3339 timestamp")
+			}
+		}
+	case "integer", "number":
+		n, ok := v.(float64)
+		if !ok {
+			return fail("expected %s", s.Type)
+		}
+		if s.Type == "integer" && n != math.Trunc(n) {
+			return fail("expected integer")
+		}
+		if s.Minimum != nil && n < *s.Minimum {
+			return fail("must be >= %v", *s.Minimum)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fail("expected boolean")
+		}
+	}
+
+	if s.Const != nil && v != s.Const {
+		return fail("must equal %v", s.Const)
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
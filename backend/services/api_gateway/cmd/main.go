@@ -40,8 +40,7 @@ func main() {
 		IdleTimeout:  120 * time.Second,
 	}
 
-	log.Printf("🚪 API Gateway starting on :%s (proxying: auth=%s, sub=%s, chat=%s, chatdata=%s)",
-		cfg.Port, cfg.AuthServiceURL, cfg.SubscriptionServiceURL, cfg.ChatServiceURL, cfg.ChatDataServiceURL)
+	log.Printf("🚪 API Gateway starting on :%s (routes: %s)", cfg.Port, cfg.RoutesFile)
 
 	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		log.Fatalf("❌ API Gateway failed: %v", err)
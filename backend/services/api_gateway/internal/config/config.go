@@ -1,27 +1,70 @@
 package config
 
-import "os"
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type Config struct {
-	AuthServiceURL         string
-	SubscriptionServiceURL string
-	ChatServiceURL         string
-	ChatDataServiceURL     string
-	OCRServiceURL          string
-	EmbeddingServiceURL    string
-	Port                   string
+	// AuthServiceURL is where API keys are exchanged; the upstreams of the
+	// routes are in the route table.
+	AuthServiceURL string
+	Port           string
+	// RoutesFile is the route table (see routes.Table); it is checked for
+	// changes every RoutesReloadInterval (0 turns reloading off).
+	RoutesFile           string
+	RoutesReloadInterval time.Duration
+	// JWTSecret verifies access tokens on routes needing auth; it must match
+	// auth_service's. Empty rejects every such request.
+	JWTSecret string
+	// APIKeyCacheTTL is how long an API key exchanged at auth_service is
+	// trusted without asking again, and so how long a revoked key may keep
+	// working.
+	APIKeyCacheTTL time.Duration
+
+	// RateLimitEnabled turns on per-client token buckets: RateLimitDefault
+	// (e.g. "300/m") for every route without a rate_limit in the route
+	// table. Buckets are kept in RedisURL, or in memory when it is empty or
+	// unreachable.
+	RateLimitEnabled bool
+	RateLimitDefault string
+	RedisURL         string
+
+	// CORS is the policy for browser requests; CORSRoutes (JSON, see
+	// middleware.CORSRoute) overrides it for some paths.
+	CORSAllowedOrigins   []string
+	CORSAllowedMethods   []string
+	CORSAllowedHeaders   []string
+	CORSExposedHeaders   []string
+	CORSAllowCredentials bool
+	CORSMaxAge           time.Duration
+	CORSRoutes           string
 }
 
 // Load reads from env and returns Config (fallbacks provided)
 func Load() Config {
 	return Config{
-		AuthServiceURL:         getEnv("AUTH_SERVICE_URL", "http://auth_service:8080"),
-		SubscriptionServiceURL: getEnv("SUBSCRIPTION_SERVICE_URL", "http://subscription_service:8081"),
-		ChatServiceURL:         getEnv("CHAT_SERVICE_URL", "http://chat_service:8082"),
-		ChatDataServiceURL:     getEnv("CHAT_DATA_SERVICE_URL", "http://chat_data_service:8083"),
-		OCRServiceURL:          getEnv("OCR_SERVICE_URL", "http://ocr_service:8090"),
-		EmbeddingServiceURL:    getEnv("EMBEDDING_SERVICE_URL", "http://embedding_service:8400"),
-		Port:                   getEnv("GATEWAY_PORT", "8085"),
+		AuthServiceURL:       getEnv("AUTH_SERVICE_URL", "http://auth_service:8080"),
+		Port:                 getEnv("GATEWAY_PORT", "8085"),
+		RoutesFile:           getEnv("ROUTES_FILE", "internal/config/routes.yaml"),
+		RoutesReloadInterval: time.Duration(getEnvInt("ROUTES_RELOAD_INTERVAL_SECONDS", 5)) * time.Second,
+		JWTSecret:            getEnv("JWT_SECRET", ""),
+		APIKeyCacheTTL:       time.Duration(getEnvInt("API_KEY_CACHE_SECONDS", 30)) * time.Second,
+		RateLimitEnabled:     getEnv("RATE_LIMIT_ENABLED", "true") == "true",
+		RateLimitDefault:     getEnv("RATE_LIMIT_DEFAULT", "300/m"),
+		RedisURL:             getEnv("REDIS_URL", ""),
+
+		CORSAllowedOrigins: parseCSV(getEnv("CORS_ALLOWED_ORIGINS", "http://localhost:5173")),
+		CORSAllowedMethods: parseCSV(getEnv("CORS_ALLOWED_METHODS", "GET,POST,PUT,PATCH,DELETE,OPTIONS")),
+		CORSAllowedHeaders: parseCSV(getEnv("CORS_ALLOWED_HEADERS",
+			"Content-Type,Authorization,X-API-Key,Last-Event-ID")),
+		CORSExposedHeaders: parseCSV(getEnv("CORS_EXPOSED_HEADERS",
+			"Retry-After,RateLimit-Limit,RateLimit-Remaining,RateLimit-Reset,RateLimit-Policy,Content-Disposition")),
+		CORSAllowCredentials: getEnv("CORS_ALLOW_CREDENTIALS", "true") == "true",
+		CORSMaxAge:           time.Duration(getEnvInt("CORS_MAX_AGE_SECONDS", 600)) * time.Second,
+		CORSRoutes:           getEnv("CORS_ROUTES", ""),
 	}
 }
 
@@ -31,3 +74,22 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+func parseCSV(s string) []string {
+	out := []string{}
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func getEnvInt(key string, fallback int) int {
+	if v := os.Getenv(key); v != "" {
+		if i, err := strconv.Atoi(v); err == nil {
+			return i
+		}
+	}
+	return fallback
+}
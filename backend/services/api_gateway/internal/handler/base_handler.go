@@ -2,49 +2,65 @@
 package handler
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
 	"net/http/httputil"
-	"net/url"
+	"strings"
+
+	"api_gateway/internal/upstream"
 )
 
 // ProxyHandler provides reverse proxy functionality for a service
 type ProxyHandler struct {
 	proxy       *httputil.ReverseProxy
-	targetURL   string
 	serviceName string
 }
 
-// NewProxyHandler creates a new proxy handler for a backend service
-func NewProxyHandler(targetURL, serviceName string) *ProxyHandler {
-	u, err := url.Parse(targetURL)
-	if err != nil {
-		log.Fatalf("❌ Invalid target URL for %s: %v", serviceName, err)
-	}
-
-	proxy := httputil.NewSingleHostReverseProxy(u)
+// NewProxyHandler creates a new proxy handler for a backend service. pool
+// picks the instance of every request and retries it if needed.
+func NewProxyHandler(pool *upstream.Pool) *ProxyHandler {
+	serviceName := pool.Name()
+	proxy := &httputil.ReverseProxy{Transport: pool}
 
-	// Custom director for headers
+	// Custom director for headers; the pool sets the instance's host
 	proxy.Director = func(req *http.Request) {
-		req.URL.Scheme = u.Scheme
-		req.URL.Host = u.Host
 		req.Header.Set("X-Forwarded-Host", req.Host)
 		req.Header.Set("X-Forwarded-Proto", "http")
 		req.Header.Set("X-Forwarded-For", req.RemoteAddr)
-		req.Host = u.Host
+	}
+
+	// CORS yalnızca gateway'de: servisin kendi başlıkları çakışmasın
+	proxy.ModifyResponse = func(resp *http.Response) error {
+		for name := range resp.Header {
+			if strings.HasPrefix(name, "Access-Control-") {
+				resp.Header.Del(name)
+			}
+		}
+		return nil
 	}
 
 	// Error handler for proxy failures
 	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		status, msg := http.StatusBadGateway, "service unavailable"
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			status, msg = http.StatusRequestEntityTooLarge, "request body too large"
+		case errors.Is(err, context.DeadlineExceeded):
+			status, msg = http.StatusGatewayTimeout, "service timed out"
+		case errors.Is(err, upstream.ErrUnavailable):
+			status = http.StatusServiceUnavailable
+		}
 		log.Printf("❌ [%s] Proxy error: %v", serviceName, err)
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadGateway)
-		w.Write([]byte(`{"error":"service unavailable"}`))
+		w.WriteHeader(status)
+		w.Write([]byte(`{"error":"` + msg + `"}`))
 	}
 
 	return &ProxyHandler{
 		proxy:       proxy,
-		targetURL:   targetURL,
 		serviceName: serviceName,
 	}
 }
@@ -61,12 +77,24 @@ func (h *ProxyHandler) Forward(targetPath string) http.HandlerFunc {
 		log.Printf("🔀 [%s] %s %s → %s", h.serviceName, r.Method, r.URL.Path, targetPath)
 
 		// Change path temporarily
-		originalPath := r.URL.Path
-		r.URL.Path = targetPath
+		originalPath, originalRawPath := r.URL.Path, r.URL.RawPath
+		r.URL.Path, r.URL.RawPath = targetPath, ""
 
 		h.proxy.ServeHTTP(w, r)
 
 		// Restore path
-		r.URL.Path = originalPath
+		r.URL.Path, r.URL.RawPath = originalPath, originalRawPath
+	}
+}
+
+// ForwardFunc forwards the request to the path that path returns for it
+func (h *ProxyHandler) ForwardFunc(path func(*http.Request) string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		targetPath := path(r)
+		if targetPath == r.URL.Path {
+			h.ServeHTTP(w, r)
+			return
+		}
+		h.Forward(targetPath)(w, r)
 	}
 }
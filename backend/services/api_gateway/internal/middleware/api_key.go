@@ -0,0 +1,213 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"contracts/auth"
+)
+
+// APIKeyAccess says whether a route can be reached with an API key and
+// which scope the key needs. An empty Scope means the route checks
+// permissions itself.
+type APIKeyAccess struct {
+	Allowed bool
+	Scope   string
+}
+
+// errInvalidAPIKey means auth_service rejected the key.
+var errInvalidAPIKey = errors.New("invalid or expired API key")
+
+// maxCachedAPIKeys bounds the cache. Once it is full, rejected keys are no
+// longer cached and a new grant evicts another entry.
+const maxCachedAPIKeys = 10000
+
+// APIKeyResolver exchanges API keys at auth_service for short-lived access
+// tokens limited to the keys' scopes. Exchanges are cached under the keys'
+// hashes for the cache TTL, which is also how long a revoked key may keep
+// working.
+type APIKeyResolver struct {
+	url    string
+	client *http.Client
+	ttl    time.Duration
+
+	mu    sync.Mutex
+	cache map[[sha256.Size]byte]apiKeyEntry
+}
+
+// NewAPIKeyResolver creates an APIKeyResolver asking the auth_service at
+// authServiceURL.
+func NewAPIKeyResolver(authServiceURL string, cacheTTL time.Duration) *APIKeyResolver {
+	return &APIKeyResolver{
+		url:    strings.TrimRight(authServiceURL, "/") + "/internal/api-keys/introspect",
+		client: &http.Client{Timeout: 5 * time.Second},
+		ttl:    cacheTTL,
+		cache:  map[[sha256.Size]byte]apiKeyEntry{},
+	}
+}
+
+// APIKeys lets requests authenticate with an API key (X-API-Key header or
+// "Authorization: Bearer ak_...") instead of an access token. The key is
+// exchanged through keys and the access token replaces it before the
+// request goes on, so the services only ever see access tokens. A request
+// with a key only gets through if access allows keys and the key has its
+// scope.
+func APIKeys(keys *APIKeyResolver, access APIKeyAccess, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, ok := apiKeyFrom(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !access.Allowed {
+			log.Printf("🔒 %s %s rejected: not available with API keys", r.Method, r.URL.Path)
+			writeError(w, http.StatusForbidden, "endpoint not available with API keys")
+			return
+		}
+
+		grant, err := keys.resolve(r.Context(), key, clientIP(r))
+		if errors.Is(err, errInvalidAPIKey) {
+			log.Printf("🔒 %s %s rejected: %v", r.Method, r.URL.Path, err)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+			writeError(w, http.StatusUnauthorized, "invalid or expired API key")
+			return
+		}
+		if err != nil {
+			log.Printf("❌ API key check for %s %s failed: %v", r.Method, r.URL.Path, err)
+			writeError(w, http.StatusServiceUnavailable, "authentication unavailable")
+			return
+		}
+		if access.Scope != "" && !slices.Contains(grant.Scopes, access.Scope) {
+			log.Printf("🔒 %s %s rejected: API key %s lacks scope %s", r.Method, r.URL.Path, grant.KeyID, access.Scope)
+			writeError(w, http.StatusForbidden, "API key lacks scope "+access.Scope)
+			return
+		}
+
+		r.Header.Del("X-API-Key")
+		r.Header.Set("Authorization", "Bearer "+grant.AccessToken)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// apiKeyFrom returns the API key the request carries, if any.
+func apiKeyFrom(r *http.Request) (string, bool) {
+	if key := strings.TrimSpace(r.Header.Get("X-API-Key")); key != "" {
+		return key, true
+	}
+	token, err := auth.BearerToken(r)
+	if err == nil && strings.HasPrefix(token, auth.APIKeyPrefix) {
+		return token, true
+	}
+	return "", false
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// apiKeyGrant is auth_service's answer for a valid key.
+type apiKeyGrant struct {
+	AccessToken string    `json:"access_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	UserID      string    `json:"user_id"`
+	KeyID       string    `json:"key_id"`
+	Scopes      []string  `json:"scopes"`
+}
+
+// apiKeyEntry is a cached exchange: a grant or errInvalidAPIKey.
+type apiKeyEntry struct {
+	grant *apiKeyGrant
+	err   error
+	until time.Time
+}
+
+func (k *APIKeyResolver) resolve(ctx context.Context, key, ip string) (*apiKeyGrant, error) {
+	id := sha256.Sum256([]byte(key))
+	now := time.Now()
+
+	k.mu.Lock()
+	e, ok := k.cache[id]
+	k.mu.Unlock()
+	if ok && now.Before(e.until) {
+		return e.grant, e.err
+	}
+
+	grant, err := k.introspect(ctx, key, ip)
+	if err != nil && !errors.Is(err, errInvalidAPIKey) {
+		// auth_service unreachable: not cached, the next request asks again.
+		return nil, err
+	}
+	until := now.Add(k.ttl)
+	// The token must still be valid when the service behind checks it.
+	if grant != nil && grant.ExpiresAt.Add(-10*time.Second).Before(until) {
+		until = grant.ExpiresAt.Add(-10 * time.Second)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.cache[id]; !ok && len(k.cache) >= maxCachedAPIKeys {
+		for id, e := range k.cache {
+			if !now.Before(e.until) {
+				delete(k.cache, id)
+			}
+		}
+		if len(k.cache) >= maxCachedAPIKeys {
+			if err != nil {
+				return grant, err
+			}
+			// Map iteration order is random, which is enough of an
+			// eviction policy for a cache this size.
+			for id := range k.cache {
+				delete(k.cache, id)
+				break
+			}
+		}
+	}
+	k.cache[id] = apiKeyEntry{grant: grant, err: err, until: until}
+	return grant, err
+}
+
+func (k *APIKeyResolver) introspect(ctx context.Context, key, ip string) (*apiKeyGrant, error) {
+	body, err := json.Marshal(map[string]string{"key": key, "ip": ip})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, k.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var grant apiKeyGrant
+		if err := json.NewDecoder(resp.Body).Decode(&grant); err != nil {
+			return nil, fmt.Errorf("decode introspection: %w", err)
+		}
+		return &grant, nil
+	case http.StatusUnauthorized:
+		return nil, errInvalidAPIKey
+	default:
+		return nil, fmt.Errorf("introspection returned %s", resp.Status)
+	}
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+
+	"contracts/auth"
+)
+
+// RequirePermission forwards the request only if it carries a valid access
+// token with permission. The token is forwarded unchanged so the service can
+// check it again.
+func RequirePermission(secret []byte, permission string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, err := auth.Authenticate(secret, r)
+		if err != nil {
+			log.Printf("🔒 %s %s rejected: %v", r.Method, r.URL.Path, err)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+			writeError(w, http.StatusUnauthorized, "authentication required")
+			return
+		}
+		if !claims.Can(permission) {
+			log.Printf("🔒 %s %s rejected: user %s lacks %s", r.Method, r.URL.Path, claims.UserID(), permission)
+			writeError(w, http.StatusForbidden, "missing permission "+permission)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(auth.WithClaims(r.Context(), claims)))
+	})
+}
+
+// RequireUser forwards the request only if it carries a valid access token.
+func RequireUser(secret []byte, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, err := auth.Authenticate(secret, r)
+		if err != nil {
+			log.Printf("🔒 %s %s rejected: %v", r.Method, r.URL.Path, err)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+			writeError(w, http.StatusUnauthorized, "authentication required")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(auth.WithClaims(r.Context(), claims)))
+	})
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write([]byte(`{"error":"` + msg + `"}`))
+}
@@ -1,19 +1,140 @@
 package middleware
 
-import "net/http"
+import (
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
 
-// Cors middleware - SADECE BURADA CORS headers set ediliyor
-func Cors(next http.Handler) http.Handler {
+// CORSPolicy says which browser origins may call the API and how. Origins
+// are exact ("https://app.example.com"), patterns with one "*"
+// ("https://*.example.com") or "*" for any origin.
+type CORSPolicy struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// CORSRoute overrides the default policy for paths starting with Prefix.
+// Fields left out keep the default's value.
+type CORSRoute struct {
+	Prefix           string   `json:"prefix"`
+	AllowedOrigins   []string `json:"allowed_origins"`
+	AllowedMethods   []string `json:"allowed_methods"`
+	AllowedHeaders   []string `json:"allowed_headers"`
+	ExposedHeaders   []string `json:"exposed_headers"`
+	AllowCredentials *bool    `json:"allow_credentials"`
+	MaxAgeSeconds    *int     `json:"max_age_seconds"`
+}
+
+// Apply returns p with rt's overrides.
+func (rt CORSRoute) Apply(p CORSPolicy) CORSPolicy {
+	if rt.AllowedOrigins != nil {
+		p.AllowedOrigins = rt.AllowedOrigins
+	}
+	if rt.AllowedMethods != nil {
+		p.AllowedMethods = rt.AllowedMethods
+	}
+	if rt.AllowedHeaders != nil {
+		p.AllowedHeaders = rt.AllowedHeaders
+	}
+	if rt.ExposedHeaders != nil {
+		p.ExposedHeaders = rt.ExposedHeaders
+	}
+	if rt.AllowCredentials != nil {
+		p.AllowCredentials = *rt.AllowCredentials
+	}
+	if rt.MaxAgeSeconds != nil {
+		p.MaxAge = time.Duration(*rt.MaxAgeSeconds) * time.Second
+	}
+	return p
+}
+
+// Validate rejects a policy browsers must not be given: any origin ("*")
+// with credentials would let every website make requests as the user.
+func (p CORSPolicy) Validate() error {
+	if p.AllowCredentials && slices.Contains(p.AllowedOrigins, "*") {
+		return errors.New(`allowed origin "*" can't be combined with credentials`)
+	}
+	return nil
+}
+
+// allows reports whether origin may call the API, and the value of
+// Access-Control-Allow-Origin for it.
+func (p CORSPolicy) allows(origin string) (string, bool) {
+	origin = strings.ToLower(origin)
+	for _, allowed := range p.AllowedOrigins {
+		allowed = strings.ToLower(allowed)
+		switch {
+		case allowed == "*":
+			// Never echoed: browsers refuse credentials with "*" (see Validate).
+			return "*", true
+		case allowed == origin:
+			return origin, true
+		case strings.Count(allowed, "*") == 1:
+			prefix, suffix, _ := strings.Cut(allowed, "*")
+			if len(origin) > len(prefix)+len(suffix) &&
+				strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) &&
+				!strings.Contains(origin[len(prefix):len(origin)-len(suffix)], "/") {
+				return origin, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Cors is the only place CORS headers are set: services behind the gateway
+// don't send their own. Requests to a path starting with a route's prefix
+// use that route's policy (longest prefix wins), others def. Preflight
+// requests are answered here and never reach the services.
+func Cors(def CORSPolicy, routes []CORSRoute, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// CORS headers - TEK DEĞER
-		w.Header().Set("Access-Control-Allow-Origin", "http://localhost:5173")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		w.Header().Set("Access-Control-Allow-Credentials", "true")
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions
+
+		policy := def
+		matched := ""
+		for _, rt := range routes {
+			if strings.HasPrefix(r.URL.Path, rt.Prefix) && len(rt.Prefix) > len(matched) {
+				policy, matched = rt.Apply(def), rt.Prefix
+			}
+		}
+
+		if origin != "" {
+			if allowOrigin, ok := policy.allows(origin); ok {
+				h.Set("Access-Control-Allow-Origin", allowOrigin)
+				if policy.AllowCredentials && allowOrigin != "*" {
+					h.Set("Access-Control-Allow-Credentials", "true")
+				}
+				if len(policy.ExposedHeaders) > 0 {
+					h.Set("Access-Control-Expose-Headers", strings.Join(policy.ExposedHeaders, ", "))
+				}
+				if preflight {
+					h.Add("Vary", "Access-Control-Request-Method")
+					h.Add("Vary", "Access-Control-Request-Headers")
+					h.Set("Access-Control-Allow-Methods", strings.Join(policy.AllowedMethods, ", "))
+					h.Set("Access-Control-Allow-Headers", strings.Join(policy.AllowedHeaders, ", "))
+					if policy.MaxAge > 0 {
+						h.Set("Access-Control-Max-Age", strconv.Itoa(int(policy.MaxAge.Seconds())))
+					}
+				}
+			} else if preflight {
+				log.Printf("🚫 CORS preflight from %s for %s rejected", origin, r.URL.Path)
+			}
+		}
 
 		// Preflight request
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
+		if preflight {
+			w.WriteHeader(http.StatusNoContent)
 			return
 		}
 
@@ -0,0 +1,72 @@
+package middleware
+
+import "testing"
+
+func TestCORSPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		origins     []string
+		credentials bool
+		wantErr     bool
+	}{
+		{"exact with credentials", []string{"https://app.example.com"}, true, false},
+		{"wildcard with credentials", []string{"https://*.example.com"}, true, false},
+		{"any without credentials", []string{"*"}, false, false},
+		{"any with credentials", []string{"https://app.example.com", "*"}, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := CORSPolicy{AllowedOrigins: tt.origins, AllowCredentials: tt.credentials}
+			if err := p.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCORSPolicyAllows(t *testing.T) {
+	exact := []string{"https://app.example.com", "http://localhost:5173"}
+	wildcard := []string{"https://*.example.com"}
+	anyOrigin := []string{"*"}
+
+	tests := []struct {
+		name        string
+		origins     []string
+		credentials bool
+		origin      string
+		want        string
+		wantOK      bool
+	}{
+		{"exact", exact, true, "https://app.example.com", "https://app.example.com", true},
+		{"exact other port", exact, true, "http://localhost:5173", "http://localhost:5173", true},
+		{"exact is case insensitive", exact, true, "https://APP.example.com", "https://app.example.com", true},
+		{"exact wrong scheme", exact, true, "http://app.example.com", "", false},
+		{"exact wrong port", exact, true, "http://localhost:3000", "", false},
+		{"exact not a prefix", exact, true, "https://app.example.com.evil.com", "", false},
+		{"not listed", exact, true, "https://evil.com", "", false},
+		{"no origin", exact, true, "", "", false},
+
+		{"wildcard subdomain", wildcard, true, "https://a.example.com", "https://a.example.com", true},
+		{"wildcard nested subdomain", wildcard, true, "https://a.b.example.com", "https://a.b.example.com", true},
+		{"wildcard needs a subdomain", wildcard, true, "https://.example.com", "", false},
+		{"wildcard bare domain", wildcard, true, "https://example.com", "", false},
+		{"wildcard other domain", wildcard, true, "https://a.example.com.evil.com", "", false},
+		{"wildcard lookalike", wildcard, true, "https://evilexample.com", "", false},
+		{"wildcard wrong scheme", wildcard, true, "http://a.example.com", "", false},
+		{"wildcard no path", wildcard, true, "https://evil.com/.example.com", "", false},
+
+		{"any without credentials", anyOrigin, false, "https://evil.com", "*", true},
+		{"any is never echoed", anyOrigin, true, "https://evil.com", "*", true},
+
+		{"no origins allowed", nil, false, "https://app.example.com", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := CORSPolicy{AllowedOrigins: tt.origins, AllowCredentials: tt.credentials}
+			got, ok := p.allows(tt.origin)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("allows(%q) = (%q, %t), want (%q, %t)", tt.origin, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+)
+
+// LimitBody answers 413 for request bodies over max bytes. Bodies without
+// a Content-Length are cut off at max while being proxied.
+func LimitBody(max int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > max {
+			log.Printf("🚫 %s %s rejected: body of %d bytes over %d", r.Method, r.URL.Path, r.ContentLength, max)
+			writeError(w, http.StatusRequestEntityTooLarge, "request body too large")
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, max)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Timeout cancels the request after d and gives the connection as long to
+// read the body and write the response, in place of the server's
+// timeouts.
+func Timeout(d time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A bit longer than d, so the 504 still gets written.
+		deadline := time.Now().Add(d + time.Second)
+		rc := http.NewResponseController(w)
+		if err := rc.SetReadDeadline(deadline); err != nil {
+			log.Printf("⚠️ Failed to set read deadline: %v", err)
+		}
+		if err := rc.SetWriteDeadline(deadline); err != nil {
+			log.Printf("⚠️ Failed to set write deadline: %v", err)
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Stream lets event streams stay open longer than the server's write
+// timeout.
+func Stream(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+			log.Printf("⚠️ Failed to clear write deadline for stream: %v", err)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
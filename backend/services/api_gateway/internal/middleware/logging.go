@@ -28,3 +28,9 @@ func (l *loggingResponseWriter) WriteHeader(code int) {
 	l.statusCode = code
 	l.ResponseWriter.WriteHeader(code)
 }
+
+// Unwrap lets http.ResponseController reach the underlying writer, so
+// proxied event streams can flush and clear their write deadline.
+func (l *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return l.ResponseWriter
+}
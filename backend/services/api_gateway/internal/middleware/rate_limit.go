@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"api_gateway/internal/ratelimit"
+
+	"contracts/auth"
+)
+
+// RateLimit answers 429 once a client has used up its bucket of rule (the
+// limiter's default bucket if rule is nil). Clients are told apart by API
+// key, then by user (valid access token) and otherwise by IP; every response
+// carries the RateLimit-* headers. If the limiter fails the request is let
+// through.
+func RateLimit(limiter *ratelimit.Limiter, secret []byte, rule *ratelimit.Rule, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if take(limiter, rateLimitClient(secret, r), rule, w, r) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// RateLimitAPIKeys limits requests carrying an API key by IP before the key
+// is looked up, so made-up keys can't each cost an auth_service call.
+// Requests without a key go straight to next.
+func RateLimitAPIKeys(limiter *ratelimit.Limiter, rule *ratelimit.Rule, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := apiKeyFrom(r); ok && !take(limiter, "ip:"+clientIP(r), rule, w, r) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// take takes a token from client's bucket of rule and sets the RateLimit-*
+// headers. It answers 429 and returns false if the bucket is empty.
+func take(limiter *ratelimit.Limiter, client string, rule *ratelimit.Rule, w http.ResponseWriter, r *http.Request) bool {
+	limit, res, err := limiter.Take(r.Context(), client, rule)
+	if err != nil {
+		log.Printf("⚠️ Rate limit check for %s failed, allowing: %v", client, err)
+		return true
+	}
+
+	h := w.Header()
+	h.Set("RateLimit-Limit", strconv.Itoa(limit.Requests))
+	h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("RateLimit-Reset", ceilSeconds(res.Reset))
+	h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%s", limit.Requests, ceilSeconds(limit.Window)))
+	if !res.Allowed {
+		log.Printf("🚦 %s %s rate limited for %s (%s)", r.Method, r.URL.Path, client, limit)
+		h.Set("Retry-After", ceilSeconds(res.RetryAfter))
+		writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
+		return false
+	}
+	return true
+}
+
+// rateLimitClient identifies whose bucket a request uses. Only verified
+// tokens count, so a made-up token can't buy a fresh bucket.
+func rateLimitClient(secret []byte, r *http.Request) string {
+	if claims, err := auth.Authenticate(secret, r); err == nil {
+		if claims.ViaAPIKey() {
+			return "key:" + claims.APIKeyID
+		}
+		return "user:" + claims.UserID()
+	}
+	return "ip:" + clientIP(r)
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,89 @@
+// Package ratelimit implements the gateway's token bucket rate limiting.
+// Buckets live in Redis so every gateway instance shares them, and in memory
+// while Redis is unavailable.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit allows Requests per Window on average and bursts of up to Requests.
+type Limit struct {
+	Requests int
+	Window   time.Duration
+}
+
+// rate is how many tokens the bucket regains per second.
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Window.Seconds()
+}
+
+// String formats l the way ParseLimit reads it.
+func (l Limit) String() string {
+	return strconv.Itoa(l.Requests) + "/" + strconv.FormatFloat(l.Window.Seconds(), 'f', -1, 64) + "s"
+}
+
+// ParseLimit reads a limit like "10/m", "300/1m" or "5/30s".
+func ParseLimit(s string) (Limit, error) {
+	n, w, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid limit %q, expected <requests>/<window>", s)
+	}
+	requests, err := strconv.Atoi(n)
+	if err != nil || requests <= 0 {
+		return Limit{}, fmt.Errorf("invalid request count in limit %q", s)
+	}
+	var window time.Duration
+	switch w {
+	case "s":
+		window = time.Second
+	case "m":
+		window = time.Minute
+	case "h":
+		window = time.Hour
+	default:
+		if window, err = time.ParseDuration(w); err != nil || window <= 0 {
+			return Limit{}, fmt.Errorf("invalid window in limit %q", s)
+		}
+	}
+	return Limit{Requests: requests, Window: window}, nil
+}
+
+// Result is the outcome of taking a token.
+type Result struct {
+	Allowed bool
+	// Remaining is how many more requests the bucket allows right now.
+	Remaining int
+	// Reset is how long until the bucket is full again; RetryAfter, for a
+	// denied request, how long until the next token.
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// Store takes a token from the bucket key, created full with limit.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// result computes the outcome of a bucket left with tokens.
+func result(allowed bool, tokens float64, limit Limit) Result {
+	rate := limit.rate()
+	r := Result{
+		Allowed:   allowed,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     seconds((float64(limit.Requests) - tokens) / rate),
+	}
+	if !allowed {
+		r.RetryAfter = seconds((1 - tokens) / rate)
+	}
+	return r
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
@@ -0,0 +1,32 @@
+package ratelimit
+
+import "context"
+
+// Rule is a bucket with its own limit, e.g. for one route.
+type Rule struct {
+	Name  string
+	Limit Limit
+}
+
+// Limiter gives every client a bucket per rule and one shared by the
+// requests without a rule, limited to Default.
+type Limiter struct {
+	store   Store
+	Default Limit
+}
+
+// NewLimiter creates a Limiter keeping its buckets in store.
+func NewLimiter(store Store, defaultLimit Limit) *Limiter {
+	return &Limiter{store: store, Default: defaultLimit}
+}
+
+// Take takes a token from client's bucket of rule (the default bucket if
+// rule is nil) and returns the limit that applied.
+func (l *Limiter) Take(ctx context.Context, client string, rule *Rule) (Limit, Result, error) {
+	name, limit := "*", l.Default
+	if rule != nil {
+		name, limit = rule.Name, rule.Limit
+	}
+	r, err := l.store.Take(ctx, "ratelimit:"+name+":"+client, limit)
+	return limit, r, err
+}
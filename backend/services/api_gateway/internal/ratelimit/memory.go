@@ -0,0 +1,81 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// maxMemoryBuckets bounds the buckets kept. Full ones are dropped first,
+// then the least recently used until a tenth of the room is free again.
+const maxMemoryBuckets = 100000
+
+type bucket struct {
+	tokens float64
+	at     time.Time
+	full   time.Time
+}
+
+// MemoryStore keeps buckets in this process.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]*bucket{}}
+}
+
+// Take implements Store.
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit) (Result, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		if len(s.buckets) >= maxMemoryBuckets {
+			s.sweep(now)
+		}
+		b = &bucket{tokens: float64(limit.Requests), at: now}
+		s.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(limit.Requests), b.tokens+now.Sub(b.at).Seconds()*limit.rate())
+	b.at = now
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	r := result(allowed, b.tokens, limit)
+	b.full = now.Add(r.Reset)
+	return r, nil
+}
+
+// sweep drops the buckets that have filled up again; they are recreated
+// full. If most are still draining, as with many clients at once, the least
+// recently used go too: their clients get a full bucket back, which beats
+// growing without bound.
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+	keep := maxMemoryBuckets * 9 / 10
+	if len(s.buckets) <= keep {
+		return
+	}
+	keys := make([]string, 0, len(s.buckets))
+	for key := range s.buckets {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		return s.buckets[a].at.Compare(s.buckets[b].at)
+	})
+	for _, key := range keys[:len(keys)-keep] {
+		delete(s.buckets, key)
+	}
+}
@@ -0,0 +1,31 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreBounded(t *testing.T) {
+	s := NewMemoryStore()
+	ctx := context.Background()
+	// One request an hour: every bucket is still draining when the cap is hit.
+	limit := Limit{Requests: 1, Window: time.Hour}
+	for i := 0; i <= maxMemoryBuckets; i++ {
+		if _, err := s.Take(ctx, strconv.Itoa(i), limit); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := len(s.buckets); n > maxMemoryBuckets*9/10+1 {
+		t.Fatalf("%d buckets kept, want at most %d", n, maxMemoryBuckets*9/10+1)
+	}
+	if _, ok := s.buckets["0"]; ok {
+		t.Error("least recently used bucket was kept")
+	}
+	last := strconv.Itoa(maxMemoryBuckets)
+	if r, _ := s.Take(ctx, last, limit); r.Allowed {
+		t.Error("newest bucket was reset")
+	}
+}
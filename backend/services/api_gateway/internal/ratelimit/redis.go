@@ -0,0 +1,109 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// takeScript refills and takes from a bucket atomically, using the Redis
+// clock so all gateway instances agree. The bucket expires once it would be
+// full again.
+var takeScript = redis.NewScript(`
+local burst = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2]) / 1000
+local t = redis.call('TIME')
+local now = t[1] * 1000 + math.floor(t[2] / 1000)
+local b = redis.call('HMGET', KEYS[1], 'tokens', 'at')
+local tokens = tonumber(b[1]) or burst
+local at = tonumber(b[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - at) * rate)
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'at', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) / rate) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+// RedisStore keeps buckets in Redis, shared by all gateway instances.
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore connects to the Redis at url (redis://host:port/db).
+func NewRedisStore(url string) (*RedisStore, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis url: %w", err)
+	}
+	opts.DialTimeout = time.Second
+	opts.ReadTimeout = 500 * time.Millisecond
+	opts.WriteTimeout = 500 * time.Millisecond
+	opts.MaxRetries = -1
+	return &RedisStore{client: redis.NewClient(opts)}, nil
+}
+
+// Take implements Store.
+func (s *RedisStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	res, err := takeScript.Run(ctx, s.client, []string{key}, limit.Requests, limit.rate()).Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("redis rate limit: %w", err)
+	}
+	if len(res) != 2 {
+		return Result{}, fmt.Errorf("redis rate limit: unexpected reply %v", res)
+	}
+	allowed, _ := res[0].(int64)
+	s2, _ := res[1].(string)
+	tokens, err := strconv.ParseFloat(s2, 64)
+	if err != nil {
+		return Result{}, fmt.Errorf("redis rate limit: unexpected tokens %q", s2)
+	}
+	return result(allowed == 1, tokens, limit), nil
+}
+
+// Close closes the connections to Redis.
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
+
+// FallbackStore uses Primary and, while it fails, Secondary. After a
+// failure Primary is left alone for RetryAfter, so an unreachable Redis
+// doesn't slow every request down.
+type FallbackStore struct {
+	Primary    Store
+	Secondary  Store
+	RetryAfter time.Duration
+
+	mu        sync.Mutex
+	downUntil time.Time
+}
+
+// Take implements Store.
+func (s *FallbackStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	down := time.Now().Before(s.downUntil)
+	s.mu.Unlock()
+
+	if !down {
+		r, err := s.Primary.Take(ctx, key, limit)
+		if err == nil {
+			return r, nil
+		}
+		if ctx.Err() != nil {
+			return Result{}, err
+		}
+		s.mu.Lock()
+		s.downUntil = time.Now().Add(s.RetryAfter)
+		s.mu.Unlock()
+		log.Printf("⚠️ Rate limit store unavailable, using in-memory buckets for %s: %v", s.RetryAfter, err)
+	}
+	return s.Secondary.Take(ctx, key, limit)
+}
@@ -1,48 +1,106 @@
 package router
 
 import (
+	"encoding/json"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"api_gateway/internal/config"
-	"api_gateway/internal/handler"
 	"api_gateway/internal/middleware"
+	"api_gateway/internal/ratelimit"
+	"api_gateway/internal/routes"
 )
 
+// SetupRoutes builds the gateway handler from the route table in
+// cfg.RoutesFile, which is reloaded when the file changes.
 func SetupRoutes(cfg config.Config) http.Handler {
-	mux := http.NewServeMux()
-
-	// Health Check
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"status":"ok","service":"api_gateway"}`))
-	})
-
-	// Create proxy handlers for each service
-	authProxy := handler.NewProxyHandler(cfg.AuthServiceURL, "Auth")
-	subscriptionProxy := handler.NewProxyHandler(cfg.SubscriptionServiceURL, "Subscription")
-	chatProxy := handler.NewProxyHandler(cfg.ChatServiceURL, "Chat")
-	chatDataProxy := handler.NewProxyHandler(cfg.ChatDataServiceURL, "ChatData")
-	ocrProxy := handler.NewProxyHandler(cfg.OCRServiceURL, "OCR")
-	embeddingProxy := handler.NewProxyHandler(cfg.EmbeddingServiceURL, "Embedding")
-
-	// Register routes
-	RegisterAuthRoutes(mux, authProxy)
-	RegisterSubscriptionRoutes(mux, subscriptionProxy)
-	RegisterChatRoutes(mux, chatProxy)
-	RegisterChatDataRoutes(mux, chatDataProxy)
-	RegisterOCRRoutes(mux, ocrProxy)
-	RegisterEmbeddingRoutes(mux, embeddingProxy)
-
-	// Fallback - 404
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("⚠️ Unknown endpoint: %s %s", r.Method, r.URL.Path)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte(`{"error":"endpoint not found"}`))
-	})
+	// AUTH_SERVICE_URL birden çok instance listeleyebilir; anahtar değişimi ilkine gider
+	authURL, _, _ := strings.Cut(cfg.AuthServiceURL, ",")
+	b := &builder{
+		secret: []byte(cfg.JWTSecret),
+		// API anahtarı önbelleği tablo yenilense de korunur
+		keys: middleware.NewAPIKeyResolver(strings.TrimSpace(authURL), cfg.APIKeyCacheTTL),
+	}
+	if cfg.RateLimitEnabled {
+		b.limiter = newLimiter(cfg)
+	}
+
+	table, err := routes.Load(cfg.RoutesFile)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	h, err := b.build(table)
+	if err != nil {
+		log.Fatalf("❌ Invalid route table %s: %v", cfg.RoutesFile, err)
+	}
+	log.Printf("🗺️ Route table %s: %d route(s), %d upstream(s)", cfg.RoutesFile, len(table.Routes), len(table.Upstreams))
+
+	rl := &reloader{path: cfg.RoutesFile, build: b.build}
+	rl.current.Store(&h)
+	if cfg.RoutesReloadInterval > 0 {
+		go rl.watch(cfg.RoutesReloadInterval)
+	}
 
 	// Apply CORS Middleware
-	return middleware.Cors(mux)
+	def := corsPolicy(cfg)
+	return middleware.Cors(def, corsRoutes(cfg, def), rl)
+}
+
+// newLimiter builds the rate limiter of cfg; buckets are shared through
+// Redis when it is configured.
+func newLimiter(cfg config.Config) *ratelimit.Limiter {
+	defaultLimit, err := ratelimit.ParseLimit(cfg.RateLimitDefault)
+	if err != nil {
+		log.Fatalf("❌ Invalid RATE_LIMIT_DEFAULT: %v", err)
+	}
+
+	var store ratelimit.Store = ratelimit.NewMemoryStore()
+	if cfg.RedisURL != "" {
+		redisStore, err := ratelimit.NewRedisStore(cfg.RedisURL)
+		if err != nil {
+			log.Fatalf("❌ Invalid REDIS_URL: %v", err)
+		}
+		store = &ratelimit.FallbackStore{Primary: redisStore, Secondary: store, RetryAfter: 10 * time.Second}
+	}
+	log.Printf("🚦 Rate limiting: default %s, redis=%t", defaultLimit, cfg.RedisURL != "")
+	return ratelimit.NewLimiter(store, defaultLimit)
+}
+
+// corsPolicy is the default CORS policy of cfg.
+func corsPolicy(cfg config.Config) middleware.CORSPolicy {
+	p := middleware.CORSPolicy{
+		AllowedOrigins:   cfg.CORSAllowedOrigins,
+		AllowedMethods:   cfg.CORSAllowedMethods,
+		AllowedHeaders:   cfg.CORSAllowedHeaders,
+		ExposedHeaders:   cfg.CORSExposedHeaders,
+		AllowCredentials: cfg.CORSAllowCredentials,
+		MaxAge:           cfg.CORSMaxAge,
+	}
+	if err := p.Validate(); err != nil {
+		log.Fatalf("❌ Invalid CORS_ALLOWED_ORIGINS / CORS_ALLOW_CREDENTIALS: %v", err)
+	}
+	return p
+}
+
+// corsRoutes reads the per-route CORS overrides of cfg, which apply on top
+// of def.
+func corsRoutes(cfg config.Config, def middleware.CORSPolicy) []middleware.CORSRoute {
+	if cfg.CORSRoutes == "" {
+		return nil
+	}
+	var routes []middleware.CORSRoute
+	if err := json.Unmarshal([]byte(cfg.CORSRoutes), &routes); err != nil {
+		log.Fatalf("❌ Invalid CORS_ROUTES: %v", err)
+	}
+	for _, rt := range routes {
+		if !strings.HasPrefix(rt.Prefix, "/") {
+			log.Fatalf("❌ Invalid CORS_ROUTES: prefix %q must start with /", rt.Prefix)
+		}
+		if err := rt.Apply(def).Validate(); err != nil {
+			log.Fatalf("❌ Invalid CORS_ROUTES: prefix %s: %v", rt.Prefix, err)
+		}
+	}
+	return routes
 }
@@ -0,0 +1,274 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"api_gateway/internal/handler"
+	"api_gateway/internal/middleware"
+	"api_gateway/internal/ratelimit"
+	"api_gateway/internal/routes"
+	"api_gateway/internal/upstream"
+)
+
+// builder turns route tables into handlers. The API key cache and the rate
+// limiter are shared by every table it builds, and so are the upstream
+// pools whose configuration didn't change, keeping their health state.
+type builder struct {
+	secret  []byte
+	keys    *middleware.APIKeyResolver
+	limiter *ratelimit.Limiter
+
+	// pools are those of the last table built.
+	pools map[string]*upstream.Pool
+}
+
+// build returns the handler of t. Routes whose patterns conflict are
+// reported as errors.
+func (b *builder) build(t *routes.Table) (http.Handler, error) {
+	pools, err := b.newPools(t)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	// Health Check: gateway ayakta + servislerin durumu
+	mux.Handle("/health", health(pools))
+
+	proxies := make(map[string]*handler.ProxyHandler, len(pools))
+	for name, pool := range pools {
+		proxies[name] = handler.NewProxyHandler(pool)
+	}
+
+	var errs []error
+	for i := range t.Routes {
+		rt := &t.Routes[i]
+		h := b.route(rt, proxies[rt.Upstream])
+		for _, pattern := range rt.Patterns() {
+			if err := handle(mux, pattern, h); err != nil {
+				errs = append(errs, fmt.Errorf("route %d (%s): %w", i+1, rt.Path, err))
+			}
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		b.closePools(pools, b.pools)
+		return nil, err
+	}
+	b.closePools(b.pools, pools)
+	b.pools = pools
+
+	// Fallback - 404, or 405 if the path has routes for other methods
+	fallback := b.rateLimited(nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if allow := allowedMethods(mux, r); len(allow) > 0 {
+			w.Header().Set("Allow", strings.Join(allow, ", "))
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			w.Write([]byte(`{"error":"method not allowed"}`))
+			return
+		}
+		notFound(w, r)
+	}))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := mux.Handler(r); pattern == "" {
+			fallback.ServeHTTP(w, r)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}), nil
+}
+
+// newPools returns the upstream pools of t, reusing the current ones whose
+// configuration is the same.
+func (b *builder) newPools(t *routes.Table) (map[string]*upstream.Pool, error) {
+	pools := make(map[string]*upstream.Pool, len(t.Upstreams))
+	for name, u := range t.Upstreams {
+		if cur, ok := b.pools[name]; ok && reflect.DeepEqual(cur.Config(), u.Config) {
+			pools[name] = cur
+			continue
+		}
+		pool, err := upstream.NewPool(name, u.Config)
+		if err != nil {
+			b.closePools(pools, b.pools)
+			return nil, err
+		}
+		pools[name] = pool
+	}
+	return pools, nil
+}
+
+// closePools closes the pools of old that keep are not using.
+func (b *builder) closePools(old, keep map[string]*upstream.Pool) {
+	for name, pool := range old {
+		if keep[name] != pool {
+			pool.Close()
+		}
+	}
+}
+
+// route builds the handler chain of rt: per-IP limit of API key requests,
+// API key exchange, rate limit, token check, body limit, timeout, proxy.
+func (b *builder) route(rt *routes.Route, proxy *handler.ProxyHandler) http.Handler {
+	if rt.Deny {
+		return b.rateLimited(nil, http.HandlerFunc(notFound))
+	}
+
+	var h http.Handler = proxy
+	if rt.Rewrite != "" {
+		h = proxy.ForwardFunc(rt.RewritePath)
+	}
+	if rt.Stream {
+		h = middleware.Stream(h)
+	} else {
+		h = middleware.Timeout(rt.TimeoutDuration, h)
+	}
+	h = middleware.LimitBody(rt.MaxBodyBytes, h)
+
+	switch {
+	case rt.Permission != "":
+		h = middleware.RequirePermission(b.secret, rt.Permission, h)
+	case rt.Auth == "user":
+		h = middleware.RequireUser(b.secret, h)
+	}
+
+	var rule *ratelimit.Rule
+	if rt.RateLimit != "" {
+		rule = &ratelimit.Rule{Name: rt.Path, Limit: rt.Limit}
+	}
+	h = b.rateLimited(rule, h)
+
+	// Yetki isteyen route'lar anahtarın token'ındaki yetkiye bakar
+	access := middleware.APIKeyAccess{Allowed: rt.APIKeyScope != "" || rt.Permission != "", Scope: rt.APIKeyScope}
+	h = middleware.APIKeys(b.keys, access, h)
+	if b.limiter != nil {
+		h = middleware.RateLimitAPIKeys(b.limiter, &ratelimit.Rule{Name: "api_key_ip", Limit: b.limiter.Default}, h)
+	}
+	return h
+}
+
+func (b *builder) rateLimited(rule *ratelimit.Rule, next http.Handler) http.Handler {
+	if b.limiter == nil {
+		return next
+	}
+	return middleware.RateLimit(b.limiter, b.secret, rule, next)
+}
+
+// handle registers h for pattern, turning the panic of a pattern that is
+// invalid or conflicts with an earlier one into an error.
+func handle(mux *http.ServeMux, pattern string, h http.Handler) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%v", p)
+		}
+	}()
+	mux.Handle(pattern, h)
+	return nil
+}
+
+// allowedMethods returns the methods mux has routes for at r's path.
+func allowedMethods(mux *http.ServeMux, r *http.Request) []string {
+	var allow []string
+	for _, m := range []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete} {
+		probe := r.WithContext(r.Context())
+		probe.Method = m
+		if _, pattern := mux.Handler(probe); pattern != "" {
+			allow = append(allow, m)
+		}
+	}
+	return allow
+}
+
+// health answers with the gateway's status and that of every service:
+// "ok" if all of them have an instance taking requests, "degraded" if some
+// don't and "down" (503) if none does.
+func health(pools map[string]*upstream.Pool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		statuses := make(map[string]upstream.Status, len(pools))
+		up := 0
+		for name, pool := range pools {
+			s := pool.Status()
+			if s.Status == "up" {
+				up++
+			}
+			statuses[name] = s
+		}
+
+		status, code := "ok", http.StatusOK
+		switch {
+		case len(pools) > 0 && up == 0:
+			status, code = "down", http.StatusServiceUnavailable
+		case up < len(pools):
+			status = "degraded"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(map[string]any{
+			"status":    status,
+			"service":   "api_gateway",
+			"upstreams": statuses,
+		})
+	})
+}
+
+func notFound(w http.ResponseWriter, r *http.Request) {
+	log.Printf("⚠️ Unknown endpoint: %s %s", r.Method, r.URL.Path)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	w.Write([]byte(`{"error":"endpoint not found"}`))
+}
+
+// reloader serves the current route table and swaps in a new one when the
+// file changes. A table that fails to load or validate is logged and the
+// current one kept.
+type reloader struct {
+	path    string
+	build   func(*routes.Table) (http.Handler, error)
+	current atomic.Pointer[http.Handler]
+}
+
+func (rl *reloader) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	(*rl.current.Load()).ServeHTTP(w, r)
+}
+
+// watch checks the file's modification time every interval.
+func (rl *reloader) watch(interval time.Duration) {
+	var modTime time.Time
+	if fi, err := os.Stat(rl.path); err == nil {
+		modTime = fi.ModTime()
+	}
+	for range time.Tick(interval) {
+		fi, err := os.Stat(rl.path)
+		if err != nil {
+			if !modTime.IsZero() {
+				log.Printf("⚠️ Route table %s unreadable, keeping the current one: %v", rl.path, err)
+				modTime = time.Time{}
+			}
+			continue
+		}
+		if fi.ModTime().Equal(modTime) {
+			continue
+		}
+		modTime = fi.ModTime()
+
+		table, err := routes.Load(rl.path)
+		if err != nil {
+			log.Printf("❌ Route table reload failed, keeping the current one: %v", err)
+			continue
+		}
+		h, err := rl.build(table)
+		if err != nil {
+			log.Printf("❌ Route table reload failed, keeping the current one: %v", err)
+			continue
+		}
+		rl.current.Store(&h)
+		log.Printf("🔁 Route table %s reloaded: %d route(s), %d upstream(s)", rl.path, len(table.Routes), len(table.Upstreams))
+	}
+}
@@ -0,0 +1,360 @@
+// Package routes loads the gateway's route table: which paths are proxied to
+// which upstream service, and how (methods, path rewrite, authentication,
+// rate limit, timeout, body size). The table is a YAML file so endpoints
+// are added without touching Go code.
+package routes
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"api_gateway/internal/ratelimit"
+	"api_gateway/internal/upstream"
+
+	"contracts/auth"
+)
+
+// Table is the route table file.
+type Table struct {
+	Defaults  Defaults             `yaml:"defaults"`
+	Upstreams map[string]*Upstream `yaml:"upstreams"`
+	Routes    []Route              `yaml:"routes"`
+}
+
+// Defaults apply to routes that don't set their own.
+type Defaults struct {
+	Timeout string `yaml:"timeout"`
+	MaxBody string `yaml:"max_body"`
+}
+
+// Upstream is a service requests are proxied to, spread over its
+// instances (see upstream.Pool).
+type Upstream struct {
+	// URL and URLs are the instances; an entry may list several separated
+	// by commas, so an environment variable can hold them all.
+	URL  string   `yaml:"url"`
+	URLs []string `yaml:"urls"`
+	// Retries is how many other instances a failed request is tried on
+	// (default 1).
+	Retries     *int        `yaml:"retries"`
+	HealthCheck HealthCheck `yaml:"health_check"`
+	// CircuitBreaker takes an instance out for open_for after failures
+	// consecutive failed requests (default 5 and 30s).
+	CircuitBreaker CircuitBreaker `yaml:"circuit_breaker"`
+
+	// Parsed by Load.
+	Config upstream.Config `yaml:"-"`
+}
+
+// HealthCheck probes every instance with GET Path; without a path there
+// are no active checks. Defaults: every 10s, 2s timeout, out after 3
+// failed and back in after 2 passed probes.
+type HealthCheck struct {
+	Path               string `yaml:"path"`
+	Interval           string `yaml:"interval"`
+	Timeout            string `yaml:"timeout"`
+	UnhealthyThreshold int    `yaml:"unhealthy_threshold"`
+	HealthyThreshold   int    `yaml:"healthy_threshold"`
+}
+
+// CircuitBreaker configures the circuit breaker of each instance.
+type CircuitBreaker struct {
+	Failures int    `yaml:"failures"`
+	OpenFor  string `yaml:"open_for"`
+}
+
+// Route proxies the requests matching Path (a net/http ServeMux pattern
+// without method: "/api/x" exact, "/api/x/" subtree, "{name}" wildcards)
+// and Methods (all if empty) to Upstream.
+type Route struct {
+	Path    string   `yaml:"path"`
+	Methods []string `yaml:"methods"`
+	// Upstream names an entry of Table.Upstreams.
+	Upstream string `yaml:"upstream"`
+	// Rewrite replaces the path sent upstream; "{name}" is replaced with
+	// the wildcard of Path.
+	Rewrite string `yaml:"rewrite"`
+	// Auth "user" requires a valid access token; Permission requires one
+	// carrying the permission. By default the service checks the token.
+	Auth       string `yaml:"auth"`
+	Permission string `yaml:"permission"`
+	// APIKeyScope lets requests with an API key of the scope through.
+	// Routes with a Permission accept keys with that permission; other
+	// routes reject API keys.
+	APIKeyScope string `yaml:"api_key_scope"`
+	// RateLimit ("10/m") gives the route its own bucket per client;
+	// otherwise it shares the default bucket.
+	RateLimit string `yaml:"rate_limit"`
+	Timeout   string `yaml:"timeout"`
+	MaxBody   string `yaml:"max_body"`
+	// Stream routes (event streams) have no timeout.
+	Stream bool `yaml:"stream"`
+	// Deny answers 404, to keep a subtree out of a broader route.
+	Deny bool `yaml:"deny"`
+
+	// Parsed by Load.
+	Limit           ratelimit.Limit `yaml:"-"`
+	TimeoutDuration time.Duration   `yaml:"-"`
+	MaxBodyBytes    int64           `yaml:"-"`
+}
+
+// Patterns returns the ServeMux patterns of the route.
+func (rt *Route) Patterns() []string {
+	if len(rt.Methods) == 0 {
+		return []string{rt.Path}
+	}
+	patterns := make([]string, 0, len(rt.Methods))
+	for _, m := range rt.Methods {
+		patterns = append(patterns, m+" "+rt.Path)
+	}
+	return patterns
+}
+
+// RewritePath returns the path to send upstream for r.
+func (rt *Route) RewritePath(r *http.Request) string {
+	if rt.Rewrite == "" {
+		return r.URL.Path
+	}
+	return wildcardRe.ReplaceAllStringFunc(rt.Rewrite, func(w string) string {
+		return r.PathValue(strings.Trim(w, "{}."))
+	})
+}
+
+var (
+	wildcardRe = regexp.MustCompile(`\{[A-Za-z_][A-Za-z0-9_]*(\.\.\.)?\}`)
+	methods    = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete}
+	permissions = []string{auth.PermSubscriptionsAssign, auth.PermPlansManage, auth.PermRolesManage,
+		auth.PermAuditRead}
+)
+
+// Load reads and validates the table at path. ${VAR} and ${VAR:-default}
+// in the file are replaced with environment variables.
+func Load(path string) (*Table, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read route table: %w", err)
+	}
+	b = []byte(os.Expand(string(b), func(name string) string {
+		name, def, _ := strings.Cut(name, ":-")
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+		return def
+	}))
+
+	var t Table
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(&t); err != nil {
+		return nil, fmt.Errorf("parse route table %s: %w", path, err)
+	}
+	if err := t.validate(); err != nil {
+		return nil, fmt.Errorf("invalid route table %s: %w", path, err)
+	}
+	return &t, nil
+}
+
+// validate checks the table and fills in the parsed fields of its routes.
+func (t *Table) validate() error {
+	var errs []error
+	fail := func(format string, args ...any) { errs = append(errs, fmt.Errorf(format, args...)) }
+
+	defTimeout, err := parseDuration(t.Defaults.Timeout, 30*time.Second)
+	if err != nil {
+		fail("defaults.timeout: %v", err)
+	}
+	defMaxBody, err := ParseSize(t.Defaults.MaxBody, 10<<20)
+	if err != nil {
+		fail("defaults.max_body: %v", err)
+	}
+
+	for name, u := range t.Upstreams {
+		if u == nil {
+			fail("upstream %s: no url", name)
+			continue
+		}
+		for _, err := range u.validate() {
+			fail("upstream %s: %v", name, err)
+		}
+	}
+	if len(t.Routes) == 0 {
+		fail("no routes")
+	}
+
+	for i := range t.Routes {
+		rt := &t.Routes[i]
+		where := fmt.Sprintf("route %d (%s)", i+1, rt.Path)
+		if !strings.HasPrefix(rt.Path, "/") {
+			fail("%s: path must start with /", where)
+		}
+		for j, m := range rt.Methods {
+			rt.Methods[j] = strings.ToUpper(m)
+			if !slices.Contains(methods, rt.Methods[j]) {
+				fail("%s: unknown method %s", where, m)
+			}
+		}
+		if rt.Deny {
+			if rt.Upstream != "" || rt.Rewrite != "" {
+				fail("%s: deny routes have no upstream or rewrite", where)
+			}
+			continue
+		}
+		if _, ok := t.Upstreams[rt.Upstream]; !ok {
+			fail("%s: unknown upstream %q", where, rt.Upstream)
+		}
+		if rt.Rewrite != "" {
+			if !strings.HasPrefix(rt.Rewrite, "/") {
+				fail("%s: rewrite must start with /", where)
+			}
+			for _, w := range wildcardRe.FindAllString(rt.Rewrite, -1) {
+				if !strings.Contains(rt.Path, "{"+strings.Trim(w, "{}.")) {
+					fail("%s: rewrite uses %s, which the path doesn't have", where, w)
+				}
+			}
+		}
+		switch rt.Auth {
+		case "", "none", "user":
+		default:
+			fail("%s: auth must be none or user", where)
+		}
+		if rt.Permission != "" {
+			if !slices.Contains(permissions, rt.Permission) {
+				fail("%s: unknown permission %q", where, rt.Permission)
+			}
+			if rt.Auth == "none" {
+				fail("%s: a permission needs auth", where)
+			}
+		}
+		if rt.APIKeyScope != "" && !slices.Contains(auth.APIKeyScopes, rt.APIKeyScope) {
+			fail("%s: unknown api_key_scope %q", where, rt.APIKeyScope)
+		}
+		if rt.RateLimit != "" {
+			if rt.Limit, err = ratelimit.ParseLimit(rt.RateLimit); err != nil {
+				fail("%s: %v", where, err)
+			}
+		}
+		if rt.Stream && rt.Timeout != "" {
+			fail("%s: stream routes have no timeout", where)
+		}
+		if rt.TimeoutDuration, err = parseDuration(rt.Timeout, defTimeout); err != nil {
+			fail("%s: timeout: %v", where, err)
+		}
+		if rt.MaxBodyBytes, err = ParseSize(rt.MaxBody, defMaxBody); err != nil {
+			fail("%s: max_body: %v", where, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validate checks u and fills in its Config.
+func (u *Upstream) validate() []error {
+	var errs []error
+	fail := func(format string, args ...any) { errs = append(errs, fmt.Errorf(format, args...)) }
+
+	cfg := upstream.Config{Retries: 1, HealthPath: u.HealthCheck.Path}
+	for _, entry := range append([]string{u.URL}, u.URLs...) {
+		for _, raw := range strings.Split(entry, ",") {
+			if raw = strings.TrimSpace(raw); raw == "" {
+				continue
+			}
+			parsed, err := url.Parse(raw)
+			if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				fail("invalid url %q", raw)
+				continue
+			}
+			if slices.Contains(cfg.URLs, raw) {
+				fail("url %q listed twice", raw)
+			}
+			cfg.URLs = append(cfg.URLs, raw)
+		}
+	}
+	if len(cfg.URLs) == 0 {
+		fail("no url")
+	}
+	if u.Retries != nil {
+		if cfg.Retries = *u.Retries; cfg.Retries < 0 || cfg.Retries > 5 {
+			fail("retries must be 0-5")
+		}
+	}
+
+	var err error
+	hc := u.HealthCheck
+	if hc.Path != "" && !strings.HasPrefix(hc.Path, "/") {
+		fail("health_check.path must start with /")
+	}
+	if cfg.HealthInterval, err = parseDuration(hc.Interval, 10*time.Second); err != nil {
+		fail("health_check.interval: %v", err)
+	}
+	if cfg.HealthTimeout, err = parseDuration(hc.Timeout, 2*time.Second); err != nil {
+		fail("health_check.timeout: %v", err)
+	}
+	if cfg.UnhealthyThreshold, err = parseCount(hc.UnhealthyThreshold, 3); err != nil {
+		fail("health_check.unhealthy_threshold: %v", err)
+	}
+	if cfg.HealthyThreshold, err = parseCount(hc.HealthyThreshold, 2); err != nil {
+		fail("health_check.healthy_threshold: %v", err)
+	}
+	if cfg.BreakerFailures, err = parseCount(u.CircuitBreaker.Failures, 5); err != nil {
+		fail("circuit_breaker.failures: %v", err)
+	}
+	if cfg.BreakerOpenFor, err = parseDuration(u.CircuitBreaker.OpenFor, 30*time.Second); err != nil {
+		fail("circuit_breaker.open_for: %v", err)
+	}
+	u.Config = cfg
+	return errs
+}
+
+func parseCount(n, def int) (int, error) {
+	switch {
+	case n == 0:
+		return def, nil
+	case n < 0:
+		return 0, errors.New("must be positive")
+	}
+	return n, nil
+}
+
+func parseDuration(s string, def time.Duration) (time.Duration, error) {
+	if s == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+// ParseSize reads a size like "512KB", "10MB" or "1048576" (bytes).
+func ParseSize(s string, def int64) (int64, error) {
+	if s == "" {
+		return def, nil
+	}
+	num, unit := strings.TrimSpace(s), int64(1)
+	for _, u := range []struct {
+		suffix string
+		n      int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(strings.ToUpper(num), u.suffix) {
+			num, unit = strings.TrimSpace(num[:len(num)-len(u.suffix)]), u.n
+			break
+		}
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * unit, nil
+}
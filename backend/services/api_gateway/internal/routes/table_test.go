@@ -0,0 +1,308 @@
+package routes
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// load writes table to a file and loads it.
+func load(t *testing.T, table string) (*Table, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "routes.yaml")
+	if err := os.WriteFile(path, []byte(table), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return Load(path)
+}
+
+const upstreams = `
+upstreams:
+  svc:
+    url: http://svc:8080
+`
+
+func TestLoadValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		table   string
+		wantErr string // empty: the table is valid
+	}{
+		{"minimal", upstreams + `
+routes:
+  - path: /api/x
+    upstream: svc
+`, ""},
+		{"all route fields", upstreams + `
+routes:
+  - path: /api/items/{id}
+    methods: [get, POST]
+    upstream: svc
+    rewrite: /items/{id}
+    auth: user
+    api_key_scope: chat
+    rate_limit: 10/m
+    timeout: 5s
+    max_body: 512KB
+  - path: /api/admin/plans
+    upstream: svc
+    permission: plans:manage
+  - path: /api/events/
+    upstream: svc
+    stream: true
+  - path: /api/admin/
+    deny: true
+`, ""},
+		{"no routes", upstreams, "no routes"},
+		{"unknown field", upstreams + `
+routes:
+  - path: /api/x
+    upstream: svc
+    auth_required: true
+`, "auth_required"},
+		{"relative path", upstreams + `
+routes:
+  - path: api/x
+    upstream: svc
+`, "path must start with /"},
+		{"unknown method", upstreams + `
+routes:
+  - path: /api/x
+    methods: [FETCH]
+    upstream: svc
+`, "unknown method FETCH"},
+		{"unknown upstream", upstreams + `
+routes:
+  - path: /api/x
+    upstream: other
+`, `unknown upstream "other"`},
+		{"deny with upstream", upstreams + `
+routes:
+  - path: /api/x/
+    upstream: svc
+    deny: true
+`, "deny routes have no upstream"},
+		{"relative rewrite", upstreams + `
+routes:
+  - path: /api/x
+    upstream: svc
+    rewrite: x
+`, "rewrite must start with /"},
+		{"rewrite wildcard missing from path", upstreams + `
+routes:
+  - path: /api/items/{id}
+    upstream: svc
+    rewrite: /items/{item}
+`, "rewrite uses {item}"},
+		{"unknown auth", upstreams + `
+routes:
+  - path: /api/x
+    upstream: svc
+    auth: admin
+`, "auth must be none or user"},
+		{"unknown permission", upstreams + `
+routes:
+  - path: /api/x
+    upstream: svc
+    permission: everything
+`, `unknown permission "everything"`},
+		{"permission without auth", upstreams + `
+routes:
+  - path: /api/x
+    upstream: svc
+    auth: none
+    permission: plans:manage
+`, "a permission needs auth"},
+		{"unknown api key scope", upstreams + `
+routes:
+  - path: /api/x
+    upstream: svc
+    api_key_scope: admin
+`, `unknown api_key_scope "admin"`},
+		{"bad rate limit", upstreams + `
+routes:
+  - path: /api/x
+    upstream: svc
+    rate_limit: lots
+`, "route 1 (/api/x)"},
+		{"stream with timeout", upstreams + `
+routes:
+  - path: /api/x
+    upstream: svc
+    stream: true
+    timeout: 10s
+`, "stream routes have no timeout"},
+		{"bad timeout", upstreams + `
+routes:
+  - path: /api/x
+    upstream: svc
+    timeout: -1s
+`, `invalid duration "-1s"`},
+		{"bad max body", upstreams + `
+routes:
+  - path: /api/x
+    upstream: svc
+    max_body: 10XB
+`, `invalid size "10XB"`},
+		{"bad default timeout", `
+defaults:
+  timeout: soon
+` + upstreams + `
+routes:
+  - path: /api/x
+    upstream: svc
+`, "defaults.timeout"},
+		{"upstream without url", `
+upstreams:
+  svc:
+    health_check:
+      path: /health
+routes:
+  - path: /api/x
+    upstream: svc
+`, "upstream svc: no url"},
+		{"upstream bad url", `
+upstreams:
+  svc:
+    url: ftp://svc
+routes:
+  - path: /api/x
+    upstream: svc
+`, `invalid url "ftp://svc"`},
+		{"upstream url twice", `
+upstreams:
+  svc:
+    url: http://a:1,http://a:1
+routes:
+  - path: /api/x
+    upstream: svc
+`, "listed twice"},
+		{"upstream too many retries", `
+upstreams:
+  svc:
+    url: http://svc
+    retries: 6
+routes:
+  - path: /api/x
+    upstream: svc
+`, "retries must be 0-5"},
+		{"upstream relative health path", `
+upstreams:
+  svc:
+    url: http://svc
+    health_check:
+      path: health
+routes:
+  - path: /api/x
+    upstream: svc
+`, "health_check.path must start with /"},
+		{"upstream negative breaker failures", `
+upstreams:
+  svc:
+    url: http://svc
+    circuit_breaker:
+      failures: -1
+routes:
+  - path: /api/x
+    upstream: svc
+`, "circuit_breaker.failures"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := load(t, tt.table)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Load() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Load() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	t.Setenv("SVC_URLS", "http://a:1, http://b:2")
+	table, err := load(t, `
+defaults:
+  timeout: 20s
+  max_body: 1MB
+upstreams:
+  svc:
+    url: ${SVC_URLS}
+  fallback:
+    url: ${UNSET_URL:-http://c:3}
+    retries: 0
+routes:
+  - path: /api/x
+    methods: [post]
+    upstream: svc
+  - path: /api/y
+    upstream: fallback
+    timeout: 2m
+    max_body: 50MB
+`)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	svc := table.Upstreams["svc"].Config
+	if got := strings.Join(svc.URLs, " "); got != "http://a:1 http://b:2" {
+		t.Errorf("svc URLs = %q", got)
+	}
+	if svc.Retries != 1 || svc.UnhealthyThreshold != 3 || svc.BreakerFailures != 5 || svc.BreakerOpenFor != 30*time.Second {
+		t.Errorf("svc config defaults = %+v", svc)
+	}
+	fallback := table.Upstreams["fallback"].Config
+	if len(fallback.URLs) != 1 || fallback.URLs[0] != "http://c:3" || fallback.Retries != 0 {
+		t.Errorf("fallback config = %+v", fallback)
+	}
+
+	x, y := table.Routes[0], table.Routes[1]
+	if got := x.Patterns(); len(got) != 1 || got[0] != "POST /api/x" {
+		t.Errorf("x patterns = %v", got)
+	}
+	if x.TimeoutDuration != 20*time.Second || x.MaxBodyBytes != 1<<20 {
+		t.Errorf("x timeout, max body = %s, %d", x.TimeoutDuration, x.MaxBodyBytes)
+	}
+	if y.TimeoutDuration != 2*time.Minute || y.MaxBodyBytes != 50<<20 {
+		t.Errorf("y timeout, max body = %s, %d", y.TimeoutDuration, y.MaxBodyBytes)
+	}
+}
+
+// The table shipped with the gateway must stay loadable.
+func TestLoadShippedTable(t *testing.T) {
+	if _, err := Load("../config/routes.yaml"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"", 42, false},
+		{"1048576", 1 << 20, false},
+		{"100B", 100, false},
+		{"512KB", 512 << 10, false},
+		{"10mb", 10 << 20, false},
+		{" 2 GB ", 2 << 30, false},
+		{"0", 0, true},
+		{"-1MB", 0, true},
+		{"MB", 0, true},
+		{"1.5MB", 0, true},
+		{"10XB", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSize(tt.in, 42)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseSize(%q) = (%d, %v), want (%d, error %t)", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
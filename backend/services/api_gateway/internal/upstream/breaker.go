@@ -0,0 +1,90 @@
+package upstream
+
+import (
+	"sync"
+	"time"
+)
+
+// Circuit states.
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// breaker is the circuit breaker of an instance. After failures
+// consecutive failed requests it opens and the instance gets no requests
+// for openFor; then a single trial request decides whether it closes or
+// opens again.
+type breaker struct {
+	failures int
+	openFor  time.Duration
+
+	mu          sync.Mutex
+	state       string
+	consecutive int
+	openUntil   time.Time
+	trial       bool
+}
+
+func newBreaker(failures int, openFor time.Duration) *breaker {
+	return &breaker{failures: failures, openFor: openFor, state: CircuitClosed}
+}
+
+// acquire reports whether a request may go to the instance. In half-open
+// state only the trial request may.
+func (b *breaker) acquire() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case CircuitOpen:
+		if time.Now().Before(b.openUntil) {
+			return false
+		}
+		b.state, b.trial = CircuitHalfOpen, true
+		return true
+	case CircuitHalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+		return true
+	default:
+		return true
+	}
+}
+
+// release gives back an acquired request that didn't tell anything about
+// the instance, e.g. one the client cancelled.
+func (b *breaker) release() {
+	b.mu.Lock()
+	b.trial = false
+	b.mu.Unlock()
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	b.state, b.consecutive, b.trial = CircuitClosed, 0, false
+	b.mu.Unlock()
+}
+
+// failure records a failed request and reports whether it opened the
+// circuit.
+func (b *breaker) failure() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.consecutive++
+	b.trial = false
+	if b.state == CircuitHalfOpen || (b.state == CircuitClosed && b.consecutive >= b.failures) {
+		b.state, b.openUntil = CircuitOpen, time.Now().Add(b.openFor)
+		return true
+	}
+	return false
+}
+
+// open reports whether the circuit is open and keeps requests away.
+func (b *breaker) open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state == CircuitOpen && time.Now().Before(b.openUntil)
+}
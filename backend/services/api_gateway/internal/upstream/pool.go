@@ -0,0 +1,318 @@
+// Package upstream spreads the gateway's requests to a service over its
+// instances: round robin over the healthy ones, active health checks, a
+// circuit breaker per instance and retries of requests that are safe to
+// send again.
+package upstream
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrUnavailable means no instance of the service can take requests.
+var ErrUnavailable = errors.New("no instance available")
+
+// maxRetryBody is the largest request body kept in memory so the request
+// can be retried.
+const maxRetryBody = 1 << 20
+
+// Config configures a Pool.
+type Config struct {
+	URLs []string
+	// Retries is how many more instances an idempotent request (or one
+	// that never reached an instance) is tried on.
+	Retries int
+
+	// HealthPath is probed with GET on every instance each HealthInterval;
+	// empty turns active checks off. An instance is taken out after
+	// UnhealthyThreshold failed probes and back in after HealthyThreshold
+	// passed ones.
+	HealthPath         string
+	HealthInterval     time.Duration
+	HealthTimeout      time.Duration
+	UnhealthyThreshold int
+	HealthyThreshold   int
+
+	// BreakerFailures consecutive failed requests open an instance's
+	// circuit for BreakerOpenFor.
+	BreakerFailures int
+	BreakerOpenFor  time.Duration
+}
+
+// Pool is the instances of a service. It is the proxy's transport.
+type Pool struct {
+	name      string
+	cfg       Config
+	instances []*instance
+	transport http.RoundTripper
+	next      atomic.Uint64
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+type instance struct {
+	index   int
+	url     *url.URL
+	healthy atomic.Bool
+	breaker *breaker
+
+	// Counts of consecutive probe results, used by the health check only.
+	passed, failed int
+}
+
+// NewPool creates the pool of the service name and starts its health
+// checks.
+func NewPool(name string, cfg Config) (*Pool, error) {
+	if len(cfg.URLs) == 0 {
+		return nil, fmt.Errorf("upstream %s has no urls", name)
+	}
+	p := &Pool{name: name, cfg: cfg, transport: http.DefaultTransport, stop: make(chan struct{})}
+	for _, raw := range cfg.URLs {
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("upstream %s: invalid url %q", name, raw)
+		}
+		inst := &instance{index: len(p.instances), url: u, breaker: newBreaker(cfg.BreakerFailures, cfg.BreakerOpenFor)}
+		inst.healthy.Store(true)
+		p.instances = append(p.instances, inst)
+	}
+	if cfg.HealthPath != "" {
+		for _, inst := range p.instances {
+			go p.check(inst)
+		}
+	}
+	return p, nil
+}
+
+// Name returns the service name.
+func (p *Pool) Name() string { return p.name }
+
+// Config returns the configuration the pool was created with.
+func (p *Pool) Config() Config { return p.cfg }
+
+// Close stops the health checks.
+func (p *Pool) Close() {
+	p.stopOnce.Do(func() { close(p.stop) })
+}
+
+// RoundTrip sends req to the next available instance. Requests that fail
+// with a connection error or a 502/503/504 are tried again on another
+// instance if they are idempotent, or if they never reached the instance.
+func (p *Pool) RoundTrip(req *http.Request) (*http.Response, error) {
+	idempotent := isIdempotent(req)
+	replayable := true
+	if p.cfg.Retries > 0 {
+		var err error
+		if replayable, err = bufferBody(req); err != nil {
+			return nil, err
+		}
+	}
+
+	var tried []*instance
+	inst := p.pick(tried)
+	if inst == nil {
+		return nil, fmt.Errorf("%s: %w", p.name, ErrUnavailable)
+	}
+	for attempt := 0; ; attempt++ {
+		tried = append(tried, inst)
+		out := req.Clone(req.Context())
+		out.URL.Scheme, out.URL.Host, out.Host = inst.url.Scheme, inst.url.Host, inst.url.Host
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			out.Body = body
+		}
+
+		resp, err := p.transport.RoundTrip(out)
+		if !p.report(inst, resp, err) {
+			return resp, err
+		}
+		retry := attempt < p.cfg.Retries && req.Context().Err() == nil && replayable &&
+			(idempotent || (err != nil && isDialError(err)))
+		if !retry {
+			return resp, err
+		}
+		next := p.pick(tried)
+		if next == nil {
+			return resp, err
+		}
+		reason := fmt.Sprint(err)
+		if resp != nil {
+			reason = resp.Status
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+		log.Printf("🔁 [%s] %s %s failed on %s (%s), retrying on %s",
+			p.name, req.Method, req.URL.Path, inst.url.Host, reason, next.url.Host)
+		inst = next
+	}
+}
+
+// pick returns the next available instance, preferring ones not tried
+// yet, or nil if there is none. A retry goes on from the instance that
+// failed, so it doesn't move the round robin along.
+func (p *Pool) pick(tried []*instance) *instance {
+	n := len(p.instances)
+	var start int
+	if len(tried) == 0 {
+		start = int(p.next.Add(1) % uint64(n))
+	} else {
+		start = (tried[len(tried)-1].index + 1) % n
+	}
+	for _, fresh := range []bool{true, false} {
+		for i := range n {
+			inst := p.instances[(start+i)%n]
+			if !inst.healthy.Load() || (fresh && slices.Contains(tried, inst)) {
+				continue
+			}
+			if inst.breaker.acquire() {
+				return inst
+			}
+		}
+	}
+	return nil
+}
+
+// report feeds the outcome of a request to the instance's circuit breaker
+// and reports whether the request failed.
+func (p *Pool) report(inst *instance, resp *http.Response, err error) bool {
+	switch {
+	case err != nil && errors.Is(err, context.Canceled):
+		inst.breaker.release()
+		return true
+	case err == nil && !isFailureStatus(resp.StatusCode):
+		inst.breaker.success()
+		return false
+	}
+	if inst.breaker.failure() {
+		reason := fmt.Sprint(err)
+		if resp != nil {
+			reason = resp.Status
+		}
+		log.Printf("⚡ [%s] Circuit open for %s for %s after: %s", p.name, inst.url.Host, p.cfg.BreakerOpenFor, reason)
+	}
+	return true
+}
+
+// check probes inst until the pool is closed.
+func (p *Pool) check(inst *instance) {
+	client := &http.Client{Timeout: p.cfg.HealthTimeout}
+	target := inst.url.JoinPath(p.cfg.HealthPath).String()
+	ticker := time.NewTicker(p.cfg.HealthInterval)
+	defer ticker.Stop()
+	for {
+		err := probe(client, target)
+		if err == nil {
+			inst.passed, inst.failed = inst.passed+1, 0
+			if !inst.healthy.Load() && inst.passed >= p.cfg.HealthyThreshold {
+				inst.healthy.Store(true)
+				log.Printf("💚 [%s] %s is healthy again", p.name, inst.url.Host)
+			}
+		} else {
+			inst.passed, inst.failed = 0, inst.failed+1
+			if inst.healthy.Load() && inst.failed >= p.cfg.UnhealthyThreshold {
+				inst.healthy.Store(false)
+				log.Printf("💔 [%s] %s is unhealthy: %v", p.name, inst.url.Host, err)
+			}
+		}
+
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func probe(client *http.Client, target string) error {
+	resp, err := client.Get(target)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("health check returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Status is the state of a pool as shown on the gateway's /health.
+type Status struct {
+	// Status is "up" if some instance can take requests, otherwise "down".
+	Status       string `json:"status"`
+	Instances    int    `json:"instances"`
+	Healthy      int    `json:"healthy"`
+	OpenCircuits int    `json:"open_circuits"`
+}
+
+// Status returns the current state of the pool.
+func (p *Pool) Status() Status {
+	s := Status{Status: "down", Instances: len(p.instances)}
+	for _, inst := range p.instances {
+		healthy := inst.healthy.Load()
+		if healthy {
+			s.Healthy++
+		}
+		open := inst.breaker.open()
+		if open {
+			s.OpenCircuits++
+		}
+		if healthy && !open {
+			s.Status = "up"
+		}
+	}
+	return s
+}
+
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// bufferBody keeps req's body in memory, if it is small enough, so the
+// request can be sent again, and reports whether it can.
+func bufferBody(req *http.Request) (bool, error) {
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody != nil {
+		return true, nil
+	}
+	if req.ContentLength <= 0 || req.ContentLength > maxRetryBody {
+		return false, nil
+	}
+	b, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return false, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(b))
+	req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(b)), nil }
+	return true, nil
+}
+
+func isFailureStatus(code int) bool {
+	return code == http.StatusBadGateway || code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout
+}
+
+// isDialError reports whether err happened while connecting, before the
+// request was sent.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
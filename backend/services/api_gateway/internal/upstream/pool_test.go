@@ -0,0 +1,244 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// outcome is what an instance answers: a status, or an error.
+type outcome struct {
+	status int
+	err    error
+}
+
+// fakeTransport answers from the outcome of each host and records the
+// hosts and bodies it was sent.
+type fakeTransport struct {
+	outcomes map[string]outcome
+	hosts    []string
+	bodies   []string
+}
+
+func (f *fakeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.hosts = append(f.hosts, req.URL.Host)
+	body := ""
+	if req.Body != nil {
+		b, _ := io.ReadAll(req.Body)
+		body = string(b)
+	}
+	f.bodies = append(f.bodies, body)
+
+	o, ok := f.outcomes[req.URL.Host]
+	if !ok {
+		o = outcome{status: http.StatusOK}
+	}
+	if o.err != nil {
+		return nil, o.err
+	}
+	return &http.Response{
+		StatusCode: o.status,
+		Status:     http.StatusText(o.status),
+		Body:       io.NopCloser(strings.NewReader("")),
+		Request:    req,
+	}, nil
+}
+
+var (
+	errDial  = &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	errReset = &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}
+)
+
+func TestPoolRoundTripRetries(t *testing.T) {
+	unavailable := outcome{status: http.StatusServiceUnavailable}
+
+	tests := []struct {
+		name       string
+		retries    int
+		method     string
+		body       string
+		unknownLen bool // body of unknown length, which can't be replayed
+		cancelled  bool
+		outcomes   map[string]outcome
+		wantHosts  []string
+		wantStatus int
+		wantErr    error
+	}{
+		{
+			name: "success", retries: 1, method: http.MethodGet,
+			wantHosts: []string{"a"}, wantStatus: http.StatusOK,
+		},
+		{
+			name: "GET 503 retried", retries: 1, method: http.MethodGet,
+			outcomes:  map[string]outcome{"a": unavailable},
+			wantHosts: []string{"a", "b"}, wantStatus: http.StatusOK,
+		},
+		{
+			name: "GET 502 retried", retries: 1, method: http.MethodGet,
+			outcomes:  map[string]outcome{"a": {status: http.StatusBadGateway}},
+			wantHosts: []string{"a", "b"}, wantStatus: http.StatusOK,
+		},
+		{
+			name: "GET 504 retried", retries: 1, method: http.MethodGet,
+			outcomes:  map[string]outcome{"a": {status: http.StatusGatewayTimeout}},
+			wantHosts: []string{"a", "b"}, wantStatus: http.StatusOK,
+		},
+		{
+			name: "GET 500 not retried", retries: 1, method: http.MethodGet,
+			outcomes:  map[string]outcome{"a": {status: http.StatusInternalServerError}},
+			wantHosts: []string{"a"}, wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name: "GET connection reset retried", retries: 1, method: http.MethodGet,
+			outcomes:  map[string]outcome{"a": {err: errReset}},
+			wantHosts: []string{"a", "b"}, wantStatus: http.StatusOK,
+		},
+		{
+			name: "no retries configured", retries: 0, method: http.MethodGet,
+			outcomes:  map[string]outcome{"a": unavailable},
+			wantHosts: []string{"a"}, wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name: "retries bounded", retries: 1, method: http.MethodGet,
+			outcomes:  map[string]outcome{"a": unavailable, "b": unavailable},
+			wantHosts: []string{"a", "b"}, wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name: "untried instances first, then tried ones", retries: 4, method: http.MethodGet,
+			outcomes:  map[string]outcome{"a": unavailable, "b": unavailable, "c": unavailable},
+			wantHosts: []string{"a", "b", "c", "a", "b"}, wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name: "PUT retried", retries: 1, method: http.MethodPut, body: `{"x":1}`,
+			outcomes:  map[string]outcome{"a": unavailable},
+			wantHosts: []string{"a", "b"}, wantStatus: http.StatusOK,
+		},
+		{
+			name: "POST 503 not retried", retries: 1, method: http.MethodPost, body: `{"x":1}`,
+			outcomes:  map[string]outcome{"a": unavailable},
+			wantHosts: []string{"a"}, wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name: "POST connection reset not retried", retries: 1, method: http.MethodPost, body: `{"x":1}`,
+			outcomes:  map[string]outcome{"a": {err: errReset}},
+			wantHosts: []string{"a"}, wantErr: errReset,
+		},
+		{
+			name: "POST dial error retried", retries: 1, method: http.MethodPost, body: `{"x":1}`,
+			outcomes:  map[string]outcome{"a": {err: errDial}},
+			wantHosts: []string{"a", "b"}, wantStatus: http.StatusOK,
+		},
+		{
+			name: "body of unknown length not retried", retries: 1, method: http.MethodPost, body: `{"x":1}`,
+			unknownLen: true,
+			outcomes:   map[string]outcome{"a": {err: errDial}},
+			wantHosts:  []string{"a"}, wantErr: errDial,
+		},
+		{
+			name: "cancelled request not retried", retries: 1, method: http.MethodGet, cancelled: true,
+			outcomes:  map[string]outcome{"a": {err: context.Canceled}},
+			wantHosts: []string{"a"}, wantErr: context.Canceled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewPool("svc", Config{
+				URLs:            []string{"http://a", "http://b", "http://c"},
+				Retries:         tt.retries,
+				BreakerFailures: 100,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.Close()
+			// Start the round robin at the first instance.
+			p.next.Store(^uint64(0))
+			ft := &fakeTransport{outcomes: tt.outcomes}
+			p.transport = ft
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancelled {
+				cancel()
+			}
+			var body io.Reader
+			if tt.body != "" {
+				body = strings.NewReader(tt.body)
+				if tt.unknownLen {
+					body = io.MultiReader(body)
+				}
+			}
+			req, err := http.NewRequestWithContext(ctx, tt.method, "http://gateway/api/x", body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.unknownLen {
+				req.ContentLength = -1
+			}
+
+			resp, err := p.RoundTrip(req)
+			if !slices.Equal(ft.hosts, tt.wantHosts) {
+				t.Errorf("hosts = %v, want %v", ft.hosts, tt.wantHosts)
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			for i, b := range ft.bodies {
+				if b != tt.body {
+					t.Errorf("attempt %d sent body %q, want %q", i+1, b, tt.body)
+				}
+			}
+		})
+	}
+}
+
+func TestPoolRoundTripSkipsOpenCircuits(t *testing.T) {
+	p, err := NewPool("svc", Config{
+		URLs:            []string{"http://a", "http://b"},
+		Retries:         1,
+		BreakerFailures: 1,
+		BreakerOpenFor:  time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	p.next.Store(^uint64(0))
+	ft := &fakeTransport{outcomes: map[string]outcome{"a": {err: errDial}, "b": {err: errDial}}}
+	p.transport = ft
+
+	send := func() error {
+		req, _ := http.NewRequest(http.MethodGet, "http://gateway/api/x", nil)
+		resp, err := p.RoundTrip(req)
+		if resp != nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+	// Both instances fail once, which opens both circuits.
+	if err := send(); !errors.Is(err, errDial) {
+		t.Fatalf("first request error = %v, want the dial error", err)
+	}
+	if err := send(); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("second request error = %v, want ErrUnavailable", err)
+	}
+	if want := []string{"a", "b"}; !slices.Equal(ft.hosts, want) {
+		t.Errorf("hosts = %v, want %v", ft.hosts, want)
+	}
+}
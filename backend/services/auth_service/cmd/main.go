@@ -1,18 +1,32 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/IBM/sarama"
 	"github.com/joho/godotenv"
 
 	"auth_service/internal/config"
 	"auth_service/internal/database"
 	"auth_service/internal/handler"
 	"auth_service/internal/migrations"
+	"auth_service/internal/oidc"
 	"auth_service/internal/repository"
 	"auth_service/internal/router"
 	"auth_service/internal/services"
+
+	"contracts/events"
+	"contracts/kafka"
+	"contracts/mailer"
+	"contracts/outbox"
 )
 
 func main() {
@@ -41,19 +55,142 @@ func main() {
 
 	// Repositories
 	userRepo := repository.NewPostgresUserRepository(database.DB)
+	orgRepo := repository.NewPostgresOrganisationRepository(database.DB)
+	roleRepo := repository.NewPostgresRoleRepository(database.DB)
+	identityRepo := repository.NewPostgresIdentityRepository(database.DB)
+	totpRepo := repository.NewPostgresTOTPRepository(database.DB)
+	securityRepo := repository.NewPostgresSecurityRepository(database.DB)
+	deletionRepo := repository.NewPostgresAccountDeletionRepository(database.DB)
+	exportRepo := repository.NewPostgresDataExportRepository(database.DB)
+	apiKeyRepo := repository.NewPostgresAPIKeyRepository(database.DB)
+
+	// Mailer (doğrulama ve şifre sıfırlama e-postaları)
+	m, err := mailer.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom)
+	if err != nil {
+		log.Fatalf("failed to create mailer: %v", err)
+	}
 
 	// Services
-	userService := services.NewUserService(userRepo, cfg.KafkaBrokers, cfg.KafkaTopicUserRegistered)
+	securityService := services.NewSecurityService(securityRepo)
+	securityService.Policy = cfg.LoginPolicy
+	userService := services.NewUserService(userRepo, roleRepo, totpRepo, deletionRepo, securityService, cfg.KafkaTopicUserRegistered,
+		cfg.JWTSecret, cfg.TokenTTL, cfg.AdminEmails, m)
+	userService.AppURL = cfg.AppURL
+	userService.VerificationTTL = cfg.VerificationTTL
+	userService.ResetTTL = cfg.ResetTTL
+	userService.RequireVerifiedEmail = cfg.RequireVerifiedEmail
+	userService.TOTPIssuer = cfg.TOTPIssuer
+	userService.MFATTL = cfg.MFATTL
+	userService.DeletionTopic = cfg.KafkaTopicUserDeleted
+	userService.PurgeServices = cfg.PurgeServices
+	userService.ReauthWindow = cfg.ReauthWindow
+	orgService := services.NewOrganisationService(orgRepo, userRepo, cfg.KafkaTopicOrgMembers)
+	roleService := services.NewRoleService(roleRepo, userRepo)
+	oidcService := services.NewOIDCService(oidc.NewRegistry(cfg.OIDCProviders), identityRepo, userRepo, userService)
+	oidcService.StateTTL = cfg.OIDCStateTTL
+	exportService := services.NewDataExportService(exportRepo, userService, orgRepo, identityRepo, securityService,
+		services.NewExportSources(cfg.SubscriptionURL, cfg.ChatDataURL, cfg.OCRURL))
+	exportService.Dir = cfg.ExportDir
+	exportService.TTL = cfg.ExportTTL
+	exportService.DownloadURL = cfg.ExportDownloadBaseURL
+	exportService.MaxAttempts = cfg.ExportMaxAttempts
+	apiKeyService := services.NewAPIKeyService(apiKeyRepo, userService, securityService)
+	apiKeyService.MaxKeys = cfg.APIKeyMaxPerUser
+	apiKeyService.DefaultTTL = cfg.APIKeyDefaultTTL
+	apiKeyService.MaxTTL = cfg.APIKeyMaxTTL
+	apiKeyService.TokenTTL = cfg.APIKeyTokenTTL
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Outbox relay: user_registered, user_deleted ve org_members event'lerini Kafka'ya taşır
+	relay := outbox.NewRelay(database.DB, cfg.KafkaBrokers, cfg.OutboxPollInterval, cfg.OutboxBatchSize)
+	relay.Retention = cfg.OutboxRetention
+	relayDone := make(chan struct{})
+	go func() {
+		defer close(relayDone)
+		relay.Run(ctx)
+	}()
+
+	// Hesap silme raporları: servisler verisini sildikçe user_data_purged gönderir.
+	// Kafka henüz yoksa servis yine açılır, consumer katılana kadar dener.
+	consumerDone := make(chan struct{})
+	go func() {
+		defer close(consumerDone)
+		for ctx.Err() == nil {
+			err := kafka.RunConsumerGroup(ctx, cfg.KafkaBrokers, cfg.KafkaGroup,
+				[]string{cfg.KafkaTopicUserDataPurged}, kafka.ParseInitialOffset(cfg.KafkaInitialOffset),
+				func(msg *sarama.ConsumerMessage) error {
+					return handleMessage(userService, msg)
+				})
+			if err == nil {
+				return
+			}
+			log.Printf("⚠️ Kafka consumer cannot start, retrying: %v", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(5 * time.Second):
+			}
+		}
+	}()
+
+	// Veri dışa aktarımı: bekleyen istekleri arşivler, süresi dolanları siler
+	exportDone := make(chan struct{})
+	go func() {
+		defer close(exportDone)
+		exportService.Run(ctx, cfg.ExportPollInterval)
+	}()
 
 	// Auth handler
-	authHandler := handler.NewAuthHandler(userService)
+	authHandler := handler.NewAuthHandler(userService, cfg.TrustProxyHeaders)
+	orgHandler := handler.NewOrganisationHandler(orgService)
+	roleHandler := handler.NewRoleHandler(roleService)
+	securityHandler := handler.NewSecurityHandler(securityService)
+	oidcHandler := handler.NewOIDCHandler(oidcService, cfg.AppURL, cfg.TrustProxyHeaders)
+	exportHandler := handler.NewExportHandler(exportService, cfg.TrustProxyHeaders)
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyService, cfg.TrustProxyHeaders)
 
 	// Router
 	mux := http.NewServeMux()
-	router.SetupAuthRoutes(mux, authHandler)
+	router.SetupAuthRoutes(mux, authHandler, orgHandler, roleHandler, oidcHandler, securityHandler, exportHandler,
+		apiKeyHandler, []byte(cfg.JWTSecret))
+
+	server := &http.Server{Addr: ":" + cfg.ServicePort, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		log.Println("🛑 Shutting down Auth Service...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
 
 	log.Printf("✅ Auth Service running on :%s", cfg.ServicePort)
-	if err := http.ListenAndServe(":"+cfg.ServicePort, mux); err != nil {
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		log.Fatalf("failed to start server: %v", err)
 	}
+
+	<-relayDone
+	<-consumerDone
+	<-exportDone
+}
+
+// handleMessage records a purge report. Errors are retried by the consumer;
+// recording the same report twice changes nothing.
+func handleMessage(userService *services.UserService, msg *sarama.ConsumerMessage) error {
+	env, payload, err := events.Decode(msg.Value)
+	if errors.Is(err, events.ErrUnknownEvent) {
+		log.Printf("ℹ️ Ignored unknown event: %v", err)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", kafka.ErrInvalidMessage, err)
+	}
+
+	switch evt := payload.(type) {
+	case *events.UserDataPurged:
+		return userService.HandleDataPurged(evt)
+	default:
+		log.Printf("ℹ️ Ignored event type: %s", env.Type)
+	}
+	return nil
 }
@@ -5,8 +5,12 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+
+	"auth_service/internal/oidc"
+	"auth_service/internal/services"
 )
 
 type Config struct {
@@ -18,6 +22,80 @@ type Config struct {
 
 	KafkaBrokers             []string
 	KafkaTopicUserRegistered string
+	KafkaTopicOrgMembers     string
+	// KafkaTopicUserDeleted receives user_deleted; the purge reports of the
+	// services come back on KafkaTopicUserDataPurged, consumed as KafkaGroup.
+	KafkaTopicUserDeleted    string
+	KafkaTopicUserDataPurged string
+	KafkaGroup               string
+	KafkaInitialOffset       string
+
+	OutboxPollInterval time.Duration
+	OutboxBatchSize    int
+	// OutboxRetention is how long published outbox rows are kept.
+	OutboxRetention time.Duration
+
+	// JWTSecret signs access tokens (shared with the services that verify
+	// them); tokens are valid for TokenTTL.
+	JWTSecret string
+	TokenTTL  time.Duration
+	// AdminEmails are granted the admin role when they log in.
+	AdminEmails []string
+
+	// SMTP delivers verification and password reset emails (MailHog locally).
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+
+	// AppURL is the frontend the emailed links open.
+	AppURL               string
+	VerificationTTL      time.Duration
+	ResetTTL             time.Duration
+	RequireVerifiedEmail bool
+
+	// TOTPIssuer names the service in authenticator apps; MFATTL is how long
+	// the second login step may take.
+	TOTPIssuer string
+	MFATTL     time.Duration
+
+	// LoginPolicy throttles failed logins per account and IP.
+	// TrustProxyHeaders takes the client IP from X-Forwarded-For, which the
+	// API gateway sets; only enable it behind the gateway.
+	LoginPolicy       services.LoginPolicy
+	TrustProxyHeaders bool
+
+	// PurgeServices must report back before an account deletion is
+	// complete; ReauthWindow is how recently an account without a password
+	// must have signed in to be deleted.
+	PurgeServices []string
+	ReauthWindow  time.Duration
+
+	// Personal data exports are built every ExportPollInterval into
+	// ExportDir, collected from the services below, and downloadable for
+	// ExportTTL through links to ExportDownloadBaseURL (the gateway).
+	ExportDir             string
+	ExportTTL             time.Duration
+	ExportPollInterval    time.Duration
+	ExportMaxAttempts     int
+	ExportDownloadBaseURL string
+	SubscriptionURL       string
+	ChatDataURL           string
+	OCRURL                string
+
+	// API keys: at most APIKeyMaxPerUser active per user, living
+	// APIKeyDefaultTTL unless asked otherwise and never longer than
+	// APIKeyMaxTTL. The gateway gets access tokens valid for APIKeyTokenTTL.
+	APIKeyMaxPerUser int
+	APIKeyDefaultTTL time.Duration
+	APIKeyMaxTTL     time.Duration
+	APIKeyTokenTTL   time.Duration
+
+	// OIDCProviders are the external identity providers users can sign in
+	// with; OIDCStateTTL is how long a sign-in may take at the provider.
+	OIDCProviders []oidc.ProviderConfig
+	OIDCStateTTL  time.Duration
 
 	ServicePort string
 	LogLevel    string
@@ -40,6 +118,83 @@ func LoadConfig() (*Config, error) {
 	// Kafka
 	cfg.KafkaBrokers = parseCSV(getenv("KAFKA_BROKERS", "localhost:9092"))
 	cfg.KafkaTopicUserRegistered = getenv("KAFKA_TOPIC_USER_REGISTERED", "user_registered")
+	cfg.KafkaTopicOrgMembers = getenv("KAFKA_TOPIC_ORG_MEMBERS", "org_members")
+	cfg.KafkaTopicUserDeleted = getenv("KAFKA_TOPIC_USER_DELETED", "user_deleted")
+	cfg.KafkaTopicUserDataPurged = getenv("KAFKA_TOPIC_USER_DATA_PURGED", "user_data_purged")
+	cfg.KafkaGroup = getenv("KAFKA_GROUP", "auth-service-group")
+	cfg.KafkaInitialOffset = getenv("KAFKA_INITIAL_OFFSET", "oldest")
+
+	// Outbox relay
+	cfg.OutboxPollInterval = time.Duration(getenvInt("OUTBOX_POLL_INTERVAL_MS", 1000)) * time.Millisecond
+	cfg.OutboxBatchSize = getenvInt("OUTBOX_BATCH_SIZE", 100)
+	cfg.OutboxRetention = time.Duration(getenvInt("OUTBOX_RETENTION_DAYS", 7)) * 24 * time.Hour
+
+	// Access tokens
+	cfg.JWTSecret = getenv("JWT_SECRET", "")
+	cfg.TokenTTL = time.Duration(getenvInt("JWT_TTL_MINUTES", 60)) * time.Minute
+	cfg.AdminEmails = parseCSV(getenv("ADMIN_EMAILS", ""))
+
+	// SMTP (yerelde MailHog)
+	cfg.SMTPHost = getenv("SMTP_HOST", "localhost")
+	cfg.SMTPPort = getenvInt("SMTP_PORT", 1025)
+	cfg.SMTPUsername = getenv("SMTP_USERNAME", "")
+	cfg.SMTPPassword = getenv("SMTP_PASSWORD", "")
+	cfg.SMTPFrom = getenv("SMTP_FROM", "Chat Platform <no-reply@chat.local>")
+
+	// E-posta doğrulama ve şifre sıfırlama
+	cfg.AppURL = strings.TrimRight(getenv("APP_URL", "http://localhost:5173"), "/")
+	cfg.VerificationTTL = time.Duration(getenvInt("EMAIL_VERIFICATION_TTL_HOURS", 24)) * time.Hour
+	cfg.ResetTTL = time.Duration(getenvInt("PASSWORD_RESET_TTL_MINUTES", 60)) * time.Minute
+	cfg.RequireVerifiedEmail = getenv("EMAIL_VERIFICATION_REQUIRED", "true") == "true"
+
+	// İki adımlı doğrulama (TOTP)
+	cfg.TOTPIssuer = getenv("TOTP_ISSUER", "Chat Platform")
+	cfg.MFATTL = time.Duration(getenvInt("MFA_TTL_MINUTES", 5)) * time.Minute
+
+	// Brute-force koruması
+	cfg.LoginPolicy = services.LoginPolicy{
+		FreeAttempts:       getenvInt("LOGIN_FREE_ATTEMPTS", 3),
+		BaseDelay:          time.Duration(getenvInt("LOGIN_DELAY_BASE_SECONDS", 1)) * time.Second,
+		MaxDelay:           time.Duration(getenvInt("LOGIN_DELAY_MAX_SECONDS", 60)) * time.Second,
+		LockoutThreshold:   getenvInt("LOGIN_LOCKOUT_THRESHOLD", 10),
+		IPLockoutThreshold: getenvInt("LOGIN_IP_LOCKOUT_THRESHOLD", 50),
+		LockoutDuration:    time.Duration(getenvInt("LOGIN_LOCKOUT_MINUTES", 15)) * time.Minute,
+		FailureWindow:      time.Duration(getenvInt("LOGIN_FAILURE_WINDOW_MINUTES", 15)) * time.Minute,
+	}
+	cfg.TrustProxyHeaders = getenv("TRUST_PROXY_HEADERS", "false") == "true"
+
+	// Hesap silme: verisini silip rapor göndermesi beklenen servisler
+	cfg.PurgeServices = parseCSV(getenv("ACCOUNT_PURGE_SERVICES",
+		"chat_data_service,embedding_service,ocr_service,subscription_service"))
+	cfg.ReauthWindow = time.Duration(getenvInt("ACCOUNT_REAUTH_WINDOW_MINUTES", 10)) * time.Minute
+
+	// Kişisel veri dışa aktarımı
+	cfg.ExportDir = getenv("EXPORT_DIR", "/tmp/exports")
+	cfg.ExportTTL = time.Duration(getenvInt("EXPORT_TTL_HOURS", 48)) * time.Hour
+	cfg.ExportPollInterval = time.Duration(getenvInt("EXPORT_POLL_INTERVAL_SECONDS", 10)) * time.Second
+	cfg.ExportMaxAttempts = getenvInt("EXPORT_MAX_ATTEMPTS", 3)
+	cfg.ExportDownloadBaseURL = strings.TrimRight(getenv("EXPORT_DOWNLOAD_BASE_URL", "http://localhost:8085"), "/")
+	cfg.SubscriptionURL = strings.TrimRight(getenv("SUBSCRIPTION_SERVICE_URL", "http://subscription_service:8081"), "/")
+	cfg.ChatDataURL = strings.TrimRight(getenv("CHAT_DATA_SERVICE_URL", "http://chat_data_service:8083"), "/")
+	cfg.OCRURL = strings.TrimRight(getenv("OCR_SERVICE_URL", "http://ocr_service:8090"), "/")
+
+	// API anahtarları
+	cfg.APIKeyMaxPerUser = getenvInt("API_KEY_MAX_PER_USER", 20)
+	cfg.APIKeyDefaultTTL = time.Duration(getenvInt("API_KEY_DEFAULT_TTL_DAYS", 90)) * 24 * time.Hour
+	cfg.APIKeyMaxTTL = time.Duration(getenvInt("API_KEY_MAX_TTL_DAYS", 365)) * 24 * time.Hour
+	cfg.APIKeyTokenTTL = time.Duration(getenvInt("API_KEY_TOKEN_TTL_SECONDS", 300)) * time.Second
+
+	// OIDC sağlayıcıları: OIDC_PROVIDERS=mock,google ve her biri için
+	// OIDC_<AD>_ISSUER, _CLIENT_ID, _CLIENT_SECRET, ...
+	redirectBase := strings.TrimRight(getenv("OIDC_REDIRECT_BASE_URL", "http://localhost:8085"), "/")
+	for _, name := range parseCSV(getenv("OIDC_PROVIDERS", "")) {
+		p, err := loadOIDCProvider(name, redirectBase)
+		if err != nil {
+			return nil, err
+		}
+		cfg.OIDCProviders = append(cfg.OIDCProviders, p)
+	}
+	cfg.OIDCStateTTL = time.Duration(getenvInt("OIDC_STATE_TTL_MINUTES", 10)) * time.Minute
 
 	// Service
 	cfg.ServicePort = getenv("SERVICE_PORT", "8080")
@@ -52,6 +207,9 @@ func LoadConfig() (*Config, error) {
 	if cfg.PostgresHost == "" || cfg.PostgresUser == "" || cfg.PostgresDB == "" {
 		return nil, fmt.Errorf("postgres config incomplete")
 	}
+	if cfg.JWTSecret == "" {
+		return nil, fmt.Errorf("JWT_SECRET not configured")
+	}
 	if len(cfg.KafkaBrokers) == 0 {
 		return nil, fmt.Errorf("kafka brokers not configured")
 	}
@@ -59,6 +217,26 @@ func LoadConfig() (*Config, error) {
 	return cfg, nil
 }
 
+// loadOIDCProvider reads the OIDC_<NAME>_* variables of one provider. The
+// callback is served through the gateway at redirectBase.
+func loadOIDCProvider(name, redirectBase string) (oidc.ProviderConfig, error) {
+	prefix := "OIDC_" + strings.ToUpper(name) + "_"
+	p := oidc.ProviderConfig{
+		Name:         strings.ToLower(name),
+		DisplayName:  getenv(prefix+"DISPLAY_NAME", name),
+		Issuer:       getenv(prefix+"ISSUER", ""),
+		ClientID:     getenv(prefix+"CLIENT_ID", ""),
+		ClientSecret: getenv(prefix+"CLIENT_SECRET", ""),
+		Scopes:       parseCSV(getenv(prefix+"SCOPES", "email,profile")),
+		AuthURL:      getenv(prefix+"AUTH_URL", ""),
+	}
+	p.RedirectURL = redirectBase + "/api/auth/oidc/" + p.Name + "/callback"
+	if p.Issuer == "" || p.ClientID == "" {
+		return p, fmt.Errorf("%sISSUER and %sCLIENT_ID are required", prefix, prefix)
+	}
+	return p, nil
+}
+
 // Helpers
 func getenv(key, def string) string {
 	if v := os.Getenv(key); v != "" {
@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"auth_service/internal/models"
+	"auth_service/internal/services"
+
+	"contracts/auth"
+)
+
+// UpdateProfileRequest represents expected JSON input for PUT /me. Omitted
+// fields stay as they are; changing the email needs current_password.
+type UpdateProfileRequest struct {
+	Username        *string `json:"username"`
+	Email           *string `json:"email"`
+	CurrentPassword string  `json:"current_password"`
+}
+
+// ChangePasswordRequest represents expected JSON input for /me/password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// DeleteAccountRequest represents expected JSON input for DELETE /me.
+// Password is required if the account has one, Code (TOTP or recovery
+// code) if it has two-factor authentication.
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+// Me serves the signed-in user's own account:
+//
+//	GET    /api/auth/me            profile
+//	PUT    /api/auth/me            update username and/or email
+//	DELETE /api/auth/me            delete the account and all its data
+//	POST   /api/auth/me/password   change password
+func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
+	claims, ok := auth.ClaimsFrom(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+	userID, err := uuid.Parse(claims.UserID())
+	if err != nil {
+		http.Error(w, "invalid token subject", http.StatusUnauthorized)
+		return
+	}
+	client := clientInfo(r, h.trustProxy)
+
+	if r.URL.Path == "/api/auth/me/password" {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req ChangePasswordRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := h.userService.ChangePassword(userID, req.CurrentPassword, req.NewPassword, client); err != nil {
+			writeAccountError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "password updated"})
+		return
+	}
+	if r.URL.Path != "/api/auth/me" {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		user, err := h.userService.GetProfile(userID)
+		if err != nil {
+			writeAccountError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, user)
+
+	case http.MethodPut:
+		var req UpdateProfileRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		user, err := h.userService.UpdateProfile(userID, models.ProfileUpdate{
+			Username:        req.Username,
+			Email:           req.Email,
+			CurrentPassword: req.CurrentPassword,
+		}, client)
+		if err != nil {
+			writeAccountError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, user)
+
+	case http.MethodDelete:
+		var req DeleteAccountRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		var signedInAt time.Time
+		if claims.IssuedAt != nil {
+			signedInAt = claims.IssuedAt.Time
+		}
+		deletion, err := h.userService.DeleteAccount(userID, req.Password, req.Code, signedInAt, client)
+		if err != nil {
+			writeAccountError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, deletion)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// AccountDeletion handles GET /account-deletions/{id}: the progress of an
+// account deletion. The account is gone, so the unguessable id returned on
+// deletion is all it takes.
+func (h *AuthHandler) AccountDeletion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := uuid.Parse(strings.TrimPrefix(r.URL.Path, "/api/auth/account-deletions/"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	deletion, err := h.userService.GetDeletion(id)
+	if err != nil {
+		writeAccountError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, deletion)
+}
+
+func writeAccountError(w http.ResponseWriter, err error) {
+	if writeThrottled(w, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, services.ErrWrongPassword), errors.Is(err, services.ErrReauthRequired):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, services.ErrInvalidProfile), errors.Is(err, services.ErrWeakPassword),
+		errors.Is(err, services.ErrInvalidCode):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, services.ErrUsernameTaken), errors.Is(err, services.ErrEmailTaken),
+		errors.Is(err, services.ErrPasswordNotSet), errors.Is(err, services.ErrOrganisationOwner):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, services.ErrUserNotFound), errors.Is(err, services.ErrDeletionNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
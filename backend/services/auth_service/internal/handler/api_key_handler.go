@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"auth_service/internal/services"
+
+	"contracts/auth"
+)
+
+type APIKeyHandler struct {
+	apiKeyService *services.APIKeyService
+	trustProxy    bool
+}
+
+// NewAPIKeyHandler constructs a new handler
+func NewAPIKeyHandler(apiKeyService *services.APIKeyService, trustProxy bool) *APIKeyHandler {
+	return &APIKeyHandler{apiKeyService: apiKeyService, trustProxy: trustProxy}
+}
+
+// CreateAPIKeyRequest represents expected JSON input for POST /me/api-keys.
+// ExpiresInDays defaults to the configured lifetime.
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expires_in_days"`
+}
+
+// IntrospectRequest represents expected JSON input for the gateway's
+// /internal/api-keys/introspect call.
+type IntrospectRequest struct {
+	Key string `json:"key"`
+	IP  string `json:"ip"`
+}
+
+// APIKeys serves the signed-in user's API keys:
+//
+//	GET    /api/auth/me/api-keys        list keys
+//	POST   /api/auth/me/api-keys        create a key (the secret is returned once)
+//	DELETE /api/auth/me/api-keys/{id}   revoke a key
+func (h *APIKeyHandler) APIKeys(w http.ResponseWriter, r *http.Request) {
+	claims, ok := auth.ClaimsFrom(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+	if claims.ViaAPIKey() {
+		writeAPIKeyError(w, services.ErrAPIKeyForbidden)
+		return
+	}
+	userID, err := uuid.Parse(claims.UserID())
+	if err != nil {
+		http.Error(w, "invalid token subject", http.StatusUnauthorized)
+		return
+	}
+	client := clientInfo(r, h.trustProxy)
+
+	if rest := strings.TrimPrefix(r.URL.Path, "/api/auth/me/api-keys"); rest != "" {
+		id, err := uuid.Parse(strings.TrimPrefix(rest, "/"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodDelete {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		key, err := h.apiKeyService.RevokeKey(userID, id, client)
+		if err != nil {
+			writeAPIKeyError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, key)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		keys, err := h.apiKeyService.ListKeys(userID)
+		if err != nil {
+			writeAPIKeyError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"api_keys": keys})
+
+	case http.MethodPost:
+		var req CreateAPIKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		ttl := time.Duration(req.ExpiresInDays) * 24 * time.Hour
+		key, err := h.apiKeyService.CreateKey(userID, req.Name, req.Scopes, ttl, client)
+		if err != nil {
+			writeAPIKeyError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, key)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Introspect handles POST /internal/api-keys/introspect: the gateway
+// exchanges an API key for a short-lived access token. Invalid keys are
+// answered with 401.
+func (h *APIKeyHandler) Introspect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req IntrospectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	grant, err := h.apiKeyService.Introspect(req.Key, req.IP)
+	if err != nil {
+		writeAPIKeyError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, grant)
+}
+
+func writeAPIKeyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidAPIKey):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, services.ErrAPIKeyForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, services.ErrInvalidAPIKeyRequest):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, services.ErrAPIKeyLimit):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, services.ErrAPIKeyNotFound), errors.Is(err, services.ErrUserNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
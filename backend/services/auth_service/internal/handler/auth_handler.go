@@ -2,6 +2,7 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"auth_service/internal/services"
@@ -9,12 +10,16 @@ import (
 
 type AuthHandler struct {
 	userService *services.UserService
+	// trustProxy takes the client IP from X-Forwarded-For (set by the
+	// gateway) for login throttling and the audit log.
+	trustProxy bool
 }
 
 // NewAuthHandler constructs a new handler
-func NewAuthHandler(userService *services.UserService) *AuthHandler {
+func NewAuthHandler(userService *services.UserService, trustProxy bool) *AuthHandler {
 	return &AuthHandler{
 		userService: userService,
+		trustProxy:  trustProxy,
 	}
 }
 
@@ -31,6 +36,22 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+// TokenRequest represents expected JSON input for /verify-email
+type TokenRequest struct {
+	Token string `json:"token"`
+}
+
+// EmailRequest represents expected JSON input for /verify-email/resend and /password/forgot
+type EmailRequest struct {
+	Email string `json:"email"`
+}
+
+// ResetPasswordRequest represents expected JSON input for /password/reset
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
 // Register handles POST /register
 func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	var req RegisterRequest
@@ -63,14 +84,88 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := h.userService.LoginUser(req.Email, req.Password)
+	session, err := h.userService.LoginUser(req.Email, req.Password, clientInfo(r, h.trustProxy))
+	if writeThrottled(w, err) {
+		return
+	}
+	if errors.Is(err, services.ErrEmailNotVerified) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
 
-	response, _ := json.Marshal(user)
+	// Access token + kullanıcı (rolleriyle birlikte)
+	response, _ := json.Marshal(session)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write(response)
 }
+
+// VerifyEmail handles POST /verify-email
+func (h *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req TokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.userService.VerifyEmail(req.Token)
+	if err != nil {
+		writeTokenError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+// ResendVerification handles POST /verify-email/resend. The answer is the
+// same whether or not the address is registered.
+func (h *AuthHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	var req EmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	h.userService.ResendVerification(req.Email)
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "sent if the address needs verification"})
+}
+
+// ForgotPassword handles POST /password/forgot. The answer is the same
+// whether or not the address is registered.
+func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req EmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	h.userService.RequestPasswordReset(req.Email)
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "sent if the address is registered"})
+}
+
+// ResetPassword handles POST /password/reset
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.userService.ResetPassword(req.Token, req.Password); err != nil {
+		writeTokenError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "password updated"})
+}
+
+func writeTokenError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidToken), errors.Is(err, services.ErrWeakPassword):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
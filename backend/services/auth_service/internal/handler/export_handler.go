@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"auth_service/internal/services"
+
+	"contracts/auth"
+)
+
+type ExportHandler struct {
+	exportService *services.DataExportService
+	trustProxy    bool
+}
+
+// NewExportHandler constructs a new handler
+func NewExportHandler(exportService *services.DataExportService, trustProxy bool) *ExportHandler {
+	return &ExportHandler{exportService: exportService, trustProxy: trustProxy}
+}
+
+// Exports serves the signed-in user's data exports:
+//
+//	GET  /api/auth/me/exports   list exports
+//	POST /api/auth/me/exports   request a new export (202)
+func (h *ExportHandler) Exports(w http.ResponseWriter, r *http.Request) {
+	claims, ok := auth.ClaimsFrom(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+	userID, err := uuid.Parse(claims.UserID())
+	if err != nil {
+		http.Error(w, "invalid token subject", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		exports, err := h.exportService.ListExports(userID)
+		if err != nil {
+			writeExportError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"exports": exports})
+
+	case http.MethodPost:
+		export, err := h.exportService.RequestExport(userID, clientInfo(r, h.trustProxy))
+		if err != nil {
+			writeExportError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, export)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Download handles GET /exports/{id}/download?token=...: the archive behind
+// an emailed link. The token is the credential, so no login is needed.
+func (h *ExportHandler) Download(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rest, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/auth/exports/"), "/download")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	id, err := uuid.Parse(rest)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	export, f, err := h.exportService.OpenDownload(id, r.URL.Query().Get("token"))
+	if err != nil {
+		writeExportError(w, err)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="data-export-%s.zip"`,
+		export.RequestedAt.Format("2006-01-02")))
+	w.Header().Set("Cache-Control", "no-store")
+	http.ServeContent(w, r, "", *export.CompletedAt, f)
+}
+
+func writeExportError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrExportInProgress):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, services.ErrExportNotFound), errors.Is(err, services.ErrUserNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
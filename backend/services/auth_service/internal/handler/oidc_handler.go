@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"auth_service/internal/oidc"
+	"auth_service/internal/services"
+)
+
+type OIDCHandler struct {
+	oidcService *services.OIDCService
+	// appURL is the frontend the callback hands the session to.
+	appURL     string
+	trustProxy bool
+}
+
+// NewOIDCHandler constructs a new handler
+func NewOIDCHandler(oidcService *services.OIDCService, appURL string, trustProxy bool) *OIDCHandler {
+	return &OIDCHandler{oidcService: oidcService, appURL: appURL, trustProxy: trustProxy}
+}
+
+// Providers handles GET /oidc/providers
+func (h *OIDCHandler) Providers(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.oidcService.Providers())
+}
+
+// Flow serves the browser side of a provider sign-in:
+//
+//	GET /api/auth/oidc/{provider}/login      redirect to the provider
+//	GET /api/auth/oidc/{provider}/callback   redirect back to the frontend
+func (h *OIDCHandler) Flow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/auth/oidc/"), "/")
+	if len(parts) != 2 || parts[0] == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	provider := parts[0]
+	switch parts[1] {
+	case "login":
+		h.login(w, r, provider)
+	case "callback":
+		h.callback(w, r, provider)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// stateCookie ties a sign-in to the browser that started it. Without it, a
+// callback URL for the attacker's provider account would sign in whoever
+// opens it (login CSRF). SameSite=Lax still sends it on the provider's
+// top-level redirect back.
+const stateCookie = "oidc_state"
+
+const stateCookiePath = "/api/auth/oidc/"
+
+func (h *OIDCHandler) login(w http.ResponseWriter, r *http.Request, provider string) {
+	authURL, state, err := h.oidcService.BeginLogin(r.Context(), provider)
+	if errors.Is(err, oidc.ErrUnknownProvider) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("⚠️ %s sign-in could not start: %v", provider, err)
+		http.Error(w, "identity provider unavailable", http.StatusBadGateway)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     stateCookie,
+		Value:    state,
+		Path:     stateCookiePath,
+		MaxAge:   int(h.oidcService.StateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// callback hands the session (or, with two-factor authentication, the MFA
+// token) to the frontend in the URL fragment, which browsers don't send to
+// servers or in Referer headers. Failures go back to the login page with a
+// short reason. A callback whose state isn't the one this browser started is
+// refused.
+func (h *OIDCHandler) callback(w http.ResponseWriter, r *http.Request, provider string) {
+	q := r.URL.Query()
+	cookie, err := r.Cookie(stateCookie)
+	http.SetCookie(w, &http.Cookie{
+		Name:     stateCookie,
+		Path:     stateCookiePath,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	if err != nil || cookie.Value == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(q.Get("state"))) != 1 {
+		log.Printf("⚠️ %s sign-in callback does not match this browser's state", provider)
+		h.fail(w, r, "expired")
+		return
+	}
+
+	if e := q.Get("error"); e != "" {
+		log.Printf("⚠️ %s sign-in refused: %s %s", provider, e, q.Get("error_description"))
+		h.fail(w, r, "denied")
+		return
+	}
+
+	session, err := h.oidcService.CompleteLogin(r.Context(), provider, q.Get("state"), q.Get("code"),
+		clientInfo(r, h.trustProxy))
+	if err != nil {
+		log.Printf("⚠️ %s sign-in failed: %v", provider, err)
+		switch {
+		case errors.Is(err, services.ErrLoginExpired):
+			h.fail(w, r, "expired")
+		case errors.Is(err, services.ErrProviderEmailUnverified):
+			h.fail(w, r, "email_unverified")
+		default:
+			h.fail(w, r, "failed")
+		}
+		return
+	}
+
+	fragment := url.Values{"token": {session.Token}}
+	if session.MFARequired {
+		fragment = url.Values{"mfa_token": {session.MFAToken}}
+	}
+	http.Redirect(w, r, h.appURL+"/oidc/callback#"+fragment.Encode(), http.StatusFound)
+}
+
+func (h *OIDCHandler) fail(w http.ResponseWriter, r *http.Request, reason string) {
+	http.Redirect(w, r, h.appURL+"/login?oidc_error="+url.QueryEscape(reason), http.StatusFound)
+}
@@ -0,0 +1,238 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"auth_service/internal/repository"
+	"auth_service/internal/services"
+
+	"contracts/auth"
+)
+
+type OrganisationHandler struct {
+	orgService *services.OrganisationService
+}
+
+// NewOrganisationHandler constructs a new handler
+func NewOrganisationHandler(orgService *services.OrganisationService) *OrganisationHandler {
+	return &OrganisationHandler{orgService: orgService}
+}
+
+// CreateOrganisationRequest represents expected JSON input for POST /orgs
+type CreateOrganisationRequest struct {
+	Name string `json:"name"`
+}
+
+// AddMemberRequest represents expected JSON input for POST /orgs/{org_id}/members
+type AddMemberRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+// ChangeRoleRequest represents expected JSON input for PUT /orgs/{org_id}/members/{member_id}
+type ChangeRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// CreateOrganisation handles POST /orgs; the token's user becomes the owner.
+func (h *OrganisationHandler) CreateOrganisation(w http.ResponseWriter, r *http.Request) {
+	uid, ok := tokenUserID(w, r)
+	if !ok {
+		return
+	}
+	var req CreateOrganisationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	org, err := h.orgService.CreateOrganisation(uid, req.Name)
+	if err != nil {
+		writeOrgError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, org)
+}
+
+// Organisations serves the organisation endpoints; the acting user is that
+// of the token:
+//
+//	GET    /api/auth/orgs/{org_id}                       organisation with members
+//	DELETE /api/auth/orgs/{org_id}                       delete (owner)
+//	POST   /api/auth/orgs/{org_id}/members               add by email (owner/admin)
+//	PUT    /api/auth/orgs/{org_id}/members/{member_id}   change role (owner)
+//	DELETE /api/auth/orgs/{org_id}/members/{member_id}   remove or leave
+func (h *OrganisationHandler) Organisations(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/auth/orgs/"), "/"), "/")
+	orgID, err := uuid.Parse(parts[0])
+	if err != nil {
+		http.Error(w, "invalid org_id", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		h.getOrganisation(w, r, orgID)
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		h.deleteOrganisation(w, r, orgID)
+	case len(parts) == 2 && parts[1] == "members" && r.Method == http.MethodPost:
+		h.addMember(w, r, orgID)
+	case len(parts) == 3 && parts[1] == "members":
+		memberID, err := uuid.Parse(parts[2])
+		if err != nil {
+			http.Error(w, "invalid member_id", http.StatusBadRequest)
+			return
+		}
+		switch r.Method {
+		case http.MethodPut:
+			h.changeRole(w, r, orgID, memberID)
+		case http.MethodDelete:
+			h.removeMember(w, r, orgID, memberID)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	case len(parts) <= 2:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// GetUserOrganisation handles GET /internal/users/{user_id}/organisation for
+// other services; the gateway doesn't route /internal.
+func (h *OrganisationHandler) GetUserOrganisation(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/internal/users/"), "/"), "/")
+	if len(parts) != 2 || parts[1] != "organisation" {
+		http.NotFound(w, r)
+		return
+	}
+	uid, err := uuid.Parse(parts[0])
+	if err != nil {
+		http.Error(w, "invalid user_id", http.StatusBadRequest)
+		return
+	}
+
+	m, err := h.orgService.GetMembership(uid)
+	if err != nil {
+		writeOrgError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, m)
+}
+
+func (h *OrganisationHandler) getOrganisation(w http.ResponseWriter, r *http.Request, orgID uuid.UUID) {
+	uid, ok := tokenUserID(w, r)
+	if !ok {
+		return
+	}
+	org, err := h.orgService.GetOrganisation(uid, orgID)
+	if err != nil {
+		writeOrgError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, org)
+}
+
+func (h *OrganisationHandler) deleteOrganisation(w http.ResponseWriter, r *http.Request, orgID uuid.UUID) {
+	uid, ok := tokenUserID(w, r)
+	if !ok {
+		return
+	}
+	if err := h.orgService.DeleteOrganisation(uid, orgID); err != nil {
+		writeOrgError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *OrganisationHandler) addMember(w http.ResponseWriter, r *http.Request, orgID uuid.UUID) {
+	uid, ok := tokenUserID(w, r)
+	if !ok {
+		return
+	}
+	var req AddMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	member, err := h.orgService.AddMember(uid, orgID, req.Email, req.Role)
+	if err != nil {
+		writeOrgError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, member)
+}
+
+func (h *OrganisationHandler) changeRole(w http.ResponseWriter, r *http.Request, orgID, memberID uuid.UUID) {
+	uid, ok := tokenUserID(w, r)
+	if !ok {
+		return
+	}
+	var req ChangeRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.orgService.ChangeRole(uid, orgID, memberID, req.Role); err != nil {
+		writeOrgError(w, err)
+		return
+	}
+	h.getOrganisation(w, r, orgID)
+}
+
+func (h *OrganisationHandler) removeMember(w http.ResponseWriter, r *http.Request, orgID, memberID uuid.UUID) {
+	uid, ok := tokenUserID(w, r)
+	if !ok {
+		return
+	}
+	if err := h.orgService.RemoveMember(uid, orgID, memberID); err != nil {
+		writeOrgError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// tokenUserID returns the id of the user the request's token was issued to.
+// It writes the error response itself.
+func tokenUserID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	claims, ok := auth.ClaimsFrom(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return uuid.Nil, false
+	}
+	uid, err := uuid.Parse(claims.UserID())
+	if err != nil {
+		http.Error(w, "invalid token subject", http.StatusUnauthorized)
+		return uuid.Nil, false
+	}
+	return uid, true
+}
+
+func writeOrgError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidRole), errors.Is(err, services.ErrInvalidName):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, services.ErrForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, repository.ErrOrganisationNotFound), errors.Is(err, repository.ErrNotMember),
+		errors.Is(err, services.ErrUserNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, repository.ErrAlreadyMember):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
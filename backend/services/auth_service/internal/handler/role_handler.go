@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"auth_service/internal/repository"
+	"auth_service/internal/services"
+
+	"contracts/auth"
+)
+
+type RoleHandler struct {
+	roleService *services.RoleService
+}
+
+// NewRoleHandler constructs a new handler
+func NewRoleHandler(roleService *services.RoleService) *RoleHandler {
+	return &RoleHandler{roleService: roleService}
+}
+
+// SetRolesRequest represents expected JSON input for PUT /admin/users/{user_id}/roles
+type SetRolesRequest struct {
+	Roles []string `json:"roles"`
+}
+
+// ListRoles handles GET /admin/roles
+func (h *RoleHandler) ListRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := h.roleService.ListRoles()
+	if err != nil {
+		writeRoleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, roles)
+}
+
+// UserRoles serves a user's roles; the acting admin comes from the token:
+//
+//	GET /api/auth/admin/users/{user_id}/roles   roles and permissions
+//	PUT /api/auth/admin/users/{user_id}/roles   replace roles
+func (h *RoleHandler) UserRoles(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/auth/admin/users/"), "/"), "/")
+	if len(parts) != 2 || parts[1] != "roles" {
+		http.NotFound(w, r)
+		return
+	}
+	uid, err := uuid.Parse(parts[0])
+	if err != nil {
+		http.Error(w, "invalid user_id", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		ur, err := h.roleService.GetUserRoles(uid)
+		if err != nil {
+			writeRoleError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, ur)
+	case http.MethodPut:
+		claims, ok := auth.ClaimsFrom(r.Context())
+		if !ok {
+			http.Error(w, "authentication required", http.StatusUnauthorized)
+			return
+		}
+		actorID, err := uuid.Parse(claims.UserID())
+		if err != nil {
+			http.Error(w, "invalid token subject", http.StatusUnauthorized)
+			return
+		}
+		var req SetRolesRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		ur, err := h.roleService.SetUserRoles(actorID, uid, req.Roles)
+		if err != nil {
+			writeRoleError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, ur)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeRoleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrUnknownRole):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, services.ErrSelfLockout):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, services.ErrUserNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"auth_service/internal/models"
+	"auth_service/internal/services"
+)
+
+type SecurityHandler struct {
+	securityService *services.SecurityService
+}
+
+// NewSecurityHandler constructs a new handler
+func NewSecurityHandler(securityService *services.SecurityService) *SecurityHandler {
+	return &SecurityHandler{securityService: securityService}
+}
+
+// ListEvents handles GET /admin/security-events. Optional filters: type,
+// user_id, email, ip, since and until (RFC 3339) and limit (max 500).
+func (h *SecurityHandler) ListEvents(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := models.SecurityEventFilter{
+		Type:  q.Get("type"),
+		Email: q.Get("email"),
+		IP:    q.Get("ip"),
+	}
+	if v := q.Get("user_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			http.Error(w, "invalid user_id", http.StatusBadRequest)
+			return
+		}
+		f.UserID = &id
+	}
+	for name, dst := range map[string]*time.Time{"since": &f.Since, "until": &f.Until} {
+		if v := q.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, "invalid "+name+", expected RFC 3339", http.StatusBadRequest)
+				return
+			}
+			*dst = t.UTC()
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		f.Limit = n
+	}
+
+	events, err := h.securityService.ListEvents(f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, events)
+}
+
+// clientInfo returns where r came from. Behind the API gateway the remote
+// address is the gateway's, so the first X-Forwarded-For entry is used when
+// trustProxy is set.
+func clientInfo(r *http.Request, trustProxy bool) models.ClientInfo {
+	ip := r.RemoteAddr
+	if fwd := r.Header.Get("X-Forwarded-For"); trustProxy && fwd != "" {
+		ip, _, _ = strings.Cut(fwd, ",")
+		ip = strings.TrimSpace(ip)
+	}
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	return models.ClientInfo{IP: ip, UserAgent: r.UserAgent()}
+}
+
+// writeThrottled answers a throttled login with 429 and Retry-After. It
+// reports whether err was one.
+func writeThrottled(w http.ResponseWriter, err error) bool {
+	var throttled *services.LoginThrottledError
+	if !errors.As(err, &throttled) {
+		return false
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+	http.Error(w, err.Error(), http.StatusTooManyRequests)
+	return true
+}
@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"auth_service/internal/services"
+
+	"contracts/auth"
+)
+
+// CodeRequest represents expected JSON input for the /2fa endpoints. Code is
+// a TOTP code or a recovery code.
+type CodeRequest struct {
+	Code string `json:"code"`
+}
+
+// MFALoginRequest represents expected JSON input for /login/2fa
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"`
+}
+
+// LoginMFA handles POST /login/2fa
+func (h *AuthHandler) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	var req MFALoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	session, err := h.userService.CompleteMFALogin(req.MFAToken, req.Code, clientInfo(r, h.trustProxy))
+	if writeThrottled(w, err) {
+		return
+	}
+	if errors.Is(err, services.ErrInvalidToken) {
+		// The challenge expired or ran out of attempts: log in again.
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, session)
+}
+
+// TwoFactor serves the signed-in user's two-factor settings:
+//
+//	GET  /api/auth/2fa                  status
+//	POST /api/auth/2fa/setup            new secret and otpauth URI
+//	POST /api/auth/2fa/confirm          enable with a code, returns recovery codes
+//	POST /api/auth/2fa/disable          disable with a code
+//	POST /api/auth/2fa/recovery-codes   replace recovery codes with a code
+func (h *AuthHandler) TwoFactor(w http.ResponseWriter, r *http.Request) {
+	claims, ok := auth.ClaimsFrom(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+	userID, err := uuid.Parse(claims.UserID())
+	if err != nil {
+		http.Error(w, "invalid token subject", http.StatusUnauthorized)
+		return
+	}
+
+	if r.URL.Path == "/api/auth/2fa" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		status, err := h.userService.TwoFactorStatus(userID)
+		if err != nil {
+			writeTwoFactorError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, status)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	action := strings.TrimPrefix(r.URL.Path, "/api/auth/2fa/")
+	if action == "setup" {
+		setup, err := h.userService.SetupTOTP(userID)
+		if err != nil {
+			writeTwoFactorError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, setup)
+		return
+	}
+
+	var req CodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	switch action {
+	case "confirm":
+		codes, err := h.userService.ConfirmTOTP(userID, req.Code)
+		if err != nil {
+			writeTwoFactorError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, codes)
+	case "disable":
+		if err := h.userService.DisableTOTP(userID, req.Code); err != nil {
+			writeTwoFactorError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case "recovery-codes":
+		codes, err := h.userService.RegenerateRecoveryCodes(userID, req.Code)
+		if err != nil {
+			writeTwoFactorError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, codes)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func writeTwoFactorError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidCode):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, services.ErrTwoFactorEnabled), errors.Is(err, services.ErrTwoFactorDisabled):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, services.ErrUserNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,14 @@
+-- 002_create_outbox.sql
+
+CREATE TABLE IF NOT EXISTS outbox (
+    id UUID PRIMARY KEY,
+    topic VARCHAR(255) NOT NULL,
+    message_key TEXT NOT NULL DEFAULT '',
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    published_at TIMESTAMP NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox (created_at) WHERE published_at IS NULL;
@@ -0,0 +1,22 @@
+-- 003_organisations.sql
+
+CREATE TABLE IF NOT EXISTS organisations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- A user belongs to at most one organisation, so its workspace and pooled
+-- quota are never ambiguous.
+CREATE TABLE IF NOT EXISTS organisation_members (
+    organisation_id UUID NOT NULL REFERENCES organisations(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('owner', 'admin', 'member')),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (organisation_id, user_id)
+);
+
+-- Exactly one owner per organisation.
+CREATE UNIQUE INDEX IF NOT EXISTS uq_organisation_owner
+    ON organisation_members (organisation_id) WHERE role = 'owner';
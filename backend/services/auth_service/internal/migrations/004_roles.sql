@@ -0,0 +1,47 @@
+-- 004_roles.sql
+
+CREATE TABLE IF NOT EXISTS roles (
+    name VARCHAR(50) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS permissions (
+    name VARCHAR(100) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role VARCHAR(50) NOT NULL REFERENCES roles(name) ON DELETE CASCADE,
+    permission VARCHAR(100) NOT NULL REFERENCES permissions(name) ON DELETE CASCADE,
+    PRIMARY KEY (role, permission)
+);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(50) NOT NULL REFERENCES roles(name) ON DELETE CASCADE,
+    granted_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, role)
+);
+
+-- Names must match contracts/auth.
+INSERT INTO roles (name, description) VALUES
+    ('user', 'Every registered user'),
+    ('admin', 'Operators with access to the admin API')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO permissions (name, description) VALUES
+    ('subscriptions:assign', 'Assign plans without payment'),
+    ('plans:manage', 'Create, update and retire plans'),
+    ('roles:manage', 'Grant and revoke user roles')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('admin', 'subscriptions:assign'),
+    ('admin', 'plans:manage'),
+    ('admin', 'roles:manage')
+ON CONFLICT DO NOTHING;
+
+-- Users registered before roles existed.
+INSERT INTO user_roles (user_id, role)
+SELECT id, 'user' FROM users
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,27 @@
+-- 005_email_tokens.sql
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'users' AND column_name = 'email_verified_at'
+    ) THEN
+        ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP;
+
+        -- Accounts created before verification existed keep working.
+        UPDATE users SET email_verified_at = COALESCE(created_at, CURRENT_TIMESTAMP);
+    END IF;
+END $$;
+
+-- Single-use tokens sent by email. Only a hash of the token is stored.
+CREATE TABLE IF NOT EXISTS user_tokens (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose VARCHAR(20) NOT NULL CHECK (purpose IN ('verify_email', 'reset_password')),
+    token_hash BYTEA NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_tokens_user_purpose ON user_tokens (user_id, purpose);
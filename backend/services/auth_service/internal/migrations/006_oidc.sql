@@ -0,0 +1,26 @@
+-- 006_oidc.sql
+
+-- Accounts at external OIDC providers linked to users.
+CREATE TABLE IF NOT EXISTS user_identities (
+    provider VARCHAR(50) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_login_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_identities_user ON user_identities (user_id);
+
+-- Sign-ins in progress: the state sent to the provider with the PKCE
+-- verifier and nonce it must be answered with. Each is used once.
+CREATE TABLE IF NOT EXISTS oidc_login_states (
+    state VARCHAR(100) PRIMARY KEY,
+    provider VARCHAR(50) NOT NULL,
+    code_verifier VARCHAR(100) NOT NULL,
+    nonce VARCHAR(100) NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_oidc_login_states_expires ON oidc_login_states (expires_at);
@@ -0,0 +1,30 @@
+-- 007_totp.sql
+
+-- TOTP second factor. A secret is stored on setup and only protects logins
+-- once the user confirmed it with a code (confirmed_at). last_used_step is
+-- the time step of the last accepted code, so a code can't be replayed.
+CREATE TABLE IF NOT EXISTS user_totp (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    secret VARCHAR(64) NOT NULL,
+    confirmed_at TIMESTAMP,
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- One-time recovery codes for when the authenticator is lost. Only a keyed
+-- hash of each code is stored.
+CREATE TABLE IF NOT EXISTS user_recovery_codes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash BYTEA NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, code_hash)
+);
+
+-- The second login step is a user_tokens row (login_mfa) that is given up
+-- after a few wrong codes.
+ALTER TABLE user_tokens ADD COLUMN IF NOT EXISTS attempts INT NOT NULL DEFAULT 0;
+ALTER TABLE user_tokens DROP CONSTRAINT IF EXISTS user_tokens_purpose_check;
+ALTER TABLE user_tokens ADD CONSTRAINT user_tokens_purpose_check
+    CHECK (purpose IN ('verify_email', 'reset_password', 'login_mfa'));
@@ -0,0 +1,37 @@
+-- 008_login_security.sql
+
+-- Failed logins per account (lower-cased email, whether or not it is
+-- registered) and per client IP. failures restarts when the last failure is
+-- older than the failure window; locked_until blocks every attempt.
+CREATE TABLE IF NOT EXISTS login_throttles (
+    scope VARCHAR(10) NOT NULL CHECK (scope IN ('account', 'ip')),
+    key VARCHAR(255) NOT NULL,
+    failures INT NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP,
+    PRIMARY KEY (scope, key)
+);
+
+-- Security audit log: logins, failures, blocked attempts and lockouts.
+CREATE TABLE IF NOT EXISTS security_events (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    event_type VARCHAR(30) NOT NULL,
+    user_id UUID,
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    detail TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_security_events_created ON security_events (created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_security_events_user ON security_events (user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_security_events_ip ON security_events (ip, created_at DESC);
+
+INSERT INTO permissions (name, description) VALUES
+    ('audit:read', 'Read the security audit log')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('admin', 'audit:read')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,25 @@
+-- 009_account_deletion.sql
+
+-- Deleted accounts and the erasure of their data in the other services.
+-- The user row is gone, so user_id has no foreign key. email is kept only
+-- until the completion report is mailed.
+CREATE TABLE IF NOT EXISTS account_deletions (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    email VARCHAR(100) NOT NULL DEFAULT '',
+    requested_at TIMESTAMP NOT NULL,
+    completed_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_account_deletions_user ON account_deletions (user_id);
+
+-- One row per service expected to purge the user's data; completed_at is
+-- set when its user_data_purged report arrives.
+CREATE TABLE IF NOT EXISTS account_deletion_steps (
+    deletion_id UUID NOT NULL REFERENCES account_deletions(id) ON DELETE CASCADE,
+    service VARCHAR(50) NOT NULL,
+    items_deleted BIGINT NOT NULL DEFAULT 0,
+    detail TEXT NOT NULL DEFAULT '',
+    completed_at TIMESTAMP,
+    PRIMARY KEY (deletion_id, service)
+);
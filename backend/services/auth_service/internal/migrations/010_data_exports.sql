@@ -0,0 +1,25 @@
+-- 010_data_exports.sql
+
+-- "Download my data" requests. The ZIP is built in the background and
+-- downloaded with an emailed link until expires_at; only the SHA-256 of the
+-- link's token is stored.
+CREATE TABLE IF NOT EXISTS data_exports (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'running', 'ready', 'failed', 'expired')),
+    attempts INT NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    size_bytes BIGINT NOT NULL DEFAULT 0,
+    token_hash BYTEA UNIQUE,
+    requested_at TIMESTAMP NOT NULL,
+    started_at TIMESTAMP,
+    completed_at TIMESTAMP,
+    expires_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_data_exports_user ON data_exports (user_id, requested_at DESC);
+
+-- A user has at most one export being built.
+CREATE UNIQUE INDEX IF NOT EXISTS uq_data_exports_in_progress
+    ON data_exports (user_id) WHERE status IN ('pending', 'running');
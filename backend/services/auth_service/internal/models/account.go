@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ProfileUpdate carries the profile fields a user changes; nil fields stay
+// as they are. Changing the email needs CurrentPassword.
+type ProfileUpdate struct {
+	Username        *string
+	Email           *string
+	CurrentPassword string
+}
+
+// Statuses of an AccountDeletion.
+const (
+	DeletionPending   = "pending"
+	DeletionCompleted = "completed"
+)
+
+// AccountDeletion tracks the erasure of a deleted account's data across the
+// services. It is completed once every step has reported.
+type AccountDeletion struct {
+	ID          uuid.UUID             `db:"id" json:"id"`
+	UserID      uuid.UUID             `db:"user_id" json:"-"`
+	Email       string                `db:"email" json:"-"`
+	Status      string                `db:"-" json:"status"`
+	RequestedAt time.Time             `db:"requested_at" json:"requested_at"`
+	CompletedAt *time.Time            `db:"completed_at" json:"completed_at"`
+	Steps       []AccountDeletionStep `db:"-" json:"steps"`
+}
+
+// AccountDeletionStep is one service's part of an AccountDeletion.
+type AccountDeletionStep struct {
+	Service      string     `db:"service" json:"service"`
+	ItemsDeleted int64      `db:"items_deleted" json:"items_deleted"`
+	Detail       string     `db:"detail" json:"detail,omitempty"`
+	CompletedAt  *time.Time `db:"completed_at" json:"completed_at"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TokenAPIKey is the purpose API keys are signed for.
+const TokenAPIKey = "api_key"
+
+// APIKey is a named credential for programmatic access. It only reaches the
+// routes of its scopes and stops working at ExpiresAt or when revoked.
+type APIKey struct {
+	ID         uuid.UUID  `db:"id" json:"id"`
+	UserID     uuid.UUID  `db:"user_id" json:"-"`
+	Name       string     `db:"name" json:"name"`
+	Prefix     string     `db:"prefix" json:"prefix"`
+	KeyHash    []byte     `db:"key_hash" json:"-"`
+	Scopes     []string   `db:"-" json:"scopes"`
+	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
+	ExpiresAt  time.Time  `db:"expires_at" json:"expires_at"`
+	LastUsedAt *time.Time `db:"last_used_at" json:"last_used_at"`
+	LastUsedIP string     `db:"last_used_ip" json:"last_used_ip,omitempty"`
+	RevokedAt  *time.Time `db:"revoked_at" json:"revoked_at"`
+}
+
+// CreatedAPIKey is a new API key with its secret, which is shown only once.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// APIKeyGrant is what the gateway gets for a valid API key: a short-lived
+// access token limited to the key's scopes.
+type APIKeyGrant struct {
+	AccessToken string    `json:"access_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	UserID      string    `json:"user_id"`
+	KeyID       string    `json:"key_id"`
+	Scopes      []string  `json:"scopes"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Statuses of a DataExport.
+const (
+	ExportPending = "pending"
+	ExportRunning = "running"
+	ExportReady   = "ready"
+	ExportFailed  = "failed"
+	ExportExpired = "expired"
+)
+
+// TokenDataExport is the purpose the download links of exports are signed for.
+const TokenDataExport = "data_export"
+
+// DataExport is a user's request for a ZIP archive of all their data.
+type DataExport struct {
+	ID          uuid.UUID  `db:"id" json:"id"`
+	UserID      uuid.UUID  `db:"user_id" json:"-"`
+	Status      string     `db:"status" json:"status"`
+	Attempts    int        `db:"attempts" json:"-"`
+	Error       string     `db:"error" json:"error,omitempty"`
+	SizeBytes   int64      `db:"size_bytes" json:"size_bytes"`
+	TokenHash   []byte     `db:"token_hash" json:"-"`
+	RequestedAt time.Time  `db:"requested_at" json:"requested_at"`
+	StartedAt   *time.Time `db:"started_at" json:"-"`
+	CompletedAt *time.Time `db:"completed_at" json:"completed_at"`
+	ExpiresAt   *time.Time `db:"expires_at" json:"expires_at"`
+}
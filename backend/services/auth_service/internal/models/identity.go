@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserIdentity links an account at an external OIDC provider to a user.
+type UserIdentity struct {
+	Provider    string    `db:"provider" json:"provider"`
+	Subject     string    `db:"subject" json:"subject"`
+	UserID      uuid.UUID `db:"user_id" json:"user_id"`
+	Email       string    `db:"email" json:"email"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+	LastLoginAt time.Time `db:"last_login_at" json:"last_login_at"`
+}
+
+// OIDCLoginState is a sign-in waiting for the provider's callback.
+type OIDCLoginState struct {
+	State        string    `db:"state"`
+	Provider     string    `db:"provider"`
+	CodeVerifier string    `db:"code_verifier"`
+	Nonce        string    `db:"nonce"`
+	ExpiresAt    time.Time `db:"expires_at"`
+}
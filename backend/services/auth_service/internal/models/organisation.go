@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Organisation is a team workspace. Its members share the organisation's
+// subscription quota and the documents shared with the workspace.
+type Organisation struct {
+	ID        uuid.UUID `db:"id" json:"id"`
+	Name      string    `db:"name" json:"name"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// OrganisationMember is a user's membership with its role (owner, admin or
+// member).
+type OrganisationMember struct {
+	OrganisationID uuid.UUID `db:"organisation_id" json:"organisation_id"`
+	UserID         uuid.UUID `db:"user_id" json:"user_id"`
+	Username       string    `db:"username" json:"username"`
+	Email          string    `db:"email" json:"email"`
+	Role           string    `db:"role" json:"role"`
+	CreatedAt      time.Time `db:"created_at" json:"created_at"`
+}
+
+// OrganisationDetails is an organisation with its members.
+type OrganisationDetails struct {
+	Organisation
+	Members []OrganisationMember `json:"members"`
+}
+
+// Membership is the organisation a user belongs to and the user's role in it.
+type Membership struct {
+	OrganisationID   uuid.UUID `db:"organisation_id" json:"organisation_id"`
+	OrganisationName string    `db:"organisation_name" json:"organisation_name"`
+	Role             string    `db:"role" json:"role"`
+}
@@ -0,0 +1,15 @@
+package models
+
+// Role is a named set of permissions assigned to users.
+type Role struct {
+	Name        string   `db:"name" json:"name"`
+	Description string   `db:"description" json:"description"`
+	Permissions []string `db:"-" json:"permissions"`
+}
+
+// UserRoles is what a user may do: their roles and the union of the roles'
+// permissions.
+type UserRoles struct {
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+}
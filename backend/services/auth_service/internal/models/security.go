@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Types of a SecurityEvent.
+const (
+	EventLoginSucceeded  = "login_succeeded"
+	EventLoginFailed     = "login_failed"
+	EventLoginBlocked    = "login_blocked"
+	EventMFAFailed       = "mfa_failed"
+	EventAccountLocked   = "account_locked"
+	EventIPLocked        = "ip_locked"
+	EventProfileUpdated  = "profile_updated"
+	EventPasswordChanged = "password_changed"
+	EventAccountDeleted  = "account_deleted"
+
+	EventDataExportRequested = "data_export_requested"
+	EventAPIKeyCreated       = "api_key_created"
+	EventAPIKeyRevoked       = "api_key_revoked"
+)
+
+// Scopes of a LoginThrottle.
+const (
+	ThrottleAccount = "account"
+	ThrottleIP      = "ip"
+)
+
+// ClientInfo identifies where a request came from.
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+// SecurityEvent is an entry of the security audit log.
+type SecurityEvent struct {
+	ID        uuid.UUID  `db:"id" json:"id"`
+	Type      string     `db:"event_type" json:"type"`
+	UserID    *uuid.UUID `db:"user_id" json:"user_id,omitempty"`
+	Email     string     `db:"email" json:"email,omitempty"`
+	IP        string     `db:"ip" json:"ip,omitempty"`
+	UserAgent string     `db:"user_agent" json:"user_agent,omitempty"`
+	Detail    string     `db:"detail" json:"detail,omitempty"`
+	CreatedAt time.Time  `db:"created_at" json:"created_at"`
+}
+
+// SecurityEventFilter selects audit log entries; zero fields match all.
+type SecurityEventFilter struct {
+	Type   string
+	UserID *uuid.UUID
+	Email  string
+	IP     string
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+}
+
+// LoginThrottle counts recent failed logins of an account or IP.
+type LoginThrottle struct {
+	Scope         string     `db:"scope"`
+	Key           string     `db:"key"`
+	Failures      int        `db:"failures"`
+	LastFailureAt time.Time  `db:"last_failure_at"`
+	LockedUntil   *time.Time `db:"locked_until"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserTOTP is a user's authenticator secret. It protects logins once
+// ConfirmedAt is set.
+type UserTOTP struct {
+	UserID       uuid.UUID  `db:"user_id"`
+	Secret       string     `db:"secret"`
+	ConfirmedAt  *time.Time `db:"confirmed_at"`
+	LastUsedStep int64      `db:"last_used_step"`
+	CreatedAt    time.Time  `db:"created_at"`
+}
+
+// TOTPSetup is what the user enrols their authenticator app with.
+type TOTPSetup struct {
+	Secret string `json:"secret"`
+	// URI is the otpauth:// provisioning URI, usually shown as a QR code.
+	URI string `json:"otpauth_uri"`
+}
+
+// TwoFactorStatus tells a user whether two-factor authentication is on.
+type TwoFactorStatus struct {
+	Enabled           bool `json:"enabled"`
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+// RecoveryCodes are shown once, when they are generated.
+type RecoveryCodes struct {
+	Codes []string `json:"recovery_codes"`
+}
@@ -13,4 +13,38 @@ type User struct {
 	Email        string    `db:"email" json:"email"`
 	PasswordHash string    `db:"password_hash" json:"-"`
 	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+	// EmailVerifiedAt is nil until the user follows the verification link.
+	EmailVerifiedAt *time.Time `db:"email_verified_at" json:"email_verified_at"`
+	Roles           []string   `db:"-" json:"roles,omitempty"`
+}
+
+// Session is returned by a successful login. For users with two-factor
+// authentication the password only gets them MFAToken (valid until ExpiresAt),
+// which is exchanged for the access token together with a code.
+type Session struct {
+	Token       string    `json:"token,omitempty"`
+	TokenType   string    `json:"token_type,omitempty"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	User        *User     `json:"user,omitempty"`
+	MFARequired bool      `json:"mfa_required,omitempty"`
+	MFAToken    string    `json:"mfa_token,omitempty"`
+}
+
+// Purposes of a UserToken.
+const (
+	TokenVerifyEmail   = "verify_email"
+	TokenResetPassword = "reset_password"
+	TokenLoginMFA      = "login_mfa"
+)
+
+// UserToken is a single-use token emailed to a user, or handed out for the
+// second login step. Only its hash is stored.
+type UserToken struct {
+	ID        uuid.UUID  `db:"id"`
+	UserID    uuid.UUID  `db:"user_id"`
+	Purpose   string     `db:"purpose"`
+	TokenHash []byte     `db:"token_hash"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+	CreatedAt time.Time  `db:"created_at"`
 }
@@ -0,0 +1,175 @@
+// Package oidc signs users in through external OpenID Connect providers with
+// the authorization code flow and PKCE.
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// ErrUnknownProvider means no provider is configured under the name.
+var ErrUnknownProvider = errors.New("unknown identity provider")
+
+// ProviderConfig configures one provider.
+type ProviderConfig struct {
+	// Name identifies the provider in URLs and linked identities.
+	Name        string
+	DisplayName string
+	// Issuer is where the discovery document is fetched from and what ID
+	// tokens must name as iss.
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// RedirectURL is this service's callback as registered at the provider.
+	RedirectURL string
+	// AuthURL overrides the discovered authorization endpoint, for when the
+	// browser reaches the provider under another host than this service
+	// (e.g. a mock server inside docker compose).
+	AuthURL string
+}
+
+// Identity is what a provider asserts about the signed-in user.
+type Identity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Username      string
+}
+
+// Provider is a configured identity provider. Discovery runs on first use
+// and is retried until it succeeds, so a provider that is down at startup
+// doesn't keep the service from starting.
+type Provider struct {
+	cfg ProviderConfig
+
+	mu       sync.Mutex
+	oauth    *oauth2.Config
+	verifier *gooidc.IDTokenVerifier
+}
+
+func NewProvider(cfg ProviderConfig) *Provider {
+	return &Provider{cfg: cfg}
+}
+
+// Name returns the provider's name.
+func (p *Provider) Name() string { return p.cfg.Name }
+
+// DisplayName returns the provider's name as shown to users.
+func (p *Provider) DisplayName() string { return p.cfg.DisplayName }
+
+func (p *Provider) discover(ctx context.Context) (*oauth2.Config, *gooidc.IDTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.oauth != nil {
+		return p.oauth, p.verifier, nil
+	}
+
+	provider, err := gooidc.NewProvider(ctx, p.cfg.Issuer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("discover %s: %w", p.cfg.Name, err)
+	}
+	endpoint := provider.Endpoint()
+	if p.cfg.AuthURL != "" {
+		endpoint.AuthURL = p.cfg.AuthURL
+	}
+	p.oauth = &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		Endpoint:     endpoint,
+		RedirectURL:  p.cfg.RedirectURL,
+		Scopes:       append([]string{gooidc.ScopeOpenID}, p.cfg.Scopes...),
+	}
+	p.verifier = provider.Verifier(&gooidc.Config{ClientID: p.cfg.ClientID})
+	return p.oauth, p.verifier, nil
+}
+
+// AuthCodeURL returns the provider URL the browser is sent to. The S256
+// challenge of verifier is sent along; Exchange must present verifier.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	oauth, _, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	return oauth.AuthCodeURL(state, gooidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), nil
+}
+
+// Exchange redeems code and returns the identity in the verified ID token.
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+	oauth, idVerifier, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := oauth.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("exchange code: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("token response has no id_token")
+	}
+	idToken, err := idVerifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("verify id_token: %w", err)
+	}
+	if idToken.Nonce != nonce {
+		return nil, errors.New("id_token nonce mismatch")
+	}
+
+	var claims struct {
+		Email             string `json:"email"`
+		EmailVerified     *bool  `json:"email_verified"`
+		PreferredUsername string `json:"preferred_username"`
+		Name              string `json:"name"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("decode id_token claims: %w", err)
+	}
+
+	username := claims.PreferredUsername
+	if username == "" {
+		username = claims.Name
+	}
+	return &Identity{
+		Provider:      p.cfg.Name,
+		Subject:       idToken.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified != nil && *claims.EmailVerified,
+		Username:      username,
+	}, nil
+}
+
+// Registry holds the configured providers by name.
+type Registry struct {
+	providers []*Provider
+}
+
+func NewRegistry(cfgs []ProviderConfig) *Registry {
+	r := &Registry{}
+	for _, c := range cfgs {
+		r.providers = append(r.providers, NewProvider(c))
+	}
+	return r
+}
+
+// Get returns the provider called name.
+func (r *Registry) Get(name string) (*Provider, error) {
+	for _, p := range r.providers {
+		if p.Name() == name {
+			return p, nil
+		}
+	}
+	return nil, ErrUnknownProvider
+}
+
+// List returns every provider in configuration order.
+func (r *Registry) List() []*Provider {
+	return r.providers
+}
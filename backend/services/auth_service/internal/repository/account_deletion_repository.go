@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"auth_service/internal/models"
+
+	"contracts/events"
+	"contracts/outbox"
+)
+
+// AccountDeletionRepository defines operations we need for deleting
+// accounts and tracking the erasure of their data.
+type AccountDeletionRepository interface {
+	DeleteUser(d *models.AccountDeletion, services []string, msg outbox.Message) error
+	CompleteStep(deletionID uuid.UUID, service string, itemsDeleted int64, detail string) (*models.AccountDeletion, string, error)
+	GetDeletion(id uuid.UUID) (*models.AccountDeletion, error)
+}
+
+var (
+	// ErrOrganisationOwner means the user owns an organisation, which would
+	// be left without an owner.
+	ErrOrganisationOwner = errors.New("organisation owners must transfer ownership or delete the organisation first")
+	// ErrDeletionNotFound means there is no account deletion with the id.
+	ErrDeletionNotFound = errors.New("account deletion not found")
+)
+
+const deletionColumns = `id, user_id, email, requested_at, completed_at`
+
+// PostgresAccountDeletionRepository is a Postgres implementation of
+// AccountDeletionRepository.
+type PostgresAccountDeletionRepository struct {
+	db *sqlx.DB
+}
+
+// NewPostgresAccountDeletionRepository creates a new
+// PostgresAccountDeletionRepository
+func NewPostgresAccountDeletionRepository(db *sqlx.DB) *PostgresAccountDeletionRepository {
+	return &PostgresAccountDeletionRepository{db: db}
+}
+
+// DeleteUser deletes the user (their roles, tokens, identities, 2FA and
+// membership cascade), removes the email from the audit log and throttles,
+// and stores the deletion with a pending step per service together with its
+// user_deleted outbox event.
+func (r *PostgresAccountDeletionRepository) DeleteUser(d *models.AccountDeletion, services []string, msg outbox.Message) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var role string
+	err = tx.Get(&role, `SELECT role FROM organisation_members WHERE user_id = $1 FOR UPDATE`, d.UserID)
+	switch {
+	case err == nil && role == events.RoleOwner:
+		return ErrOrganisationOwner
+	case err != nil && !errors.Is(err, sql.ErrNoRows):
+		return fmt.Errorf("get membership: %w", err)
+	}
+
+	if _, err := tx.NamedExec(`
+		INSERT INTO account_deletions (id, user_id, email, requested_at)
+		VALUES (:id, :user_id, :email, :requested_at)`, d); err != nil {
+		return fmt.Errorf("insert account deletion: %w", err)
+	}
+	for _, service := range services {
+		if _, err := tx.Exec(`
+			INSERT INTO account_deletion_steps (deletion_id, service) VALUES ($1, $2)`,
+			d.ID, service); err != nil {
+			return fmt.Errorf("insert deletion step: %w", err)
+		}
+	}
+
+	email := strings.ToLower(strings.TrimSpace(d.Email))
+	if _, err := tx.Exec(`
+		UPDATE security_events SET email = '', user_agent = ''
+		WHERE user_id = $1 OR email = $2`, d.UserID, email); err != nil {
+		return fmt.Errorf("anonymise security events: %w", err)
+	}
+	if _, err := tx.Exec(`
+		DELETE FROM login_throttles WHERE scope = $1 AND key = $2`,
+		models.ThrottleAccount, email); err != nil {
+		return fmt.Errorf("delete login throttle: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM users WHERE id = $1`, d.UserID); err != nil {
+		return fmt.Errorf("delete user: %w", err)
+	}
+	if err := outbox.Insert(tx, msg); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+// CompleteStep records a service's purge report. A repeated report changes
+// nothing. When the last step completes, the deletion is completed and its
+// email cleared; that email is returned (once) so the report can be sent.
+func (r *PostgresAccountDeletionRepository) CompleteStep(deletionID uuid.UUID, service string, itemsDeleted int64, detail string) (*models.AccountDeletion, string, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return nil, "", fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var d models.AccountDeletion
+	if err := tx.Get(&d, `SELECT `+deletionColumns+` FROM account_deletions WHERE id = $1 FOR UPDATE`, deletionID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, "", ErrDeletionNotFound
+		}
+		return nil, "", fmt.Errorf("get account deletion: %w", err)
+	}
+
+	now := time.Now().UTC()
+	// A service missing from the expected list still gets its report kept.
+	if _, err := tx.Exec(`
+		INSERT INTO account_deletion_steps (deletion_id, service, items_deleted, detail, completed_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (deletion_id, service) DO UPDATE SET
+			items_deleted = EXCLUDED.items_deleted,
+			detail = EXCLUDED.detail,
+			completed_at = EXCLUDED.completed_at
+		WHERE account_deletion_steps.completed_at IS NULL`,
+		deletionID, service, itemsDeleted, detail, now); err != nil {
+		return nil, "", fmt.Errorf("complete deletion step: %w", err)
+	}
+
+	var pending int
+	if err := tx.Get(&pending, `
+		SELECT COUNT(*) FROM account_deletion_steps
+		WHERE deletion_id = $1 AND completed_at IS NULL`, deletionID); err != nil {
+		return nil, "", fmt.Errorf("count pending steps: %w", err)
+	}
+	var email string
+	if pending == 0 && d.CompletedAt == nil {
+		email = d.Email
+		if _, err := tx.Exec(`
+			UPDATE account_deletions SET completed_at = $2, email = ''
+			WHERE id = $1`, deletionID, now); err != nil {
+			return nil, "", fmt.Errorf("complete account deletion: %w", err)
+		}
+		d.CompletedAt, d.Email = &now, ""
+	}
+	if err := loadSteps(tx, &d); err != nil {
+		return nil, "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, "", fmt.Errorf("commit tx: %w", err)
+	}
+	return &d, email, nil
+}
+
+// GetDeletion returns the deletion with its steps.
+func (r *PostgresAccountDeletionRepository) GetDeletion(id uuid.UUID) (*models.AccountDeletion, error) {
+	var d models.AccountDeletion
+	if err := r.db.Get(&d, `SELECT `+deletionColumns+` FROM account_deletions WHERE id = $1`, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrDeletionNotFound
+		}
+		return nil, fmt.Errorf("get account deletion: %w", err)
+	}
+	if err := loadSteps(r.db, &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// loadSteps fills in the steps and the status of d.
+func loadSteps(q sqlx.Queryer, d *models.AccountDeletion) error {
+	d.Steps = []models.AccountDeletionStep{}
+	if err := sqlx.Select(q, &d.Steps, `
+		SELECT service, items_deleted, detail, completed_at
+		FROM account_deletion_steps
+		WHERE deletion_id = $1
+		ORDER BY service`, d.ID); err != nil {
+		return fmt.Errorf("list deletion steps: %w", err)
+	}
+	d.Status = models.DeletionPending
+	if d.CompletedAt != nil {
+		d.Status = models.DeletionCompleted
+	}
+	return nil
+}
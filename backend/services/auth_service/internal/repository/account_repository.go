@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"auth_service/internal/models"
+)
+
+// ErrEmailTaken means another account uses the email.
+var ErrEmailTaken = errors.New("email already in use")
+
+// UpdateProfile changes the username and/or email of the user; nil leaves a
+// field as it is. A new email is unverified until its link is followed.
+func (r *PostgresUserRepository) UpdateProfile(id uuid.UUID, username, email *string) (*models.User, error) {
+	var u models.User
+	err := r.db.Get(&u, `
+		UPDATE users SET
+			username = COALESCE($2, username),
+			email_verified_at = CASE WHEN $3::text IS NULL OR $3 = email THEN email_verified_at END,
+			email = COALESCE($3, email)
+		WHERE id = $1
+		RETURNING `+userColumns, id, username, email)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "users_username_key":
+			return nil, ErrUsernameTaken
+		case errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "users_email_key":
+			return nil, ErrEmailTaken
+		}
+		return nil, fmt.Errorf("update profile: %w", err)
+	}
+	return &u, nil
+}
+
+// ChangePassword sets the user's password and invalidates unused password
+// reset links, which were sent for the old one.
+func (r *PostgresUserRepository) ChangePassword(id uuid.UUID, passwordHash string) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE users SET password_hash = $2 WHERE id = $1`, id, passwordHash); err != nil {
+		return fmt.Errorf("change password: %w", err)
+	}
+	if _, err := tx.Exec(`
+		UPDATE user_tokens SET used_at = $3
+		WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL`,
+		id, models.TokenResetPassword, time.Now().UTC()); err != nil {
+		return fmt.Errorf("invalidate reset tokens: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
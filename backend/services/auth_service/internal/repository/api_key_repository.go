@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"auth_service/internal/models"
+)
+
+// APIKeyRepository defines operations we need for API keys.
+type APIKeyRepository interface {
+	CreateAPIKey(k *models.APIKey, maxActive int) error
+	ListAPIKeys(userID uuid.UUID) ([]models.APIKey, error)
+	RevokeAPIKey(userID, id uuid.UUID) (*models.APIKey, error)
+	GetActiveAPIKey(keyHash []byte) (*models.APIKey, error)
+	TouchAPIKey(id uuid.UUID, ip string) error
+}
+
+var (
+	// ErrAPIKeyLimit means the user already has the most active keys allowed.
+	ErrAPIKeyLimit = errors.New("too many active API keys, revoke one first")
+	// ErrAPIKeyNotFound means the key doesn't exist, isn't the user's, or is
+	// revoked or expired.
+	ErrAPIKeyNotFound = errors.New("API key not found")
+)
+
+const apiKeyColumns = `id, user_id, name, prefix, key_hash, scopes, created_at, expires_at,
+	last_used_at, last_used_ip, revoked_at`
+
+// apiKeyRow is an api_keys row; scopes are a Postgres array.
+type apiKeyRow struct {
+	models.APIKey
+	Scopes pq.StringArray `db:"scopes"`
+}
+
+func (row *apiKeyRow) key() *models.APIKey {
+	k := row.APIKey
+	k.Scopes = []string(row.Scopes)
+	return &k
+}
+
+// PostgresAPIKeyRepository is a Postgres implementation of APIKeyRepository.
+type PostgresAPIKeyRepository struct {
+	db *sqlx.DB
+}
+
+// NewPostgresAPIKeyRepository creates a new PostgresAPIKeyRepository
+func NewPostgresAPIKeyRepository(db *sqlx.DB) *PostgresAPIKeyRepository {
+	return &PostgresAPIKeyRepository{db: db}
+}
+
+// CreateAPIKey stores k unless its user already has maxActive unrevoked,
+// unexpired keys.
+func (r *PostgresAPIKeyRepository) CreateAPIKey(k *models.APIKey, maxActive int) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Serialises concurrent creations by the same user.
+	if _, err := tx.Exec(`SELECT id FROM users WHERE id = $1 FOR UPDATE`, k.UserID); err != nil {
+		return fmt.Errorf("lock user: %w", err)
+	}
+	var active int
+	if err := tx.Get(&active, `
+		SELECT COUNT(*) FROM api_keys
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2`,
+		k.UserID, time.Now().UTC()); err != nil {
+		return fmt.Errorf("count api keys: %w", err)
+	}
+	if active >= maxActive {
+		return ErrAPIKeyLimit
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO api_keys (id, user_id, name, prefix, key_hash, scopes, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		k.ID, k.UserID, k.Name, k.Prefix, k.KeyHash, pq.Array(k.Scopes), k.CreatedAt, k.ExpiresAt); err != nil {
+		return fmt.Errorf("insert api key: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+// ListAPIKeys returns all of the user's keys, newest first.
+func (r *PostgresAPIKeyRepository) ListAPIKeys(userID uuid.UUID) ([]models.APIKey, error) {
+	var rows []apiKeyRow
+	if err := r.db.Select(&rows, `
+		SELECT `+apiKeyColumns+` FROM api_keys
+		WHERE user_id = $1
+		ORDER BY created_at DESC`, userID); err != nil {
+		return nil, fmt.Errorf("list api keys: %w", err)
+	}
+	keys := make([]models.APIKey, 0, len(rows))
+	for i := range rows {
+		keys = append(keys, *rows[i].key())
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey revokes one of the user's keys. Revoking a revoked key
+// returns it unchanged.
+func (r *PostgresAPIKeyRepository) RevokeAPIKey(userID, id uuid.UUID) (*models.APIKey, error) {
+	var row apiKeyRow
+	err := r.db.Get(&row, `
+		UPDATE api_keys SET revoked_at = COALESCE(revoked_at, $3)
+		WHERE id = $1 AND user_id = $2
+		RETURNING `+apiKeyColumns, id, userID, time.Now().UTC())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("revoke api key: %w", err)
+	}
+	return row.key(), nil
+}
+
+// GetActiveAPIKey returns the unrevoked, unexpired key with the hash.
+func (r *PostgresAPIKeyRepository) GetActiveAPIKey(keyHash []byte) (*models.APIKey, error) {
+	var row apiKeyRow
+	err := r.db.Get(&row, `
+		SELECT `+apiKeyColumns+` FROM api_keys
+		WHERE key_hash = $1 AND revoked_at IS NULL AND expires_at > $2`,
+		keyHash, time.Now().UTC())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get api key: %w", err)
+	}
+	return row.key(), nil
+}
+
+// TouchAPIKey records that the key was just used from ip.
+func (r *PostgresAPIKeyRepository) TouchAPIKey(id uuid.UUID, ip string) error {
+	if _, err := r.db.Exec(`
+		UPDATE api_keys SET last_used_at = $2, last_used_ip = $3 WHERE id = $1`,
+		id, time.Now().UTC(), ip); err != nil {
+		return fmt.Errorf("touch api key: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"auth_service/internal/models"
+)
+
+// DataExportRepository defines operations we need for personal data
+// exports.
+type DataExportRepository interface {
+	CreateExport(e *models.DataExport) error
+	ListExports(userID uuid.UUID, limit int) ([]models.DataExport, error)
+	ClaimExport(staleAfter time.Duration) (*models.DataExport, error)
+	CompleteExport(id uuid.UUID, tokenHash []byte, size int64, expiresAt time.Time) error
+	FailExport(id uuid.UUID, reason string, final bool) error
+	GetDownload(id uuid.UUID, tokenHash []byte) (*models.DataExport, error)
+	ExpireExports() (int, error)
+	LiveExportIDs() ([]uuid.UUID, error)
+}
+
+var (
+	// ErrExportInProgress means the user already has an export being built.
+	ErrExportInProgress = errors.New("a data export is already in progress")
+	// ErrExportNotFound means the export doesn't exist, isn't ready, has
+	// expired or the download token doesn't match.
+	ErrExportNotFound = errors.New("data export not found or expired")
+)
+
+const exportColumns = `id, user_id, status, attempts, error, size_bytes, token_hash,
+	requested_at, started_at, completed_at, expires_at`
+
+// PostgresDataExportRepository is a Postgres implementation of
+// DataExportRepository.
+type PostgresDataExportRepository struct {
+	db *sqlx.DB
+}
+
+// NewPostgresDataExportRepository creates a new PostgresDataExportRepository
+func NewPostgresDataExportRepository(db *sqlx.DB) *PostgresDataExportRepository {
+	return &PostgresDataExportRepository{db: db}
+}
+
+// CreateExport stores a pending export.
+func (r *PostgresDataExportRepository) CreateExport(e *models.DataExport) error {
+	_, err := r.db.NamedExec(`
+		INSERT INTO data_exports (id, user_id, status, requested_at)
+		VALUES (:id, :user_id, :status, :requested_at)`, e)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "uq_data_exports_in_progress" {
+		return ErrExportInProgress
+	}
+	if err != nil {
+		return fmt.Errorf("insert data export: %w", err)
+	}
+	return nil
+}
+
+// ListExports returns the user's newest exports.
+func (r *PostgresDataExportRepository) ListExports(userID uuid.UUID, limit int) ([]models.DataExport, error) {
+	exports := []models.DataExport{}
+	if err := r.db.Select(&exports, `
+		SELECT `+exportColumns+` FROM data_exports
+		WHERE user_id = $1
+		ORDER BY requested_at DESC
+		LIMIT $2`, userID, limit); err != nil {
+		return nil, fmt.Errorf("list data exports: %w", err)
+	}
+	return exports, nil
+}
+
+// ClaimExport marks the oldest pending export running and returns it, or
+// nil if there is none. An export left running longer than staleAfter (its
+// builder died) is claimed again.
+func (r *PostgresDataExportRepository) ClaimExport(staleAfter time.Duration) (*models.DataExport, error) {
+	now := time.Now().UTC()
+	var e models.DataExport
+	err := r.db.Get(&e, `
+		UPDATE data_exports SET status = $1, attempts = attempts + 1, started_at = $2
+		WHERE id = (
+			SELECT id FROM data_exports
+			WHERE status = $3 OR (status = $1 AND started_at < $4)
+			ORDER BY requested_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+exportColumns,
+		models.ExportRunning, now, models.ExportPending, now.Add(-staleAfter))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("claim data export: %w", err)
+	}
+	return &e, nil
+}
+
+// CompleteExport marks the export ready for download until expiresAt.
+func (r *PostgresDataExportRepository) CompleteExport(id uuid.UUID, tokenHash []byte, size int64, expiresAt time.Time) error {
+	if _, err := r.db.Exec(`
+		UPDATE data_exports
+		SET status = $2, token_hash = $3, size_bytes = $4, error = '', completed_at = $5, expires_at = $6
+		WHERE id = $1`,
+		id, models.ExportReady, tokenHash, size, time.Now().UTC(), expiresAt); err != nil {
+		return fmt.Errorf("complete data export: %w", err)
+	}
+	return nil
+}
+
+// FailExport records why building the export failed. Unless final, it is
+// put back to be tried again.
+func (r *PostgresDataExportRepository) FailExport(id uuid.UUID, reason string, final bool) error {
+	status, completedAt := models.ExportPending, (*time.Time)(nil)
+	if final {
+		now := time.Now().UTC()
+		status, completedAt = models.ExportFailed, &now
+	}
+	if _, err := r.db.Exec(`
+		UPDATE data_exports SET status = $2, error = $3, completed_at = $4
+		WHERE id = $1`, id, status, reason, completedAt); err != nil {
+		return fmt.Errorf("fail data export: %w", err)
+	}
+	return nil
+}
+
+// GetDownload returns the ready, unexpired export the token was issued for.
+func (r *PostgresDataExportRepository) GetDownload(id uuid.UUID, tokenHash []byte) (*models.DataExport, error) {
+	var e models.DataExport
+	err := r.db.Get(&e, `
+		SELECT `+exportColumns+` FROM data_exports
+		WHERE id = $1 AND token_hash = $2 AND status = $3 AND expires_at > $4`,
+		id, tokenHash, models.ExportReady, time.Now().UTC())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrExportNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get data export: %w", err)
+	}
+	return &e, nil
+}
+
+// ExpireExports marks ready exports past their expiry expired and returns
+// how many there were.
+func (r *PostgresDataExportRepository) ExpireExports() (int, error) {
+	res, err := r.db.Exec(`
+		UPDATE data_exports SET status = $1, token_hash = NULL
+		WHERE status = $2 AND expires_at <= $3`,
+		models.ExportExpired, models.ExportReady, time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("expire data exports: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// LiveExportIDs returns the exports whose archives must be kept: those
+// being built or ready for download.
+func (r *PostgresDataExportRepository) LiveExportIDs() ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	if err := r.db.Select(&ids, `
+		SELECT id FROM data_exports WHERE status IN ($1, $2)`,
+		models.ExportRunning, models.ExportReady); err != nil {
+		return nil, fmt.Errorf("list live data exports: %w", err)
+	}
+	return ids, nil
+}
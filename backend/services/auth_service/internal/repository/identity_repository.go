@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"auth_service/internal/models"
+
+	"contracts/outbox"
+)
+
+// IdentityRepository defines operations we need for external sign-in.
+type IdentityRepository interface {
+	SaveLoginState(st *models.OIDCLoginState) error
+	TakeLoginState(state string) (*models.OIDCLoginState, error)
+	GetIdentityUser(provider, subject string) (*models.User, error)
+	LinkIdentity(id *models.UserIdentity) error
+	CreateUserWithIdentity(u *models.User, id *models.UserIdentity, msg outbox.Message) (*models.User, error)
+	ListIdentities(userID uuid.UUID) ([]models.UserIdentity, error)
+}
+
+var (
+	// ErrLoginStateNotFound means the callback's state is unknown, expired
+	// or was already used.
+	ErrLoginStateNotFound = errors.New("sign-in expired or already completed")
+	// ErrIdentityNotFound means the external account is not linked yet.
+	ErrIdentityNotFound = errors.New("identity not linked")
+	// ErrUsernameTaken means the username is already in use.
+	ErrUsernameTaken = errors.New("username already taken")
+)
+
+// PostgresIdentityRepository is a Postgres implementation of
+// IdentityRepository.
+type PostgresIdentityRepository struct {
+	db *sqlx.DB
+}
+
+// NewPostgresIdentityRepository creates a new PostgresIdentityRepository
+func NewPostgresIdentityRepository(db *sqlx.DB) *PostgresIdentityRepository {
+	return &PostgresIdentityRepository{db: db}
+}
+
+// SaveLoginState stores a new sign-in and drops expired ones.
+func (r *PostgresIdentityRepository) SaveLoginState(st *models.OIDCLoginState) error {
+	if _, err := r.db.Exec(`DELETE FROM oidc_login_states WHERE expires_at <= $1`, time.Now().UTC()); err != nil {
+		return fmt.Errorf("delete expired login states: %w", err)
+	}
+	_, err := r.db.NamedExec(`
+		INSERT INTO oidc_login_states (state, provider, code_verifier, nonce, expires_at)
+		VALUES (:state, :provider, :code_verifier, :nonce, :expires_at)`, st)
+	if err != nil {
+		return fmt.Errorf("insert login state: %w", err)
+	}
+	return nil
+}
+
+// TakeLoginState removes and returns the sign-in for state, so a callback
+// can only be completed once.
+func (r *PostgresIdentityRepository) TakeLoginState(state string) (*models.OIDCLoginState, error) {
+	var st models.OIDCLoginState
+	err := r.db.Get(&st, `
+		DELETE FROM oidc_login_states WHERE state = $1
+		RETURNING state, provider, code_verifier, nonce, expires_at`, state)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrLoginStateNotFound
+		}
+		return nil, fmt.Errorf("take login state: %w", err)
+	}
+	if !st.ExpiresAt.After(time.Now().UTC()) {
+		return nil, ErrLoginStateNotFound
+	}
+	return &st, nil
+}
+
+// GetIdentityUser returns the user the external account is linked to and
+// records the sign-in.
+func (r *PostgresIdentityRepository) GetIdentityUser(provider, subject string) (*models.User, error) {
+	var u models.User
+	err := r.db.Get(&u, `
+		WITH touched AS (
+			UPDATE user_identities SET last_login_at = $3
+			WHERE provider = $1 AND subject = $2
+			RETURNING user_id
+		)
+		SELECT `+userColumns+` FROM users WHERE id IN (SELECT user_id FROM touched)`,
+		provider, subject, time.Now().UTC())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrIdentityNotFound
+		}
+		return nil, fmt.Errorf("get identity user: %w", err)
+	}
+	return &u, nil
+}
+
+// LinkIdentity links the external account to an existing user. The provider
+// verified the email, so the user's email counts as verified too. If it
+// wasn't verified before, whoever registered the address may not own it, so
+// their password is dropped; the owner can set one with a password reset.
+func (r *PostgresIdentityRepository) LinkIdentity(id *models.UserIdentity) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertIdentity(tx, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		UPDATE users SET
+			password_hash = CASE WHEN email_verified_at IS NULL THEN '' ELSE password_hash END,
+			email_verified_at = COALESCE(email_verified_at, $2)
+		WHERE id = $1`, id.UserID, id.CreatedAt); err != nil {
+		return fmt.Errorf("verify email: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+// CreateUserWithIdentity inserts a user signing in through a provider for
+// the first time, its identity and its outbox event in one transaction.
+func (r *PostgresIdentityRepository) CreateUserWithIdentity(u *models.User, id *models.UserIdentity, msg outbox.Message) (*models.User, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := insertUser(tx, u); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "users_username_key" {
+			return nil, ErrUsernameTaken
+		}
+		return nil, err
+	}
+	id.UserID = u.ID
+	if err := insertIdentity(tx, id); err != nil {
+		return nil, err
+	}
+	if err := outbox.Insert(tx, msg); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+	return u, nil
+}
+
+// ListIdentities returns the external accounts linked to the user.
+func (r *PostgresIdentityRepository) ListIdentities(userID uuid.UUID) ([]models.UserIdentity, error) {
+	identities := []models.UserIdentity{}
+	if err := r.db.Select(&identities, `
+		SELECT provider, subject, user_id, email, created_at, last_login_at
+		FROM user_identities
+		WHERE user_id = $1
+		ORDER BY created_at`, userID); err != nil {
+		return nil, fmt.Errorf("list identities: %w", err)
+	}
+	return identities, nil
+}
+
+func insertIdentity(tx *sqlx.Tx, id *models.UserIdentity) error {
+	now := time.Now().UTC()
+	id.CreatedAt, id.LastLoginAt = now, now
+	_, err := tx.NamedExec(`
+		INSERT INTO user_identities (provider, subject, user_id, email, created_at, last_login_at)
+		VALUES (:provider, :subject, :user_id, :email, :created_at, :last_login_at)`, id)
+	if err != nil {
+		return fmt.Errorf("insert identity: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"auth_service/internal/models"
+
+	"contracts/events"
+	"contracts/outbox"
+)
+
+// OrganisationRepository defines operations we need for organisations and
+// their members. Every change is stored together with its outbox events.
+type OrganisationRepository interface {
+	CreateOrganisation(org *models.Organisation, ownerID uuid.UUID, msgs ...outbox.Message) error
+	GetOrganisation(id uuid.UUID) (*models.Organisation, error)
+	DeleteOrganisation(id uuid.UUID, msgs ...outbox.Message) error
+	ListMembers(orgID uuid.UUID) ([]models.OrganisationMember, error)
+	GetMembership(userID uuid.UUID) (*models.Membership, error)
+	AddMember(orgID, userID uuid.UUID, role string, msgs ...outbox.Message) error
+	SetMemberRole(orgID, userID uuid.UUID, role string, msgs ...outbox.Message) error
+	TransferOwnership(orgID, fromID, toID uuid.UUID, msgs ...outbox.Message) error
+	RemoveMember(orgID, userID uuid.UUID, msgs ...outbox.Message) error
+}
+
+var (
+	// ErrOrganisationNotFound means no organisation exists with the given id.
+	ErrOrganisationNotFound = errors.New("organisation not found")
+	// ErrNotMember means the user is not a member of the organisation.
+	ErrNotMember = errors.New("user is not a member of the organisation")
+	// ErrAlreadyMember means the user already belongs to an organisation.
+	ErrAlreadyMember = errors.New("user already belongs to an organisation")
+)
+
+// PostgresOrganisationRepository is a Postgres implementation of
+// OrganisationRepository.
+type PostgresOrganisationRepository struct {
+	db *sqlx.DB
+}
+
+// NewPostgresOrganisationRepository creates a new PostgresOrganisationRepository
+func NewPostgresOrganisationRepository(db *sqlx.DB) *PostgresOrganisationRepository {
+	return &PostgresOrganisationRepository{db: db}
+}
+
+// CreateOrganisation inserts org with ownerID as its owner.
+func (r *PostgresOrganisationRepository) CreateOrganisation(org *models.Organisation, ownerID uuid.UUID, msgs ...outbox.Message) error {
+	return r.inTx(func(tx *sqlx.Tx) error {
+		if org.ID == uuid.Nil {
+			org.ID = uuid.New()
+		}
+		now := time.Now().UTC()
+		org.CreatedAt, org.UpdatedAt = now, now
+
+		if _, err := tx.NamedExec(`
+			INSERT INTO organisations (id, name, created_at, updated_at)
+			VALUES (:id, :name, :created_at, :updated_at)`, org); err != nil {
+			return fmt.Errorf("insert organisation: %w", err)
+		}
+		return insertMember(tx, org.ID, ownerID, events.RoleOwner)
+	}, msgs)
+}
+
+// GetOrganisation fetches an organisation by id
+func (r *PostgresOrganisationRepository) GetOrganisation(id uuid.UUID) (*models.Organisation, error) {
+	var org models.Organisation
+	query := `SELECT id, name, created_at, updated_at FROM organisations WHERE id=$1`
+	if err := r.db.Get(&org, query, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrOrganisationNotFound
+		}
+		return nil, fmt.Errorf("get organisation: %w", err)
+	}
+	return &org, nil
+}
+
+// DeleteOrganisation deletes the organisation and all its memberships.
+func (r *PostgresOrganisationRepository) DeleteOrganisation(id uuid.UUID, msgs ...outbox.Message) error {
+	return r.inTx(func(tx *sqlx.Tx) error {
+		res, err := tx.Exec(`DELETE FROM organisations WHERE id=$1`, id)
+		if err != nil {
+			return fmt.Errorf("delete organisation: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return ErrOrganisationNotFound
+		}
+		return nil
+	}, msgs)
+}
+
+// ListMembers returns the members of the organisation, owner first.
+func (r *PostgresOrganisationRepository) ListMembers(orgID uuid.UUID) ([]models.OrganisationMember, error) {
+	members := []models.OrganisationMember{}
+	query := `
+		SELECT m.organisation_id, m.user_id, u.username, u.email, m.role, m.created_at
+		FROM organisation_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.organisation_id = $1
+		ORDER BY CASE m.role WHEN 'owner' THEN 0 WHEN 'admin' THEN 1 ELSE 2 END, m.created_at`
+	if err := r.db.Select(&members, query, orgID); err != nil {
+		return nil, fmt.Errorf("list organisation members: %w", err)
+	}
+	return members, nil
+}
+
+// GetMembership returns the organisation the user belongs to, or ErrNotMember.
+func (r *PostgresOrganisationRepository) GetMembership(userID uuid.UUID) (*models.Membership, error) {
+	var m models.Membership
+	query := `
+		SELECT m.organisation_id, o.name AS organisation_name, m.role
+		FROM organisation_members m
+		JOIN organisations o ON o.id = m.organisation_id
+		WHERE m.user_id = $1`
+	if err := r.db.Get(&m, query, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotMember
+		}
+		return nil, fmt.Errorf("get membership: %w", err)
+	}
+	return &m, nil
+}
+
+// AddMember adds the user to the organisation with role.
+func (r *PostgresOrganisationRepository) AddMember(orgID, userID uuid.UUID, role string, msgs ...outbox.Message) error {
+	return r.inTx(func(tx *sqlx.Tx) error {
+		return insertMember(tx, orgID, userID, role)
+	}, msgs)
+}
+
+// SetMemberRole changes the role of a member. Ownership moves with
+// TransferOwnership instead.
+func (r *PostgresOrganisationRepository) SetMemberRole(orgID, userID uuid.UUID, role string, msgs ...outbox.Message) error {
+	return r.inTx(func(tx *sqlx.Tx) error {
+		return updateRole(tx, orgID, userID, role)
+	}, msgs)
+}
+
+// TransferOwnership makes toID the owner and demotes fromID to admin.
+func (r *PostgresOrganisationRepository) TransferOwnership(orgID, fromID, toID uuid.UUID, msgs ...outbox.Message) error {
+	return r.inTx(func(tx *sqlx.Tx) error {
+		// Demote first: uq_organisation_owner allows one owner at a time.
+		if err := updateRole(tx, orgID, fromID, events.RoleAdmin); err != nil {
+			return err
+		}
+		return updateRole(tx, orgID, toID, events.RoleOwner)
+	}, msgs)
+}
+
+// RemoveMember removes the user from the organisation.
+func (r *PostgresOrganisationRepository) RemoveMember(orgID, userID uuid.UUID, msgs ...outbox.Message) error {
+	return r.inTx(func(tx *sqlx.Tx) error {
+		res, err := tx.Exec(`DELETE FROM organisation_members WHERE organisation_id=$1 AND user_id=$2`, orgID, userID)
+		if err != nil {
+			return fmt.Errorf("remove member: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return ErrNotMember
+		}
+		return nil
+	}, msgs)
+}
+
+// inTx runs fn and inserts msgs into the outbox in one transaction.
+func (r *PostgresOrganisationRepository) inTx(fn func(tx *sqlx.Tx) error, msgs []outbox.Message) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	for _, msg := range msgs {
+		if err := outbox.Insert(tx, msg); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+func insertMember(tx *sqlx.Tx, orgID, userID uuid.UUID, role string) error {
+	_, err := tx.Exec(`
+		INSERT INTO organisation_members (organisation_id, user_id, role, created_at)
+		VALUES ($1, $2, $3, $4)`,
+		orgID, userID, role, time.Now().UTC())
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrAlreadyMember
+		}
+		return fmt.Errorf("insert member: %w", err)
+	}
+	return nil
+}
+
+func updateRole(tx *sqlx.Tx, orgID, userID uuid.UUID, role string) error {
+	res, err := tx.Exec(`UPDATE organisation_members SET role=$3 WHERE organisation_id=$1 AND user_id=$2`,
+		orgID, userID, role)
+	if err != nil {
+		return fmt.Errorf("update member role: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotMember
+	}
+	return nil
+}
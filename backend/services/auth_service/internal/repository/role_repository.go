@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"auth_service/internal/models"
+
+	"contracts/auth"
+)
+
+// RoleRepository defines operations we need for roles and permissions.
+type RoleRepository interface {
+	ListRoles() ([]models.Role, error)
+	GetUserRoles(userID uuid.UUID) (*models.UserRoles, error)
+	GrantRole(userID uuid.UUID, role string) error
+	SetUserRoles(userID uuid.UUID, roles []string) (*models.UserRoles, error)
+}
+
+// ErrUnknownRole means a role name is not in the roles table.
+var ErrUnknownRole = errors.New("unknown role")
+
+// PostgresRoleRepository is a Postgres implementation of RoleRepository.
+type PostgresRoleRepository struct {
+	db *sqlx.DB
+}
+
+// NewPostgresRoleRepository creates a new PostgresRoleRepository
+func NewPostgresRoleRepository(db *sqlx.DB) *PostgresRoleRepository {
+	return &PostgresRoleRepository{db: db}
+}
+
+// ListRoles returns every role with its permissions.
+func (r *PostgresRoleRepository) ListRoles() ([]models.Role, error) {
+	var roles []models.Role
+	if err := r.db.Select(&roles, `SELECT name, description FROM roles ORDER BY name`); err != nil {
+		return nil, fmt.Errorf("list roles: %w", err)
+	}
+
+	var grants []struct {
+		Role       string `db:"role"`
+		Permission string `db:"permission"`
+	}
+	if err := r.db.Select(&grants, `SELECT role, permission FROM role_permissions ORDER BY permission`); err != nil {
+		return nil, fmt.Errorf("list role permissions: %w", err)
+	}
+	for i := range roles {
+		roles[i].Permissions = []string{}
+		for _, g := range grants {
+			if g.Role == roles[i].Name {
+				roles[i].Permissions = append(roles[i].Permissions, g.Permission)
+			}
+		}
+	}
+	return roles, nil
+}
+
+// GetUserRoles returns the user's roles and the permissions they grant.
+func (r *PostgresRoleRepository) GetUserRoles(userID uuid.UUID) (*models.UserRoles, error) {
+	return getUserRoles(r.db, userID)
+}
+
+func getUserRoles(q sqlx.Queryer, userID uuid.UUID) (*models.UserRoles, error) {
+	ur := &models.UserRoles{Roles: []string{}, Permissions: []string{}}
+	if err := sqlx.Select(q, &ur.Roles, `SELECT role FROM user_roles WHERE user_id=$1 ORDER BY role`, userID); err != nil {
+		return nil, fmt.Errorf("get user roles: %w", err)
+	}
+	err := sqlx.Select(q, &ur.Permissions, `
+		SELECT DISTINCT p.permission
+		FROM user_roles u
+		JOIN role_permissions p ON p.role = u.role
+		WHERE u.user_id=$1
+		ORDER BY p.permission`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get user permissions: %w", err)
+	}
+	return ur, nil
+}
+
+// GrantRole gives the user role; granting a role they have is a no-op.
+func (r *PostgresRoleRepository) GrantRole(userID uuid.UUID, role string) error {
+	return grantRole(r.db, userID, role)
+}
+
+func grantRole(q sqlx.Execer, userID uuid.UUID, role string) error {
+	_, err := q.Exec(`INSERT INTO user_roles (user_id, role) VALUES ($1, $2) ON CONFLICT DO NOTHING`, userID, role)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" && pqErr.Constraint == "user_roles_role_fkey" {
+			return fmt.Errorf("%w: %s", ErrUnknownRole, role)
+		}
+		return fmt.Errorf("grant role: %w", err)
+	}
+	return nil
+}
+
+// SetUserRoles replaces the user's roles with roles. RoleUser is always kept.
+func (r *PostgresRoleRepository) SetUserRoles(userID uuid.UUID, roles []string) (*models.UserRoles, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	roles = append(roles, auth.RoleUser)
+	if _, err := tx.Exec(`DELETE FROM user_roles WHERE user_id=$1 AND role <> ALL($2)`, userID, pq.Array(roles)); err != nil {
+		return nil, fmt.Errorf("revoke roles: %w", err)
+	}
+	for _, role := range roles {
+		if err := grantRole(tx, userID, role); err != nil {
+			return nil, err
+		}
+	}
+	ur, err := getUserRoles(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+	return ur, nil
+}
@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"auth_service/internal/models"
+)
+
+// SecurityRepository defines operations we need for login throttling and the
+// security audit log.
+type SecurityRepository interface {
+	GetThrottle(scope, key string) (*models.LoginThrottle, error)
+	RecordFailure(scope, key string, window time.Duration) (*models.LoginThrottle, error)
+	Lock(scope, key string, until time.Time) error
+	ClearThrottle(scope, key string) error
+	RecordEvent(ev *models.SecurityEvent) error
+	ListEvents(f models.SecurityEventFilter) ([]models.SecurityEvent, error)
+}
+
+// PostgresSecurityRepository is a Postgres implementation of
+// SecurityRepository.
+type PostgresSecurityRepository struct {
+	db *sqlx.DB
+}
+
+// NewPostgresSecurityRepository creates a new PostgresSecurityRepository
+func NewPostgresSecurityRepository(db *sqlx.DB) *PostgresSecurityRepository {
+	return &PostgresSecurityRepository{db: db}
+}
+
+// GetThrottle returns the failures recorded for key, or nil if there are none.
+func (r *PostgresSecurityRepository) GetThrottle(scope, key string) (*models.LoginThrottle, error) {
+	var t models.LoginThrottle
+	err := r.db.Get(&t, `
+		SELECT scope, key, failures, last_failure_at, locked_until
+		FROM login_throttles WHERE scope = $1 AND key = $2`, scope, key)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get throttle: %w", err)
+	}
+	return &t, nil
+}
+
+// RecordFailure counts a failed login for key. Failures older than window are
+// forgotten, so the count starts over.
+func (r *PostgresSecurityRepository) RecordFailure(scope, key string, window time.Duration) (*models.LoginThrottle, error) {
+	now := time.Now().UTC()
+	var t models.LoginThrottle
+	err := r.db.Get(&t, `
+		INSERT INTO login_throttles (scope, key, failures, last_failure_at)
+		VALUES ($1, $2, 1, $3)
+		ON CONFLICT (scope, key) DO UPDATE SET
+			failures = CASE WHEN login_throttles.last_failure_at < $4 THEN 1
+			                ELSE login_throttles.failures + 1 END,
+			last_failure_at = EXCLUDED.last_failure_at
+		RETURNING scope, key, failures, last_failure_at, locked_until`,
+		scope, key, now, now.Add(-window))
+	if err != nil {
+		return nil, fmt.Errorf("record failure: %w", err)
+	}
+	return &t, nil
+}
+
+// Lock blocks key until the given time and starts its count over.
+func (r *PostgresSecurityRepository) Lock(scope, key string, until time.Time) error {
+	_, err := r.db.Exec(`
+		UPDATE login_throttles SET locked_until = $3, failures = 0
+		WHERE scope = $1 AND key = $2`, scope, key, until)
+	if err != nil {
+		return fmt.Errorf("lock: %w", err)
+	}
+	return nil
+}
+
+// ClearThrottle forgets the failures of key.
+func (r *PostgresSecurityRepository) ClearThrottle(scope, key string) error {
+	if _, err := r.db.Exec(`DELETE FROM login_throttles WHERE scope = $1 AND key = $2`, scope, key); err != nil {
+		return fmt.Errorf("clear throttle: %w", err)
+	}
+	return nil
+}
+
+// RecordEvent appends ev to the audit log.
+func (r *PostgresSecurityRepository) RecordEvent(ev *models.SecurityEvent) error {
+	if ev.ID == uuid.Nil {
+		ev.ID = uuid.New()
+	}
+	ev.CreatedAt = time.Now().UTC()
+	_, err := r.db.NamedExec(`
+		INSERT INTO security_events (id, event_type, user_id, email, ip, user_agent, detail, created_at)
+		VALUES (:id, :event_type, :user_id, :email, :ip, :user_agent, :detail, :created_at)`, ev)
+	if err != nil {
+		return fmt.Errorf("insert security event: %w", err)
+	}
+	return nil
+}
+
+// ListEvents returns the newest audit log entries matching f.
+func (r *PostgresSecurityRepository) ListEvents(f models.SecurityEventFilter) ([]models.SecurityEvent, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+	if f.Type != "" {
+		add("event_type = $%d", f.Type)
+	}
+	if f.UserID != nil {
+		add("user_id = $%d", *f.UserID)
+	}
+	if f.Email != "" {
+		add("email = $%d", strings.ToLower(f.Email))
+	}
+	if f.IP != "" {
+		add("ip = $%d", f.IP)
+	}
+	if !f.Since.IsZero() {
+		add("created_at >= $%d", f.Since)
+	}
+	if !f.Until.IsZero() {
+		add("created_at < $%d", f.Until)
+	}
+
+	query := `SELECT id, event_type, user_id, email, ip, user_agent, detail, created_at FROM security_events`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	args = append(args, f.Limit)
+	query += fmt.Sprintf(` ORDER BY created_at DESC LIMIT $%d`, len(args))
+
+	events := []models.SecurityEvent{}
+	if err := r.db.Select(&events, query, args...); err != nil {
+		return nil, fmt.Errorf("list security events: %w", err)
+	}
+	return events, nil
+}
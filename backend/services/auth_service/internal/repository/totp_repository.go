@@ -0,0 +1,256 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"auth_service/internal/models"
+)
+
+// TOTPRepository defines operations we need for two-factor authentication.
+type TOTPRepository interface {
+	SaveTOTPSecret(userID uuid.UUID, secret string) error
+	GetTOTP(userID uuid.UUID) (*models.UserTOTP, error)
+	EnableTOTP(userID uuid.UUID, step int64, codeHashes [][]byte) error
+	UseTOTPStep(userID uuid.UUID, step int64) error
+	DisableTOTP(userID uuid.UUID) error
+	ReplaceRecoveryCodes(userID uuid.UUID, codeHashes [][]byte) error
+	UseRecoveryCode(userID uuid.UUID, codeHash []byte) error
+	CountRecoveryCodes(userID uuid.UUID) (int, error)
+	GetMFAChallenge(tokenHash []byte) (uuid.UUID, error)
+	FailMFAChallenge(tokenHash []byte, maxAttempts int) error
+	UseMFAChallenge(tokenHash []byte) error
+}
+
+var (
+	// ErrTOTPNotFound means the user has no authenticator secret.
+	ErrTOTPNotFound = errors.New("two-factor authentication not set up")
+	// ErrTOTPConfirmed means two-factor authentication is already on.
+	ErrTOTPConfirmed = errors.New("two-factor authentication already enabled")
+	// ErrCodeUsed means the code (or a later one) was already accepted.
+	ErrCodeUsed = errors.New("code already used")
+)
+
+// PostgresTOTPRepository is a Postgres implementation of TOTPRepository.
+type PostgresTOTPRepository struct {
+	db *sqlx.DB
+}
+
+// NewPostgresTOTPRepository creates a new PostgresTOTPRepository
+func NewPostgresTOTPRepository(db *sqlx.DB) *PostgresTOTPRepository {
+	return &PostgresTOTPRepository{db: db}
+}
+
+// SaveTOTPSecret stores a new, unconfirmed secret, replacing an earlier
+// unconfirmed one. A confirmed secret is never replaced.
+func (r *PostgresTOTPRepository) SaveTOTPSecret(userID uuid.UUID, secret string) error {
+	res, err := r.db.Exec(`
+		INSERT INTO user_totp (user_id, secret, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, last_used_step = 0, created_at = EXCLUDED.created_at
+		WHERE user_totp.confirmed_at IS NULL`,
+		userID, secret, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("save totp secret: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrTOTPConfirmed
+	}
+	return nil
+}
+
+// GetTOTP returns the user's secret, confirmed or not.
+func (r *PostgresTOTPRepository) GetTOTP(userID uuid.UUID) (*models.UserTOTP, error) {
+	var t models.UserTOTP
+	err := r.db.Get(&t, `
+		SELECT user_id, secret, confirmed_at, last_used_step, created_at
+		FROM user_totp WHERE user_id = $1`, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTOTPNotFound
+		}
+		return nil, fmt.Errorf("get totp: %w", err)
+	}
+	return &t, nil
+}
+
+// EnableTOTP confirms the secret with the code's time step and stores the
+// first recovery codes.
+func (r *PostgresTOTPRepository) EnableTOTP(userID uuid.UUID, step int64, codeHashes [][]byte) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE user_totp SET confirmed_at = $2, last_used_step = $3
+		WHERE user_id = $1 AND confirmed_at IS NULL`,
+		userID, time.Now().UTC(), step)
+	if err != nil {
+		return fmt.Errorf("confirm totp: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrTOTPConfirmed
+	}
+	if err := replaceRecoveryCodes(tx, userID, codeHashes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+// UseTOTPStep records that a code for step was accepted. Of two concurrent
+// logins with the same code only one succeeds.
+func (r *PostgresTOTPRepository) UseTOTPStep(userID uuid.UUID, step int64) error {
+	res, err := r.db.Exec(`
+		UPDATE user_totp SET last_used_step = $2
+		WHERE user_id = $1 AND confirmed_at IS NOT NULL AND last_used_step < $2`,
+		userID, step)
+	if err != nil {
+		return fmt.Errorf("use totp step: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrCodeUsed
+	}
+	return nil
+}
+
+// DisableTOTP removes the secret and the recovery codes.
+func (r *PostgresTOTPRepository) DisableTOTP(userID uuid.UUID) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("delete recovery codes: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM user_totp WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("delete totp: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+// ReplaceRecoveryCodes invalidates the user's recovery codes and stores new
+// ones.
+func (r *PostgresTOTPRepository) ReplaceRecoveryCodes(userID uuid.UUID, codeHashes [][]byte) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(tx, userID, codeHashes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+// UseRecoveryCode marks an unused recovery code used.
+func (r *PostgresTOTPRepository) UseRecoveryCode(userID uuid.UUID, codeHash []byte) error {
+	res, err := r.db.Exec(`
+		UPDATE user_recovery_codes SET used_at = $3
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`,
+		userID, codeHash, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("use recovery code: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrCodeUsed
+	}
+	return nil
+}
+
+// CountRecoveryCodes returns how many unused recovery codes the user has.
+func (r *PostgresTOTPRepository) CountRecoveryCodes(userID uuid.UUID) (int, error) {
+	var n int
+	err := r.db.Get(&n, `
+		SELECT COUNT(*) FROM user_recovery_codes
+		WHERE user_id = $1 AND used_at IS NULL`, userID)
+	if err != nil {
+		return 0, fmt.Errorf("count recovery codes: %w", err)
+	}
+	return n, nil
+}
+
+// GetMFAChallenge returns the user a pending second login step belongs to.
+func (r *PostgresTOTPRepository) GetMFAChallenge(tokenHash []byte) (uuid.UUID, error) {
+	var userID uuid.UUID
+	err := r.db.Get(&userID, `
+		SELECT user_id FROM user_tokens
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > $3`,
+		tokenHash, models.TokenLoginMFA, time.Now().UTC())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return uuid.Nil, ErrInvalidToken
+		}
+		return uuid.Nil, fmt.Errorf("get mfa challenge: %w", err)
+	}
+	return userID, nil
+}
+
+// FailMFAChallenge counts a wrong code; after maxAttempts the challenge is
+// used up and the user has to log in again.
+func (r *PostgresTOTPRepository) FailMFAChallenge(tokenHash []byte, maxAttempts int) error {
+	_, err := r.db.Exec(`
+		UPDATE user_tokens SET
+			attempts = attempts + 1,
+			used_at = CASE WHEN attempts + 1 >= $3 THEN $4 ELSE used_at END
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL`,
+		tokenHash, models.TokenLoginMFA, maxAttempts, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("fail mfa challenge: %w", err)
+	}
+	return nil
+}
+
+// UseMFAChallenge completes a second login step. Of two concurrent requests
+// only one succeeds.
+func (r *PostgresTOTPRepository) UseMFAChallenge(tokenHash []byte) error {
+	now := time.Now().UTC()
+	res, err := r.db.Exec(`
+		UPDATE user_tokens SET used_at = $3
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > $3`,
+		tokenHash, models.TokenLoginMFA, now)
+	if err != nil {
+		return fmt.Errorf("use mfa challenge: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+func replaceRecoveryCodes(tx *sqlx.Tx, userID uuid.UUID, codeHashes [][]byte) error {
+	if _, err := tx.Exec(`DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("delete recovery codes: %w", err)
+	}
+	now := time.Now().UTC()
+	for _, h := range codeHashes {
+		if _, err := tx.Exec(`
+			INSERT INTO user_recovery_codes (id, user_id, code_hash, created_at)
+			VALUES ($1, $2, $3, $4)`, uuid.New(), userID, h, now); err != nil {
+			return fmt.Errorf("insert recovery code: %w", err)
+		}
+	}
+	return nil
+}
@@ -8,15 +8,26 @@ import (
 	"github.com/jmoiron/sqlx"
 
 	"auth_service/internal/models"
+
+	"contracts/auth"
+	"contracts/outbox"
 )
 
 // UserRepository defines operations we need for users.
 type UserRepository interface {
 	CreateUser(u *models.User) (*models.User, error)
+	CreateUserWithEvent(u *models.User, msg outbox.Message) (*models.User, error)
 	GetByEmail(email string) (*models.User, error)
 	GetByID(id uuid.UUID) (*models.User, error)
+	CreateToken(t *models.UserToken) error
+	VerifyEmail(tokenHash []byte) (*models.User, error)
+	ResetPassword(tokenHash []byte, passwordHash string) (*models.User, error)
+	UpdateProfile(id uuid.UUID, username, email *string) (*models.User, error)
+	ChangePassword(id uuid.UUID, passwordHash string) error
 }
 
+const userColumns = `id, username, email, password_hash, created_at, email_verified_at`
+
 // PostgresUserRepository is a Postgres implementation of UserRepository.
 type PostgresUserRepository struct {
 	db *sqlx.DB
@@ -30,6 +41,32 @@ func NewPostgresUserRepository(db *sqlx.DB) *PostgresUserRepository {
 // CreateUser inserts a new user into the database.
 // Expects u.PasswordHash to be already set (hashed).
 func (r *PostgresUserRepository) CreateUser(u *models.User) (*models.User, error) {
+	return insertUser(r.db, u)
+}
+
+// CreateUserWithEvent inserts the user and its outbox event in one
+// transaction, so the event exists if and only if the user does.
+func (r *PostgresUserRepository) CreateUserWithEvent(u *models.User, msg outbox.Message) (*models.User, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := insertUser(tx, u); err != nil {
+		return nil, err
+	}
+	if err := outbox.Insert(tx, msg); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+	return u, nil
+}
+
+func insertUser(q sqlx.Ext, u *models.User) (*models.User, error) {
 	if u.ID == uuid.Nil {
 		u.ID = uuid.New()
 	}
@@ -40,13 +77,13 @@ func (r *PostgresUserRepository) CreateUser(u *models.User) (*models.User, error
 	}
 
 	query := `
-		INSERT INTO users (id, username, email, password_hash, created_at)
-		VALUES (:id, :username, :email, :password_hash, :created_at)
+		INSERT INTO users (id, username, email, password_hash, created_at, email_verified_at)
+		VALUES (:id, :username, :email, :password_hash, :created_at, :email_verified_at)
 		RETURNING id, created_at
 	`
 
 	// Use NamedQuery to bind struct fields to named params
-	rows, err := r.db.NamedQuery(query, u)
+	rows, err := sqlx.NamedQuery(q, query, u)
 	if err != nil {
 		return nil, fmt.Errorf("insert user: %w", err)
 	}
@@ -61,6 +98,13 @@ func (r *PostgresUserRepository) CreateUser(u *models.User) (*models.User, error
 		u.ID = returnedID
 		u.CreatedAt = createdAt
 	}
+	rows.Close()
+
+	// Every user starts with the user role.
+	if err := grantRole(q, u.ID, auth.RoleUser); err != nil {
+		return nil, err
+	}
+	u.Roles = []string{auth.RoleUser}
 
 	return u, nil
 }
@@ -68,7 +112,7 @@ func (r *PostgresUserRepository) CreateUser(u *models.User) (*models.User, error
 // GetByEmail fetches a user by email
 func (r *PostgresUserRepository) GetByEmail(email string) (*models.User, error) {
 	var u models.User
-	query := `SELECT id, username, email, password_hash, created_at FROM users WHERE email=$1 LIMIT 1`
+	query := `SELECT ` + userColumns + ` FROM users WHERE email=$1 LIMIT 1`
 	if err := r.db.Get(&u, query, email); err != nil {
 		return nil, fmt.Errorf("get user by email: %w", err)
 	}
@@ -78,7 +122,7 @@ func (r *PostgresUserRepository) GetByEmail(email string) (*models.User, error)
 // GetByID fetches a user by id
 func (r *PostgresUserRepository) GetByID(id uuid.UUID) (*models.User, error) {
 	var u models.User
-	query := `SELECT id, username, email, password_hash, created_at FROM users WHERE id=$1 LIMIT 1`
+	query := `SELECT ` + userColumns + ` FROM users WHERE id=$1 LIMIT 1`
 	if err := r.db.Get(&u, query, id); err != nil {
 		return nil, fmt.Errorf("get user by id: %w", err)
 	}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"auth_service/internal/models"
+)
+
+// ErrInvalidToken means an emailed token is unknown, expired or already used.
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// CreateToken stores t and invalidates the user's earlier unused tokens for
+// the same purpose, so only the latest email works.
+func (r *PostgresUserRepository) CreateToken(t *models.UserToken) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	now := time.Now().UTC()
+	t.CreatedAt = now
+
+	if _, err := tx.Exec(`
+		UPDATE user_tokens SET used_at = $3
+		WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL`,
+		t.UserID, t.Purpose, now); err != nil {
+		return fmt.Errorf("invalidate tokens: %w", err)
+	}
+	if _, err := tx.NamedExec(`
+		INSERT INTO user_tokens (id, user_id, purpose, token_hash, expires_at, created_at)
+		VALUES (:id, :user_id, :purpose, :token_hash, :expires_at, :created_at)`, t); err != nil {
+		return fmt.Errorf("insert token: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+// VerifyEmail uses a verify_email token and marks its user's email verified.
+func (r *PostgresUserRepository) VerifyEmail(tokenHash []byte) (*models.User, error) {
+	return r.useToken(models.TokenVerifyEmail, tokenHash, func(tx *sqlx.Tx, userID uuid.UUID) error {
+		_, err := tx.Exec(`
+			UPDATE users SET email_verified_at = COALESCE(email_verified_at, $2)
+			WHERE id = $1`, userID, time.Now().UTC())
+		if err != nil {
+			return fmt.Errorf("verify email: %w", err)
+		}
+		return nil
+	})
+}
+
+// ResetPassword uses a reset_password token and sets its user's password.
+// Following the emailed link also proves the address, so the email counts as
+// verified.
+func (r *PostgresUserRepository) ResetPassword(tokenHash []byte, passwordHash string) (*models.User, error) {
+	return r.useToken(models.TokenResetPassword, tokenHash, func(tx *sqlx.Tx, userID uuid.UUID) error {
+		_, err := tx.Exec(`
+			UPDATE users SET password_hash = $2, email_verified_at = COALESCE(email_verified_at, $3)
+			WHERE id = $1`, userID, passwordHash, time.Now().UTC())
+		if err != nil {
+			return fmt.Errorf("reset password: %w", err)
+		}
+		return nil
+	})
+}
+
+// useToken marks the token used and runs apply for its user in the same
+// transaction. The conditional update lets exactly one of two concurrent
+// requests with the same token succeed.
+func (r *PostgresUserRepository) useToken(purpose string, tokenHash []byte, apply func(tx *sqlx.Tx, userID uuid.UUID) error) (*models.User, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var userID uuid.UUID
+	err = tx.Get(&userID, `
+		UPDATE user_tokens SET used_at = $3
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > $3
+		RETURNING user_id`,
+		tokenHash, purpose, time.Now().UTC())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidToken
+		}
+		return nil, fmt.Errorf("use token: %w", err)
+	}
+	if err := apply(tx, userID); err != nil {
+		return nil, err
+	}
+
+	var u models.User
+	if err := tx.Get(&u, `SELECT `+userColumns+` FROM users WHERE id=$1`, userID); err != nil {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+	return &u, nil
+}
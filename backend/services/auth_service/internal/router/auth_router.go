@@ -4,10 +4,20 @@ import (
 	"net/http"
 
 	"auth_service/internal/handler"
+
+	"contracts/auth"
 )
 
 // SetupAuthRoutes sets up all routes for the auth service
-func SetupAuthRoutes(mux *http.ServeMux, authHandler *handler.AuthHandler) {
+func SetupAuthRoutes(mux *http.ServeMux, authHandler *handler.AuthHandler, orgHandler *handler.OrganisationHandler,
+	roleHandler *handler.RoleHandler, oidcHandler *handler.OIDCHandler, securityHandler *handler.SecurityHandler,
+	exportHandler *handler.ExportHandler, apiKeyHandler *handler.APIKeyHandler, jwtSecret []byte) {
+	// Health check (API gateway'in instance kontrolü)
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"ok","service":"auth_service"}`))
+	})
+
 	// User registration
 	mux.HandleFunc("/api/auth/register", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodPost {
@@ -24,4 +34,120 @@ func SetupAuthRoutes(mux *http.ServeMux, authHandler *handler.AuthHandler) {
 		}
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 	})
+
+	// İki adımlı doğrulama: şifreden sonra TOTP veya kurtarma kodu ile giriş
+	mux.HandleFunc("/api/auth/login/2fa", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			authHandler.LoginMFA(w, r)
+			return
+		}
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	})
+
+	// Kullanıcının kendi 2FA ayarları (token gerekir)
+	mux.HandleFunc("/api/auth/2fa", auth.RequireUser(jwtSecret, authHandler.TwoFactor))
+	mux.HandleFunc("/api/auth/2fa/", auth.RequireUser(jwtSecret, authHandler.TwoFactor))
+
+	// Kullanıcının kendi hesabı: profil, şifre değiştirme, hesap silme (token gerekir)
+	mux.HandleFunc("/api/auth/me", auth.RequireUser(jwtSecret, authHandler.Me))
+	mux.HandleFunc("/api/auth/me/", auth.RequireUser(jwtSecret, authHandler.Me))
+
+	// Kişisel veri dışa aktarımı: iste / listele (token gerekir), e-postadaki
+	// bağlantıyla indir (bağlantıdaki token yeterli)
+	mux.HandleFunc("/api/auth/me/exports", auth.RequireUser(jwtSecret, exportHandler.Exports))
+	mux.HandleFunc("/api/auth/exports/", exportHandler.Download)
+
+	// API anahtarları: oluştur / listele / iptal et (token gerekir)
+	mux.HandleFunc("/api/auth/me/api-keys", auth.RequireUser(jwtSecret, apiKeyHandler.APIKeys))
+	mux.HandleFunc("/api/auth/me/api-keys/", auth.RequireUser(jwtSecret, apiKeyHandler.APIKeys))
+
+	// API Gateway'in API anahtarını kısa ömürlü token'a çevirmesi (gateway'de yönlendirilmez)
+	mux.HandleFunc("/internal/api-keys/introspect", apiKeyHandler.Introspect)
+
+	// Hesap silme ilerlemesi (silmede dönen id ile; hesap artık yok)
+	mux.HandleFunc("/api/auth/account-deletions/", authHandler.AccountDeletion)
+
+	// E-posta doğrulama (kayıtta gönderilen bağlantıdaki token)
+	mux.HandleFunc("/api/auth/verify-email", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			authHandler.VerifyEmail(w, r)
+			return
+		}
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	})
+
+	mux.HandleFunc("/api/auth/verify-email/resend", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			authHandler.ResendVerification(w, r)
+			return
+		}
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	})
+
+	// Şifre sıfırlama: e-posta ile bağlantı iste, token ile yeni şifre belirle
+	mux.HandleFunc("/api/auth/password/forgot", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			authHandler.ForgotPassword(w, r)
+			return
+		}
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	})
+
+	mux.HandleFunc("/api/auth/password/reset", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			authHandler.ResetPassword(w, r)
+			return
+		}
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	})
+
+	// OIDC ile giriş: sağlayıcı listesi, sağlayıcıya yönlendirme ve dönüş
+	mux.HandleFunc("/api/auth/oidc/providers", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			oidcHandler.Providers(w, r)
+			return
+		}
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	})
+	mux.HandleFunc("/api/auth/oidc/", oidcHandler.Flow)
+
+	// Organizasyon oluştur (token gerekir; oluşturan kullanıcı owner olur)
+	mux.HandleFunc("/api/auth/orgs", auth.RequireUser(jwtSecret, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			orgHandler.CreateOrganisation(w, r)
+			return
+		}
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	// Organizasyon ve üyelik işlemleri (token gerekir)
+	mux.HandleFunc("/api/auth/orgs/", auth.RequireUser(jwtSecret, orgHandler.Organisations))
+
+	// Kullanıcının organizasyonu (yalnızca servisler arası: chat_service, embedding_service; gateway yönlendirmez)
+	mux.HandleFunc("/internal/users/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			orgHandler.GetUserOrganisation(w, r)
+			return
+		}
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	})
+
+	// Admin: roller ve kullanıcı rolleri (roles:manage yetkisi gerekir)
+	mux.HandleFunc("/api/auth/admin/roles", auth.Require(jwtSecret, auth.PermRolesManage, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			roleHandler.ListRoles(w, r)
+			return
+		}
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}))
+	mux.HandleFunc("/api/auth/admin/users/", auth.Require(jwtSecret, auth.PermRolesManage, roleHandler.UserRoles))
+
+	// Admin: güvenlik denetim kaydı (audit:read yetkisi gerekir)
+	mux.HandleFunc("/api/auth/admin/security-events", auth.Require(jwtSecret, auth.PermAuditRead, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			securityHandler.ListEvents(w, r)
+			return
+		}
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}))
 }
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"auth_service/internal/models"
+	"auth_service/internal/repository"
+	"auth_service/internal/utils"
+
+	"contracts/events"
+	"contracts/kafka"
+	"contracts/outbox"
+)
+
+var (
+	// ErrWrongPassword means the current password given to confirm an
+	// account change is wrong.
+	ErrWrongPassword = errors.New("current password is incorrect")
+	// ErrPasswordNotSet means the account signs in only through an identity
+	// provider; a password reset sets one.
+	ErrPasswordNotSet = errors.New("account has no password, set one with a password reset")
+	// ErrReauthRequired means an account without a password can only be
+	// deleted shortly after signing in.
+	ErrReauthRequired = errors.New("sign in again to confirm")
+	// ErrInvalidProfile means a profile field has an invalid value.
+	ErrInvalidProfile = errors.New("invalid profile")
+
+	// Errors of the repository handlers tell apart.
+	ErrUsernameTaken     = repository.ErrUsernameTaken
+	ErrEmailTaken        = repository.ErrEmailTaken
+	ErrOrganisationOwner = repository.ErrOrganisationOwner
+	ErrDeletionNotFound  = repository.ErrDeletionNotFound
+)
+
+const maxUsernameLength = 50
+
+// GetProfile returns the user with their roles.
+func (s *UserService) GetProfile(userID uuid.UUID) (*models.User, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	ur, err := s.roleRepo.GetUserRoles(userID)
+	if err != nil {
+		return nil, err
+	}
+	user.Roles = ur.Roles
+	return user, nil
+}
+
+// UpdateProfile changes the user's username and/or email. A new email needs
+// the current password and has to be verified again; the old address is
+// told about the change.
+func (s *UserService) UpdateProfile(userID uuid.UUID, upd models.ProfileUpdate, client models.ClientInfo) (*models.User, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	var changed []string
+	if upd.Username != nil {
+		name := strings.TrimSpace(*upd.Username)
+		if name == "" || len([]rune(name)) > maxUsernameLength {
+			return nil, fmt.Errorf("%w: username must be 1-%d characters", ErrInvalidProfile, maxUsernameLength)
+		}
+		if name == user.Username {
+			upd.Username = nil
+		} else {
+			upd.Username = &name
+			changed = append(changed, "username")
+		}
+	}
+	if upd.Email != nil {
+		addr, err := mail.ParseAddress(strings.TrimSpace(*upd.Email))
+		if err != nil || addr.Name != "" || len(addr.Address) > 100 {
+			return nil, fmt.Errorf("%w: invalid email", ErrInvalidProfile)
+		}
+		if strings.EqualFold(addr.Address, user.Email) {
+			upd.Email = nil
+		} else {
+			if err := s.confirmPassword(user, upd.CurrentPassword, client); err != nil {
+				return nil, err
+			}
+			upd.Email = &addr.Address
+			changed = append(changed, "email")
+		}
+	}
+	if len(changed) == 0 {
+		return s.GetProfile(userID)
+	}
+
+	updated, err := s.userRepo.UpdateProfile(userID, upd.Username, upd.Email)
+	if err != nil {
+		return nil, err
+	}
+	s.security.AccountChanged(models.EventProfileUpdated, userID, updated.Email, client, strings.Join(changed, ","))
+	log.Printf("👤 Profile of %s updated: %s", userID, strings.Join(changed, ", "))
+
+	if upd.Email != nil {
+		s.send(user, "E-posta adresin değişti", fmt.Sprintf("Merhaba %s,\n\n"+
+			"Hesabının e-posta adresi %s olarak değiştirildi. Bu değişikliği sen yapmadıysan hemen bizimle iletişime geç.",
+			updated.Username, updated.Email))
+		s.sendVerificationEmail(updated)
+	}
+	return s.GetProfile(userID)
+}
+
+// ChangePassword replaces the password of a signed-in user who knows the
+// current one. Wrong current passwords count as failed logins.
+func (s *UserService) ChangePassword(userID uuid.UUID, current, password string, client models.ClientInfo) error {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	if err := s.confirmPassword(user, current, client); err != nil {
+		return err
+	}
+	if err := validatePassword(password); err != nil {
+		return err
+	}
+	passwordHash, err := utils.HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	if err := s.userRepo.ChangePassword(userID, passwordHash); err != nil {
+		return err
+	}
+
+	s.security.AccountChanged(models.EventPasswordChanged, userID, user.Email, client, "")
+	log.Printf("🔑 Password changed: %s", userID)
+	s.send(user, "Şifren değişti", fmt.Sprintf("Merhaba %s,\n\n"+
+		"Hesabının şifresi değiştirildi. Bu değişikliği sen yapmadıysan şifreni hemen sıfırla.", user.Username))
+	return nil
+}
+
+// DeleteAccount deletes the user and publishes user_deleted, which makes
+// every service holding their data erase it. The user confirms with their
+// password, or, for an account without one, by having signed in within
+// ReauthWindow (signedInAt is when their access token was issued); with
+// two-factor authentication also with a code. Organisation owners have to
+// hand over or delete the organisation first.
+func (s *UserService) DeleteAccount(userID uuid.UUID, password, code string, signedInAt time.Time, client models.ClientInfo) (*models.AccountDeletion, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if user.PasswordHash != "" {
+		if err := s.confirmPassword(user, password, client); err != nil {
+			return nil, err
+		}
+	} else if time.Since(signedInAt) > s.ReauthWindow {
+		return nil, ErrReauthRequired
+	}
+	enabled, err := s.twoFactorEnabled(userID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		if err := s.checkSecondFactor(userID, code); err != nil {
+			return nil, err
+		}
+	}
+
+	d := &models.AccountDeletion{
+		ID:          uuid.New(),
+		UserID:      userID,
+		Email:       user.Email,
+		RequestedAt: time.Now().UTC(),
+	}
+	env, err := events.New(events.ProducerAuth, d.ID.String(), &events.UserDeleted{
+		UserID:     userID.String(),
+		DeletionID: d.ID.String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build event: %w", err)
+	}
+	msg := outbox.Message{Topic: s.DeletionTopic, Key: userID.String(), Envelope: env}
+	if err := s.deletions.DeleteUser(d, s.PurgeServices, msg); err != nil {
+		return nil, err
+	}
+
+	s.security.AccountChanged(models.EventAccountDeleted, userID, "", models.ClientInfo{IP: client.IP}, "deletion "+d.ID.String())
+	log.Printf("🗑️ Account %s deleted (deletion %s)", userID, d.ID)
+	s.send(user, "Hesabın silindi", fmt.Sprintf("Merhaba %s,\n\n"+
+		"Hesabın silindi. Verilerin tüm servislerimizden siliniyor; işlem tamamlandığında sana bir rapor göndereceğiz.\n\n"+
+		"Silme numarası: %s", user.Username, d.ID))
+
+	return s.deletions.GetDeletion(d.ID)
+}
+
+// GetDeletion returns the progress of an account deletion.
+func (s *UserService) GetDeletion(id uuid.UUID) (*models.AccountDeletion, error) {
+	return s.deletions.GetDeletion(id)
+}
+
+// HandleDataPurged records a service's user_data_purged report. The report
+// that completes the deletion is mailed to the deleted account's address.
+func (s *UserService) HandleDataPurged(evt *events.UserDataPurged) error {
+	deletionID, err := uuid.Parse(evt.DeletionID)
+	if err != nil {
+		return fmt.Errorf("%w: invalid deletion_id: %v", kafka.ErrInvalidMessage, err)
+	}
+	d, email, err := s.deletions.CompleteStep(deletionID, evt.Service, int64(evt.ItemsDeleted), evt.Detail)
+	if errors.Is(err, ErrDeletionNotFound) {
+		return fmt.Errorf("%w: %v", kafka.ErrInvalidMessage, err)
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("🧹 Deletion %s: %s purged %d item(s)", deletionID, evt.Service, evt.ItemsDeleted)
+
+	if email == "" {
+		return nil
+	}
+	log.Printf("✅ Deletion %s completed", deletionID)
+	var report strings.Builder
+	for _, step := range d.Steps {
+		fmt.Fprintf(&report, "- %s: %d kayıt silindi\n", step.Service, step.ItemsDeleted)
+	}
+	s.send(&models.User{ID: d.UserID, Email: email}, "Verilerin silindi", fmt.Sprintf("Merhaba,\n\n"+
+		"Hesabına ait veriler tüm servislerimizden silindi:\n\n%s\n"+
+		"Silme numarası: %s", report.String(), d.ID))
+	return nil
+}
+
+// confirmPassword checks the current password for an account change. It is
+// throttled like a login, and wrong passwords count as failed logins.
+func (s *UserService) confirmPassword(user *models.User, password string, client models.ClientInfo) error {
+	if user.PasswordHash == "" {
+		return ErrPasswordNotSet
+	}
+	if err := s.security.CheckLogin(user.Email, client); err != nil {
+		return err
+	}
+	if !utils.CheckPasswordHash(password, user.PasswordHash) {
+		s.security.LoginFailed(models.EventLoginFailed, user.Email, user, client, "wrong current password")
+		return ErrWrongPassword
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"auth_service/internal/models"
+	"auth_service/internal/repository"
+	"auth_service/internal/utils"
+)
+
+var (
+	// ErrInvalidCredentials means the email or the password is wrong.
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	// ErrEmailNotVerified means the user must follow the verification link
+	// before logging in.
+	ErrEmailNotVerified = errors.New("email not verified")
+	// ErrInvalidToken means an emailed link is malformed, expired or used.
+	ErrInvalidToken = errors.New("invalid or expired token")
+	// ErrWeakPassword means the password is too short.
+	ErrWeakPassword = errors.New("password must be at least 8 characters")
+)
+
+const minPasswordLength = 8
+
+func validatePassword(password string) error {
+	if len([]rune(password)) < minPasswordLength {
+		return ErrWeakPassword
+	}
+	return nil
+}
+
+// VerifyEmail marks the email of the token's user verified.
+func (s *UserService) VerifyEmail(token string) (*models.User, error) {
+	hash, err := utils.VerifySignedToken(s.jwtSecret, models.TokenVerifyEmail, token)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	user, err := s.userRepo.VerifyEmail(hash)
+	if err != nil {
+		return nil, tokenError(err)
+	}
+	log.Printf("✅ Email verified: %s", user.Email)
+	return user, nil
+}
+
+// ResendVerification emails a new verification link if email belongs to an
+// unverified user. It never reports whether the address is registered.
+func (s *UserService) ResendVerification(email string) {
+	user, err := s.userRepo.GetByEmail(email)
+	if err != nil || user.EmailVerifiedAt != nil {
+		return
+	}
+	s.sendVerificationEmail(user)
+}
+
+// RequestPasswordReset emails a reset link if email is registered. It never
+// reports whether the address is registered.
+func (s *UserService) RequestPasswordReset(email string) {
+	user, err := s.userRepo.GetByEmail(email)
+	if err != nil {
+		return
+	}
+	link, err := s.issueLink(user, models.TokenResetPassword, s.ResetTTL, "/reset-password")
+	if err != nil {
+		log.Printf("⚠️ Failed to create reset token for %s: %v", user.ID, err)
+		return
+	}
+	body := fmt.Sprintf("Merhaba %s,\n\nŞifreni sıfırlamak için bağlantıyı aç:\n%s\n\n"+
+		"Bağlantı %s geçerlidir ve bir kez kullanılabilir. Bu isteği sen yapmadıysan bu e-postayı yok sayabilirsin.",
+		user.Username, link, formatTTL(s.ResetTTL))
+	s.send(user, "Şifre sıfırlama", body)
+}
+
+// ResetPassword sets a new password for the token's user.
+func (s *UserService) ResetPassword(token, password string) error {
+	if err := validatePassword(password); err != nil {
+		return err
+	}
+	hash, err := utils.VerifySignedToken(s.jwtSecret, models.TokenResetPassword, token)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	passwordHash, err := utils.HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	user, err := s.userRepo.ResetPassword(hash, passwordHash)
+	if err != nil {
+		return tokenError(err)
+	}
+	log.Printf("🔑 Password reset: %s", user.Email)
+	return nil
+}
+
+func (s *UserService) sendVerificationEmail(user *models.User) {
+	link, err := s.issueLink(user, models.TokenVerifyEmail, s.VerificationTTL, "/verify-email")
+	if err != nil {
+		log.Printf("⚠️ Failed to create verification token for %s: %v", user.ID, err)
+		return
+	}
+	body := fmt.Sprintf("Merhaba %s,\n\nHesabını etkinleştirmek için e-posta adresini doğrula:\n%s\n\n"+
+		"Bağlantı %s geçerlidir ve bir kez kullanılabilir.",
+		user.Username, link, formatTTL(s.VerificationTTL))
+	s.send(user, "E-posta adresini doğrula", body)
+}
+
+// issueLink stores a new token for user and returns the frontend link
+// carrying it.
+func (s *UserService) issueLink(user *models.User, purpose string, ttl time.Duration, path string) (string, error) {
+	token, hash, err := utils.NewSignedToken(s.jwtSecret, purpose)
+	if err != nil {
+		return "", err
+	}
+	err = s.userRepo.CreateToken(&models.UserToken{
+		UserID:    user.ID,
+		Purpose:   purpose,
+		TokenHash: hash,
+		ExpiresAt: time.Now().UTC().Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+	return s.AppURL + path + "?token=" + url.QueryEscape(token), nil
+}
+
+// send delivers in the background so a slow SMTP server doesn't hold up the
+// request. A lost email can be requested again.
+func (s *UserService) send(user *models.User, subject, body string) {
+	go func() {
+		if err := s.mailer.Send(user.Email, subject, body); err != nil {
+			log.Printf("⚠️ Email %q to %s failed: %v", subject, user.ID, err)
+			return
+		}
+		log.Printf("📧 Email %q sent to %s", subject, user.ID)
+	}()
+}
+
+func tokenError(err error) error {
+	if errors.Is(err, repository.ErrInvalidToken) {
+		return ErrInvalidToken
+	}
+	return err
+}
+
+func formatTTL(d time.Duration) string {
+	if d >= time.Hour && d%time.Hour == 0 {
+		return fmt.Sprintf("%d saat", int(d/time.Hour))
+	}
+	return fmt.Sprintf("%d dakika", int(d/time.Minute))
+}
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"auth_service/internal/models"
+	"auth_service/internal/repository"
+	"auth_service/internal/utils"
+
+	"contracts/auth"
+)
+
+var (
+	// ErrInvalidAPIKeyRequest means the name, scopes or lifetime of a new
+	// key are invalid.
+	ErrInvalidAPIKeyRequest = errors.New("invalid API key request")
+	// ErrInvalidAPIKey means a presented key is malformed, unknown, revoked
+	// or expired.
+	ErrInvalidAPIKey = errors.New("invalid or expired API key")
+	// ErrAPIKeyForbidden means a request authenticated with an API key tried
+	// to manage API keys.
+	ErrAPIKeyForbidden = errors.New("API keys cannot manage API keys")
+
+	// Errors of the repository handlers tell apart.
+	ErrAPIKeyLimit    = repository.ErrAPIKeyLimit
+	ErrAPIKeyNotFound = repository.ErrAPIKeyNotFound
+)
+
+const (
+	maxAPIKeyNameLength = 100
+	// apiKeyPrefixLength is how much of a key is kept to tell keys apart.
+	apiKeyPrefixLength = len(auth.APIKeyPrefix) + 8
+)
+
+type APIKeyService struct {
+	keys     repository.APIKeyRepository
+	users    *UserService
+	security *SecurityService
+
+	// MaxKeys is how many active keys a user may have.
+	MaxKeys int
+	// DefaultTTL is the lifetime of a key created without one; no key lives
+	// longer than MaxTTL.
+	DefaultTTL time.Duration
+	MaxTTL     time.Duration
+	// TokenTTL is how long an access token issued for a key is valid, and
+	// so how long a revoked key may keep working at most.
+	TokenTTL time.Duration
+}
+
+func NewAPIKeyService(keys repository.APIKeyRepository, users *UserService, security *SecurityService) *APIKeyService {
+	return &APIKeyService{
+		keys:       keys,
+		users:      users,
+		security:   security,
+		MaxKeys:    20,
+		DefaultTTL: 90 * 24 * time.Hour,
+		MaxTTL:     365 * 24 * time.Hour,
+		TokenTTL:   5 * time.Minute,
+	}
+}
+
+// CreateKey creates a key named name for the user, valid for ttl (zero means
+// DefaultTTL). Scopes are API key scopes or permissions the user holds. The
+// returned key is not stored and cannot be shown again.
+func (s *APIKeyService) CreateKey(userID uuid.UUID, name string, scopes []string, ttl time.Duration, client models.ClientInfo) (*models.CreatedAPIKey, error) {
+	user, err := s.users.GetProfile(userID)
+	if err != nil {
+		return nil, err
+	}
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > maxAPIKeyNameLength {
+		return nil, fmt.Errorf("%w: name must be 1-%d characters", ErrInvalidAPIKeyRequest, maxAPIKeyNameLength)
+	}
+	if ttl == 0 {
+		ttl = s.DefaultTTL
+	}
+	if ttl < 0 || ttl > s.MaxTTL {
+		return nil, fmt.Errorf("%w: lifetime must be at most %d days", ErrInvalidAPIKeyRequest, int(s.MaxTTL/(24*time.Hour)))
+	}
+	scopes, err = s.checkScopes(userID, scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	token, _, err := utils.NewSignedToken(s.users.jwtSecret, models.TokenAPIKey)
+	if err != nil {
+		return nil, fmt.Errorf("create api key: %w", err)
+	}
+	secret := auth.APIKeyPrefix + token
+	now := time.Now().UTC()
+	k := &models.APIKey{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    secret[:apiKeyPrefixLength],
+		KeyHash:   utils.HashToken(secret),
+		Scopes:    scopes,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	if err := s.keys.CreateAPIKey(k, s.MaxKeys); err != nil {
+		return nil, err
+	}
+
+	s.security.AccountChanged(models.EventAPIKeyCreated, userID, user.Email, client, "key "+k.ID.String())
+	log.Printf("🔑 API key %s (%s) created for %s", k.ID, k.Prefix, userID)
+	s.users.send(user, "Yeni API anahtarı oluşturuldu", fmt.Sprintf("Merhaba %s,\n\n"+
+		"Hesabında \"%s\" adlı yeni bir API anahtarı oluşturuldu (%s..., kapsamlar: %s). "+
+		"Bunu sen yapmadıysan anahtarı hemen iptal et ve şifreni değiştir.",
+		user.Username, k.Name, k.Prefix, strings.Join(k.Scopes, ", ")))
+	return &models.CreatedAPIKey{APIKey: *k, Key: secret}, nil
+}
+
+// ListKeys returns all of the user's keys, including revoked and expired
+// ones.
+func (s *APIKeyService) ListKeys(userID uuid.UUID) ([]models.APIKey, error) {
+	return s.keys.ListAPIKeys(userID)
+}
+
+// RevokeKey stops one of the user's keys from working. Access tokens the
+// gateway already holds for it expire within TokenTTL.
+func (s *APIKeyService) RevokeKey(userID, id uuid.UUID, client models.ClientInfo) (*models.APIKey, error) {
+	k, err := s.keys.RevokeAPIKey(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	s.security.AccountChanged(models.EventAPIKeyRevoked, userID, "", client, "key "+k.ID.String())
+	log.Printf("🔑 API key %s (%s) revoked by %s", k.ID, k.Prefix, userID)
+	return k, nil
+}
+
+// Introspect exchanges a key presented to the gateway from ip for a
+// short-lived access token. The token carries the user's roles and those of
+// their current permissions the key has as scopes.
+func (s *APIKeyService) Introspect(key, ip string) (*models.APIKeyGrant, error) {
+	token, ok := strings.CutPrefix(key, auth.APIKeyPrefix)
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+	// Forged keys are turned away without a database lookup.
+	if _, err := utils.VerifySignedToken(s.users.jwtSecret, models.TokenAPIKey, token); err != nil {
+		return nil, ErrInvalidAPIKey
+	}
+	k, err := s.keys.GetActiveAPIKey(utils.HashToken(key))
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.users.userRepo.GetByID(k.UserID)
+	if err != nil {
+		return nil, ErrInvalidAPIKey
+	}
+	ur, err := s.users.roleRepo.GetUserRoles(k.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := []string{}
+	for _, p := range ur.Permissions {
+		if slices.Contains(k.Scopes, p) {
+			permissions = append(permissions, p)
+		}
+	}
+	ttl := min(s.TokenTTL, time.Until(k.ExpiresAt))
+	accessToken, expiresAt, err := auth.Sign(s.users.jwtSecret, auth.Claims{
+		Username:         user.Username,
+		Email:            user.Email,
+		Roles:            ur.Roles,
+		Permissions:      permissions,
+		APIKeyID:         k.ID.String(),
+		Scopes:           k.Scopes,
+		RegisteredClaims: jwt.RegisteredClaims{Subject: user.ID.String()},
+	}, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.keys.TouchAPIKey(k.ID, ip); err != nil {
+		log.Printf("⚠️ %v", err)
+	}
+	return &models.APIKeyGrant{
+		AccessToken: accessToken,
+		ExpiresAt:   expiresAt,
+		UserID:      user.ID.String(),
+		KeyID:       k.ID.String(),
+		Scopes:      k.Scopes,
+	}, nil
+}
+
+// checkScopes validates and normalises the scopes of a new key.
+func (s *APIKeyService) checkScopes(userID uuid.UUID, scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidAPIKeyRequest)
+	}
+	ur, err := s.users.roleRepo.GetUserRoles(userID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		if !slices.Contains(auth.APIKeyScopes, scope) && !slices.Contains(ur.Permissions, scope) {
+			return nil, fmt.Errorf("%w: unknown scope %q", ErrInvalidAPIKeyRequest, scope)
+		}
+		if !slices.Contains(out, scope) {
+			out = append(out, scope)
+		}
+	}
+	slices.Sort(out)
+	return out, nil
+}
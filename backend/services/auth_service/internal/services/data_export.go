@@ -0,0 +1,492 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"auth_service/internal/models"
+	"auth_service/internal/repository"
+	"auth_service/internal/utils"
+)
+
+var (
+	// ErrExportInProgress means the user already has an export being built.
+	ErrExportInProgress = repository.ErrExportInProgress
+	// ErrExportNotFound means a download link is wrong or expired.
+	ErrExportNotFound = repository.ErrExportNotFound
+)
+
+const (
+	// exportListLimit is how many of a user's exports are listed.
+	exportListLimit = 20
+	// exportEventLimit bounds the audit log entries put into an export.
+	exportEventLimit = 1000
+	// usageWindowDays is the longest range subscription_service returns
+	// daily usage for in one request.
+	usageWindowDays = 366
+)
+
+// exportReadme is the README.txt at the root of every archive.
+const exportReadme = `Kişisel veri dışa aktarımı
+
+profile.json               Hesap bilgileri, roller, organizasyon, bağlı OIDC hesapları, 2FA durumu
+security_events.json       Hesabınla ilgili güvenlik kayıtları (girişler, değişiklikler)
+subscription/              Güncel abonelik, dönem kullanımı, günlük kullanım, kota sıfırlamaları
+subscription/invoices/     Faturalar (JSON ve PDF)
+conversations.jsonl        Tüm sohbet mesajların, her satırda bir mesaj (JSON)
+documents/index.json       Yüklediğin dokümanların listesi
+documents/                 Yüklediğin dokümanların orijinalleri
+
+Tüm dosyalar makinece okunabilir biçimdedir (JSON / JSON Lines); zamanlar UTC'dir.
+`
+
+// exportDocument is an uploaded document as ocr_service lists it.
+type exportDocument struct {
+	FileID     string `json:"file_id"`
+	FileName   string `json:"file_name"`
+	Size       int64  `json:"size"`
+	UploadedAt string `json:"uploaded_at"`
+}
+
+// DataExportService builds ZIP archives of all of a user's data in the
+// background and hands them out through expiring download links.
+type DataExportService struct {
+	exports    repository.DataExportRepository
+	users      *UserService
+	orgRepo    repository.OrganisationRepository
+	identities repository.IdentityRepository
+	security   *SecurityService
+	sources    *ExportSources
+
+	// Dir holds the archives. It must be shared by all instances.
+	Dir string
+	// TTL is how long a finished archive can be downloaded.
+	TTL time.Duration
+	// DownloadURL is the public address (API Gateway) download links use.
+	DownloadURL string
+	// MaxAttempts is how often building an export is tried before it fails.
+	MaxAttempts int
+	// StaleAfter is when an export left running by a crashed builder is
+	// picked up again.
+	StaleAfter time.Duration
+}
+
+func NewDataExportService(
+	exports repository.DataExportRepository,
+	users *UserService,
+	orgRepo repository.OrganisationRepository,
+	identities repository.IdentityRepository,
+	security *SecurityService,
+	sources *ExportSources,
+) *DataExportService {
+	return &DataExportService{
+		exports:     exports,
+		users:       users,
+		orgRepo:     orgRepo,
+		identities:  identities,
+		security:    security,
+		sources:     sources,
+		Dir:         "/tmp/exports",
+		TTL:         48 * time.Hour,
+		DownloadURL: "http://localhost:8085",
+		MaxAttempts: 3,
+		StaleAfter:  30 * time.Minute,
+	}
+}
+
+// RequestExport queues an export of the user's data. The user is emailed a
+// download link when it is ready.
+func (s *DataExportService) RequestExport(userID uuid.UUID, client models.ClientInfo) (*models.DataExport, error) {
+	user, err := s.users.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	e := &models.DataExport{
+		ID:          uuid.New(),
+		UserID:      userID,
+		Status:      models.ExportPending,
+		RequestedAt: time.Now().UTC(),
+	}
+	if err := s.exports.CreateExport(e); err != nil {
+		return nil, err
+	}
+	s.security.AccountChanged(models.EventDataExportRequested, userID, user.Email, client, "export "+e.ID.String())
+	log.Printf("📦 Data export %s requested by %s", e.ID, userID)
+	return e, nil
+}
+
+// ListExports returns the user's latest exports.
+func (s *DataExportService) ListExports(userID uuid.UUID) ([]models.DataExport, error) {
+	return s.exports.ListExports(userID, exportListLimit)
+}
+
+// OpenDownload returns the archive the download token was issued for. The
+// caller closes the file.
+func (s *DataExportService) OpenDownload(id uuid.UUID, token string) (*models.DataExport, *os.File, error) {
+	hash, err := utils.VerifySignedToken(s.users.jwtSecret, models.TokenDataExport, token)
+	if err != nil {
+		return nil, nil, ErrExportNotFound
+	}
+	e, err := s.exports.GetDownload(id, hash)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Open(s.archivePath(e.ID))
+	if err != nil {
+		return nil, nil, fmt.Errorf("open archive: %w", err)
+	}
+	return e, f, nil
+}
+
+// Run builds queued exports and removes expired archives every interval
+// until ctx is done.
+func (s *DataExportService) Run(ctx context.Context, interval time.Duration) {
+	if err := os.MkdirAll(s.Dir, 0o700); err != nil {
+		log.Printf("⚠️ Data exports disabled, cannot create %s: %v", s.Dir, err)
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.cleanup()
+		for ctx.Err() == nil {
+			e, err := s.exports.ClaimExport(s.StaleAfter)
+			if err != nil {
+				log.Printf("⚠️ Failed to claim data export: %v", err)
+				break
+			}
+			if e == nil {
+				break
+			}
+			s.process(ctx, e)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// process builds one export and emails the user the outcome.
+func (s *DataExportService) process(ctx context.Context, e *models.DataExport) {
+	user, err := s.users.GetProfile(e.UserID)
+	if err == nil {
+		var size int64
+		if size, err = s.build(ctx, e, user); err == nil {
+			err = s.publish(e, user, size)
+		}
+	}
+	if err == nil {
+		return
+	}
+	if ctx.Err() != nil {
+		// Shutting down: the export is claimed again once it is stale.
+		return
+	}
+
+	final := e.Attempts >= s.MaxAttempts
+	log.Printf("❌ Data export %s failed (attempt %d/%d): %v", e.ID, e.Attempts, s.MaxAttempts, err)
+	if ferr := s.exports.FailExport(e.ID, err.Error(), final); ferr != nil {
+		log.Printf("⚠️ %v", ferr)
+	}
+	if final && user != nil {
+		s.users.send(user, "Veri dışa aktarımı başarısız", fmt.Sprintf("Merhaba %s,\n\n"+
+			"Verilerinin dışa aktarımı hazırlanamadı. Lütfen daha sonra yeniden dene.", user.Username))
+	}
+}
+
+// build writes the user's archive and returns its size.
+func (s *DataExportService) build(ctx context.Context, e *models.DataExport, user *models.User) (int64, error) {
+	final := s.archivePath(e.ID)
+	tmp := final + ".part"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return 0, fmt.Errorf("create archive: %w", err)
+	}
+	defer os.Remove(tmp)
+
+	zw := zip.NewWriter(f)
+	err = s.writeArchive(ctx, zw, user)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp, final); err != nil {
+		return 0, fmt.Errorf("store archive: %w", err)
+	}
+	info, err := os.Stat(final)
+	if err != nil {
+		return 0, fmt.Errorf("stat archive: %w", err)
+	}
+	return info.Size(), nil
+}
+
+// publish makes the archive downloadable and emails the link.
+func (s *DataExportService) publish(e *models.DataExport, user *models.User, size int64) error {
+	token, hash, err := utils.NewSignedToken(s.users.jwtSecret, models.TokenDataExport)
+	if err != nil {
+		return fmt.Errorf("create download token: %w", err)
+	}
+	expiresAt := time.Now().UTC().Add(s.TTL)
+	if err := s.exports.CompleteExport(e.ID, hash, size, expiresAt); err != nil {
+		os.Remove(s.archivePath(e.ID))
+		return err
+	}
+	log.Printf("✅ Data export %s ready (%d bytes)", e.ID, size)
+
+	link := fmt.Sprintf("%s/api/auth/exports/%s/download?token=%s",
+		strings.TrimRight(s.DownloadURL, "/"), e.ID, url.QueryEscape(token))
+	s.users.send(user, "Verilerin indirilmeye hazır", fmt.Sprintf("Merhaba %s,\n\n"+
+		"İstediğin veri dışa aktarımı hazır (%.1f MB). İndirmek için bağlantıyı aç:\n%s\n\n"+
+		"Bağlantı %s geçerlidir. Bu isteği sen yapmadıysan şifreni hemen değiştir.",
+		user.Username, float64(size)/(1<<20), link, formatTTL(s.TTL)))
+	return nil
+}
+
+// writeArchive puts all of the user's data into zw.
+func (s *DataExportService) writeArchive(ctx context.Context, zw *zip.Writer, user *models.User) error {
+	if err := writeZipFile(zw, "README.txt", strings.NewReader(exportReadme)); err != nil {
+		return err
+	}
+	if err := s.writeProfile(zw, user); err != nil {
+		return err
+	}
+	if err := s.writeSubscription(ctx, zw, user); err != nil {
+		return err
+	}
+	if err := s.writeConversations(ctx, zw, user.ID); err != nil {
+		return err
+	}
+	return s.writeDocuments(ctx, zw, user.ID)
+}
+
+func (s *DataExportService) writeProfile(zw *zip.Writer, user *models.User) error {
+	membership, err := s.orgRepo.GetMembership(user.ID)
+	if errors.Is(err, repository.ErrNotMember) {
+		membership, err = nil, nil
+	}
+	if err != nil {
+		return err
+	}
+	identities, err := s.identities.ListIdentities(user.ID)
+	if err != nil {
+		return err
+	}
+	twoFactor, err := s.users.twoFactorEnabled(user.ID)
+	if err != nil {
+		return err
+	}
+	profile := map[string]interface{}{
+		"user":               user,
+		"organisation":       membership,
+		"identities":         identities,
+		"two_factor_enabled": twoFactor,
+	}
+	if err := writeZipJSON(zw, "profile.json", profile); err != nil {
+		return err
+	}
+
+	events, err := s.security.ListEvents(models.SecurityEventFilter{UserID: &user.ID, Limit: exportEventLimit})
+	if err != nil {
+		return err
+	}
+	return writeZipJSON(zw, "security_events.json", events)
+}
+
+func (s *DataExportService) writeSubscription(ctx context.Context, zw *zip.Writer, user *models.User) error {
+	// Per-user endpoints for services, not routed by the gateway
+	base := strings.TrimRight(s.sources.SubscriptionURL, "/") + "/internal/users/" + user.ID.String() + "/"
+
+	// Without a current subscription the service answers 403.
+	for _, f := range []struct{ name, url string }{
+		{"subscription/current.json", base + "current"},
+		{"subscription/usage.json", base + "usage"},
+		{"subscription/quota_resets.json", base + "quota_resets"},
+	} {
+		if err := s.copyFrom(ctx, zw, f.name, f.url, http.StatusForbidden); err != nil {
+			return err
+		}
+	}
+
+	// Daily usage since sign-up, one file per window the service allows.
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	for from := user.CreatedAt.UTC().Truncate(24 * time.Hour); !from.After(today); from = from.AddDate(0, 0, usageWindowDays) {
+		to := from.AddDate(0, 0, usageWindowDays-1)
+		if to.After(today) {
+			to = today
+		}
+		name := fmt.Sprintf("subscription/daily_usage_%s_%s.json", from.Format("2006-01-02"), to.Format("2006-01-02"))
+		u := fmt.Sprintf("%susage/daily?from=%s&to=%s", base, from.Format("2006-01-02"), to.Format("2006-01-02"))
+		if err := s.copyFrom(ctx, zw, name, u); err != nil {
+			return err
+		}
+	}
+
+	body, err := s.sources.get(ctx, base+"invoices")
+	if err != nil || body == nil {
+		return err
+	}
+	var list struct {
+		Invoices []struct {
+			ID string `json:"id"`
+		} `json:"invoices"`
+	}
+	raw, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		return fmt.Errorf("read invoices: %w", err)
+	}
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return fmt.Errorf("decode invoices: %w", err)
+	}
+	if err := writeZipFile(zw, "subscription/invoices/invoices.json", bytes.NewReader(raw)); err != nil {
+		return err
+	}
+	for _, inv := range list.Invoices {
+		if _, err := uuid.Parse(inv.ID); err != nil {
+			continue
+		}
+		if err := s.copyFrom(ctx, zw, "subscription/invoices/"+inv.ID+".json", base+"invoices/"+inv.ID); err != nil {
+			return err
+		}
+		if err := s.copyFrom(ctx, zw, "subscription/invoices/"+inv.ID+".pdf", base+"invoices/"+inv.ID+"/pdf"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *DataExportService) writeConversations(ctx context.Context, zw *zip.Writer, userID uuid.UUID) error {
+	u := fmt.Sprintf("%s/internal/users/%s/messages", strings.TrimRight(s.sources.ChatDataURL, "/"), userID)
+	return s.copyFrom(ctx, zw, "conversations.jsonl", u)
+}
+
+func (s *DataExportService) writeDocuments(ctx context.Context, zw *zip.Writer, userID uuid.UUID) error {
+	base := fmt.Sprintf("%s/internal/users/%s/files", strings.TrimRight(s.sources.OCRURL, "/"), userID)
+	body, err := s.sources.get(ctx, base)
+	if err != nil {
+		return err
+	}
+	list := struct {
+		Files []exportDocument `json:"files"`
+	}{Files: []exportDocument{}}
+	if body != nil {
+		err = json.NewDecoder(body).Decode(&list)
+		body.Close()
+		if err != nil {
+			return fmt.Errorf("decode documents: %w", err)
+		}
+	}
+	if err := writeZipJSON(zw, "documents/index.json", list.Files); err != nil {
+		return err
+	}
+	for _, f := range list.Files {
+		if _, err := uuid.Parse(f.FileID); err != nil {
+			continue
+		}
+		// The uploaded name may carry path elements; keep only its base.
+		name := "documents/" + f.FileID + "_" + path.Base(filepath.ToSlash(f.FileName))
+		if err := s.copyFrom(ctx, zw, name, base+"/"+f.FileID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyFrom streams url into the archive as name. It adds nothing if the
+// service has nothing (404 or one of absent).
+func (s *DataExportService) copyFrom(ctx context.Context, zw *zip.Writer, name, url string, absent ...int) error {
+	body, err := s.sources.get(ctx, url, absent...)
+	if err != nil || body == nil {
+		return err
+	}
+	defer body.Close()
+	return writeZipFile(zw, name, body)
+}
+
+// cleanup expires old exports and deletes every archive that is no longer
+// needed, including those of deleted accounts.
+func (s *DataExportService) cleanup() {
+	n, err := s.exports.ExpireExports()
+	if err != nil {
+		log.Printf("⚠️ %v", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("🧹 %d data export(s) expired", n)
+	}
+	ids, err := s.exports.LiveExportIDs()
+	if err != nil {
+		log.Printf("⚠️ %v", err)
+		return
+	}
+	live := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		live[id.String()] = true
+	}
+
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		log.Printf("⚠️ Failed to list data exports: %v", err)
+		return
+	}
+	for _, entry := range entries {
+		id, _, _ := strings.Cut(entry.Name(), ".")
+		if live[id] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.Dir, entry.Name())); err != nil {
+			log.Printf("⚠️ Failed to remove data export %s: %v", entry.Name(), err)
+		}
+	}
+}
+
+func (s *DataExportService) archivePath(id uuid.UUID) string {
+	return filepath.Join(s.Dir, id.String()+".zip")
+}
+
+func writeZipJSON(zw *zip.Writer, name string, v interface{}) error {
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now().UTC()})
+	if err != nil {
+		return fmt.Errorf("add %s: %w", name, err)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	return nil
+}
+
+func writeZipFile(zw *zip.Writer, name string, r io.Reader) error {
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now().UTC()})
+	if err != nil {
+		return fmt.Errorf("add %s: %w", name, err)
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	return nil
+}
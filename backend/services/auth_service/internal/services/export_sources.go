@@ -0,0 +1,54 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// ExportSources reads a user's data from the services that hold it, for
+// data exports. The endpoints are internal and not routed by the gateway.
+type ExportSources struct {
+	SubscriptionURL string
+	ChatDataURL     string
+	OCRURL          string
+	client          *http.Client
+}
+
+func NewExportSources(subscriptionURL, chatDataURL, ocrURL string) *ExportSources {
+	return &ExportSources{
+		SubscriptionURL: subscriptionURL,
+		ChatDataURL:     chatDataURL,
+		OCRURL:          ocrURL,
+		// Originals of uploaded documents can take a while.
+		client: &http.Client{Timeout: 10 * time.Minute},
+	}
+}
+
+// get fetches url and returns its body, or nil if the service has nothing
+// (404, or one of absent). Any other status is an error.
+func (c *ExportSources) get(ctx context.Context, url string, absent ...int) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request %s failed: %w", url, err)
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp.Body, nil
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	for _, code := range absent {
+		if resp.StatusCode == code {
+			return nil, nil
+		}
+	}
+	return nil, fmt.Errorf("%s responded %d", url, resp.StatusCode)
+}
@@ -0,0 +1,237 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+
+	"auth_service/internal/models"
+	"auth_service/internal/oidc"
+	"auth_service/internal/repository"
+)
+
+var (
+	// ErrLoginExpired means the callback doesn't belong to a sign-in in
+	// progress: it expired, was already completed or was forged.
+	ErrLoginExpired = errors.New("sign-in expired or already completed")
+	// ErrProviderEmailUnverified means the provider didn't vouch for the
+	// email, so it can't be used to find or create an account.
+	ErrProviderEmailUnverified = errors.New("identity provider did not verify the email")
+)
+
+// usernameAttempts bounds how many random suffixes are tried when the
+// username derived from the identity is taken.
+const usernameAttempts = 5
+
+var usernameUnsafe = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// ProviderInfo describes a provider users can sign in with.
+type ProviderInfo struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+}
+
+type OIDCService struct {
+	providers    *oidc.Registry
+	identityRepo repository.IdentityRepository
+	userRepo     repository.UserRepository
+	users        *UserService
+	// StateTTL is how long the user has to finish signing in at the provider.
+	StateTTL time.Duration
+}
+
+func NewOIDCService(providers *oidc.Registry, identityRepo repository.IdentityRepository,
+	userRepo repository.UserRepository, users *UserService) *OIDCService {
+	return &OIDCService{
+		providers:    providers,
+		identityRepo: identityRepo,
+		userRepo:     userRepo,
+		users:        users,
+		StateTTL:     10 * time.Minute,
+	}
+}
+
+// Providers lists the configured providers.
+func (s *OIDCService) Providers() []ProviderInfo {
+	out := []ProviderInfo{}
+	for _, p := range s.providers.List() {
+		out = append(out, ProviderInfo{Name: p.Name(), DisplayName: p.DisplayName()})
+	}
+	return out
+}
+
+// BeginLogin starts a sign-in at provider and returns the URL to send the
+// browser to, and the state the callback must come back with. The caller
+// binds the state to the browser so a callback started elsewhere can't be
+// completed in it.
+func (s *OIDCService) BeginLogin(ctx context.Context, provider string) (authURL, state string, err error) {
+	p, err := s.providers.Get(provider)
+	if err != nil {
+		return "", "", err
+	}
+	state, err = randomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	st := &models.OIDCLoginState{
+		State:        state,
+		Provider:     provider,
+		CodeVerifier: oauth2.GenerateVerifier(),
+		Nonce:        nonce,
+		ExpiresAt:    time.Now().UTC().Add(s.StateTTL),
+	}
+
+	authURL, err = p.AuthCodeURL(ctx, st.State, st.Nonce, st.CodeVerifier)
+	if err != nil {
+		return "", "", err
+	}
+	if err := s.identityRepo.SaveLoginState(st); err != nil {
+		return "", "", err
+	}
+	return authURL, state, nil
+}
+
+// CompleteLogin redeems the provider's callback and signs the user in. An
+// identity seen before signs in its user; otherwise it is linked to the user
+// with the same (provider-verified) email, or a new user is created.
+func (s *OIDCService) CompleteLogin(ctx context.Context, provider, state, code string, client models.ClientInfo) (*models.Session, error) {
+	p, err := s.providers.Get(provider)
+	if err != nil {
+		return nil, err
+	}
+	st, err := s.identityRepo.TakeLoginState(state)
+	if err != nil {
+		if errors.Is(err, repository.ErrLoginStateNotFound) {
+			return nil, ErrLoginExpired
+		}
+		return nil, err
+	}
+	if st.Provider != provider {
+		return nil, ErrLoginExpired
+	}
+
+	identity, err := p.Exchange(ctx, code, st.CodeVerifier, st.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.identityRepo.GetIdentityUser(identity.Provider, identity.Subject)
+	switch {
+	case err == nil:
+	case errors.Is(err, repository.ErrIdentityNotFound):
+		if !identity.EmailVerified || identity.Email == "" {
+			return nil, ErrProviderEmailUnverified
+		}
+		user, err = s.linkOrCreate(identity)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	log.Printf("🔓 %s sign-in: %s", provider, user.Email)
+	return s.users.startSession(user, client, "oidc:"+provider)
+}
+
+// linkOrCreate attaches a new identity to the user with its email, creating
+// the user if there is none.
+func (s *OIDCService) linkOrCreate(identity *oidc.Identity) (*models.User, error) {
+	link := &models.UserIdentity{
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	}
+
+	user, err := s.userRepo.GetByEmail(identity.Email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if user != nil {
+		link.UserID = user.ID
+		if err := s.identityRepo.LinkIdentity(link); err != nil {
+			return nil, err
+		}
+		if user.EmailVerifiedAt == nil {
+			user.EmailVerifiedAt = &link.CreatedAt
+		}
+		log.Printf("🔗 Linked %s identity to %s", identity.Provider, user.Email)
+		return user, nil
+	}
+
+	now := time.Now().UTC()
+	base := deriveUsername(identity)
+	for attempt := 0; attempt < usernameAttempts; attempt++ {
+		username := base
+		if attempt > 0 {
+			suffix, err := randomString(2)
+			if err != nil {
+				return nil, err
+			}
+			username = base + "-" + suffix
+		}
+
+		// No password: the account signs in through the provider until the
+		// user sets one with a password reset.
+		user := &models.User{
+			ID:              uuid.New(),
+			Username:        username,
+			Email:           identity.Email,
+			CreatedAt:       now,
+			EmailVerifiedAt: &now,
+		}
+		msg, err := s.users.registeredMessage(user)
+		if err != nil {
+			return nil, err
+		}
+		created, err := s.identityRepo.CreateUserWithIdentity(user, link, msg)
+		if errors.Is(err, repository.ErrUsernameTaken) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("👤 Created user %s from %s identity", created.Email, identity.Provider)
+		return created, nil
+	}
+	return nil, fmt.Errorf("no free username for %q", base)
+}
+
+// deriveUsername picks a username from the identity's preferred username or
+// the local part of its email.
+func deriveUsername(identity *oidc.Identity) string {
+	name := identity.Username
+	if name == "" {
+		name, _, _ = strings.Cut(identity.Email, "@")
+	}
+	name = strings.Trim(usernameUnsafe.ReplaceAllString(name, "-"), "-")
+	if len(name) > 40 {
+		name = name[:40]
+	}
+	if name == "" {
+		name = "user"
+	}
+	return name
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate random: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
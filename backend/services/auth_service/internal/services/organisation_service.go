@@ -0,0 +1,279 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"auth_service/internal/models"
+	"auth_service/internal/repository"
+
+	"contracts/events"
+	"contracts/outbox"
+)
+
+var (
+	// ErrForbidden means the acting user's role doesn't allow the change.
+	ErrForbidden = errors.New("not allowed for your role in the organisation")
+	// ErrInvalidRole means the role is not owner, admin or member, or can't
+	// be given this way.
+	ErrInvalidRole = errors.New("invalid role")
+	// ErrInvalidName means the organisation name is empty or too long.
+	ErrInvalidName = errors.New("name must be 1-100 characters")
+	// ErrUserNotFound means no user exists with the given email.
+	ErrUserNotFound = errors.New("user not found")
+)
+
+// OrganisationService manages organisations and their members. Every
+// membership change is published on KafkaTopic so other services can mirror
+// it (pooled quota, shared documents).
+type OrganisationService struct {
+	orgRepo    repository.OrganisationRepository
+	userRepo   repository.UserRepository
+	KafkaTopic string
+}
+
+func NewOrganisationService(
+	orgRepo repository.OrganisationRepository,
+	userRepo repository.UserRepository,
+	kafkaTopic string,
+) *OrganisationService {
+	return &OrganisationService{
+		orgRepo:    orgRepo,
+		userRepo:   userRepo,
+		KafkaTopic: kafkaTopic,
+	}
+}
+
+// CreateOrganisation creates an organisation owned by userID. A user can
+// belong to one organisation only.
+func (s *OrganisationService) CreateOrganisation(userID uuid.UUID, name string) (*models.OrganisationDetails, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 {
+		return nil, ErrInvalidName
+	}
+	if _, err := s.userRepo.GetByID(userID); err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	org := &models.Organisation{ID: uuid.New(), Name: name}
+	msg, err := s.memberEvent(org.ID, userID, events.RoleOwner, events.MemberAdded)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.orgRepo.CreateOrganisation(org, userID, msg); err != nil {
+		return nil, err
+	}
+
+	log.Printf("🏢 Organisation %s created by %s", org.ID, userID)
+	return s.GetOrganisation(userID, org.ID)
+}
+
+// GetOrganisation returns the organisation with its members. Only members
+// can see it.
+func (s *OrganisationService) GetOrganisation(userID, orgID uuid.UUID) (*models.OrganisationDetails, error) {
+	if _, err := s.actorRole(userID, orgID); err != nil {
+		return nil, err
+	}
+	org, err := s.orgRepo.GetOrganisation(orgID)
+	if err != nil {
+		return nil, err
+	}
+	members, err := s.orgRepo.ListMembers(orgID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.OrganisationDetails{Organisation: *org, Members: members}, nil
+}
+
+// GetMembership returns the organisation the user belongs to.
+func (s *OrganisationService) GetMembership(userID uuid.UUID) (*models.Membership, error) {
+	return s.orgRepo.GetMembership(userID)
+}
+
+// DeleteOrganisation deletes the organisation. Only the owner can do it.
+func (s *OrganisationService) DeleteOrganisation(userID, orgID uuid.UUID) error {
+	role, err := s.actorRole(userID, orgID)
+	if err != nil {
+		return err
+	}
+	if role != events.RoleOwner {
+		return ErrForbidden
+	}
+
+	env, err := events.New(events.ProducerAuth, orgID.String(), &events.OrganisationDeleted{
+		OrganisationID: orgID.String(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to build event: %w", err)
+	}
+	if err := s.orgRepo.DeleteOrganisation(orgID, outbox.Message{
+		Topic:    s.KafkaTopic,
+		Key:      orgID.String(),
+		Envelope: env,
+	}); err != nil {
+		return err
+	}
+
+	log.Printf("🗑️ Organisation %s deleted by %s", orgID, userID)
+	return nil
+}
+
+// AddMember adds the user with the given email to the organisation. Owners
+// and admins can add members; only the owner can add admins.
+func (s *OrganisationService) AddMember(userID, orgID uuid.UUID, email, role string) (*models.OrganisationMember, error) {
+	if role == "" {
+		role = events.RoleMember
+	}
+	if role != events.RoleMember && role != events.RoleAdmin {
+		return nil, ErrInvalidRole
+	}
+	actorRole, err := s.actorRole(userID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	if !canManage(actorRole, role) {
+		return nil, ErrForbidden
+	}
+
+	user, err := s.userRepo.GetByEmail(strings.TrimSpace(email))
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	msg, err := s.memberEvent(orgID, user.ID, role, events.MemberAdded)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.orgRepo.AddMember(orgID, user.ID, role, msg); err != nil {
+		return nil, err
+	}
+
+	log.Printf("👥 User %s added to organisation %s as %s", user.ID, orgID, role)
+	return &models.OrganisationMember{
+		OrganisationID: orgID,
+		UserID:         user.ID,
+		Username:       user.Username,
+		Email:          user.Email,
+		Role:           role,
+	}, nil
+}
+
+// ChangeRole changes a member's role. Only the owner can change roles;
+// giving someone the owner role transfers ownership and makes the current
+// owner an admin.
+func (s *OrganisationService) ChangeRole(userID, orgID, memberID uuid.UUID, role string) error {
+	if role != events.RoleOwner && role != events.RoleAdmin && role != events.RoleMember {
+		return ErrInvalidRole
+	}
+	actorRole, err := s.actorRole(userID, orgID)
+	if err != nil {
+		return err
+	}
+	if actorRole != events.RoleOwner {
+		return ErrForbidden
+	}
+	if memberID == userID {
+		// The owner stays owner until ownership is given to someone else.
+		return ErrInvalidRole
+	}
+	if _, err := s.memberRole(orgID, memberID); err != nil {
+		return err
+	}
+
+	msg, err := s.memberEvent(orgID, memberID, role, events.MemberRoleChanged)
+	if err != nil {
+		return err
+	}
+	if role != events.RoleOwner {
+		return s.orgRepo.SetMemberRole(orgID, memberID, role, msg)
+	}
+
+	demoted, err := s.memberEvent(orgID, userID, events.RoleAdmin, events.MemberRoleChanged)
+	if err != nil {
+		return err
+	}
+	if err := s.orgRepo.TransferOwnership(orgID, userID, memberID, demoted, msg); err != nil {
+		return err
+	}
+	log.Printf("👑 Organisation %s transferred from %s to %s", orgID, userID, memberID)
+	return nil
+}
+
+// RemoveMember removes a member from the organisation. Owners and admins
+// can remove members, only the owner can remove admins, and anyone but the
+// owner can leave.
+func (s *OrganisationService) RemoveMember(userID, orgID, memberID uuid.UUID) error {
+	actorRole, err := s.actorRole(userID, orgID)
+	if err != nil {
+		return err
+	}
+	role, err := s.memberRole(orgID, memberID)
+	if err != nil {
+		return err
+	}
+	if role == events.RoleOwner {
+		// Transfer ownership or delete the organisation instead.
+		return ErrForbidden
+	}
+	if memberID != userID && !canManage(actorRole, role) {
+		return ErrForbidden
+	}
+
+	msg, err := s.memberEvent(orgID, memberID, role, events.MemberRemoved)
+	if err != nil {
+		return err
+	}
+	if err := s.orgRepo.RemoveMember(orgID, memberID, msg); err != nil {
+		return err
+	}
+	log.Printf("👋 User %s removed from organisation %s", memberID, orgID)
+	return nil
+}
+
+// actorRole returns userID's role in orgID; users outside the organisation
+// get ErrOrganisationNotFound so its existence isn't revealed.
+func (s *OrganisationService) actorRole(userID, orgID uuid.UUID) (string, error) {
+	role, err := s.memberRole(orgID, userID)
+	if errors.Is(err, repository.ErrNotMember) {
+		return "", repository.ErrOrganisationNotFound
+	}
+	return role, err
+}
+
+func (s *OrganisationService) memberRole(orgID, userID uuid.UUID) (string, error) {
+	m, err := s.orgRepo.GetMembership(userID)
+	if err != nil {
+		return "", err
+	}
+	if m.OrganisationID != orgID {
+		return "", repository.ErrNotMember
+	}
+	return m.Role, nil
+}
+
+// canManage reports whether actorRole may add or remove a member with role.
+func canManage(actorRole, role string) bool {
+	switch actorRole {
+	case events.RoleOwner:
+		return true
+	case events.RoleAdmin:
+		return role == events.RoleMember
+	}
+	return false
+}
+
+func (s *OrganisationService) memberEvent(orgID, userID uuid.UUID, role, action string) (outbox.Message, error) {
+	env, err := events.New(events.ProducerAuth, orgID.String(), &events.OrgMemberChanged{
+		OrganisationID: orgID.String(),
+		UserID:         userID.String(),
+		Role:           role,
+		Action:         action,
+	})
+	if err != nil {
+		return outbox.Message{}, fmt.Errorf("failed to build event: %w", err)
+	}
+	return outbox.Message{Topic: s.KafkaTopic, Key: orgID.String(), Envelope: env}, nil
+}
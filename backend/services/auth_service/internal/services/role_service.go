@@ -0,0 +1,80 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"slices"
+
+	"github.com/google/uuid"
+
+	"auth_service/internal/models"
+	"auth_service/internal/repository"
+
+	"contracts/auth"
+)
+
+// ErrSelfLockout means the change would take role management away from the
+// acting user, which could leave nobody able to grant it back.
+var ErrSelfLockout = errors.New("you can't revoke your own role management permission")
+
+// RoleService manages the roles granted to users. Changes show up in the
+// user's next access token.
+type RoleService struct {
+	roleRepo repository.RoleRepository
+	userRepo repository.UserRepository
+}
+
+func NewRoleService(roleRepo repository.RoleRepository, userRepo repository.UserRepository) *RoleService {
+	return &RoleService{roleRepo: roleRepo, userRepo: userRepo}
+}
+
+// ListRoles returns every role with its permissions.
+func (s *RoleService) ListRoles() ([]models.Role, error) {
+	return s.roleRepo.ListRoles()
+}
+
+// GetUserRoles returns the user's roles and permissions.
+func (s *RoleService) GetUserRoles(userID uuid.UUID) (*models.UserRoles, error) {
+	if _, err := s.userRepo.GetByID(userID); err != nil {
+		return nil, ErrUserNotFound
+	}
+	return s.roleRepo.GetUserRoles(userID)
+}
+
+// SetUserRoles replaces the user's roles; the user role is always kept.
+func (s *RoleService) SetUserRoles(actorID, userID uuid.UUID, roles []string) (*models.UserRoles, error) {
+	if _, err := s.userRepo.GetByID(userID); err != nil {
+		return nil, ErrUserNotFound
+	}
+	if actorID == userID {
+		granted, err := s.grantedPermissions(roles)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(granted, auth.PermRolesManage) {
+			return nil, ErrSelfLockout
+		}
+	}
+
+	ur, err := s.roleRepo.SetUserRoles(userID, roles)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("🛡️ Roles of %s set to %v by %s", userID, ur.Roles, actorID)
+	return ur, nil
+}
+
+// grantedPermissions returns the permissions roles would grant together.
+func (s *RoleService) grantedPermissions(roles []string) ([]string, error) {
+	all, err := s.roleRepo.ListRoles()
+	if err != nil {
+		return nil, err
+	}
+	var perms []string
+	for _, role := range all {
+		if slices.Contains(roles, role.Name) {
+			perms = append(perms, role.Permissions...)
+		}
+	}
+	return perms, nil
+}
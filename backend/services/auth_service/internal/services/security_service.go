@@ -0,0 +1,187 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"auth_service/internal/models"
+	"auth_service/internal/repository"
+)
+
+// LoginThrottledError means login attempts for the account or from the IP
+// are paused; the attempt was rejected without checking the password.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+// LoginPolicy configures brute-force protection. After FreeAttempts failures
+// every further attempt has to wait BaseDelay, doubling per failure up to
+// MaxDelay. LockoutThreshold failures lock the account (IPLockoutThreshold
+// for an IP) for LockoutDuration. Failures older than FailureWindow are
+// forgotten.
+type LoginPolicy struct {
+	FreeAttempts       int
+	BaseDelay          time.Duration
+	MaxDelay           time.Duration
+	LockoutThreshold   int
+	IPLockoutThreshold int
+	LockoutDuration    time.Duration
+	FailureWindow      time.Duration
+}
+
+// DefaultLoginPolicy is used unless configured otherwise.
+var DefaultLoginPolicy = LoginPolicy{
+	FreeAttempts:       3,
+	BaseDelay:          time.Second,
+	MaxDelay:           time.Minute,
+	LockoutThreshold:   10,
+	IPLockoutThreshold: 50,
+	LockoutDuration:    15 * time.Minute,
+	FailureWindow:      15 * time.Minute,
+}
+
+// SecurityService throttles failed logins per account and per IP and keeps
+// the security audit log.
+type SecurityService struct {
+	repo   repository.SecurityRepository
+	Policy LoginPolicy
+}
+
+func NewSecurityService(repo repository.SecurityRepository) *SecurityService {
+	return &SecurityService{repo: repo, Policy: DefaultLoginPolicy}
+}
+
+// CheckLogin returns a *LoginThrottledError if attempts for email or from
+// the client's IP have to wait.
+func (s *SecurityService) CheckLogin(email string, client models.ClientInfo) error {
+	var wait time.Duration
+	for _, k := range s.keys(email, client) {
+		t, err := s.repo.GetThrottle(k.scope, k.key)
+		if err != nil {
+			return err
+		}
+		wait = max(wait, s.waitFor(t, k.scope))
+	}
+	if wait <= 0 {
+		return nil
+	}
+
+	s.record(&models.SecurityEvent{Type: models.EventLoginBlocked, Email: email},
+		client, fmt.Sprintf("retry in %s", wait.Round(time.Second)))
+	return &LoginThrottledError{RetryAfter: wait}
+}
+
+// LoginFailed records a failed login (eventType is EventLoginFailed or
+// EventMFAFailed) against the account and the IP and locks them when they
+// reach their threshold. user is nil if email isn't registered.
+func (s *SecurityService) LoginFailed(eventType, email string, user *models.User, client models.ClientInfo, reason string) {
+	ev := &models.SecurityEvent{Type: eventType, Email: email}
+	if user != nil {
+		ev.UserID = &user.ID
+	}
+	s.record(ev, client, reason)
+
+	for _, k := range s.keys(email, client) {
+		t, err := s.repo.RecordFailure(k.scope, k.key, s.Policy.FailureWindow)
+		if err != nil {
+			log.Printf("⚠️ Failed to record login failure for %s %s: %v", k.scope, k.key, err)
+			continue
+		}
+		threshold, lockType := s.Policy.LockoutThreshold, models.EventAccountLocked
+		if k.scope == models.ThrottleIP {
+			threshold, lockType = s.Policy.IPLockoutThreshold, models.EventIPLocked
+		}
+		if threshold <= 0 || t.Failures < threshold {
+			continue
+		}
+
+		until := time.Now().UTC().Add(s.Policy.LockoutDuration)
+		if err := s.repo.Lock(k.scope, k.key, until); err != nil {
+			log.Printf("⚠️ Failed to lock %s %s: %v", k.scope, k.key, err)
+			continue
+		}
+		log.Printf("🔒 Locked %s %s until %s after %d failed logins", k.scope, k.key, until.Format(time.RFC3339), t.Failures)
+		lock := &models.SecurityEvent{Type: lockType, Email: email, UserID: ev.UserID}
+		s.record(lock, client, fmt.Sprintf("%d failed logins, locked until %s", t.Failures, until.Format(time.RFC3339)))
+	}
+}
+
+// LoginSucceeded records a completed login (method is "password" or
+// "oidc:<provider>") and forgets the account's failures. The IP's failures
+// are kept so one valid account can't be used to reset them.
+func (s *SecurityService) LoginSucceeded(user *models.User, client models.ClientInfo, method string) {
+	s.record(&models.SecurityEvent{Type: models.EventLoginSucceeded, UserID: &user.ID, Email: user.Email}, client, method)
+	if err := s.repo.ClearThrottle(models.ThrottleAccount, accountKey(user.Email)); err != nil {
+		log.Printf("⚠️ Failed to clear login failures of %s: %v", user.ID, err)
+	}
+}
+
+// AccountChanged records a change the user made to their account
+// (EventProfileUpdated, EventPasswordChanged, EventAccountDeleted). email is
+// empty for a deleted account.
+func (s *SecurityService) AccountChanged(eventType string, userID uuid.UUID, email string, client models.ClientInfo, detail string) {
+	s.record(&models.SecurityEvent{Type: eventType, UserID: &userID, Email: email}, client, detail)
+}
+
+// ListEvents returns the newest audit log entries matching f.
+func (s *SecurityService) ListEvents(f models.SecurityEventFilter) ([]models.SecurityEvent, error) {
+	if f.Limit <= 0 || f.Limit > 500 {
+		f.Limit = 100
+	}
+	return s.repo.ListEvents(f)
+}
+
+// waitFor returns how long the next attempt for t has to wait.
+func (s *SecurityService) waitFor(t *models.LoginThrottle, scope string) time.Duration {
+	if t == nil {
+		return 0
+	}
+	now := time.Now().UTC()
+	if t.LockedUntil != nil && t.LockedUntil.After(now) {
+		return t.LockedUntil.Sub(now)
+	}
+	// Progressive delays apply to accounts; IPs are shared (NAT, offices)
+	// and only get locked at their higher threshold.
+	if scope != models.ThrottleAccount || t.Failures <= s.Policy.FreeAttempts ||
+		now.Sub(t.LastFailureAt) > s.Policy.FailureWindow {
+		return 0
+	}
+	delay := s.Policy.BaseDelay
+	for i := s.Policy.FreeAttempts + 1; i < t.Failures && delay < s.Policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, s.Policy.MaxDelay)
+	return t.LastFailureAt.Add(delay).Sub(now)
+}
+
+type throttleKey struct{ scope, key string }
+
+func (s *SecurityService) keys(email string, client models.ClientInfo) []throttleKey {
+	keys := []throttleKey{{models.ThrottleAccount, accountKey(email)}}
+	if client.IP != "" {
+		keys = append(keys, throttleKey{models.ThrottleIP, client.IP})
+	}
+	return keys
+}
+
+// record writes ev to the audit log. A failed write is logged rather than
+// failing the login.
+func (s *SecurityService) record(ev *models.SecurityEvent, client models.ClientInfo, detail string) {
+	ev.Email = accountKey(ev.Email)
+	ev.IP, ev.UserAgent, ev.Detail = client.IP, client.UserAgent, detail
+	if err := s.repo.RecordEvent(ev); err != nil {
+		log.Printf("⚠️ Failed to write security event %s: %v", ev.Type, err)
+	}
+}
+
+func accountKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"auth_service/internal/models"
+	"auth_service/internal/repository"
+	"auth_service/internal/utils"
+)
+
+var (
+	// ErrInvalidCode means the TOTP or recovery code is wrong or was used.
+	ErrInvalidCode = errors.New("invalid verification code")
+	// ErrTwoFactorEnabled means two-factor authentication is already on.
+	ErrTwoFactorEnabled = errors.New("two-factor authentication already enabled")
+	// ErrTwoFactorDisabled means the user has no confirmed authenticator.
+	ErrTwoFactorDisabled = errors.New("two-factor authentication not enabled")
+)
+
+const (
+	recoveryCodeCount = 10
+	// maxMFAAttempts is how many wrong codes a second login step survives.
+	maxMFAAttempts = 5
+)
+
+// TwoFactorStatus reports whether the user has two-factor authentication on.
+func (s *UserService) TwoFactorStatus(userID uuid.UUID) (*models.TwoFactorStatus, error) {
+	t, err := s.totpRepo.GetTOTP(userID)
+	if errors.Is(err, repository.ErrTOTPNotFound) || (err == nil && t.ConfirmedAt == nil) {
+		return &models.TwoFactorStatus{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	left, err := s.totpRepo.CountRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.TwoFactorStatus{Enabled: true, RecoveryCodesLeft: left}, nil
+}
+
+// SetupTOTP creates a new authenticator secret for the user. It protects
+// logins only after ConfirmTOTP.
+func (s *UserService) SetupTOTP(userID uuid.UUID) (*models.TOTPSetup, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	secret, err := utils.NewTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.totpRepo.SaveTOTPSecret(userID, secret); err != nil {
+		if errors.Is(err, repository.ErrTOTPConfirmed) {
+			return nil, ErrTwoFactorEnabled
+		}
+		return nil, err
+	}
+	return &models.TOTPSetup{Secret: secret, URI: utils.TOTPURI(s.TOTPIssuer, user.Email, secret)}, nil
+}
+
+// ConfirmTOTP turns two-factor authentication on once the user proves their
+// app generates valid codes, and returns the first recovery codes.
+func (s *UserService) ConfirmTOTP(userID uuid.UUID, code string) (*models.RecoveryCodes, error) {
+	t, err := s.totpRepo.GetTOTP(userID)
+	if errors.Is(err, repository.ErrTOTPNotFound) {
+		return nil, ErrTwoFactorDisabled
+	}
+	if err != nil {
+		return nil, err
+	}
+	if t.ConfirmedAt != nil {
+		return nil, ErrTwoFactorEnabled
+	}
+	step, ok := utils.VerifyTOTP(t.Secret, code, time.Now(), t.LastUsedStep)
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+
+	codes, hashes, err := s.newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.totpRepo.EnableTOTP(userID, step, hashes); err != nil {
+		if errors.Is(err, repository.ErrTOTPConfirmed) {
+			return nil, ErrTwoFactorEnabled
+		}
+		return nil, err
+	}
+	log.Printf("🔐 Two-factor authentication enabled for %s", userID)
+	return codes, nil
+}
+
+// DisableTOTP turns two-factor authentication off; it takes a current code
+// so a stolen access token alone can't do it.
+func (s *UserService) DisableTOTP(userID uuid.UUID, code string) error {
+	if err := s.checkSecondFactor(userID, code); err != nil {
+		return err
+	}
+	if err := s.totpRepo.DisableTOTP(userID); err != nil {
+		return err
+	}
+	log.Printf("🔓 Two-factor authentication disabled for %s", userID)
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes.
+func (s *UserService) RegenerateRecoveryCodes(userID uuid.UUID, code string) (*models.RecoveryCodes, error) {
+	if err := s.checkSecondFactor(userID, code); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := s.newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.totpRepo.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// CompleteMFALogin finishes a login started with the password: a valid TOTP
+// or recovery code exchanges mfaToken for the access token. Wrong codes count
+// as failed logins of the account.
+func (s *UserService) CompleteMFALogin(mfaToken, code string, client models.ClientInfo) (*models.Session, error) {
+	hash, err := utils.VerifySignedToken(s.jwtSecret, models.TokenLoginMFA, mfaToken)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	userID, err := s.totpRepo.GetMFAChallenge(hash)
+	if err != nil {
+		return nil, tokenError(err)
+	}
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.security.CheckLogin(user.Email, client); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkSecondFactor(userID, code); err != nil {
+		if errors.Is(err, ErrInvalidCode) {
+			s.security.LoginFailed(models.EventMFAFailed, user.Email, user, client, "wrong code")
+			if ferr := s.totpRepo.FailMFAChallenge(hash, maxMFAAttempts); ferr != nil {
+				return nil, ferr
+			}
+		}
+		return nil, err
+	}
+	if err := s.totpRepo.UseMFAChallenge(hash); err != nil {
+		return nil, tokenError(err)
+	}
+	return s.finishSession(user, client, "2fa")
+}
+
+// startMFAChallenge hands out the token for the second login step.
+func (s *UserService) startMFAChallenge(user *models.User) (*models.Session, error) {
+	token, hash, err := utils.NewSignedToken(s.jwtSecret, models.TokenLoginMFA)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().UTC().Add(s.MFATTL)
+	err = s.userRepo.CreateToken(&models.UserToken{
+		UserID:    user.ID,
+		Purpose:   models.TokenLoginMFA,
+		TokenHash: hash,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &models.Session{MFARequired: true, MFAToken: token, ExpiresAt: expiresAt}, nil
+}
+
+// twoFactorEnabled reports whether logins of the user need a second factor.
+func (s *UserService) twoFactorEnabled(userID uuid.UUID) (bool, error) {
+	t, err := s.totpRepo.GetTOTP(userID)
+	if errors.Is(err, repository.ErrTOTPNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return t.ConfirmedAt != nil, nil
+}
+
+// checkSecondFactor accepts a current TOTP code or an unused recovery code.
+// Either works only once.
+func (s *UserService) checkSecondFactor(userID uuid.UUID, code string) error {
+	t, err := s.totpRepo.GetTOTP(userID)
+	if errors.Is(err, repository.ErrTOTPNotFound) || (err == nil && t.ConfirmedAt == nil) {
+		return ErrTwoFactorDisabled
+	}
+	if err != nil {
+		return err
+	}
+
+	if utils.IsTOTPCode(code) {
+		step, ok := utils.VerifyTOTP(t.Secret, code, time.Now(), t.LastUsedStep)
+		if !ok {
+			return ErrInvalidCode
+		}
+		return codeError(s.totpRepo.UseTOTPStep(userID, step))
+	}
+
+	if err := s.totpRepo.UseRecoveryCode(userID, utils.HashRecoveryCode(s.jwtSecret, code)); err != nil {
+		return codeError(err)
+	}
+	log.Printf("🧯 Recovery code used by %s", userID)
+	return nil
+}
+
+func (s *UserService) newRecoveryCodes() (*models.RecoveryCodes, [][]byte, error) {
+	codes, err := utils.NewRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([][]byte, len(codes))
+	for i, c := range codes {
+		hashes[i] = utils.HashRecoveryCode(s.jwtSecret, c)
+	}
+	return &models.RecoveryCodes{Codes: codes}, hashes, nil
+}
+
+func codeError(err error) error {
+	if errors.Is(err, repository.ErrCodeUsed) {
+		return ErrInvalidCode
+	}
+	return err
+}
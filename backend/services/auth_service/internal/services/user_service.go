@@ -1,35 +1,96 @@
 package services
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"slices"
+	"strings"
 	"time"
 
-	"github.com/IBM/sarama"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 
 	"auth_service/internal/models"
 	"auth_service/internal/repository"
 	"auth_service/internal/utils"
+
+	"contracts/auth"
+	"contracts/events"
+	"contracts/mailer"
+	"contracts/outbox"
 )
 
 type UserService struct {
-	userRepo     repository.UserRepository
-	KafkaBrokers []string
-	KafkaTopic   string
+	userRepo   repository.UserRepository
+	roleRepo   repository.RoleRepository
+	totpRepo   repository.TOTPRepository
+	deletions  repository.AccountDeletionRepository
+	security   *SecurityService
+	KafkaTopic string
+
+	// DeletionTopic receives user_deleted; PurgeServices are the services
+	// expected to report back before a deletion counts as complete.
+	DeletionTopic string
+	PurgeServices []string
+	// ReauthWindow is how recently an account without a password must have
+	// signed in to be deleted.
+	ReauthWindow time.Duration
+
+	// jwtSecret signs access tokens, which are valid for tokenTTL.
+	jwtSecret []byte
+	tokenTTL  time.Duration
+	// adminEmails are granted the admin role when they log in.
+	adminEmails []string
+
+	mailer mailer.Mailer
+	// AppURL is the frontend the verification and reset links point to.
+	AppURL string
+	// VerificationTTL and ResetTTL are how long emailed links stay valid.
+	VerificationTTL time.Duration
+	ResetTTL        time.Duration
+	// RequireVerifiedEmail blocks login until the email is verified.
+	RequireVerifiedEmail bool
+
+	// TOTPIssuer names this service in authenticator apps. MFATTL is how
+	// long the second login step may take.
+	TOTPIssuer string
+	MFATTL     time.Duration
 }
 
 func NewUserService(
 	userRepo repository.UserRepository,
-	kafkaBrokers []string,
+	roleRepo repository.RoleRepository,
+	totpRepo repository.TOTPRepository,
+	deletions repository.AccountDeletionRepository,
+	security *SecurityService,
 	kafkaTopic string,
+	jwtSecret string,
+	tokenTTL time.Duration,
+	adminEmails []string,
+	m mailer.Mailer,
 ) *UserService {
 	return &UserService{
-		userRepo:     userRepo,
-		KafkaBrokers: kafkaBrokers,
-		KafkaTopic:   kafkaTopic,
+		userRepo:    userRepo,
+		roleRepo:    roleRepo,
+		totpRepo:    totpRepo,
+		deletions:   deletions,
+		security:    security,
+		KafkaTopic:  kafkaTopic,
+		jwtSecret:   []byte(jwtSecret),
+		tokenTTL:    tokenTTL,
+		adminEmails: adminEmails,
+		mailer:      m,
+
+		VerificationTTL:      24 * time.Hour,
+		ResetTTL:             time.Hour,
+		RequireVerifiedEmail: true,
+		TOTPIssuer:           "Chat Platform",
+		MFATTL:               5 * time.Minute,
+		DeletionTopic:        "user_deleted",
+		PurgeServices: []string{events.ProducerChatData, events.ProducerEmbedding,
+			events.ProducerOCR, events.ProducerSubscription},
+		ReauthWindow: 10 * time.Minute,
 	}
 }
 
@@ -41,6 +102,10 @@ func (s *UserService) RegisterUser(username, email, password string) (*models.Us
 		return nil, errors.New("user with this email already exists")
 	}
 
+	if err := validatePassword(password); err != nil {
+		return nil, err
+	}
+
 	// 2. Hash the password
 	hashedPassword, err := utils.HashPassword(password)
 	if err != nil {
@@ -56,72 +121,107 @@ func (s *UserService) RegisterUser(username, email, password string) (*models.Us
 		CreatedAt:    time.Now().UTC(),
 	}
 
-	// 4. Insert into DB
-	createdUser, err := s.userRepo.CreateUser(user)
+	// 4. Build the user_registered event; the outbox relay publishes it
+	msg, err := s.registeredMessage(user)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create user: %w", err)
+		return nil, err
 	}
 
-	// 5. Publish Kafka event
-	if err := s.publishUserRegisteredEvent(createdUser); err != nil {
-		log.Printf("⚠️ Failed to publish Kafka event: %v\n", err)
+	// 5. Insert user and outbox event in one transaction
+	createdUser, err := s.userRepo.CreateUserWithEvent(user, msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 
 	log.Printf("✅ User registered successfully: %s\n", createdUser.Email)
+
+	// 6. Doğrulama e-postası (gönderilemezse kullanıcı yeniden isteyebilir)
+	s.sendVerificationEmail(createdUser)
 	return createdUser, nil
 }
 
-// LoginUser validates user credentials
-func (s *UserService) LoginUser(email, password string) (*models.User, error) {
+// LoginUser validates user credentials and issues an access token carrying
+// the user's roles and permissions, or starts the second step for users with
+// two-factor authentication. Repeated failures for the account or from the
+// client's IP pause further attempts (*LoginThrottledError).
+func (s *UserService) LoginUser(email, password string, client models.ClientInfo) (*models.Session, error) {
+	if err := s.security.CheckLogin(email, client); err != nil {
+		return nil, err
+	}
+
 	user, err := s.userRepo.GetByEmail(email)
 	if err != nil || user == nil {
-		return nil, errors.New("invalid email or password")
+		s.security.LoginFailed(models.EventLoginFailed, email, nil, client, "unknown email")
+		return nil, ErrInvalidCredentials
 	}
 
 	if !utils.CheckPasswordHash(password, user.PasswordHash) {
-		return nil, errors.New("invalid email or password")
+		s.security.LoginFailed(models.EventLoginFailed, email, user, client, "wrong password")
+		return nil, ErrInvalidCredentials
+	}
+	if s.RequireVerifiedEmail && user.EmailVerifiedAt == nil {
+		return nil, ErrEmailNotVerified
 	}
 
-	return user, nil
+	return s.startSession(user, client, "password")
 }
 
-// publishUserRegisteredEvent publishes Kafka event for other services
-func (s *UserService) publishUserRegisteredEvent(user *models.User) error {
-	if len(s.KafkaBrokers) == 0 {
-		return fmt.Errorf("Kafka brokers not configured")
+// registeredMessage builds the user_registered outbox message for a new user.
+func (s *UserService) registeredMessage(user *models.User) (outbox.Message, error) {
+	event, err := events.New(events.ProducerAuth, user.ID.String(), &events.UserRegistered{
+		UserID:   user.ID.String(),
+		Email:    user.Email,
+		Username: user.Username,
+	})
+	if err != nil {
+		return outbox.Message{}, fmt.Errorf("failed to build event: %w", err)
 	}
+	return outbox.Message{Topic: s.KafkaTopic, Key: user.ID.String(), Envelope: event}, nil
+}
 
-	config := sarama.NewConfig()
-	config.Producer.Return.Successes = true
-
-	producer, err := sarama.NewSyncProducer(s.KafkaBrokers, config)
+// startSession signs in a user who just proved who they are with a password
+// or an identity provider. Users with two-factor authentication get an MFA
+// challenge instead of the access token.
+func (s *UserService) startSession(user *models.User, client models.ClientInfo, method string) (*models.Session, error) {
+	enabled, err := s.twoFactorEnabled(user.ID)
 	if err != nil {
-		return fmt.Errorf("failed to create Kafka producer: %w", err)
+		return nil, err
 	}
-	defer producer.Close()
-
-	event := map[string]interface{}{
-		"type":     "user_registered",
-		"user_id":  user.ID.String(),
-		"email":    user.Email,
-		"username": user.Username,
+	if enabled {
+		return s.startMFAChallenge(user)
 	}
+	return s.finishSession(user, client, method)
+}
 
-	payload, err := json.Marshal(event)
-	if err != nil {
-		return fmt.Errorf("failed to marshal event: %w", err)
-	}
+// finishSession records the login, grants bootstrap roles and issues the
+// access token.
+func (s *UserService) finishSession(user *models.User, client models.ClientInfo, method string) (*models.Session, error) {
+	s.security.LoginSucceeded(user, client, method)
 
-	msg := &sarama.ProducerMessage{
-		Topic: s.KafkaTopic,
-		Value: sarama.ByteEncoder(payload),
+	if slices.ContainsFunc(s.adminEmails, func(e string) bool { return strings.EqualFold(e, user.Email) }) {
+		if err := s.roleRepo.GrantRole(user.ID, auth.RoleAdmin); err != nil {
+			return nil, err
+		}
 	}
+	return s.issueToken(user)
+}
 
-	partition, offset, err := producer.SendMessage(msg)
+func (s *UserService) issueToken(user *models.User) (*models.Session, error) {
+	ur, err := s.roleRepo.GetUserRoles(user.ID)
 	if err != nil {
-		return fmt.Errorf("failed to send Kafka message: %w", err)
+		return nil, err
 	}
-
-	log.Printf("📤 Event sent to Kafka topic %s [partition:%d, offset:%d]\n", s.KafkaTopic, partition, offset)
-	return nil
+	user.Roles = ur.Roles
+
+	token, expiresAt, err := auth.Sign(s.jwtSecret, auth.Claims{
+		Username:         user.Username,
+		Email:            user.Email,
+		Roles:            ur.Roles,
+		Permissions:      ur.Permissions,
+		RegisteredClaims: jwt.RegisteredClaims{Subject: user.ID.String()},
+	}, s.tokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Session{Token: token, TokenType: "Bearer", ExpiresAt: expiresAt, User: user}, nil
 }
@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTP parameters (RFC 6238 defaults, which every authenticator app supports).
+const (
+	totpPeriod = 30
+	totpDigits = 6
+	// totpSkew is how many periods before and after now are accepted, to
+	// allow for clock drift and slow typing.
+	totpSkew = 1
+)
+
+var base32NoPad = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewTOTPSecret returns a random 160-bit secret, base32 encoded as
+// authenticator apps expect it.
+func NewTOTPSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base32NoPad.EncodeToString(b), nil
+}
+
+// TOTPURI returns the otpauth:// URI authenticator apps enrol from, usually
+// shown as a QR code.
+func TOTPURI(issuer, account, secret string) string {
+	label := url.PathEscape(issuer + ":" + account)
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(totpDigits))
+	q.Set("period", fmt.Sprint(totpPeriod))
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// VerifyTOTP checks code against secret at now and returns the time step it
+// matched. Only steps after lastStep are accepted, so a code can't be
+// replayed once used.
+func VerifyTOTP(secret, code string, now time.Time, lastStep int64) (int64, bool) {
+	key, err := base32NoPad.DecodeString(strings.ToUpper(secret))
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		if hmac.Equal([]byte(totpCode(key, step)), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// IsTOTPCode reports whether code looks like a TOTP code rather than a
+// recovery code.
+func IsTOTPCode(code string) bool {
+	if len(code) != totpDigits {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// totpCode is the HOTP value (RFC 4226) of key for counter step.
+func totpCode(key []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1_000_000)
+}
+
+// recoveryAlphabet leaves out i, l, o and 1, which are easy to misread. It
+// has 32 characters so every random byte maps to it without bias.
+const recoveryAlphabet = "abcdefghjkmnpqrstuvwxyz023456789"
+
+// NewRecoveryCodes returns n random one-time codes formatted as xxxxx-xxxxx.
+func NewRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+	b := make([]byte, 10)
+	for i := range codes {
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		var sb strings.Builder
+		for j, c := range b {
+			if j == 5 {
+				sb.WriteByte('-')
+			}
+			sb.WriteByte(recoveryAlphabet[c&31])
+		}
+		codes[i] = sb.String()
+	}
+	return codes, nil
+}
+
+// HashRecoveryCode returns the keyed hash a recovery code is stored under.
+// Codes are compared case-insensitively and with or without the dash.
+func HashRecoveryCode(secret []byte, code string) []byte {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("recovery_code." + code))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyTOTP(t *testing.T) {
+	// RFC 6238 appendix B: SHA-1 secret "12345678901234567890", codes cut to
+	// their last 6 digits.
+	const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		now      int64
+		lastStep int64
+		wantStep int64
+		wantOK   bool
+	}{
+		{"rfc vector 59", secret, "287082", 59, -1, 1, true},
+		{"rfc vector 1111111109", secret, "081804", 1111111109, 0, 37037036, true},
+		{"rfc vector 1234567890", secret, "005924", 1234567890, 0, 41152263, true},
+		{"rfc vector 2000000000", secret, "279037", 2000000000, 0, 66666666, true},
+		{"lowercase secret", strings.ToLower(secret), "005924", 1234567890, 0, 41152263, true},
+		{"previous period", secret, "005924", 1234567890 + 30, 0, 41152263, true},
+		{"next period", secret, "005924", 1234567890 - 30, 0, 41152263, true},
+		{"two periods late", secret, "005924", 1234567890 + 60, 0, 0, false},
+		{"two periods early", secret, "005924", 1234567890 - 60, 0, 0, false},
+		{"replayed step", secret, "005924", 1234567890, 41152263, 0, false},
+		{"step before last used", secret, "005924", 1234567890, 41152264, 0, false},
+		{"wrong code", secret, "005925", 1234567890, 0, 0, false},
+		{"too short", secret, "05924", 1234567890, 0, 0, false},
+		{"too long", secret, "0005924", 1234567890, 0, 0, false},
+		{"invalid secret", "not base32!", "005924", 1234567890, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := VerifyTOTP(tt.secret, tt.code, time.Unix(tt.now, 0), tt.lastStep)
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("VerifyTOTP() = (%d, %t), want (%d, %t)", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestNewTOTPSecretVerifies(t *testing.T) {
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		t.Fatalf("NewTOTPSecret: %v", err)
+	}
+	key, err := base32NoPad.DecodeString(secret)
+	if err != nil {
+		t.Fatalf("secret %q is not base32: %v", secret, err)
+	}
+	if len(key) != 20 {
+		t.Errorf("secret is %d bytes, want 20", len(key))
+	}
+
+	now := time.Unix(1_700_000_000, 0)
+	step := now.Unix() / totpPeriod
+	if got, ok := VerifyTOTP(secret, totpCode(key, step), now, 0); !ok || got != step {
+		t.Errorf("VerifyTOTP() = (%d, %t), want (%d, true)", got, ok, step)
+	}
+}
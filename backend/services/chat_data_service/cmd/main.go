@@ -1,11 +1,15 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"chat_data_service/internal/config"
@@ -15,6 +19,9 @@ import (
 	"chat_data_service/internal/repository"
 	"chat_data_service/internal/router"
 	"chat_data_service/internal/services"
+	"chat_data_service/internal/utils"
+	"contracts/events"
+	"contracts/kafka"
 
 	"github.com/IBM/sarama"
 )
@@ -51,137 +58,139 @@ func main() {
 
 	repo := repository.NewChatRepository(conn)
 	service := services.NewChatDataService(repo)
-	r := router.SetupRouter(service)
+	r := router.SetupRouter(service, []byte(cfg.JWTSecret))
 
 	if err := waitForKafka(strings.Split(cfg.KafkaBrokers, ","), 12, 5*time.Second); err != nil {
 		log.Fatalf("❌ %v", err)
 	}
 
-	go startKafkaConsumer(cfg, service)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// user_data_purged raporları için producer
+	producer, err := utils.NewProducer(strings.Split(cfg.KafkaBrokers, ","))
+	if err != nil {
+		log.Fatalf("❌ Kafka producer oluşturulamadı: %v", err)
+	}
+	defer producer.Close()
+
+	consumerDone := make(chan struct{})
+	go func() {
+		defer close(consumerDone)
+		startKafkaConsumer(ctx, cfg, service, producer)
+	}()
 
 	addr := fmt.Sprintf(":%s", cfg.Port)
+	server := &http.Server{Addr: addr, Handler: r}
+
+	go func() {
+		<-ctx.Done()
+		log.Println("🛑 Chat Data Service kapatılıyor...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
 	log.Printf("🚀 Chat Data Service %s portunda çalışıyor...", addr)
 
-	if err := http.ListenAndServe(addr, r); err != nil {
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		log.Fatalf("❌ Sunucu başlatılamadı: %v", err)
 	}
-}
-
-func startKafkaConsumer(cfg *config.Config, service *services.ChatDataService) {
-	log.Printf("🎧 Kafka consumer başlatılıyor (topic=%s, broker=%s)...", cfg.KafkaTopic, cfg.KafkaBrokers)
 
-	kafkaCfg := sarama.NewConfig()
-	kafkaCfg.Consumer.Return.Errors = true
-	kafkaCfg.Version = sarama.V2_8_0_0
+	// Let the consumer group leave cleanly so partitions are reassigned quickly.
+	<-consumerDone
+}
 
-	consumer, err := sarama.NewConsumer(strings.Split(cfg.KafkaBrokers, ","), kafkaCfg)
+func startKafkaConsumer(ctx context.Context, cfg *config.Config, service *services.ChatDataService, producer *utils.Producer) {
+	log.Printf("🎧 Kafka consumer başlatılıyor (topics=%s,%s, group=%s, broker=%s)...",
+		cfg.KafkaTopic, cfg.KafkaTopicUserDeleted, cfg.KafkaGroup, cfg.KafkaBrokers)
+
+	err := kafka.RunConsumerGroup(
+		ctx,
+		strings.Split(cfg.KafkaBrokers, ","),
+		cfg.KafkaGroup,
+		[]string{cfg.KafkaTopic, cfg.KafkaTopicUserDeleted},
+		kafka.ParseInitialOffset(cfg.KafkaInitialOffset),
+		func(msg *sarama.ConsumerMessage) error {
+			return handleMessage(service, producer, cfg.KafkaTopicUserDataPurged, msg)
+		},
+	)
 	if err != nil {
 		log.Fatalf("❌ Kafka consumer oluşturulamadı: %v", err)
 	}
-	defer consumer.Close()
+}
 
-	partitionConsumer, err := consumer.ConsumePartition(cfg.KafkaTopic, 0, sarama.OffsetNewest)
+// handleMessage stores a single chat event or purges a deleted user's
+// messages. Errors from ClickHouse or Kafka are returned so the consumer
+// retries the message instead of committing past it.
+func handleMessage(service *services.ChatDataService, producer *utils.Producer, purgedTopic string, msg *sarama.ConsumerMessage) error {
+	log.Printf("📥 Kafka mesajı alındı: %s", string(msg.Value))
+
+	env, payload, err := events.Decode(msg.Value)
+	if errors.Is(err, events.ErrUnknownEvent) {
+		log.Printf("⚠️ Unknown event type: %v", err)
+		return nil
+	}
 	if err != nil {
-		log.Fatalf("❌ Kafka partition dinlenemedi: %v", err)
+		return fmt.Errorf("%w: %v", kafka.ErrInvalidMessage, err)
 	}
-	defer partitionConsumer.Close()
 
-	for msg := range partitionConsumer.Messages() {
-		log.Printf("📥 Kafka mesajı alındı: %s", string(msg.Value))
+	switch evt := payload.(type) {
+	case *events.ChatCompleted:
+		chMsg := &models.ChatMessage{
+			UserID:         evt.UserID,
+			UserMessage:    evt.Message,
+			AIResponse:     evt.Response,
+			ConversationID: evt.ConversationID,
+			Timestamp:      env.OccurredAt,
+		}
+
+		if err := service.SaveMessage(chMsg); err != nil {
+			return fmt.Errorf("ClickHouse kaydedilemedi: %w", err)
+		}
+		log.Printf("✅ Mesaj ClickHouse'a kaydedildi: user=%s, user_msg=%s, ai_msg=%s",
+			evt.UserID, chMsg.UserMessage, chMsg.AIResponse)
+
+	case *events.FileAttached: // ✅ YENİ
+		// ClickHouse'a kaydet (AI response boş)
+		fileMsg := &models.ChatMessage{
+			UserID:         evt.UserID,
+			UserMessage:    evt.Message, // "📎 öneri.pdf"
+			AIResponse:     "",          // AI response yok
+			ConversationID: evt.ConversationID,
+			Timestamp:      env.OccurredAt,
+		}
 
-		var rawEvent map[string]interface{}
-		if err := json.Unmarshal(msg.Value, &rawEvent); err != nil {
-			log.Printf("❌ Invalid JSON: %v", err)
-			continue
+		if err := service.SaveMessage(fileMsg); err != nil {
+			return fmt.Errorf("file attachment message save error: %w", err)
 		}
 
-		eventType, _ := rawEvent["event_type"].(string)
-
-		switch eventType {
-		case "chat_completed":
-			var event struct {
-				EventType      string `json:"event_type"`
-				UserID         string `json:"user_id"`
-				Message        string `json:"message"`
-				Response       string `json:"response"`
-				ConversationID string `json:"conversation_id"`
-				Timestamp      string `json:"timestamp"`
-			}
-
-			if err := json.Unmarshal(msg.Value, &event); err != nil {
-				log.Printf("⚠️ JSON çözümlenemedi: %v", err)
-				continue
-			}
-
-			ts := time.Now().UTC()
-			if event.Timestamp != "" {
-				t, err := time.Parse(time.RFC3339, event.Timestamp)
-				if err == nil {
-					ts = t
-				} else {
-					log.Printf("⚠️ Timestamp parse hatası: %v", err)
-				}
-			}
-
-			chMsg := &models.ChatMessage{
-				UserID:         event.UserID,
-				UserMessage:    event.Message,
-				AIResponse:     event.Response,
-				ConversationID: event.ConversationID,
-				Timestamp:      ts,
-			}
-
-			if err := service.SaveMessage(chMsg); err != nil {
-				log.Printf("❌ ClickHouse kaydedilemedi: %v", err)
-			} else {
-				log.Printf("✅ Mesaj ClickHouse'a kaydedildi: user=%s, user_msg=%s, ai_msg=%s",
-					event.UserID, chMsg.UserMessage, chMsg.AIResponse)
-			}
-
-		case "file_attached": // ✅ YENİ
-			var fileEvent struct {
-				EventType      string `json:"event_type"`
-				UserID         string `json:"user_id"`
-				Message        string `json:"message"`
-				ConversationID string `json:"conversation_id"`
-				FileID         string `json:"file_id"`
-				FileName       string `json:"file_name"`
-				Timestamp      string `json:"timestamp"`
-			}
-
-			if err := json.Unmarshal(msg.Value, &fileEvent); err != nil {
-				log.Printf("❌ Failed to parse file_attached event: %v", err)
-				continue
-			}
-
-			// Timestamp parse
-			timestamp := time.Now().UTC()
-			if fileEvent.Timestamp != "" {
-				t, err := time.Parse(time.RFC3339, fileEvent.Timestamp)
-				if err == nil {
-					timestamp = t
-				}
-			}
-
-			// ClickHouse'a kaydet (AI response boş)
-			fileMsg := &models.ChatMessage{
-				UserID:         fileEvent.UserID,
-				UserMessage:    fileEvent.Message, // "📎 öneri.pdf"
-				AIResponse:     "",                // AI response yok
-				ConversationID: fileEvent.ConversationID,
-				Timestamp:      timestamp,
-			}
-
-			if err := service.SaveMessage(fileMsg); err != nil {
-				log.Printf("❌ File attachment message save error: %v", err)
-				continue
-			}
-
-			log.Printf("✅ File attachment message saved: user=%s, file=%s, conversation=%s",
-				fileEvent.UserID, fileEvent.FileName, fileEvent.ConversationID)
-
-		default:
-			log.Printf("⚠️ Unknown event type: %s", eventType)
+		log.Printf("✅ File attachment message saved: user=%s, file=%s, conversation=%s",
+			evt.UserID, evt.FileName, evt.ConversationID)
+
+	case *events.UserDeleted:
+		// Hesap silindi → tüm mesajlarını sil, auth_service'e raporla
+		n, err := service.PurgeUser(evt.UserID)
+		if err != nil {
+			return fmt.Errorf("mesajlar silinemedi: %w", err)
+		}
+		b, err := events.Marshal(events.ProducerChatData, env.CorrelationID, &events.UserDataPurged{
+			DeletionID:   evt.DeletionID,
+			UserID:       evt.UserID,
+			Service:      events.ProducerChatData,
+			ItemsDeleted: int(n),
+			Detail:       "chat messages",
+		})
+		if err != nil {
+			return fmt.Errorf("%w: %v", kafka.ErrInvalidMessage, err)
 		}
+		if err := producer.Publish(purgedTopic, evt.UserID, b); err != nil {
+			return fmt.Errorf("user_data_purged gönderilemedi: %w", err)
+		}
+		log.Printf("🗑️ Kullanıcı %s silindi: %d mesaj ClickHouse'tan kaldırıldı", evt.UserID, n)
+
+	default:
+		log.Printf("⚠️ Unhandled event type: %s", env.Type)
 	}
+	return nil
 }
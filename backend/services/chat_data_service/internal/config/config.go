@@ -10,6 +10,15 @@ type Config struct {
 	ClickHousePassword string
 	KafkaBrokers       string
 	KafkaTopic         string
+	KafkaGroup         string
+	KafkaInitialOffset string
+	// KafkaTopicUserDeleted triggers the erasure of a deleted user's
+	// messages, reported on KafkaTopicUserDataPurged.
+	KafkaTopicUserDeleted    string
+	KafkaTopicUserDataPurged string
+	// JWTSecret verifies the access tokens of history requests; the same
+	// secret auth_service signs them with.
+	JWTSecret string
 }
 
 func Load() (*Config, error) {
@@ -21,6 +30,13 @@ func Load() (*Config, error) {
 		ClickHousePassword: getEnv("CLICKHOUSE_PASSWORD", ""),
 		KafkaBrokers:       getEnv("KAFKA_BROKERS", "kafka:9092"),
 		KafkaTopic:         getEnv("KAFKA_TOPIC", "chat_messages"),
+		KafkaGroup:         getEnv("KAFKA_GROUP", "chat-data-service-group"),
+		KafkaInitialOffset: getEnv("KAFKA_INITIAL_OFFSET", "newest"),
+
+		KafkaTopicUserDeleted:    getEnv("KAFKA_TOPIC_USER_DELETED", "user_deleted"),
+		KafkaTopicUserDataPurged: getEnv("KAFKA_TOPIC_USER_DATA_PURGED", "user_data_purged"),
+
+		JWTSecret: getEnv("JWT_SECRET", ""),
 	}, nil
 }
 
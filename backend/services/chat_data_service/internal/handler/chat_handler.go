@@ -2,12 +2,16 @@ package handler
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
 
+	"chat_data_service/internal/models"
 	"chat_data_service/internal/services"
 
+	"contracts/auth"
+
 	"github.com/google/uuid"
 )
 
@@ -30,6 +34,11 @@ func (h *ChatDataHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "invalid user_id", http.StatusBadRequest)
 		return
 	}
+	// Only the token's own user reads their history
+	if c, ok := auth.ClaimsFrom(r.Context()); !ok || c.UserID() != userID {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
 
 	limit := 50
 	if v := r.URL.Query().Get("limit"); v != "" {
@@ -56,6 +65,43 @@ func (h *ChatDataHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, hist)
 }
 
+// ExportMessages handles GET /internal/users/{user_id}/messages: every
+// message of the user as JSON lines, for auth_service's data export. It is
+// not routed by the API gateway.
+func (h *ChatDataHandler) ExportMessages(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	parts := splitPath(r.URL.Path)
+	if len(parts) != 5 || parts[4] != "messages" {
+		http.NotFound(w, r)
+		return
+	}
+	userID := parts[3]
+	if _, err := uuid.Parse(userID); err != nil {
+		http.Error(w, "invalid user_id", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	n := 0
+	err := h.service.ExportMessages(r.Context(), userID, func(msg *models.ChatMessage) error {
+		n++
+		return enc.Encode(msg)
+	})
+	if err != nil {
+		if n == 0 {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// Headers are gone; a cut-off body makes the reader fail.
+		log.Printf("❌ Export of user %s failed after %d message(s): %v", userID, n, err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
 func splitPath(p string) []string {
 	out := []string{}
 	cur := ""
@@ -55,3 +55,46 @@ func (r *ChatRepository) GetHistory(ctx context.Context, userID string, conversa
 	}
 	return messages, nil
 }
+
+// EachUserMessage calls fn for every message of the user, conversation by
+// conversation and oldest first, without loading them all into memory.
+func (r *ChatRepository) EachUserMessage(ctx context.Context, userID string, fn func(*models.ChatMessage) error) error {
+	rows, err := r.db.Query(ctx, `
+		SELECT user_id, user_message, ai_response, conversation_id, timestamp
+		FROM chat_messages
+		WHERE user_id = ?
+		ORDER BY conversation_id, timestamp`, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var msg models.ChatMessage
+		if err := rows.ScanStruct(&msg); err != nil {
+			return err
+		}
+		if err := fn(&msg); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// DeleteUserMessages deletes every message of the user and returns how many
+// there were. The mutation is waited for, so the messages are gone when it
+// returns.
+func (r *ChatRepository) DeleteUserMessages(ctx context.Context, userID string) (uint64, error) {
+	var n uint64
+	if err := r.db.QueryRow(ctx, `SELECT count() FROM chat_messages WHERE user_id = ?`, userID).Scan(&n); err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	ctx = clickhouse.Context(ctx, clickhouse.WithSettings(clickhouse.Settings{"mutations_sync": 1}))
+	if err := r.db.Exec(ctx, `ALTER TABLE chat_messages DELETE WHERE user_id = ?`, userID); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
@@ -5,12 +5,23 @@ import (
 
 	"chat_data_service/internal/handler"
 	"chat_data_service/internal/services"
+
+	"contracts/auth"
 )
 
-func SetupRouter(service *services.ChatDataService) *http.ServeMux {
+func SetupRouter(service *services.ChatDataService, jwtSecret []byte) *http.ServeMux {
 	r := http.NewServeMux()
 	h := handler.NewChatDataHandler(service)
 
-	r.HandleFunc("/api/chat/history/", h.GetHistory)
+	// Health check (API gateway'in instance kontrolü)
+	r.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"ok","service":"chat_data_service"}`))
+	})
+
+	// Geçmiş yalnızca token'ın kullanıcısına açık
+	r.HandleFunc("/api/chat/history/", auth.RequireUser(jwtSecret, h.GetHistory))
+	// Yalnızca servisler arası (auth_service veri dışa aktarımı)
+	r.HandleFunc("/internal/users/", h.ExportMessages)
 	return r
 }
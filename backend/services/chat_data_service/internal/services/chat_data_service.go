@@ -44,3 +44,16 @@ func (s *ChatDataService) GetHistory(userID string, conversationID string, limit
 	ctx := context.Background()
 	return s.repo.GetHistory(ctx, userID, conversationID, limit, since)
 }
+
+// ExportMessages kullanıcının tüm mesajlarını (veri dışa aktarımı için)
+// sırayla fn'e verir
+func (s *ChatDataService) ExportMessages(ctx context.Context, userID string, fn func(*models.ChatMessage) error) error {
+	return s.repo.EachUserMessage(ctx, userID, fn)
+}
+
+// PurgeUser hesabı silinen kullanıcının tüm mesajlarını siler ve kaç mesaj
+// silindiğini döner
+func (s *ChatDataService) PurgeUser(userID string) (uint64, error) {
+	ctx := context.Background()
+	return s.repo.DeleteUserMessages(ctx, userID)
+}
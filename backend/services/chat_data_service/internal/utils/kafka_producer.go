@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"fmt"
+
+	"github.com/IBM/sarama"
+)
+
+// Producer publishes messages synchronously: Publish returns once the broker
+// has acknowledged the message.
+type Producer struct {
+	producer sarama.SyncProducer
+}
+
+// NewProducer connects to the brokers.
+func NewProducer(brokers []string) (*Producer, error) {
+	cfg := sarama.NewConfig()
+	cfg.Version = sarama.V2_8_0_0
+	cfg.Producer.Return.Successes = true
+	cfg.Producer.RequiredAcks = sarama.WaitForAll
+
+	p, err := sarama.NewSyncProducer(brokers, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("create kafka producer: %w", err)
+	}
+	return &Producer{producer: p}, nil
+}
+
+// Publish sends value to topic, keyed by key.
+func (p *Producer) Publish(topic, key string, value []byte) error {
+	_, _, err := p.producer.SendMessage(&sarama.ProducerMessage{
+		Topic: topic,
+		Key:   sarama.StringEncoder(key),
+		Value: sarama.ByteEncoder(value),
+	})
+	return err
+}
+
+func (p *Producer) Close() error {
+	return p.producer.Close()
+}
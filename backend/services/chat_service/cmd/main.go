@@ -1,15 +1,25 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"chat_service/internal/config"
+	"chat_service/internal/database"
+	"chat_service/internal/migrations"
 	"chat_service/internal/models"
 	"chat_service/internal/router"
 	"chat_service/internal/services"
+	"contracts/events"
+	"contracts/kafka"
+	"contracts/outbox"
 
 	"github.com/IBM/sarama"
 )
@@ -32,62 +42,96 @@ func waitForKafka(brokers []string, retries int) error {
 func main() {
 	cfg := config.LoadConfig()
 
-	chatSvc = services.NewChatService(cfg)
+	if err := database.Connect(cfg); err != nil {
+		log.Fatalf("❌ failed to connect to database: %v", err)
+	}
+	defer database.Close()
+
+	if err := migrations.Run(cfg.MigrationsPath); err != nil {
+		log.Fatalf("❌ failed to run migrations: %v", err)
+	}
 
-	app := router.SetupRouter(chatSvc)
+	chatSvc = services.NewChatService(cfg, database.DB)
+
+	app := router.SetupRouter(chatSvc, []byte(cfg.JWTSecret))
 
 	if err := waitForKafka(cfg.KafkaBrokers, 12); err != nil {
 		log.Fatalf("❌ %v", err)
 	}
 
-	go startChatConsumer(cfg)
-	go startEmbeddingConsumer(cfg)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	consumerDone := make(chan struct{})
+	go func() {
+		defer close(consumerDone)
+		startChatConsumer(ctx, cfg)
+	}()
+	go startEmbeddingConsumer(ctx, cfg)
+
+	relay := outbox.NewRelay(database.DB, cfg.KafkaBrokers, cfg.OutboxPollInterval, cfg.OutboxBatchSize)
+	relay.Retention = cfg.OutboxRetention
+	relayDone := make(chan struct{})
+	go func() {
+		defer close(relayDone)
+		relay.Run(ctx)
+	}()
+
+	go func() {
+		<-ctx.Done()
+		log.Println("🛑 Shutting down Chat Service...")
+		app.ShutdownWithTimeout(5 * time.Second)
+	}()
 
 	log.Printf("Starting Chat Service on port %s...", cfg.Port)
-	log.Fatal(app.Listen("0.0.0.0:" + cfg.Port))
-}
+	if err := app.Listen("0.0.0.0:" + cfg.Port); err != nil {
+		log.Fatal(err)
+	}
 
-func startChatConsumer(cfg *config.Config) {
-	configKafka := sarama.NewConfig()
-	configKafka.Consumer.Return.Errors = true
-	configKafka.Version = sarama.V2_8_0_0
+	// Let the consumer group leave cleanly so partitions are reassigned quickly.
+	<-consumerDone
+	<-relayDone
+}
 
-	master, err := sarama.NewConsumer(cfg.KafkaBrokers, configKafka)
+func startChatConsumer(ctx context.Context, cfg *config.Config) {
+	err := kafka.RunConsumerGroup(
+		ctx,
+		cfg.KafkaBrokers,
+		cfg.KafkaGroup,
+		[]string{cfg.KafkaTopicChatMessages},
+		kafka.ParseInitialOffset(cfg.KafkaInitialOffset),
+		handleChatMessage,
+	)
 	if err != nil {
 		log.Fatalf("failed to start Kafka consumer: %v", err)
 	}
-	defer master.Close()
+}
 
-	consumer, err := master.ConsumePartition(cfg.KafkaTopicChatMessages, 0, sarama.OffsetNewest)
+func handleChatMessage(msg *sarama.ConsumerMessage) error {
+	env, payload, err := events.Decode(msg.Value)
+	if errors.Is(err, events.ErrUnknownEvent) {
+		log.Printf("ℹ️ Ignored event: %v", err)
+		return nil
+	}
 	if err != nil {
-		log.Fatalf("failed to consume partition: %v", err)
+		return fmt.Errorf("%w: %v", kafka.ErrInvalidMessage, err)
 	}
-	defer consumer.Close()
-
-	log.Printf("🎧 Chat consumer started for topic: %s", cfg.KafkaTopicChatMessages)
-
-	for msg := range consumer.Messages() {
-		var event struct {
-			Type           string `json:"type"`
-			UserID         string `json:"user_id"`
-			Message        string `json:"message,omitempty"`
-			ConversationID string `json:"conversation_id,omitempty"`
-		}
-		if err := json.Unmarshal(msg.Value, &event); err != nil {
-			log.Printf("⚠️ Failed to unmarshal event: %v", err)
-			continue
-		}
 
-		switch event.Type {
-		case "quota_changed":
-			log.Printf("💡 Quota changed for user %s", event.UserID)
-		default:
-			log.Printf("ℹ️ Event: %s ; conversation=%s", event.Type, event.ConversationID)
-		}
+	switch evt := payload.(type) {
+	case *events.QuotaChanged:
+		log.Printf("💡 Quota changed for user %s: %d left", evt.UserID, evt.RemainingQuota)
+	case *events.ChatCompleted:
+		log.Printf("ℹ️ Event: %s ; conversation=%s", env.Type, evt.ConversationID)
+	default:
+		log.Printf("ℹ️ Event: %s", env.Type)
 	}
+	return nil
 }
 
-func startEmbeddingConsumer(cfg *config.Config) {
+// startEmbeddingConsumer reads every partition of the embedding topic from the
+// oldest offset without a consumer group. FileTracker is in-memory, so each
+// chat_service instance must see all events to rebuild its own file state.
+func startEmbeddingConsumer(ctx context.Context, cfg *config.Config) {
 	configKafka := sarama.NewConfig()
 	configKafka.Consumer.Return.Errors = true
 	configKafka.Version = sarama.V2_8_0_0
@@ -98,70 +142,97 @@ func startEmbeddingConsumer(cfg *config.Config) {
 	}
 	defer master.Close()
 
-	topic := "embedding_stored"
+	topic := cfg.KafkaTopicEmbedding
 
-	consumer, err := master.ConsumePartition(topic, 0, sarama.OffsetOldest)
+	partitions, err := master.Partitions(topic)
 	if err != nil {
-		log.Fatalf("failed to consume embedding partition: %v", err)
+		log.Fatalf("failed to list embedding partitions: %v", err)
 	}
-	defer consumer.Close()
-
-	log.Printf("🎧 Embedding consumer started for topic: %s (reading from oldest)", topic)
-
-	for msg := range consumer.Messages() {
-		log.Printf("📥 Raw Kafka message (offset=%d): %s", msg.Offset, string(msg.Value))
 
-		var evt models.EmbeddingStoredEvent
-		if err := json.Unmarshal(msg.Value, &evt); err != nil {
-			log.Printf("⚠️ Failed to unmarshal embedding event: %v", err)
-			continue
+	var wg sync.WaitGroup
+	for _, partition := range partitions {
+		consumer, err := master.ConsumePartition(topic, partition, sarama.OffsetOldest)
+		if err != nil {
+			log.Fatalf("failed to consume embedding partition %d: %v", partition, err)
 		}
 
-		if evt.Event == "EMBEDDING_STORED" {
-			log.Printf("📥 EMBEDDING_STORED event received: file_id=%s, chunks=%d",
-				evt.FileID, evt.TotalChunks)
-
-			// FileTracker'ı güncelle
-			chatSvc.GetFileTracker().UpdateStatus(
-				evt.FileID,
-				evt.FileName,
-				"ready",
-				evt.TotalChunks,
-			)
-
-			log.Printf("✅ File ready: %s (%s) with %d chunks",
-				evt.FileID, evt.FileName, evt.TotalChunks)
-
-			// ✅ YENİ: User bilgisi için 5 saniye bekle (retry)
-			var fileInfo *models.FileStatus
-			maxRetries := 5
-			for i := 0; i < maxRetries; i++ {
-				fileInfo = chatSvc.GetFileTracker().GetFileInfo(evt.FileID)
-				if fileInfo != nil && fileInfo.UserID != "" {
-					break // Bulundu!
+		wg.Add(1)
+		go func(pc sarama.PartitionConsumer) {
+			defer wg.Done()
+			defer pc.Close()
+			for {
+				select {
+				case msg, ok := <-pc.Messages():
+					if !ok {
+						return
+					}
+					handleEmbeddingMessage(msg)
+				case <-ctx.Done():
+					return
 				}
-				log.Printf("⏳ Waiting for user info... (attempt %d/%d)", i+1, maxRetries)
-				time.Sleep(1 * time.Second)
 			}
+		}(consumer)
+	}
 
-			if fileInfo != nil && fileInfo.UserID != "" {
-				// Kafka'ya file_attached eventi gönder
-				err := chatSvc.GetKafkaProducer().PublishFileAttached(
-					fileInfo.UserID,
-					evt.FileName,
-					evt.FileID,
-					fileInfo.ConversationID,
-				)
-				if err != nil {
-					log.Printf("⚠️ Failed to publish file_attached event: %v", err)
-				} else {
-					log.Printf("✅ File attachment message sent to chat history")
-				}
-			} else {
-				log.Printf("⚠️ No user info found for file %s after waiting, skipping chat history entry", evt.FileID)
-			}
+	log.Printf("🎧 Embedding consumer started for topic: %s (%d partitions, reading from oldest)", topic, len(partitions))
+	wg.Wait()
+}
+
+func handleEmbeddingMessage(msg *sarama.ConsumerMessage) {
+	log.Printf("📥 Raw Kafka message (partition=%d, offset=%d): %s", msg.Partition, msg.Offset, string(msg.Value))
+
+	_, payload, err := events.Decode(msg.Value)
+	if err != nil {
+		log.Printf("⚠️ Failed to decode embedding event: %v", err)
+		return
+	}
+
+	evt, ok := payload.(*events.EmbeddingStored)
+	if !ok {
+		log.Printf("⚠️ Unexpected event on embedding topic: %T", payload)
+		return
+	}
+
+	log.Printf("📥 EMBEDDING_STORED event received: file_id=%s, chunks=%d",
+		evt.FileID, evt.TotalChunks)
+
+	// FileTracker'ı güncelle
+	chatSvc.GetFileTracker().UpdateStatus(
+		evt.FileID,
+		evt.FileName,
+		"ready",
+		evt.TotalChunks,
+	)
+
+	log.Printf("✅ File ready: %s (%s) with %d chunks",
+		evt.FileID, evt.FileName, evt.TotalChunks)
+
+	// ✅ YENİ: User bilgisi için 5 saniye bekle (retry)
+	var fileInfo *models.FileStatus
+	maxRetries := 5
+	for i := 0; i < maxRetries; i++ {
+		fileInfo = chatSvc.GetFileTracker().GetFileInfo(evt.FileID)
+		if fileInfo != nil && fileInfo.UserID != "" {
+			break // Bulundu!
+		}
+		log.Printf("⏳ Waiting for user info... (attempt %d/%d)", i+1, maxRetries)
+		time.Sleep(1 * time.Second)
+	}
+
+	if fileInfo != nil && fileInfo.UserID != "" {
+		// Kafka'ya file_attached eventi gönder
+		err := chatSvc.GetKafkaProducer().PublishFileAttached(
+			fileInfo.UserID,
+			evt.FileName,
+			evt.FileID,
+			fileInfo.ConversationID,
+		)
+		if err != nil {
+			log.Printf("⚠️ Failed to publish file_attached event: %v", err)
 		} else {
-			log.Printf("⚠️ Unknown event type: '%s'", evt.Event)
+			log.Printf("✅ File attachment message sent to chat history")
 		}
+	} else {
+		log.Printf("⚠️ No user info found for file %s after waiting, skipping chat history entry", evt.FileID)
 	}
 }
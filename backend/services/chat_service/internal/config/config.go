@@ -3,6 +3,9 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // Config: Chat service config struct
@@ -12,10 +15,28 @@ type Config struct {
 	KafkaBrokers           []string
 	KafkaTopicChatMessages string
 	KafkaTopicEmbedding    string // ✅ YENİ
+	KafkaGroup             string
+	KafkaInitialOffset     string
 	AuthServiceURL         string
 	SubscriptionServiceURL string
 	QdrantURL              string // ✅ YENİ
 	XenovaURL              string // ✅ YENİ
+
+	// JWTSecret verifies the access tokens of chat requests (see contracts/auth)
+	JWTSecret string
+
+	// Postgres (outbox)
+	PostgresHost     string
+	PostgresPort     int
+	PostgresUser     string
+	PostgresPassword string
+	PostgresDB       string
+	MigrationsPath   string
+
+	OutboxPollInterval time.Duration
+	OutboxBatchSize    int
+	// OutboxRetention is how long published outbox rows are kept.
+	OutboxRetention time.Duration
 }
 
 // LoadConfig: Çevresel değişkenleri okuyup Config struct'ını döner
@@ -34,7 +55,7 @@ func LoadConfig() *Config {
 	if kafkaBrokersEnv == "" {
 		kafkaBrokersEnv = "localhost:9092"
 	}
-	kafkaBrokers := []string{kafkaBrokersEnv}
+	kafkaBrokers := strings.Split(kafkaBrokersEnv, ",")
 
 	kafkaTopic := os.Getenv("KAFKA_TOPIC")
 	if kafkaTopic == "" {
@@ -47,6 +68,16 @@ func LoadConfig() *Config {
 		kafkaTopicEmbedding = "embedding_stored"
 	}
 
+	kafkaGroup := os.Getenv("KAFKA_GROUP")
+	if kafkaGroup == "" {
+		kafkaGroup = "chat-service-group"
+	}
+
+	kafkaInitialOffset := os.Getenv("KAFKA_INITIAL_OFFSET")
+	if kafkaInitialOffset == "" {
+		kafkaInitialOffset = "newest"
+	}
+
 	authURL := os.Getenv("AUTH_SERVICE_URL")
 	if authURL == "" {
 		authURL = "http://localhost:8000"
@@ -74,9 +105,39 @@ func LoadConfig() *Config {
 		KafkaBrokers:           kafkaBrokers,
 		KafkaTopicChatMessages: kafkaTopic,
 		KafkaTopicEmbedding:    kafkaTopicEmbedding, // ✅ YENİ
+		KafkaGroup:             kafkaGroup,
+		KafkaInitialOffset:     kafkaInitialOffset,
 		AuthServiceURL:         authURL,
 		SubscriptionServiceURL: subscriptionURL,
 		QdrantURL:              qdrantURL, // ✅ YENİ
 		XenovaURL:              xenovaURL, // ✅ YENİ
+		JWTSecret:              getEnv("JWT_SECRET", ""),
+
+		PostgresHost:     getEnv("POSTGRES_HOST", "localhost"),
+		PostgresPort:     getEnvInt("POSTGRES_PORT", 5432),
+		PostgresUser:     getEnv("POSTGRES_USER", "postgres"),
+		PostgresPassword: getEnv("POSTGRES_PASSWORD", "postgres"),
+		PostgresDB:       getEnv("POSTGRES_DB", "chat_db"),
+		MigrationsPath:   getEnv("MIGRATIONS_PATH", "internal/migrations"),
+
+		OutboxPollInterval: time.Duration(getEnvInt("OUTBOX_POLL_INTERVAL_MS", 1000)) * time.Millisecond,
+		OutboxBatchSize:    getEnvInt("OUTBOX_BATCH_SIZE", 100),
+		OutboxRetention:    time.Duration(getEnvInt("OUTBOX_RETENTION_DAYS", 7)) * 24 * time.Hour,
+	}
+}
+
+func getEnv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func getEnvInt(key string, def int) int {
+	if v := os.Getenv(key); v != "" {
+		if i, err := strconv.Atoi(v); err == nil {
+			return i
+		}
 	}
+	return def
 }
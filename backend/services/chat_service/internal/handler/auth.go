@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+
+	"contracts/auth"
+)
+
+// userIDKey holds the id of the token's user in fiber.Ctx.Locals.
+const userIDKey = "user_id"
+
+// RequireUser lets a request through only with a valid access token (see
+// contracts/auth) and stores its user id for the handlers. A missing or
+// invalid token is answered with 401; an empty secret rejects every request.
+func RequireUser(secret []byte) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token, err := auth.ParseBearer(c.Get(fiber.HeaderAuthorization))
+		var claims *auth.Claims
+		if err == nil && len(secret) > 0 {
+			claims, err = auth.Verify(secret, token)
+		}
+		if err != nil || claims == nil {
+			c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="api"`)
+			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
+				"error": "authentication required",
+			})
+		}
+		c.Locals(userIDKey, claims.UserID())
+		return c.Next()
+	}
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"log"
 	"net/http"
 
@@ -24,7 +25,15 @@ func NewChatHandlerWithService(chatService *services.ChatService) *ChatHandler {
 	}
 }
 
+// HandleChat answers a message of the token's user (see RequireUser).
 func (h *ChatHandler) HandleChat(c *fiber.Ctx) error {
+	userID, _ := c.Locals(userIDKey).(string)
+	if userID == "" {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
+			"error": "authentication required",
+		})
+	}
+
 	var req models.ChatRequest
 
 	if err := c.BodyParser(&req); err != nil {
@@ -34,17 +43,17 @@ func (h *ChatHandler) HandleChat(c *fiber.Ctx) error {
 		})
 	}
 
-	if req.UserID == "" || req.Message == "" {
+	if req.Message == "" {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
-			"error": "user_id and message are required",
+			"error": "message is required",
 		})
 	}
 
 	log.Printf("📥 Chat request: user=%s, message='%s', file_id='%s'",
-		req.UserID, truncate(req.Message, 50), req.FileID)
+		userID, truncate(req.Message, 50), req.FileID)
 
 	response, convID, err := h.chatService.HandleUserMessage(
-		req.UserID,
+		userID,
 		req.Message,
 		req.ConversationID,
 		req.FileID,
@@ -52,7 +61,14 @@ func (h *ChatHandler) HandleChat(c *fiber.Ctx) error {
 
 	if err != nil {
 		log.Printf("❌ Chat error: %v", err)
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrQuotaExhausted):
+			status = http.StatusPaymentRequired
+		case errors.Is(err, services.ErrSubscriptionInactive):
+			status = http.StatusForbidden
+		}
+		return c.Status(status).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
@@ -0,0 +1,8 @@
+-- 003_outbox_parking.sql
+
+-- The relay parks a row the broker keeps failing (failed_at), so it stops
+-- holding back later events of its key. Parked rows are not pending.
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS failed_at TIMESTAMP NULL;
+
+DROP INDEX IF EXISTS idx_outbox_pending;
+CREATE INDEX IF NOT EXISTS idx_outbox_ready ON outbox (created_at) WHERE published_at IS NULL AND failed_at IS NULL;
@@ -2,9 +2,8 @@ package models
 
 import "time"
 
-// Frontend'den gelen istek
+// Frontend'den gelen istek; kullanıcı access token'dan alınır
 type ChatRequest struct {
-	UserID         string `json:"user_id"`
 	Message        string `json:"message"`
 	ConversationID string `json:"conversation_id,omitempty"`
 	FileID         string `json:"file_id,omitempty"` // ✅ YENİ - RAG için
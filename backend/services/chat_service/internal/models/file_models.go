@@ -9,19 +9,3 @@ type FileStatus struct {
 	UserID         string `json:"user_id"`         // ✅ YENİ
 	ConversationID string `json:"conversation_id"` // ✅ YENİ
 }
-
-type EmbeddingStoredEvent struct {
-	Event       string            `json:"event"`
-	FileID      string            `json:"file_id"`
-	FileName    string            `json:"file_name"`
-	ContentType string            `json:"content_type"`
-	Chunks      []StoredChunkInfo `json:"chunks"`
-	TotalChunks int               `json:"total_chunks"`
-	Timestamp   string            `json:"timestamp"`
-}
-
-type StoredChunkInfo struct {
-	ChunkID  string `json:"chunk_id"`
-	VectorID string `json:"vector_id"`
-	Page     int    `json:"page"`
-}
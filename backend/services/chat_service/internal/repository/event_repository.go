@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"contracts/events"
+	"contracts/outbox"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// EventRepository stores outgoing events in the outbox table; the outbox
+// relay publishes them to Kafka.
+type EventRepository struct {
+	db    *sqlx.DB
+	topic string
+}
+
+func NewEventRepository(db *sqlx.DB, topic string) *EventRepository {
+	return &EventRepository{db: db, topic: topic}
+}
+
+// TokenUsage is the token count reported by the LLM provider for a turn.
+type TokenUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// SaveChatCompleted records a finished chat turn. Once it returns nil the
+// chat_completed event is guaranteed to reach Kafka eventually. usageID is
+// the idempotency key subscription_service charges the turn under.
+func (r *EventRepository) SaveChatCompleted(usageID, userID, message, response, conversationID, fileID string, usage TokenUsage) error {
+	env, err := events.New(events.ProducerChat, conversationID, &events.ChatCompleted{
+		UserID:           userID,
+		ConversationID:   conversationID,
+		Message:          message,
+		Response:         response,
+		FileID:           fileID,
+		UsageID:          usageID,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+	})
+	if err != nil {
+		return err
+	}
+
+	return outbox.Insert(r.db, outbox.Message{
+		Topic:    r.topic,
+		Key:      userID,
+		Envelope: env,
+	})
+}
@@ -2,9 +2,9 @@ package repository
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"time"
+
+	"contracts/events"
 
 	"github.com/segmentio/kafka-go"
 )
@@ -17,76 +17,33 @@ func NewKafkaProducer(brokers []string, topic string) *KafkaProducer {
 	writer := kafka.NewWriter(kafka.WriterConfig{
 		Brokers:  brokers,
 		Topic:    topic,
-		Balancer: &kafka.LeastBytes{},
+		Balancer: &kafka.Hash{},
 	})
 	return &KafkaProducer{writer: writer}
 }
 
-type ChatEvent struct {
-	EventType      string `json:"event_type"`
-	UserID         string `json:"user_id"`
-	Message        string `json:"message"`
-	Response       string `json:"response"`
-	ConversationID string `json:"conversation_id"`
-	Timestamp      string `json:"timestamp"`
-	DecreaseQuota  bool   `json:"decrease_quota"`
-}
-
-func (k *KafkaProducer) PublishChatCompleted(userID, message, response, conversationID string) error {
-	event := ChatEvent{
-		EventType:      "chat_completed",
-		UserID:         userID,
-		Message:        message,
-		Response:       response,
-		ConversationID: conversationID,
-		Timestamp:      time.Now().UTC().Format(time.RFC3339),
-		DecreaseQuota:  true,
-	}
-
-	data, err := json.Marshal(event)
-	if err != nil {
-		return err
-	}
-
-	err = k.writer.WriteMessages(context.Background(),
-		kafka.Message{
-			Value: data,
-		})
-	if err != nil {
-		fmt.Println("Kafka publish error:", err)
-	}
-	return err
-}
-
 // ✅ YENİ: PublishFileAttached - PDF yükleme mesajını kaydet
-type FileAttachedEvent struct {
-	EventType      string `json:"event_type"` // "file_attached"
-	UserID         string `json:"user_id"`
-	Message        string `json:"message"` // "📎 öneri.pdf"
-	ConversationID string `json:"conversation_id"`
-	FileID         string `json:"file_id"`
-	FileName       string `json:"file_name"`
-	Timestamp      string `json:"timestamp"`
-}
-
 func (k *KafkaProducer) PublishFileAttached(userID, fileName, fileID, conversationID string) error {
-	event := FileAttachedEvent{
-		EventType:      "file_attached",
+	return k.publish(userID, fileID, &events.FileAttached{
 		UserID:         userID,
-		Message:        fmt.Sprintf("📎 %s", fileName),
 		ConversationID: conversationID,
+		Message:        fmt.Sprintf("📎 %s", fileName),
 		FileID:         fileID,
 		FileName:       fileName,
-		Timestamp:      time.Now().UTC().Format(time.RFC3339),
-	}
+	})
+}
 
-	data, err := json.Marshal(event)
+// publish keys messages by user so one user's events stay ordered on a
+// single partition.
+func (k *KafkaProducer) publish(userID, correlationID string, p events.Payload) error {
+	data, err := events.Marshal(events.ProducerChat, correlationID, p)
 	if err != nil {
 		return err
 	}
 
 	err = k.writer.WriteMessages(context.Background(),
 		kafka.Message{
+			Key:   []byte(userID),
 			Value: data,
 		})
 	if err != nil {
@@ -7,15 +7,20 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
-// ✅ ChatService'i parametre olarak al
-func SetupRouter(chatSvc *services.ChatService) *fiber.App {
+// ✅ ChatService'i parametre olarak al; jwtSecret chat isteklerinin token'ını doğrular
+func SetupRouter(chatSvc *services.ChatService, jwtSecret []byte) *fiber.App {
 	app := fiber.New()
 
 	// ✅ Handler'a mevcut ChatService'i geçir
 	chatHandler := handler.NewChatHandlerWithService(chatSvc)
 
+	// Health check (API gateway'in instance kontrolü)
+	app.Get("/health", func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{"status": "ok", "service": "chat_service"})
+	})
+
 	api := app.Group("/api")
-	api.Post("/chat", chatHandler.HandleChat)
+	api.Post("/chat", handler.RequireUser(jwtSecret), chatHandler.HandleChat)
 	api.Get("/file/status/:file_id", chatHandler.GetFileStatus)
 
 	return app
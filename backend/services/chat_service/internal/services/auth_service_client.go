@@ -1,12 +1,22 @@
 package services
 
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
 type AuthClient struct {
 	BaseURL string
+	client  *http.Client
 }
 
 func NewAuthClient(baseURL string) *AuthClient {
 	return &AuthClient{
 		BaseURL: baseURL,
+		client:  &http.Client{Timeout: 5 * time.Second},
 	}
 }
 
@@ -15,3 +25,27 @@ func NewAuthClient(baseURL string) *AuthClient {
 func (a *AuthClient) IsUserValid(userID string) bool {
 	return true
 }
+
+// Kullanıcının organizasyonunu döner; organizasyonu yoksa ""
+func (a *AuthClient) OrganisationOf(userID string) (string, error) {
+	resp, err := a.client.Get(fmt.Sprintf("%s/internal/users/%s/organisation", a.BaseURL, url.PathEscape(userID)))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get organisation, status: %d", resp.StatusCode)
+	}
+
+	var result struct {
+		OrganisationID string `json:"organisation_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	return result.OrganisationID, nil
+}
@@ -14,6 +14,7 @@ import (
 	"chat_service/internal/repository"
 
 	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
 )
 
 type ChatService struct {
@@ -22,20 +23,22 @@ type ChatService struct {
 	subscriptionClient *SubscriptionClient
 	memoryService      *MemoryService
 	kafkaProducer      *repository.KafkaProducer
+	eventRepo          *repository.EventRepository
 	ragService         *RAGService
 	fileTracker        *FileTracker
 }
 
-func NewChatService(cfg *config.Config) *ChatService {
+func NewChatService(cfg *config.Config, db *sqlx.DB) *ChatService {
 	qdrantClient := repository.NewQdrantClient(cfg.QdrantURL)
 	xenovaClient := NewXenovaClient(cfg.XenovaURL)
 
 	return &ChatService{
 		cfg:                cfg,
 		authClient:         NewAuthClient(cfg.AuthServiceURL),
-		subscriptionClient: NewSubscriptionClient(cfg.SubscriptionServiceURL, cfg.KafkaBrokers, cfg.KafkaTopicChatMessages),
+		subscriptionClient: NewSubscriptionClient(cfg.SubscriptionServiceURL),
 		memoryService:      NewMemoryService(20),
 		kafkaProducer:      repository.NewKafkaProducer(cfg.KafkaBrokers, cfg.KafkaTopicChatMessages),
+		eventRepo:          repository.NewEventRepository(db, cfg.KafkaTopicChatMessages),
 		ragService:         NewRAGService(qdrantClient, xenovaClient),
 		fileTracker:        NewFileTracker(),
 	}
@@ -60,18 +63,22 @@ func (c *ChatService) HandleUserMessage(userID, message, conversationID, fileID
 		return "", conversationID, fmt.Errorf("unauthorized user")
 	}
 
-	// 2. Plan aktif mi ve kota var mı?
-	if !c.subscriptionClient.IsSubscriptionActive(userID) {
-		return "", conversationID, fmt.Errorf("subscription inactive or expired")
-	}
-
-	quota, err := c.subscriptionClient.GetQuota(userID)
-	if err != nil {
-		return "", conversationID, fmt.Errorf("quota check failed: %v", err)
-	}
-	if quota <= 0 {
-		return "", conversationID, fmt.Errorf("quota exhausted")
+	// 2. Kotayı LLM çağrısından önce ayır. Rezervasyon id'si usage_id olarak
+	// chat_completed event'ine de yazılır; istek yarıda kalırsa serbest
+	// bırakılır, servis çökerse subscription_service süresi dolunca geri verir.
+	usageID := uuid.New().String()
+	if _, err := c.subscriptionClient.Reserve(usageID, userID, 1); err != nil {
+		return "", conversationID, err
 	}
+	committed := false
+	defer func() {
+		if committed {
+			return
+		}
+		if err := c.subscriptionClient.Release(usageID); err != nil {
+			log.Printf("⚠️ Failed to release reservation %s: %v", usageID, err)
+		}
+	}()
 
 	// ✅ Eğer fileID varsa, user bilgilerini FileTracker'a kaydet
 	if fileID != "" {
@@ -85,7 +92,13 @@ func (c *ChatService) HandleUserMessage(userID, message, conversationID, fileID
 			return "", conversationID, fmt.Errorf("file is still processing or not found")
 		}
 
-		chunks, err := c.ragService.SearchRelevantChunks(context.Background(), message, fileID, 5)
+		// Çalışma alanıyla paylaşılan dokümanlar da aranabilir
+		orgID, err := c.authClient.OrganisationOf(userID)
+		if err != nil {
+			return "", conversationID, fmt.Errorf("failed to resolve workspace: %v", err)
+		}
+
+		chunks, err := c.ragService.SearchRelevantChunks(context.Background(), message, fileID, userID, orgID, 5)
 		if err != nil {
 			return "", conversationID, fmt.Errorf("failed to search document: %v", err)
 		}
@@ -112,7 +125,7 @@ func (c *ChatService) HandleUserMessage(userID, message, conversationID, fileID
 		systemPrompt = "Sen kullanıcıya belge içeriğine dayalı cevaplar veren bir yapay zekâsın. Verilen belge bölümlerini analiz edip kullanıcının sorusuna doğru ve detaylı cevap ver."
 	}
 
-	response, err := callOpenRouterAPI(fullPrompt, systemPrompt, c.cfg.OpenRouterKey)
+	response, usage, err := callOpenRouterAPI(fullPrompt, systemPrompt, c.cfg.OpenRouterKey)
 	if err != nil {
 		return "", conversationID, fmt.Errorf("AI response error: %v", err)
 	}
@@ -121,23 +134,18 @@ func (c *ChatService) HandleUserMessage(userID, message, conversationID, fileID
 	c.memoryService.AddMessage(userID, "User: "+message)
 	c.memoryService.AddMessage(userID, "AI: "+response)
 
-	// 6. Subscription Service'e kota azaltma bildirimi gönder
-	go func() {
-		event := map[string]string{
-			"user_id": userID,
-			"event":   "message_sent",
-		}
-		jsonData, _ := json.Marshal(event)
-		http.Post(fmt.Sprintf("%s/api/subscription/event", c.cfg.SubscriptionServiceURL),
-			"application/json", bytes.NewBuffer(jsonData))
-	}()
+	// 6. chat_completed event'ini outbox'a yaz (relay Kafka'ya taşır).
+	// Event de rezervasyonu aynı usage_id ile onaylar; kota tam bir kez düşülür.
+	// Sağlayıcının bildirdiği token kullanımı subscription_service'te ayrıca ölçülür.
+	if err := c.eventRepo.SaveChatCompleted(usageID, userID, message, response, conversationID, fileID, usage); err != nil {
+		return "", conversationID, fmt.Errorf("failed to record chat event: %v", err)
+	}
+	committed = true
 
-	// 7. Kafka'ya event gönder
-	go func() {
-		if err := c.kafkaProducer.PublishChatCompleted(userID, message, response, conversationID); err != nil {
-			log.Printf("⚠️ Kafka event publish failed: %v", err)
-		}
-	}()
+	// 7. Rezervasyonu hemen onayla; başarısız olursa event onaylayacak.
+	if err := c.subscriptionClient.Commit(usageID); err != nil {
+		log.Printf("⚠️ Failed to commit reservation %s, chat_completed event will: %v", usageID, err)
+	}
 
 	return response, conversationID, nil
 }
@@ -150,7 +158,7 @@ func (c *ChatService) GetKafkaProducer() *repository.KafkaProducer {
 	return c.kafkaProducer
 }
 
-func callOpenRouterAPI(prompt, systemPrompt, apiKey string) (string, error) {
+func callOpenRouterAPI(prompt, systemPrompt, apiKey string) (string, repository.TokenUsage, error) {
 	url := "https://openrouter.ai/api/v1/chat/completions"
 
 	reqBody := map[string]interface{}{
@@ -173,14 +181,14 @@ func callOpenRouterAPI(prompt, systemPrompt, apiKey string) (string, error) {
 	client := &http.Client{Timeout: 30 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("request failed: %w", err)
+		return "", repository.TokenUsage{}, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 300 {
 		var errResp map[string]interface{}
 		json.NewDecoder(resp.Body).Decode(&errResp)
-		return "", fmt.Errorf("API returned status %d: %v", resp.StatusCode, errResp)
+		return "", repository.TokenUsage{}, fmt.Errorf("API returned status %d: %v", resp.StatusCode, errResp)
 	}
 
 	var result struct {
@@ -189,6 +197,7 @@ func callOpenRouterAPI(prompt, systemPrompt, apiKey string) (string, error) {
 				Content string `json:"content"`
 			} `json:"message"`
 		} `json:"choices"`
+		Usage repository.TokenUsage `json:"usage"`
 		Error *struct {
 			Message string `json:"message"`
 			Code    string `json:"code"`
@@ -196,16 +205,16 @@ func callOpenRouterAPI(prompt, systemPrompt, apiKey string) (string, error) {
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", fmt.Errorf("decode failed: %w", err)
+		return "", repository.TokenUsage{}, fmt.Errorf("decode failed: %w", err)
 	}
 
 	if result.Error != nil {
-		return "", fmt.Errorf("API error: %s (code: %s)", result.Error.Message, result.Error.Code)
+		return "", repository.TokenUsage{}, fmt.Errorf("API error: %s (code: %s)", result.Error.Message, result.Error.Code)
 	}
 
 	if len(result.Choices) == 0 {
-		return "", fmt.Errorf("AI returned empty response")
+		return "", repository.TokenUsage{}, fmt.Errorf("AI returned empty response")
 	}
 
-	return result.Choices[0].Message.Content, nil
+	return result.Choices[0].Message.Content, result.Usage, nil
 }
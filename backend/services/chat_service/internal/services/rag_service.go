@@ -29,7 +29,10 @@ type RelevantChunk struct {
 	FileName string
 }
 
-func (r *RAGService) SearchRelevantChunks(ctx context.Context, query string, fileID string, limit int) ([]RelevantChunk, error) {
+// SearchRelevantChunks searches fileID for query. Only chunks the user may
+// see match: their own uploads, documents shared with their workspace orgID
+// and documents uploaded without a user.
+func (r *RAGService) SearchRelevantChunks(ctx context.Context, query, fileID, userID, orgID string, limit int) ([]RelevantChunk, error) {
 	log.Printf("🔄 Embedding query: %s", truncateString(query, 50))
 
 	queryVector, err := r.xenovaClient.Embed(query)
@@ -39,15 +42,16 @@ func (r *RAGService) SearchRelevantChunks(ctx context.Context, query string, fil
 
 	log.Printf("✅ Query embedded, dimension: %d", len(queryVector))
 
+	access := []map[string]interface{}{
+		matchFilter("uploader_id", userID),
+		{"is_empty": map[string]interface{}{"key": "uploader_id"}},
+	}
+	if orgID != "" {
+		access = append(access, matchFilter("workspace_id", orgID))
+	}
 	filter := map[string]interface{}{
-		"must": []map[string]interface{}{
-			{
-				"key": "file_id",
-				"match": map[string]interface{}{
-					"value": fileID,
-				},
-			},
-		},
+		"must":   []map[string]interface{}{matchFilter("file_id", fileID)},
+		"should": access,
 	}
 
 	log.Printf("🔍 Searching in Qdrant for file_id: %s (limit: %d)", fileID, limit)
@@ -105,6 +109,13 @@ func (r *RAGService) BuildContext(chunks []RelevantChunk) string {
 	return contextStr
 }
 
+func matchFilter(key, value string) map[string]interface{} {
+	return map[string]interface{}{
+		"key":   key,
+		"match": map[string]interface{}{"value": value},
+	}
+}
+
 func truncateString(s string, maxLen int) string {
 	if len(s) <= maxLen {
 		return s
@@ -1,51 +1,49 @@
 package services
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
+)
 
-	"github.com/IBM/sarama"
+var (
+	// ErrQuotaExhausted: kullanıcının ayrılabilecek kotası kalmadı
+	ErrQuotaExhausted = errors.New("quota exhausted")
+	// ErrSubscriptionInactive: kullanıcının aktif planı yok
+	ErrSubscriptionInactive = errors.New("subscription inactive or expired")
 )
 
 // SubscriptionClient: Subscription Service ile iletişimi sağlar
 type SubscriptionClient struct {
-	BaseURL      string
-	KafkaBrokers []string
-	KafkaTopic   string
+	BaseURL string
+	client  *http.Client
 }
 
 type QuotaResponse struct {
 	Quota int `json:"quota"`
 }
 
-func NewSubscriptionClient(baseURL string, brokers []string, topic string) *SubscriptionClient {
-	return &SubscriptionClient{
-		BaseURL:      baseURL,
-		KafkaBrokers: brokers,
-		KafkaTopic:   topic,
-	}
+// Reservation: LLM çağrısı süresince ayrılan kota
+type Reservation struct {
+	ID             string    `json:"reservation_id"`
+	Amount         int       `json:"amount"`
+	ExpiresAt      time.Time `json:"expires_at"`
+	RemainingQuota int       `json:"remaining_quota"`
 }
 
-// Kullanıcının aktif planı var mı?
-func (s *SubscriptionClient) IsSubscriptionActive(userID string) bool {
-	resp, err := http.Get(fmt.Sprintf("%s/api/subscription/quota/%s", s.BaseURL, userID))
-	if err != nil || resp.StatusCode != http.StatusOK {
-		return false
-	}
-	defer resp.Body.Close()
-
-	var result QuotaResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return false
+func NewSubscriptionClient(baseURL string) *SubscriptionClient {
+	return &SubscriptionClient{
+		BaseURL: baseURL,
+		client:  &http.Client{Timeout: 5 * time.Second},
 	}
-
-	return result.Quota > 0
 }
 
 // Kullanıcının kalan kotasını döner
 func (s *SubscriptionClient) GetQuota(userID string) (int, error) {
-	resp, err := http.Get(fmt.Sprintf("%s/api/subscription/quota/%s", s.BaseURL, userID))
+	resp, err := s.client.Get(fmt.Sprintf("%s/internal/users/%s/quota", s.BaseURL, userID))
 	if err != nil {
 		return 0, err
 	}
@@ -63,29 +61,58 @@ func (s *SubscriptionClient) GetQuota(userID string) (int, error) {
 	return result.Quota, nil
 }
 
-// (İsteğe bağlı) Kafka üzerinden kota azaltma event'i gönderir
-func (s *SubscriptionClient) DecreaseQuota(userID string) error {
-	producer, err := sarama.NewSyncProducer(s.KafkaBrokers, nil)
+// Reserve, LLM çağrısından önce kotayı ayırır. reservationID aynı kalırsa
+// tekrar denemeler kotayı ikinci kez düşmez.
+func (s *SubscriptionClient) Reserve(reservationID, userID string, amount int) (*Reservation, error) {
+	body, _ := json.Marshal(map[string]interface{}{
+		"reservation_id": reservationID,
+		"user_id":        userID,
+		"amount":         amount,
+	})
+
+	resp, err := s.client.Post(s.BaseURL+"/internal/reservations", "application/json", bytes.NewReader(body))
 	if err != nil {
-		return fmt.Errorf("failed to create Kafka producer: %w", err)
+		return nil, fmt.Errorf("reserve quota: %w", err)
 	}
-	defer producer.Close()
+	defer resp.Body.Close()
 
-	event := map[string]string{
-		"type":    "chat_completed",
-		"user_id": userID,
+	switch resp.StatusCode {
+	case http.StatusCreated:
+	case http.StatusPaymentRequired:
+		return nil, ErrQuotaExhausted
+	case http.StatusForbidden:
+		return nil, ErrSubscriptionInactive
+	default:
+		return nil, fmt.Errorf("reserve quota failed, status: %d", resp.StatusCode)
 	}
 
-	data, _ := json.Marshal(event)
-	msg := &sarama.ProducerMessage{
-		Topic: s.KafkaTopic,
-		Value: sarama.ByteEncoder(data),
+	var res Reservation
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("decode reservation: %w", err)
 	}
+	return &res, nil
+}
+
+// Commit, ayrılan kotayı harcanmış olarak işaretler
+func (s *SubscriptionClient) Commit(reservationID string) error {
+	return s.reservationAction(reservationID, "commit")
+}
 
-	_, _, err = producer.SendMessage(msg)
+// Release, ayrılan kotayı kullanıcıya geri verir
+func (s *SubscriptionClient) Release(reservationID string) error {
+	return s.reservationAction(reservationID, "release")
+}
+
+func (s *SubscriptionClient) reservationAction(reservationID, action string) error {
+	url := fmt.Sprintf("%s/internal/reservations/%s/%s", s.BaseURL, reservationID, action)
+	resp, err := s.client.Post(url, "application/json", nil)
 	if err != nil {
-		return fmt.Errorf("failed to send Kafka message: %w", err)
+		return fmt.Errorf("%s reservation: %w", action, err)
 	}
+	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s reservation failed, status: %d", action, resp.StatusCode)
+	}
 	return nil
 }
@@ -114,6 +114,20 @@ func main() {
 	}
 	defer consumer.Close()
 
+	// org_members consumer (çalışma alanı paylaşımlarını geri alır)
+	orgConsumer, err := events.NewConsumer(cfg.KafkaBrokers, cfg.KafkaTopicOrgMembers, cfg.KafkaGroup+"-workspace")
+	if err != nil {
+		log.Fatalf("failed to create consumer: %v", err)
+	}
+	defer orgConsumer.Close()
+
+	// user_deleted consumer (silinen kullanıcının dokümanlarını siler)
+	userConsumer, err := events.NewConsumer(cfg.KafkaBrokers, cfg.KafkaTopicUserDeleted, cfg.KafkaGroup+"-user-data")
+	if err != nil {
+		log.Fatalf("failed to create consumer: %v", err)
+	}
+	defer userConsumer.Close()
+
 	// Qdrant repo
 	qrepo := repository.NewQdrantRepo(cfg.QdrantURL)
 
@@ -123,15 +137,26 @@ func main() {
 	// Embedding service
 	embSvc := services.NewEmbeddingService(consumer, producer, qrepo, xcli)
 
+	// Auth client (kullanıcının organizasyonu)
+	authCli := services.NewAuthClient(cfg.AuthServiceURL)
+
 	// Search service
-	searchSvc := services.NewSearchService(qrepo, xcli)
+	searchSvc := services.NewSearchService(qrepo, xcli, authCli)
+
+	// Workspace sharing
+	workspaceSvc := services.NewWorkspaceService(orgConsumer, qrepo, authCli)
+
+	// Account deletion
+	userDataSvc := services.NewUserDataService(userConsumer, producer, qrepo, cfg.KafkaTopicUserDataPurged)
 
 	// Run consumer in background
 	ctx, cancel := context.WithCancel(context.Background())
 	go embSvc.Run(ctx)
+	go workspaceSvc.Run(ctx)
+	go userDataSvc.Run(ctx)
 
 	// 🆕 Setup router
-	handler := router.SetupRouter(searchSvc)
+	handler := router.SetupRouter(searchSvc, workspaceSvc, []byte(cfg.JWTSecret))
 
 	// HTTP server with search endpoints
 	go func() {
@@ -139,6 +164,7 @@ func main() {
 		log.Printf("✅ Embedding Service HTTP running on %s", addr)
 		log.Printf("📍 Search endpoint: POST http://localhost:%s/api/search", cfg.Port)
 		log.Printf("📍 File search: POST http://localhost:%s/api/search/file?file_id=xxx", cfg.Port)
+		log.Printf("📍 Share: POST http://localhost:%s/api/documents/share", cfg.Port)
 
 		// 🆕 Router kullanılıyor (embSvc.RunHTTP yerine)
 		if err := http.ListenAndServe(addr, handler); err != nil {
@@ -12,6 +12,19 @@ type Config struct {
 	KafkaGroup   string
 	QdrantURL    string
 	XenovaURL    string
+
+	// AuthServiceURL resolves a user's organisation for workspace sharing.
+	AuthServiceURL       string
+	KafkaTopicOrgMembers string
+
+	// KafkaTopicUserDeleted triggers the erasure of a deleted user's
+	// documents, reported on KafkaTopicUserDataPurged.
+	KafkaTopicUserDeleted    string
+	KafkaTopicUserDataPurged string
+
+	// JWTSecret verifies the access tokens of search and share requests;
+	// the same secret auth_service signs them with.
+	JWTSecret string
 }
 
 func LoadConfig() *Config {
@@ -38,11 +51,35 @@ func LoadConfig() *Config {
 	if xenova == "" {
 		xenova = "http://localhost:3000"
 	}
+	authURL := os.Getenv("AUTH_SERVICE_URL")
+	if authURL == "" {
+		authURL = "http://auth_service:8080"
+	}
+	orgTopic := os.Getenv("KAFKA_TOPIC_ORG_MEMBERS")
+	if orgTopic == "" {
+		orgTopic = "org_members"
+	}
+	deletedTopic := os.Getenv("KAFKA_TOPIC_USER_DELETED")
+	if deletedTopic == "" {
+		deletedTopic = "user_deleted"
+	}
+	purgedTopic := os.Getenv("KAFKA_TOPIC_USER_DATA_PURGED")
+	if purgedTopic == "" {
+		purgedTopic = "user_data_purged"
+	}
 	return &Config{
 		Port:         port,
 		KafkaBrokers: []string{brokersEnv},
 		KafkaGroup:   group,
 		QdrantURL:    qdrant,
 		XenovaURL:    xenova,
+
+		AuthServiceURL:       authURL,
+		KafkaTopicOrgMembers: orgTopic,
+
+		KafkaTopicUserDeleted:    deletedTopic,
+		KafkaTopicUserDataPurged: purgedTopic,
+
+		JWTSecret: os.Getenv("JWT_SECRET"),
 	}
 }
@@ -64,11 +64,15 @@ app.post("/embed", async (req, res) => {
     // Output tensor formatında gelir - .data ile raw array'e çevir
     const vector = Array.from(output.data);
 
-    console.log(`📊 Generated embedding with dimension: ${vector.length}`);
+    // Kota ölçümü için modele giren token sayısı (özel tokenlar dahil)
+    const tokens = embedModel.tokenizer.encode(text).length;
+
+    console.log(`📊 Generated embedding with dimension: ${vector.length}, tokens: ${tokens}`);
 
     res.json({
       embedding: vector,
       dimension: vector.length,
+      tokens,
     });
   } catch (err) {
     console.error("❌ Embedding error:", err);
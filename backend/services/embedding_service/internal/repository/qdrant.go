@@ -141,8 +141,8 @@ func (r *QdrantRepo) EnsureCollection(collection string, dim int) error {
 	return nil
 }
 
-// Search performs similarity search
-func (r *QdrantRepo) Search(collection string, vector []float32, limit int) ([]SearchResult, error) {
+// Search performs similarity search; filter (Qdrant filter JSON) may be nil
+func (r *QdrantRepo) Search(collection string, vector []float32, limit int, filter map[string]interface{}) ([]SearchResult, error) {
 	url := fmt.Sprintf("%s/collections/%s/points/search", r.baseURL, collection)
 
 	// ✅ vector'ü float64'e çevir
@@ -157,6 +157,9 @@ func (r *QdrantRepo) Search(collection string, vector []float32, limit int) ([]S
 		"with_payload": true,
 		"with_vector":  false,
 	}
+	if filter != nil {
+		body["filter"] = filter
+	}
 
 	b, _ := json.Marshal(body)
 	resp, err := r.client.Post(url, "application/json", bytes.NewReader(b))
@@ -183,6 +186,75 @@ func (r *QdrantRepo) Search(collection string, vector []float32, limit int) ([]S
 	return result.Result, nil
 }
 
+// Count returns how many points match filter
+func (r *QdrantRepo) Count(collection string, filter map[string]interface{}) (int, error) {
+	url := fmt.Sprintf("%s/collections/%s/points/count", r.baseURL, collection)
+
+	var result struct {
+		Result struct {
+			Count int `json:"count"`
+		} `json:"result"`
+	}
+	body := map[string]interface{}{"filter": filter, "exact": true}
+	if err := r.post(url, body, &result); err != nil {
+		return 0, fmt.Errorf("count points: %w", err)
+	}
+	return result.Result.Count, nil
+}
+
+// SetPayload sets payload keys on every point matching filter
+func (r *QdrantRepo) SetPayload(collection string, payload, filter map[string]interface{}) error {
+	url := fmt.Sprintf("%s/collections/%s/points/payload?wait=true", r.baseURL, collection)
+	if err := r.post(url, map[string]interface{}{"payload": payload, "filter": filter}, nil); err != nil {
+		return fmt.Errorf("set payload: %w", err)
+	}
+	return nil
+}
+
+// DeletePayload removes payload keys from every point matching filter
+func (r *QdrantRepo) DeletePayload(collection string, keys []string, filter map[string]interface{}) error {
+	url := fmt.Sprintf("%s/collections/%s/points/payload/delete?wait=true", r.baseURL, collection)
+	if err := r.post(url, map[string]interface{}{"keys": keys, "filter": filter}, nil); err != nil {
+		return fmt.Errorf("delete payload: %w", err)
+	}
+	return nil
+}
+
+// DeletePoints deletes every point matching filter
+func (r *QdrantRepo) DeletePoints(collection string, filter map[string]interface{}) error {
+	url := fmt.Sprintf("%s/collections/%s/points/delete?wait=true", r.baseURL, collection)
+	if err := r.post(url, map[string]interface{}{"filter": filter}, nil); err != nil {
+		return fmt.Errorf("delete points: %w", err)
+	}
+	return nil
+}
+
+// post sends body as JSON and decodes the response into out, if given. A
+// missing collection (nothing embedded yet) is not an error.
+func (r *QdrantRepo) post(url string, body interface{}, out interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal body: %w", err)
+	}
+	resp, err := r.client.Post(url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("qdrant request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if resp.StatusCode >= 300 {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("qdrant responded %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
 type SearchResult struct {
 	ID      string                 `json:"id"`
 	Score   float64                `json:"score"`
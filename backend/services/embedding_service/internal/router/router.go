@@ -5,10 +5,13 @@ import (
 	"net/http"
 
 	"embedding_service/internal/services"
+
+	"contracts/auth"
 )
 
-// SetupRouter configures all HTTP routes
-func SetupRouter(searchSvc *services.SearchService) http.Handler {
+// SetupRouter configures all HTTP routes. Search and sharing act on the
+// user of the request's access token.
+func SetupRouter(searchSvc *services.SearchService, workspaceSvc *services.WorkspaceService, jwtSecret []byte) http.Handler {
 	mux := http.NewServeMux()
 
 	// Health check
@@ -19,25 +22,13 @@ func SetupRouter(searchSvc *services.SearchService) http.Handler {
 	})
 
 	// Search endpoints
-	mux.HandleFunc("/api/search", searchSvc.HandleSearch)
-	mux.HandleFunc("/api/search/file", searchSvc.HandleSearchByFileID)
-
-	// CORS middleware
-	corsHandler := func(h http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-
-			if r.Method == "OPTIONS" {
-				w.WriteHeader(http.StatusOK)
-				return
-			}
+	mux.HandleFunc("/api/search", auth.RequireUser(jwtSecret, searchSvc.HandleSearch))
+	mux.HandleFunc("/api/search/file", auth.RequireUser(jwtSecret, searchSvc.HandleSearchByFileID))
 
-			h.ServeHTTP(w, r)
-		})
-	}
+	// Workspace sharing
+	mux.HandleFunc("/api/documents/share", auth.RequireUser(jwtSecret, workspaceSvc.HandleShare))
 
+	// CORS is handled by the API gateway
 	log.Printf("🌐 Router configured successfully")
-	return corsHandler(mux)
+	return mux
 }
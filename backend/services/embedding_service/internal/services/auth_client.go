@@ -0,0 +1,47 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// AuthClient asks auth_service which organisation a user belongs to
+type AuthClient struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewAuthClient(baseURL string) *AuthClient {
+	return &AuthClient{
+		baseURL: baseURL,
+		client:  &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// OrganisationOf returns the id of the user's organisation, or "" if the
+// user doesn't belong to one
+func (a *AuthClient) OrganisationOf(userID string) (string, error) {
+	resp, err := a.client.Get(fmt.Sprintf("%s/internal/users/%s/organisation", a.baseURL, url.PathEscape(userID)))
+	if err != nil {
+		return "", fmt.Errorf("auth service request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("auth service responded %d", resp.StatusCode)
+	}
+
+	var m struct {
+		OrganisationID string `json:"organisation_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+		return "", fmt.Errorf("failed to decode organisation: %w", err)
+	}
+	return m.OrganisationID, nil
+}
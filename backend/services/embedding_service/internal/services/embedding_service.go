@@ -11,8 +11,9 @@ import (
 	"time"
 
 	"embedding_service/internal/events"
-	"embedding_service/internal/models"
 	"embedding_service/internal/repository"
+
+	contracts "contracts/events"
 )
 
 type EmbeddingService struct {
@@ -75,11 +76,16 @@ func (s *EmbeddingService) Run(ctx context.Context) {
 				continue
 			}
 
-			var evt models.OCRProcessedEvent
-			if err := json.Unmarshal(msg.Value, &evt); err != nil {
+			env, payload, err := contracts.Decode(msg.Value)
+			if err != nil {
 				log.Printf("❌ invalid ocr_processed event: %v", err)
 				continue
 			}
+			evt, ok := payload.(*contracts.OCRProcessed)
+			if !ok {
+				log.Printf("❌ unexpected %s event on ocr_processed topic", env.Type)
+				continue
+			}
 
 			log.Printf("📥 Processing OCR_PROCESSED for file %s (%s) with %d chunks", evt.FileID, evt.FileName, len(evt.Chunks))
 
@@ -104,7 +110,7 @@ func (s *EmbeddingService) Run(ctx context.Context) {
 			if err != nil {
 				log.Printf("❌ xenova embed failed after retries: %v", err)
 				s.updateFileStatus(evt.FileID, "failed", 0, fmt.Sprintf("Embedding failed: %v", err))
-				s.publishError(evt.FileID, err)
+				s.publishError(env.CorrelationID, evt.FileID, err)
 				continue
 			}
 
@@ -115,19 +121,21 @@ func (s *EmbeddingService) Run(ctx context.Context) {
 			if err := s.qrepo.EnsureCollection("documents", dimension); err != nil {
 				log.Printf("❌ failed to ensure collection: %v", err)
 				s.updateFileStatus(evt.FileID, "failed", 0, fmt.Sprintf("Collection setup failed: %v", err))
-				s.publishError(evt.FileID, err)
+				s.publishError(env.CorrelationID, evt.FileID, err)
 				continue
 			}
 
-			var stored []models.StoredChunkInfo
+			var stored []contracts.StoredChunk
 			successCount := 0
+			embeddedTokens := 0
 
 			// Tüm chunk'ları işle
 			for i, ch := range evt.Chunks {
 				// Embedding oluştur (retry ile)
 				var vec []float32
+				var tokens int
 				for retry := 0; retry < maxRetries; retry++ {
-					vec, err = s.xenova.Embed(ch.Text)
+					vec, tokens, err = s.xenova.EmbedWithUsage(ch.Text)
 					if err == nil {
 						break
 					}
@@ -148,6 +156,10 @@ func (s *EmbeddingService) Run(ctx context.Context) {
 					"chunk_id":     ch.ChunkID,
 					"text":         ch.Text,
 				}
+				// Arama erişimi: yükleyen (ve paylaşılırsa çalışma alanı) görür
+				if evt.UploaderID != "" {
+					payload["uploader_id"] = evt.UploaderID
+				}
 
 				// Sadece 0'dan büyük değerleri ekle
 				if ch.Page > 0 {
@@ -206,13 +218,14 @@ func (s *EmbeddingService) Run(ctx context.Context) {
 					continue
 				}
 
-				stored = append(stored, models.StoredChunkInfo{
+				stored = append(stored, contracts.StoredChunk{
 					ChunkID:  ch.ChunkID,
 					VectorID: ch.ChunkID,
 					Page:     ch.Page,
 				})
 
 				successCount++
+				embeddedTokens += tokens
 
 				if i == 0 {
 					log.Printf("✅ First chunk embedded successfully")
@@ -228,17 +241,21 @@ func (s *EmbeddingService) Run(ctx context.Context) {
 			s.updateFileStatus(evt.FileID, "completed", len(stored), fmt.Sprintf("Successfully embedded %d/%d chunks", successCount, len(evt.Chunks)))
 
 			// EMBEDDING_STORED event yayınla
-			out := models.EmbeddingStoredEvent{
-				Event:       "EMBEDDING_STORED",
-				FileID:      evt.FileID,
-				FileName:    evt.FileName,
-				ContentType: evt.ContentType,
-				Chunks:      stored,
-				TotalChunks: len(stored),
-				Timestamp:   time.Now().UTC().Format(time.RFC3339),
+			b, err := contracts.Marshal(contracts.ProducerEmbedding, env.CorrelationID, &contracts.EmbeddingStored{
+				FileID:         evt.FileID,
+				FileName:       evt.FileName,
+				ContentType:    evt.ContentType,
+				Chunks:         stored,
+				TotalChunks:    len(stored),
+				UploaderID:     evt.UploaderID,
+				EmbeddedTokens: embeddedTokens,
+				Pages:          evt.Pages,
+			})
+			if err != nil {
+				log.Printf("❌ failed to build embedding_stored: %v", err)
+				continue
 			}
 
-			b, _ := json.Marshal(out)
 			if err := s.producer.Publish("embedding_stored", b); err != nil {
 				log.Printf("❌ failed to publish embedding_stored: %v", err)
 			} else {
@@ -248,13 +265,14 @@ func (s *EmbeddingService) Run(ctx context.Context) {
 	}
 }
 
-func (s *EmbeddingService) publishError(fileID string, err error) {
-	errorEvt := map[string]interface{}{
-		"event":     "EMBEDDING_FAILED",
-		"file_id":   fileID,
-		"error":     err.Error(),
-		"timestamp": time.Now().UTC().Format(time.RFC3339),
+func (s *EmbeddingService) publishError(correlationID, fileID string, err error) {
+	b, mErr := contracts.Marshal(contracts.ProducerEmbedding, correlationID, &contracts.EmbeddingFailed{
+		FileID: fileID,
+		Error:  err.Error(),
+	})
+	if mErr != nil {
+		log.Printf("⚠️ Failed to build error event: %v", mErr)
+		return
 	}
-	b, _ := json.Marshal(errorEvt)
 	s.producer.Publish("embedding_failed", b)
 }
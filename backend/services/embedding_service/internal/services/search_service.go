@@ -7,22 +7,27 @@ import (
 	"net/http"
 
 	"embedding_service/internal/repository"
+
+	"contracts/auth"
 )
 
 type SearchService struct {
 	qrepo  *repository.QdrantRepo
 	xenova *XenovaClient
+	auth   *AuthClient
 }
 
-func NewSearchService(qrepo *repository.QdrantRepo, xenova *XenovaClient) *SearchService {
+func NewSearchService(qrepo *repository.QdrantRepo, xenova *XenovaClient, auth *AuthClient) *SearchService {
 	return &SearchService{
 		qrepo:  qrepo,
 		xenova: xenova,
+		auth:   auth,
 	}
 }
 
 type SearchRequest struct {
 	Query    string  `json:"query"`
+	UserID   string  `json:"-"` // Token'ın kullanıcısı: kendi + çalışma alanında paylaşılan dokümanlar
 	Limit    int     `json:"limit,omitempty"`
 	FileID   string  `json:"file_id,omitempty"`   // Belirli bir dosyada ara
 	MinScore float64 `json:"min_score,omitempty"` // Minimum benzerlik skoru
@@ -57,6 +62,7 @@ func (s *SearchService) HandleSearch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	req.UserID = tokenUserID(r)
 	if req.Query == "" {
 		http.Error(w, "query field is required", http.StatusBadRequest)
 		return
@@ -82,8 +88,14 @@ func (s *SearchService) HandleSearch(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("✅ Query embedded, dimension: %d", len(queryVec))
 
-	// 2. Qdrant'ta ara
-	results, err := s.qrepo.Search("documents", queryVec, req.Limit*2) // 2x al, filtreleyeceğiz
+	// 2. Qdrant'ta ara (sadece kullanıcının erişebildiği dokümanlar)
+	filter, err := s.searchFilter(req)
+	if err != nil {
+		log.Printf("Organisation lookup failed: %v", err)
+		http.Error(w, fmt.Sprintf("Search failed: %v", err), http.StatusBadGateway)
+		return
+	}
+	results, err := s.qrepo.Search("documents", queryVec, req.Limit*2, filter) // 2x al, filtreleyeceğiz
 	if err != nil {
 		log.Printf("Qdrant search failed: %v", err)
 		http.Error(w, fmt.Sprintf("Search failed: %v", err), http.StatusInternalServerError)
@@ -169,6 +181,7 @@ func (s *SearchService) HandleSearchByFileID(w http.ResponseWriter, r *http.Requ
 	// ✅ FileID'yi ekle ve direkt arama yap
 	req.FileID = fileID
 
+	req.UserID = tokenUserID(r)
 	if req.Query == "" {
 		http.Error(w, "query field is required", http.StatusBadRequest)
 		return
@@ -194,8 +207,14 @@ func (s *SearchService) HandleSearchByFileID(w http.ResponseWriter, r *http.Requ
 
 	log.Printf("✅ Query embedded, dimension: %d", len(queryVec))
 
-	// Qdrant'ta ara
-	results, err := s.qrepo.Search("documents", queryVec, req.Limit*2)
+	// Qdrant'ta ara (sadece kullanıcının erişebildiği dokümanlar)
+	filter, err := s.searchFilter(req)
+	if err != nil {
+		log.Printf("Organisation lookup failed: %v", err)
+		http.Error(w, fmt.Sprintf("Search failed: %v", err), http.StatusBadGateway)
+		return
+	}
+	results, err := s.qrepo.Search("documents", queryVec, req.Limit*2, filter)
 	if err != nil {
 		log.Printf("Qdrant search failed: %v", err)
 		http.Error(w, fmt.Sprintf("Search failed: %v", err), http.StatusInternalServerError)
@@ -254,3 +273,26 @@ func (s *SearchService) HandleSearchByFileID(w http.ResponseWriter, r *http.Requ
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
+
+// searchFilter limits a search to the documents req.UserID may see and, if
+// set, to req.FileID.
+func (s *SearchService) searchFilter(req SearchRequest) (map[string]interface{}, error) {
+	orgID, err := s.auth.OrganisationOf(req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	filter := map[string]interface{}{"should": accessFilter(req.UserID, orgID)}
+	if req.FileID != "" {
+		filter["must"] = []map[string]interface{}{matchFilter("file_id", req.FileID)}
+	}
+	return filter, nil
+}
+
+// tokenUserID returns the id of the user the request's token was issued to.
+// The search and share endpoints are served behind auth.RequireUser.
+func tokenUserID(r *http.Request) string {
+	if c, ok := auth.ClaimsFrom(r.Context()); ok {
+		return c.UserID()
+	}
+	return ""
+}
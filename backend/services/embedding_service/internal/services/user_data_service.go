@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"embedding_service/internal/events"
+	"embedding_service/internal/repository"
+
+	contracts "contracts/events"
+)
+
+// UserDataService erases the documents of deleted users: every chunk they
+// uploaded is removed from Qdrant, shared or not.
+type UserDataService struct {
+	consumer    *events.Consumer
+	producer    *events.Producer
+	qrepo       *repository.QdrantRepo
+	purgedTopic string
+}
+
+func NewUserDataService(consumer *events.Consumer, producer *events.Producer, qrepo *repository.QdrantRepo, purgedTopic string) *UserDataService {
+	return &UserDataService{
+		consumer:    consumer,
+		producer:    producer,
+		qrepo:       qrepo,
+		purgedTopic: purgedTopic,
+	}
+}
+
+// Run consumes user_deleted, deletes the user's points and reports how many
+// with user_data_purged.
+func (s *UserDataService) Run(ctx context.Context) {
+	log.Println("🚀 User data service: start user_deleted consumer loop")
+
+	for {
+		msg, err := s.consumer.FetchMessage(ctx)
+		if ctx.Err() != nil {
+			log.Println("🛑 User data service: context done")
+			return
+		}
+		if err != nil {
+			log.Printf("❌ consumer read error: %v", err)
+			time.Sleep(time.Second)
+			continue
+		}
+
+		env, payload, err := contracts.Decode(msg.Value)
+		if err != nil {
+			log.Printf("❌ invalid user_deleted event: %v", err)
+			s.commit(ctx, msg)
+			continue
+		}
+		evt, ok := payload.(*contracts.UserDeleted)
+		if !ok {
+			log.Printf("ℹ️ Ignored %s event on user_deleted topic", env.Type)
+			s.commit(ctx, msg)
+			continue
+		}
+
+		// Qdrant or Kafka down: retry rather than keep the user's documents.
+		for ctx.Err() == nil {
+			if err = s.purge(env.CorrelationID, evt); err == nil {
+				break
+			}
+			log.Printf("⚠️ Failed to purge documents of user %s: %v", evt.UserID, err)
+			time.Sleep(5 * time.Second)
+		}
+		if err != nil {
+			// Stopped before the purge succeeded: left uncommitted, so it
+			// runs again after the restart.
+			return
+		}
+		s.commit(ctx, msg)
+	}
+}
+
+// commit marks msg done. If that fails the event comes again after a
+// restart, which is harmless: purging twice finds nothing the second time.
+func (s *UserDataService) commit(ctx context.Context, msg kafka.Message) {
+	if err := s.consumer.CommitMessage(ctx, msg); err != nil {
+		log.Printf("⚠️ Failed to commit user_deleted offset %d: %v", msg.Offset, err)
+	}
+}
+
+func (s *UserDataService) purge(correlationID string, evt *contracts.UserDeleted) error {
+	filter := map[string]interface{}{
+		"must": []map[string]interface{}{matchFilter("uploader_id", evt.UserID)},
+	}
+	n, err := s.qrepo.Count("documents", filter)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		if err := s.qrepo.DeletePoints("documents", filter); err != nil {
+			return err
+		}
+	}
+
+	b, err := contracts.Marshal(contracts.ProducerEmbedding, correlationID, &contracts.UserDataPurged{
+		DeletionID:   evt.DeletionID,
+		UserID:       evt.UserID,
+		Service:      contracts.ProducerEmbedding,
+		ItemsDeleted: n,
+		Detail:       "document chunks",
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.producer.Publish(s.purgedTopic, b); err != nil {
+		return err
+	}
+	log.Printf("🗑️ User %s deleted: %d document chunk(s) removed from Qdrant", evt.UserID, n)
+	return nil
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"embedding_service/internal/events"
+	"embedding_service/internal/repository"
+
+	contracts "contracts/events"
+)
+
+// WorkspaceService shares documents with the uploader's organisation
+// workspace. A shared document's chunks carry workspace_id in their Qdrant
+// payload, so every member finds them in search and chat.
+type WorkspaceService struct {
+	consumer *events.Consumer
+	qrepo    *repository.QdrantRepo
+	auth     *AuthClient
+}
+
+func NewWorkspaceService(consumer *events.Consumer, qrepo *repository.QdrantRepo, auth *AuthClient) *WorkspaceService {
+	return &WorkspaceService{
+		consumer: consumer,
+		qrepo:    qrepo,
+		auth:     auth,
+	}
+}
+
+type ShareRequest struct {
+	FileID string `json:"file_id"`
+	UserID string `json:"-"` // token's user, the uploader
+	Shared bool   `json:"shared"`
+}
+
+type ShareResponse struct {
+	FileID      string `json:"file_id"`
+	Shared      bool   `json:"shared"`
+	WorkspaceID string `json:"workspace_id,omitempty"`
+}
+
+// HandleShare shares a document with the workspace or takes it back. Only
+// the uploader can do it, and only while in an organisation.
+func (s *WorkspaceService) HandleShare(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ShareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	req.UserID = tokenUserID(r)
+	if req.FileID == "" {
+		http.Error(w, "file_id field is required", http.StatusBadRequest)
+		return
+	}
+
+	own := map[string]interface{}{
+		"must": []map[string]interface{}{
+			matchFilter("file_id", req.FileID),
+			matchFilter("uploader_id", req.UserID),
+		},
+	}
+	n, err := s.qrepo.Count("documents", own)
+	if err != nil {
+		log.Printf("Qdrant count failed: %v", err)
+		http.Error(w, fmt.Sprintf("Share failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		http.Error(w, "document not found", http.StatusNotFound)
+		return
+	}
+
+	resp := ShareResponse{FileID: req.FileID, Shared: req.Shared}
+	if req.Shared {
+		orgID, err := s.auth.OrganisationOf(req.UserID)
+		if err != nil {
+			log.Printf("Organisation lookup failed: %v", err)
+			http.Error(w, fmt.Sprintf("Share failed: %v", err), http.StatusBadGateway)
+			return
+		}
+		if orgID == "" {
+			http.Error(w, "user does not belong to an organisation", http.StatusConflict)
+			return
+		}
+		err = s.qrepo.SetPayload("documents", map[string]interface{}{"workspace_id": orgID}, own)
+		resp.WorkspaceID = orgID
+	} else {
+		err = s.qrepo.DeletePayload("documents", []string{"workspace_id"}, own)
+	}
+	if err != nil {
+		log.Printf("Qdrant payload update failed: %v", err)
+		http.Error(w, fmt.Sprintf("Share failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("🤝 File %s shared=%t by %s (workspace %s)", req.FileID, req.Shared, req.UserID, resp.WorkspaceID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Run consumes org_members and takes documents out of a workspace when
+// their uploader leaves it or the organisation is deleted.
+func (s *WorkspaceService) Run(ctx context.Context) {
+	log.Println("🚀 Workspace service: start org_members consumer loop")
+
+	for {
+		msg, err := s.consumer.ReadMessage(ctx)
+		if ctx.Err() != nil {
+			log.Println("🛑 Workspace service: context done")
+			return
+		}
+		if err != nil {
+			log.Printf("❌ consumer read error: %v", err)
+			time.Sleep(time.Second)
+			continue
+		}
+
+		env, payload, err := contracts.Decode(msg.Value)
+		if err != nil {
+			log.Printf("❌ invalid org_members event: %v", err)
+			continue
+		}
+
+		var filter map[string]interface{}
+		switch evt := payload.(type) {
+		case *contracts.OrgMemberChanged:
+			if evt.Action != contracts.MemberRemoved {
+				continue
+			}
+			filter = map[string]interface{}{
+				"must": []map[string]interface{}{
+					matchFilter("workspace_id", evt.OrganisationID),
+					matchFilter("uploader_id", evt.UserID),
+				},
+			}
+		case *contracts.OrganisationDeleted:
+			filter = map[string]interface{}{
+				"must": []map[string]interface{}{matchFilter("workspace_id", evt.OrganisationID)},
+			}
+		default:
+			log.Printf("ℹ️ Ignored %s event on org_members topic", env.Type)
+			continue
+		}
+
+		// Qdrant down: retry rather than leave documents shared.
+		for ctx.Err() == nil {
+			if err = s.qrepo.DeletePayload("documents", []string{"workspace_id"}, filter); err == nil {
+				log.Printf("✅ Workspace documents unshared after %s", env.Type)
+				break
+			}
+			log.Printf("⚠️ Failed to unshare documents after %s: %v", env.Type, err)
+			time.Sleep(5 * time.Second)
+		}
+	}
+}
+
+// accessFilter matches the chunks userID may search: their own uploads,
+// documents shared with their workspace orgID, and documents uploaded
+// without a user.
+func accessFilter(userID, orgID string) []map[string]interface{} {
+	should := []map[string]interface{}{
+		matchFilter("uploader_id", userID),
+		{"is_empty": map[string]interface{}{"key": "uploader_id"}},
+	}
+	if orgID != "" {
+		should = append(should, matchFilter("workspace_id", orgID))
+	}
+	return should
+}
+
+func matchFilter(key, value string) map[string]interface{} {
+	return map[string]interface{}{
+		"key":   key,
+		"match": map[string]interface{}{"value": value},
+	}
+}
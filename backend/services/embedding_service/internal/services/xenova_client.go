@@ -29,11 +29,18 @@ type EmbedRequest struct {
 type EmbedResponse struct {
 	Embedding []float32 `json:"embedding"`
 	Dimension int       `json:"dimension"`
+	Tokens    int       `json:"tokens"`
 }
 
 func (x *XenovaClient) Embed(text string) ([]float32, error) {
+	vec, _, err := x.EmbedWithUsage(text)
+	return vec, err
+}
+
+// EmbedWithUsage also returns the number of tokens the model consumed.
+func (x *XenovaClient) EmbedWithUsage(text string) ([]float32, int, error) {
 	if text == "" {
-		return nil, fmt.Errorf("text cannot be empty")
+		return nil, 0, fmt.Errorf("text cannot be empty")
 	}
 
 	url := fmt.Sprintf("%s/embed", x.baseURL)
@@ -41,29 +48,29 @@ func (x *XenovaClient) Embed(text string) ([]float32, error) {
 	reqBody := EmbedRequest{Text: text}
 	b, err := json.Marshal(reqBody)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+		return nil, 0, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	resp, err := x.client.Post(url, "application/json", bytes.NewReader(b))
 	if err != nil {
-		return nil, fmt.Errorf("xenova request failed: %w", err)
+		return nil, 0, fmt.Errorf("xenova request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("xenova returned status %d", resp.StatusCode)
+		return nil, 0, fmt.Errorf("xenova returned status %d", resp.StatusCode)
 	}
 
 	var embedResp EmbedResponse
 	if err := json.NewDecoder(resp.Body).Decode(&embedResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+		return nil, 0, fmt.Errorf("failed to decode response: %w", err)
 	}
 
 	if len(embedResp.Embedding) == 0 {
-		return nil, fmt.Errorf("empty embedding returned")
+		return nil, 0, fmt.Errorf("empty embedding returned")
 	}
 
-	return embedResp.Embedding, nil
+	return embedResp.Embedding, embedResp.Tokens, nil
 }
 
 // Health check
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/joho/godotenv"
+
+	"contracts/mailer"
+
+	"notification_service/internal/config"
+	"notification_service/internal/database"
+	"notification_service/internal/handler"
+	"notification_service/internal/hub"
+	"notification_service/internal/migrations"
+	"notification_service/internal/repository"
+	"notification_service/internal/router"
+	"notification_service/internal/services"
+)
+
+// waitForKafka retries connecting to Kafka before giving up
+func waitForKafka(brokers []string, retries int) error {
+	for i := 0; i < retries; i++ {
+		config := sarama.NewConfig()
+		client, err := sarama.NewClient(brokers, config)
+		if err == nil {
+			client.Close()
+			return nil
+		}
+		log.Printf("Kafka not ready yet, retrying... (%d/%d)", i+1, retries)
+		time.Sleep(5 * time.Second)
+	}
+	return fmt.Errorf("kafka not available after %d retries", retries)
+}
+
+func main() {
+	// Load environment variables
+	_ = godotenv.Load()
+
+	// Load configuration
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("❌ Failed to load config: %v", err)
+	}
+
+	log.Printf("Config loaded: pg=%s:%d db=%s kafka=%v smtp=%s:%d port=%s",
+		cfg.PostgresHost, cfg.PostgresPort, cfg.PostgresDB, cfg.KafkaBrokers, cfg.SMTPHost, cfg.SMTPPort, cfg.ServicePort)
+
+	// Connect to Postgres
+	if err := database.Connect(cfg); err != nil {
+		log.Fatalf("❌ Failed to connect to database: %v", err)
+	}
+	defer database.Close()
+
+	if err := migrations.Run(cfg.MigrationsPath); err != nil {
+		log.Fatalf("❌ Failed to run migrations: %v", err)
+	}
+
+	// Wait for Kafka to be ready
+	if err := waitForKafka(cfg.KafkaBrokers, 12); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	smtpMailer, err := mailer.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom)
+	if err != nil {
+		log.Fatalf("❌ Invalid SMTP config: %v", err)
+	}
+
+	repo := repository.NewPostgresNotificationRepository(database.DB)
+
+	// Initialize service (Kafka consumer dahil)
+	svc := services.NewNotificationService(
+		repo,
+		smtpMailer,
+		cfg.KafkaBrokers,
+		[]string{cfg.KafkaTopicUserRegistered, cfg.KafkaTopicQuotaAlerts},
+		cfg.KafkaGroup,
+		cfg.KafkaInitialOffset,
+	)
+	svc.AppURL = cfg.AppURL
+	svc.EmailRetryInterval = cfg.EmailRetryInterval
+	svc.EmailMaxAttempts = cfg.EmailMaxAttempts
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Start Kafka consumer group for registration and quota alert events
+	consumerDone := make(chan struct{})
+	go func() {
+		defer close(consumerDone)
+		if err := svc.StartKafkaConsumer(ctx); err != nil {
+			log.Fatalf("❌ Failed to start Kafka consumer: %v", err)
+		}
+	}()
+
+	// E-posta gönderimi (retry + backoff)
+	go svc.RunEmailDispatcher(ctx)
+
+	// Yeni bildirimleri bu instance'a bağlı SSE client'larına dağıt
+	notifications := hub.New()
+	go func() {
+		if err := notifications.Listen(ctx, cfg.PostgresDSN(), repository.NotifyChannel, svc.GetNotification); err != nil {
+			log.Fatalf("❌ Failed to listen for notifications: %v", err)
+		}
+	}()
+
+	// Initialize HTTP handler
+	notificationHandler := handler.NewNotificationHandler(svc, notifications, cfg.SSEKeepAlive)
+
+	// Setup routes
+	mux := http.NewServeMux()
+	router.SetupNotificationRoutes(mux, notificationHandler, []byte(cfg.JWTSecret))
+
+	// No WriteTimeout: streams stay open; the handler manages its own writes.
+	server := &http.Server{Addr: ":" + cfg.ServicePort, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		log.Println("🛑 Shutting down Notification Service...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("✅ Notification Service running on :%s", cfg.ServicePort)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Fatalf("❌ Failed to start server: %v", err)
+	}
+
+	// Let the consumer group leave cleanly so partitions are reassigned quickly.
+	<-consumerDone
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+type Config struct {
+	PostgresHost     string
+	PostgresPort     int
+	PostgresUser     string
+	PostgresPassword string
+	PostgresDB       string
+
+	KafkaBrokers             []string
+	KafkaTopicUserRegistered string
+	KafkaTopicQuotaAlerts    string
+	KafkaGroup               string
+	KafkaInitialOffset       string
+
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+	// AppURL is linked from emails.
+	AppURL string
+
+	EmailRetryInterval time.Duration
+	EmailMaxAttempts   int
+
+	SSEKeepAlive time.Duration
+
+	// JWTSecret verifies the access tokens of notification requests; the
+	// same secret auth_service signs them with.
+	JWTSecret string
+
+	ServicePort    string
+	LogLevel       string
+	MigrationsPath string
+}
+
+func LoadConfig() (*Config, error) {
+	_ = godotenv.Load()
+
+	cfg := &Config{}
+	cfg.PostgresHost = getenv("POSTGRES_HOST", "localhost")
+	cfg.PostgresPort = getenvInt("POSTGRES_PORT", 5432)
+	cfg.PostgresUser = getenv("POSTGRES_USER", "postgres")
+	cfg.PostgresPassword = getenv("POSTGRES_PASSWORD", "postgres")
+	cfg.PostgresDB = getenv("POSTGRES_DB", "notification_db")
+
+	cfg.KafkaBrokers = parseCSV(getenv("KAFKA_BROKERS", "localhost:9092"))
+	cfg.KafkaTopicUserRegistered = getenv("KAFKA_TOPIC_USER_REGISTERED", "user_registered")
+	cfg.KafkaTopicQuotaAlerts = getenv("KAFKA_TOPIC_QUOTA_ALERTS", "quota_alerts")
+	cfg.KafkaGroup = getenv("KAFKA_GROUP", "notification-service-group")
+	cfg.KafkaInitialOffset = getenv("KAFKA_INITIAL_OFFSET", "oldest")
+
+	// SMTP (yerelde MailHog)
+	cfg.SMTPHost = getenv("SMTP_HOST", "localhost")
+	cfg.SMTPPort = getenvInt("SMTP_PORT", 1025)
+	cfg.SMTPUsername = getenv("SMTP_USERNAME", "")
+	cfg.SMTPPassword = getenv("SMTP_PASSWORD", "")
+	cfg.SMTPFrom = getenv("SMTP_FROM", "Chat Platform <no-reply@chat.local>")
+	cfg.AppURL = getenv("APP_URL", "http://localhost:5173")
+
+	cfg.EmailRetryInterval = time.Duration(getenvInt("EMAIL_RETRY_INTERVAL_SECONDS", 30)) * time.Second
+	cfg.EmailMaxAttempts = getenvInt("EMAIL_MAX_ATTEMPTS", 5)
+
+	cfg.SSEKeepAlive = time.Duration(getenvInt("SSE_KEEPALIVE_SECONDS", 25)) * time.Second
+
+	cfg.JWTSecret = getenv("JWT_SECRET", "")
+
+	cfg.ServicePort = getenv("SERVICE_PORT", "8084")
+	cfg.LogLevel = getenv("LOG_LEVEL", "info")
+	cfg.MigrationsPath = getenv("MIGRATIONS_PATH", "internal/migrations")
+
+	if cfg.PostgresHost == "" || cfg.PostgresUser == "" || cfg.PostgresDB == "" {
+		return nil, fmt.Errorf("postgres config incomplete")
+	}
+	if len(cfg.KafkaBrokers) == 0 {
+		return nil, fmt.Errorf("kafka brokers not configured")
+	}
+	if cfg.EmailMaxAttempts < 1 {
+		return nil, fmt.Errorf("EMAIL_MAX_ATTEMPTS must be at least 1")
+	}
+
+	return cfg, nil
+}
+
+// PostgresDSN returns the lib/pq connection string.
+func (c *Config) PostgresDSN() string {
+	return fmt.Sprintf(
+		"host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
+		c.PostgresHost, c.PostgresPort, c.PostgresUser, c.PostgresPassword, c.PostgresDB,
+	)
+}
+
+func getenv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func getenvInt(key string, def int) int {
+	if v := os.Getenv(key); v != "" {
+		if i, err := strconv.Atoi(v); err == nil {
+			return i
+		}
+	}
+	return def
+}
+
+func parseCSV(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	parts := strings.Split(s, ",")
+	out := make([]string, 0, len(parts))
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
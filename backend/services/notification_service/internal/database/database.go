@@ -0,0 +1,45 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+
+	"notification_service/internal/config"
+)
+
+var DB *sqlx.DB
+
+// Connect connects to PostgreSQL
+func Connect(cfg *config.Config) error {
+	db, err := sqlx.Open("postgres", cfg.PostgresDSN())
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+
+	db.SetMaxOpenConns(20)
+	db.SetMaxIdleConns(10)
+	db.SetConnMaxLifetime(30 * time.Minute)
+
+	if err := db.Ping(); err != nil {
+		return fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	DB = db
+	log.Println("✅ Database connected successfully")
+	return nil
+}
+
+// Close closes DB connection
+func Close() {
+	if DB != nil {
+		if err := DB.Close(); err != nil {
+			log.Printf("⚠️ Error closing DB: %v", err)
+		} else {
+			log.Println("🧹 Database connection closed")
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"contracts/auth"
+
+	"notification_service/internal/hub"
+	"notification_service/internal/models"
+	"notification_service/internal/repository"
+	"notification_service/internal/services"
+)
+
+type NotificationHandler struct {
+	service *services.NotificationService
+	hub     *hub.Hub
+	// keepAlive is how often an idle stream gets a comment line, so proxies
+	// don't close it.
+	keepAlive time.Duration
+}
+
+func NewNotificationHandler(service *services.NotificationService, h *hub.Hub, keepAlive time.Duration) *NotificationHandler {
+	return &NotificationHandler{service: service, hub: h, keepAlive: keepAlive}
+}
+
+// Stream pushes the user's new notifications as server-sent events:
+// /api/notifications/stream/{user_id}. A reconnecting client (sending
+// Last-Event-ID) first gets what it missed.
+func (h *NotificationHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	uid, err := uuid.Parse(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/notifications/stream/"), "/"))
+	if err != nil {
+		http.Error(w, "invalid user_id", http.StatusBadRequest)
+		return
+	}
+	if !isTokenUser(w, r, uid) {
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	// A stream outlives the server's write timeout.
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Subscribe before loading the backlog so nothing falls in between; a
+	// notification in both is sent twice and the client dedupes by id.
+	client := h.hub.Subscribe(uid)
+	defer h.hub.Unsubscribe(client)
+
+	var missed []models.Notification
+	if last := r.Header.Get("Last-Event-ID"); last != "" {
+		lastID, err := uuid.Parse(last)
+		if err != nil {
+			http.Error(w, "invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+		if missed, err = h.service.MissedNotifications(uid, lastID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// retry: reconnect delay for EventSource, in ms
+	fmt.Fprint(w, "retry: 5000\n\n")
+	for i := range missed {
+		if err := writeEvent(w, &missed[i]); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(h.keepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-client.Done:
+			return
+		case n := <-client.C:
+			if err := writeEvent(w, n); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			log.Printf("⚠️ SSE flush failed for user %s: %v", uid, err)
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, n *models.Notification) error {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: notification\ndata: %s\n\n", n.ID, data)
+	return err
+}
+
+// Notifications serves the notification endpoints:
+//
+//	GET  /api/notifications/{user_id}?unread=true  latest notifications
+//	POST /api/notifications/{user_id}/read_all     mark all read
+//	POST /api/notifications/{user_id}/{id}/read    mark one read
+func (h *NotificationHandler) Notifications(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/notifications/"), "/"), "/")
+	uid, err := uuid.Parse(parts[0])
+	if err != nil {
+		http.Error(w, "invalid user_id", http.StatusBadRequest)
+		return
+	}
+	if !isTokenUser(w, r, uid) {
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		list, err := h.service.ListNotifications(uid, r.URL.Query().Get("unread") == "true")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"notifications": list})
+
+	case len(parts) == 2 && parts[1] == "read_all" && r.Method == http.MethodPost:
+		count, err := h.service.MarkAllRead(uid)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]int{"marked": count})
+
+	case len(parts) == 3 && parts[2] == "read" && r.Method == http.MethodPost:
+		id, err := uuid.Parse(parts[1])
+		if err != nil {
+			http.Error(w, "invalid notification id", http.StatusBadRequest)
+			return
+		}
+		n, err := h.service.MarkRead(uid, id)
+		if errors.Is(err, repository.ErrNotificationNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, n)
+
+	case len(parts) <= 3:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// isTokenUser reports whether uid is the user the request's token was issued
+// to; a user only sees their own notifications. It answers 403 otherwise.
+func isTokenUser(w http.ResponseWriter, r *http.Request, uid uuid.UUID) bool {
+	if c, ok := auth.ClaimsFrom(r.Context()); ok && c.UserID() == uid.String() {
+		return true
+	}
+	http.Error(w, "forbidden", http.StatusForbidden)
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
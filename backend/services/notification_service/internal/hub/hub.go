@@ -0,0 +1,126 @@
+// Package hub fans new notifications out to the SSE clients connected to
+// this instance.
+package hub
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"notification_service/internal/models"
+)
+
+// clientBuffer is how many notifications a slow client may lag behind
+// before it is disconnected; it reconnects with Last-Event-ID and catches up.
+const clientBuffer = 16
+
+// Client receives the notifications of one user.
+type Client struct {
+	UserID uuid.UUID
+	C      chan *models.Notification
+	// Done is closed when the hub drops the client for lagging behind.
+	Done chan struct{}
+}
+
+type Hub struct {
+	mu      sync.Mutex
+	clients map[uuid.UUID]map[*Client]struct{}
+}
+
+func New() *Hub {
+	return &Hub{clients: map[uuid.UUID]map[*Client]struct{}{}}
+}
+
+// Subscribe registers a client for userID. Call Unsubscribe when the
+// connection ends.
+func (h *Hub) Subscribe(userID uuid.UUID) *Client {
+	c := &Client{UserID: userID, C: make(chan *models.Notification, clientBuffer), Done: make(chan struct{})}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.clients[userID] == nil {
+		h.clients[userID] = map[*Client]struct{}{}
+	}
+	h.clients[userID][c] = struct{}{}
+	return c
+}
+
+func (h *Hub) Unsubscribe(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remove(c)
+}
+
+// remove drops c; h.mu must be held.
+func (h *Hub) remove(c *Client) {
+	clients := h.clients[c.UserID]
+	if _, ok := clients[c]; !ok {
+		return
+	}
+	delete(clients, c)
+	if len(clients) == 0 {
+		delete(h.clients, c.UserID)
+	}
+	close(c.Done)
+}
+
+// Publish hands n to every client of its user without blocking.
+func (h *Hub) Publish(n *models.Notification) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.clients[n.UserID] {
+		select {
+		case c.C <- n:
+		default:
+			log.Printf("⚠️ SSE client of user %s is lagging, disconnecting", n.UserID)
+			h.remove(c)
+		}
+	}
+}
+
+// Listen follows the Postgres channel new notification ids are announced on
+// and publishes each notification, loaded with get, until ctx is cancelled.
+// Every instance listens, so a client gets its notifications whichever
+// instance consumed the event.
+func (h *Hub) Listen(ctx context.Context, dsn, channel string, get func(uuid.UUID) (*models.Notification, error)) error {
+	listener := pq.NewListener(dsn, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("⚠️ Notification listener: %v", err)
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(channel); err != nil {
+		return err
+	}
+	log.Printf("🎧 Listening for new notifications on %q", channel)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev := <-listener.Notify:
+			// nil after a reconnect; clients catch up with Last-Event-ID.
+			if ev == nil {
+				continue
+			}
+			id, err := uuid.Parse(ev.Extra)
+			if err != nil {
+				log.Printf("⚠️ Invalid notification id %q: %v", ev.Extra, err)
+				continue
+			}
+			n, err := get(id)
+			if err != nil {
+				log.Printf("⚠️ Failed to load notification %s: %v", id, err)
+				continue
+			}
+			h.Publish(n)
+		case <-time.After(90 * time.Second):
+			// Detect dead connections that never reported an error.
+			go listener.Ping()
+		}
+	}
+}
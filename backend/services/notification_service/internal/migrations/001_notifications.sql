@@ -0,0 +1,39 @@
+-- ---------------------------
+-- Recipients
+-- ---------------------------
+
+-- user_registered event'lerinden doldurulur (e-posta adresi auth_service'te)
+CREATE TABLE IF NOT EXISTS recipients (
+    user_id UUID PRIMARY KEY,
+    email VARCHAR(255) NOT NULL,
+    username VARCHAR(100) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- ---------------------------
+-- Notifications
+-- ---------------------------
+
+-- event_id: kaynak event'in id'si; tekrar teslimlerde bildirim bir kez oluşur
+-- email_status: pending → sent | failed (deneme hakkı bitti)
+-- email_next_attempt_at: bir sonraki deneme; gönderim sırasında kilit süresi kadar ileri alınır
+CREATE TABLE IF NOT EXISTS notifications (
+    id UUID PRIMARY KEY,
+    event_id UUID UNIQUE NOT NULL,
+    user_id UUID NOT NULL,
+    kind VARCHAR(50) NOT NULL,
+    title TEXT NOT NULL,
+    body TEXT NOT NULL,
+    data JSONB NOT NULL DEFAULT '{}',
+    email_status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    email_attempts INT NOT NULL DEFAULT 0,
+    email_error TEXT NULL,
+    email_next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    emailed_at TIMESTAMP NULL,
+    read_at TIMESTAMP NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications (user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_notifications_email_pending ON notifications (email_next_attempt_at) WHERE email_status = 'pending';
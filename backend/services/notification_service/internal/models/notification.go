@@ -0,0 +1,55 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Notification kinds.
+const (
+	KindQuotaThreshold = "quota_threshold"
+	KindQuotaExhausted = "quota_exhausted"
+	KindQuotaExpired   = "subscription_expired"
+)
+
+// Email delivery statuses.
+const (
+	EmailPending = "pending"
+	EmailSent    = "sent"
+	EmailFailed  = "failed"
+)
+
+// Notification is one message to a user, shown in the client and sent by
+// email.
+type Notification struct {
+	ID            uuid.UUID       `db:"id" json:"id"`
+	EventID       uuid.UUID       `db:"event_id" json:"-"`
+	UserID        uuid.UUID       `db:"user_id" json:"user_id"`
+	Kind          string          `db:"kind" json:"kind"`
+	Title         string          `db:"title" json:"title"`
+	Body          string          `db:"body" json:"body"`
+	Data          json.RawMessage `db:"data" json:"data"`
+	EmailStatus   string          `db:"email_status" json:"email_status"`
+	EmailAttempts int             `db:"email_attempts" json:"-"`
+	EmailError    *string         `db:"email_error" json:"-"`
+	EmailedAt     *time.Time      `db:"emailed_at" json:"emailed_at,omitempty"`
+	ReadAt        *time.Time      `db:"read_at" json:"read_at,omitempty"`
+	CreatedAt     time.Time       `db:"created_at" json:"created_at"`
+}
+
+// Recipient is where a user's emails go.
+type Recipient struct {
+	UserID   uuid.UUID `db:"user_id" json:"user_id"`
+	Email    string    `db:"email" json:"email"`
+	Username string    `db:"username" json:"username"`
+}
+
+// PendingEmail is a notification waiting to be emailed, with its recipient
+// if one is known.
+type PendingEmail struct {
+	Notification
+	Email    *string `db:"email"`
+	Username *string `db:"username"`
+}
@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"notification_service/internal/models"
+)
+
+// NotifyChannel is the Postgres channel that carries the id of every new
+// notification, so each instance can push it to its own SSE clients.
+const NotifyChannel = "notifications"
+
+// ErrNotificationNotFound means the user has no notification with that id.
+var ErrNotificationNotFound = errors.New("notification not found")
+
+type NotificationRepository interface {
+	UpsertRecipient(r *models.Recipient) error
+	CreateNotification(n *models.Notification) (bool, error)
+	GetNotification(id uuid.UUID) (*models.Notification, error)
+	ListNotifications(userID uuid.UUID, unreadOnly bool, limit int) ([]models.Notification, error)
+	ListNotificationsAfter(userID, afterID uuid.UUID, limit int) ([]models.Notification, error)
+	MarkRead(userID, id uuid.UUID) (*models.Notification, error)
+	MarkAllRead(userID uuid.UUID) (int, error)
+	ClaimPendingEmails(limit int, lease time.Duration) ([]models.PendingEmail, error)
+	MarkEmailSent(id uuid.UUID) error
+	MarkEmailFailed(id uuid.UUID, reason string, retryAt *time.Time) error
+}
+
+type PostgresNotificationRepository struct {
+	db *sqlx.DB
+}
+
+func NewPostgresNotificationRepository(db *sqlx.DB) *PostgresNotificationRepository {
+	return &PostgresNotificationRepository{db: db}
+}
+
+const notificationColumns = `id, event_id, user_id, kind, title, body, data, email_status, email_attempts,
+	email_error, emailed_at, read_at, created_at`
+
+// UpsertRecipient stores or updates where the user's emails go.
+func (r *PostgresNotificationRepository) UpsertRecipient(rc *models.Recipient) error {
+	_, err := r.db.Exec(`
+		INSERT INTO recipients (user_id, email, username)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE
+		SET email = EXCLUDED.email, username = EXCLUDED.username, updated_at = NOW()`,
+		rc.UserID, rc.Email, rc.Username)
+	if err != nil {
+		return fmt.Errorf("upsert recipient: %w", err)
+	}
+	return nil
+}
+
+// CreateNotification stores n unless a notification for the same event
+// exists, and announces it on NotifyChannel when the transaction commits.
+// It reports whether n was created.
+func (r *PostgresNotificationRepository) CreateNotification(n *models.Notification) (bool, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return false, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.Get(n, `
+		INSERT INTO notifications (id, event_id, user_id, kind, title, body, data)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (event_id) DO NOTHING
+		RETURNING `+notificationColumns,
+		n.ID, n.EventID, n.UserID, n.Kind, n.Title, n.Body, n.Data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("insert notification: %w", err)
+	}
+
+	if _, err := tx.Exec(`SELECT pg_notify($1, $2)`, NotifyChannel, n.ID.String()); err != nil {
+		return false, fmt.Errorf("announce notification: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("commit tx: %w", err)
+	}
+	return true, nil
+}
+
+func (r *PostgresNotificationRepository) GetNotification(id uuid.UUID) (*models.Notification, error) {
+	var n models.Notification
+	if err := r.db.Get(&n, `SELECT `+notificationColumns+` FROM notifications WHERE id = $1`, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotificationNotFound
+		}
+		return nil, fmt.Errorf("get notification: %w", err)
+	}
+	return &n, nil
+}
+
+// ListNotifications returns the user's latest notifications, newest first.
+func (r *PostgresNotificationRepository) ListNotifications(userID uuid.UUID, unreadOnly bool, limit int) ([]models.Notification, error) {
+	list := []models.Notification{}
+	err := r.db.Select(&list, `
+		SELECT `+notificationColumns+`
+		FROM notifications
+		WHERE user_id = $1 AND (NOT $2 OR read_at IS NULL)
+		ORDER BY created_at DESC
+		LIMIT $3`,
+		userID, unreadOnly, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list notifications: %w", err)
+	}
+	return list, nil
+}
+
+// ListNotificationsAfter returns the user's notifications created after
+// afterID, oldest first. An unknown afterID returns nothing.
+func (r *PostgresNotificationRepository) ListNotificationsAfter(userID, afterID uuid.UUID, limit int) ([]models.Notification, error) {
+	list := []models.Notification{}
+	err := r.db.Select(&list, `
+		SELECT `+notificationColumns+`
+		FROM notifications
+		WHERE user_id = $1
+		  AND created_at > (SELECT created_at FROM notifications WHERE id = $2 AND user_id = $1)
+		ORDER BY created_at
+		LIMIT $3`,
+		userID, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list notifications: %w", err)
+	}
+	return list, nil
+}
+
+// MarkRead marks one of the user's notifications read. Marking it again
+// keeps the first read time.
+func (r *PostgresNotificationRepository) MarkRead(userID, id uuid.UUID) (*models.Notification, error) {
+	var n models.Notification
+	err := r.db.Get(&n, `
+		UPDATE notifications
+		SET read_at = COALESCE(read_at, NOW())
+		WHERE id = $1 AND user_id = $2
+		RETURNING `+notificationColumns,
+		id, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotificationNotFound
+		}
+		return nil, fmt.Errorf("mark notification read: %w", err)
+	}
+	return &n, nil
+}
+
+// MarkAllRead marks every unread notification of the user read and returns
+// how many there were.
+func (r *PostgresNotificationRepository) MarkAllRead(userID uuid.UUID) (int, error) {
+	res, err := r.db.Exec(`UPDATE notifications SET read_at = NOW() WHERE user_id = $1 AND read_at IS NULL`, userID)
+	if err != nil {
+		return 0, fmt.Errorf("mark notifications read: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// ClaimPendingEmails picks up to limit notifications due for an email
+// attempt, counts the attempt and hides them from other claimers for lease.
+// Rows are locked with SKIP LOCKED so several instances can deliver.
+func (r *PostgresNotificationRepository) ClaimPendingEmails(limit int, lease time.Duration) ([]models.PendingEmail, error) {
+	pending := []models.PendingEmail{}
+	err := r.db.Select(&pending, `
+		WITH due AS (
+			SELECT id FROM notifications
+			WHERE email_status = $1 AND email_next_attempt_at <= NOW()
+			ORDER BY email_next_attempt_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		), claimed AS (
+			UPDATE notifications n
+			SET email_attempts = n.email_attempts + 1,
+				email_next_attempt_at = NOW() + make_interval(secs => $3)
+			FROM due
+			WHERE n.id = due.id
+			RETURNING n.*
+		)
+		SELECT c.id, c.event_id, c.user_id, c.kind, c.title, c.body, c.data, c.email_status, c.email_attempts,
+			c.email_error, c.emailed_at, c.read_at, c.created_at,
+			rc.email, rc.username
+		FROM claimed c
+		LEFT JOIN recipients rc ON rc.user_id = c.user_id
+		ORDER BY c.created_at`,
+		models.EmailPending, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("claim pending emails: %w", err)
+	}
+	return pending, nil
+}
+
+func (r *PostgresNotificationRepository) MarkEmailSent(id uuid.UUID) error {
+	_, err := r.db.Exec(`
+		UPDATE notifications
+		SET email_status = $2, emailed_at = NOW(), email_error = NULL
+		WHERE id = $1`,
+		id, models.EmailSent)
+	if err != nil {
+		return fmt.Errorf("mark email sent: %w", err)
+	}
+	return nil
+}
+
+// MarkEmailFailed records a failed attempt. With retryAt the email stays
+// pending until then; without it the email is given up.
+func (r *PostgresNotificationRepository) MarkEmailFailed(id uuid.UUID, reason string, retryAt *time.Time) error {
+	var err error
+	if retryAt != nil {
+		_, err = r.db.Exec(`UPDATE notifications SET email_error = $2, email_next_attempt_at = $3 WHERE id = $1`,
+			id, reason, *retryAt)
+	} else {
+		_, err = r.db.Exec(`UPDATE notifications SET email_status = $2, email_error = $3 WHERE id = $1`,
+			id, models.EmailFailed, reason)
+	}
+	if err != nil {
+		return fmt.Errorf("mark email failed: %w", err)
+	}
+	return nil
+}
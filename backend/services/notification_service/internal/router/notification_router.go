@@ -0,0 +1,29 @@
+package router
+
+import (
+	"net/http"
+
+	"contracts/auth"
+
+	"notification_service/internal/handler"
+)
+
+func SetupNotificationRoutes(mux *http.ServeMux, h *handler.NotificationHandler, jwtSecret []byte) {
+	// Health check
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"ok","service":"notification_service"}`))
+	})
+
+	// Gerçek zamanlı bildirimler (SSE); yalnızca token'ın kullanıcısına
+	mux.HandleFunc("/api/notifications/stream/", auth.RequireUser(jwtSecret, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			h.Stream(w, r)
+			return
+		}
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	// Bildirim listesi / okundu işaretleme
+	mux.HandleFunc("/api/notifications/", auth.RequireUser(jwtSecret, h.Notifications))
+}
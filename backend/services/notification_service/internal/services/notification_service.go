@@ -0,0 +1,240 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/google/uuid"
+
+	"contracts/events"
+	"contracts/kafka"
+	"contracts/mailer"
+
+	"notification_service/internal/models"
+	"notification_service/internal/repository"
+)
+
+const (
+	// emailBatchSize is how many emails one dispatcher round sends.
+	emailBatchSize = 50
+	// emailLease hides a claimed email from other instances while it is
+	// being sent.
+	emailLease = 2 * time.Minute
+	// maxEmailBackoff caps the wait between attempts.
+	maxEmailBackoff = time.Hour
+)
+
+type NotificationService struct {
+	repo   repository.NotificationRepository
+	mailer mailer.Mailer
+
+	KafkaBrokers       []string
+	KafkaTopics        []string
+	KafkaGroup         string
+	KafkaInitialOffset string
+
+	// AppURL is linked from emails.
+	AppURL string
+	// EmailRetryInterval is the dispatcher's poll interval and the first
+	// retry delay, doubled on each failed attempt up to EmailMaxAttempts.
+	EmailRetryInterval time.Duration
+	EmailMaxAttempts   int
+
+	// wake starts a dispatcher round right after a notification is created.
+	wake chan struct{}
+}
+
+// NewNotificationService constructs a new service
+func NewNotificationService(repo repository.NotificationRepository, m mailer.Mailer, brokers, topics []string, group, initialOffset string) *NotificationService {
+	return &NotificationService{
+		repo:               repo,
+		mailer:             m,
+		KafkaBrokers:       brokers,
+		KafkaTopics:        topics,
+		KafkaGroup:         group,
+		KafkaInitialOffset: initialOffset,
+		EmailRetryInterval: 30 * time.Second,
+		EmailMaxAttempts:   5,
+		wake:               make(chan struct{}, 1),
+	}
+}
+
+// StartKafkaConsumer joins the notification consumer group and handles
+// registration and quota alert events until ctx is cancelled.
+func (s *NotificationService) StartKafkaConsumer(ctx context.Context) error {
+	return kafka.RunConsumerGroup(
+		ctx,
+		s.KafkaBrokers,
+		s.KafkaGroup,
+		s.KafkaTopics,
+		kafka.ParseInitialOffset(s.KafkaInitialOffset),
+		s.handleKafkaMessage,
+	)
+}
+
+// handleKafkaMessage processes one event. Returned errors are retried by the
+// consumer, so handlers must be safe to run more than once per event.
+func (s *NotificationService) handleKafkaMessage(msg *sarama.ConsumerMessage) error {
+	env, payload, err := events.Decode(msg.Value)
+	if errors.Is(err, events.ErrUnknownEvent) {
+		log.Printf("ℹ️ Ignored unknown event: %v", err)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", kafka.ErrInvalidMessage, err)
+	}
+
+	switch evt := payload.(type) {
+	case *events.UserRegistered:
+		// Auth service’den gelen event → e-posta adresini sakla
+		uid, err := uuid.Parse(evt.UserID)
+		if err != nil {
+			return fmt.Errorf("%w: invalid user_id: %v", kafka.ErrInvalidMessage, err)
+		}
+		return s.repo.UpsertRecipient(&models.Recipient{UserID: uid, Email: evt.Email, Username: evt.Username})
+
+	case *events.QuotaAlert:
+		// Subscription service’den gelen event → bildirim oluştur
+		uid, err := uuid.Parse(evt.UserID)
+		if err != nil {
+			return fmt.Errorf("%w: invalid user_id: %v", kafka.ErrInvalidMessage, err)
+		}
+		eventID, err := uuid.Parse(env.ID)
+		if err != nil {
+			return fmt.Errorf("%w: invalid event id: %v", kafka.ErrInvalidMessage, err)
+		}
+		data, err := json.Marshal(evt)
+		if err != nil {
+			return fmt.Errorf("%w: %v", kafka.ErrInvalidMessage, err)
+		}
+
+		kind, title, body := renderQuotaAlert(evt)
+		n := &models.Notification{
+			ID:      uuid.New(),
+			EventID: eventID,
+			UserID:  uid,
+			Kind:    kind,
+			Title:   title,
+			Body:    body,
+			Data:    data,
+		}
+		created, err := s.repo.CreateNotification(n)
+		if err != nil {
+			return err
+		}
+		if !created {
+			log.Printf("ℹ️ Event %s already notified, skipping", eventID)
+			return nil
+		}
+		log.Printf("🔔 Notification %s (%s) created for user %s", n.ID, kind, uid)
+		s.wakeDispatcher()
+
+	default:
+		log.Printf("ℹ️ Ignored event type: %s", env.Type)
+	}
+	return nil
+}
+
+func (s *NotificationService) wakeDispatcher() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// RunEmailDispatcher emails pending notifications every EmailRetryInterval,
+// and right after new ones are created, until ctx is cancelled.
+func (s *NotificationService) RunEmailDispatcher(ctx context.Context) {
+	ticker := time.NewTicker(s.EmailRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		// A full batch means more may be waiting.
+		if s.deliverPendingEmails() == emailBatchSize && ctx.Err() == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// deliverPendingEmails sends one batch of due emails and returns how many
+// were claimed.
+func (s *NotificationService) deliverPendingEmails() int {
+	pending, err := s.repo.ClaimPendingEmails(emailBatchSize, emailLease)
+	if err != nil {
+		log.Printf("⚠️ Email dispatcher error: %v", err)
+		return 0
+	}
+
+	for i := range pending {
+		p := &pending[i]
+		var sendErr error
+		if p.Email == nil {
+			// The user_registered event may still be on its way.
+			sendErr = errors.New("no email address known for user")
+		} else {
+			username := ""
+			if p.Username != nil {
+				username = *p.Username
+			}
+			sendErr = s.mailer.Send(*p.Email, p.Title, emailBody(username, p.Body, s.AppURL))
+		}
+
+		if sendErr == nil {
+			if err := s.repo.MarkEmailSent(p.ID); err != nil {
+				log.Printf("⚠️ %v", err)
+			}
+			log.Printf("📧 Notification %s emailed to user %s", p.ID, p.UserID)
+			continue
+		}
+
+		var retryAt *time.Time
+		if p.EmailAttempts < s.EmailMaxAttempts {
+			backoff := min(s.EmailRetryInterval<<(p.EmailAttempts-1), maxEmailBackoff)
+			at := time.Now().UTC().Add(backoff)
+			retryAt = &at
+		}
+		log.Printf("⚠️ Email for notification %s failed (attempt %d/%d): %v",
+			p.ID, p.EmailAttempts, s.EmailMaxAttempts, sendErr)
+		if err := s.repo.MarkEmailFailed(p.ID, sendErr.Error(), retryAt); err != nil {
+			log.Printf("⚠️ %v", err)
+		}
+	}
+	return len(pending)
+}
+
+// ListNotifications returns the user's latest notifications
+func (s *NotificationService) ListNotifications(userID uuid.UUID, unreadOnly bool) ([]models.Notification, error) {
+	return s.repo.ListNotifications(userID, unreadOnly, 50)
+}
+
+// MissedNotifications returns what a reconnecting stream missed since
+// lastID.
+func (s *NotificationService) MissedNotifications(userID, lastID uuid.UUID) ([]models.Notification, error) {
+	return s.repo.ListNotificationsAfter(userID, lastID, 100)
+}
+
+// MarkRead marks one notification read
+func (s *NotificationService) MarkRead(userID, id uuid.UUID) (*models.Notification, error) {
+	return s.repo.MarkRead(userID, id)
+}
+
+// MarkAllRead marks all notifications of the user read
+func (s *NotificationService) MarkAllRead(userID uuid.UUID) (int, error) {
+	return s.repo.MarkAllRead(userID)
+}
+
+// GetNotification loads a notification for the SSE hub
+func (s *NotificationService) GetNotification(id uuid.UUID) (*models.Notification, error) {
+	return s.repo.GetNotification(id)
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"fmt"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"contracts/events"
+
+	"notification_service/internal/models"
+)
+
+// meterNames are the user facing names of the subscription meters.
+var meterNames = map[string]string{
+	"messages":          "mesaj",
+	"prompt_tokens":     "girdi token",
+	"completion_tokens": "çıktı token",
+	"embedding_tokens":  "doküman token",
+	"pages":             "sayfa (OCR)",
+}
+
+func meterName(meter string) string {
+	if name, ok := meterNames[meter]; ok {
+		return name
+	}
+	return meter
+}
+
+// formatDate prints an RFC 3339 time as a Turkish date, or the raw value if
+// it doesn't parse.
+func formatDate(s string) string {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return s
+	}
+	return t.UTC().Format("02.01.2006 15:04") + " UTC"
+}
+
+// renderQuotaAlert turns a quota alert into the notification kind, title
+// and body shown to the user. Alerts of an organisation's pooled
+// subscription say so.
+func renderQuotaAlert(a *events.QuotaAlert) (kind, title, body string) {
+	kind, title, body = renderSubscriptionAlert(a)
+	if a.OrganisationID != "" {
+		title = "Organizasyon: " + title
+		body += " Bu uyarı, organizasyonunuzun ortak kotası içindir."
+	}
+	return kind, title, body
+}
+
+func renderSubscriptionAlert(a *events.QuotaAlert) (kind, title, body string) {
+	switch {
+	case a.Kind == events.AlertExpired:
+		return models.KindQuotaExpired,
+			"Aboneliğiniz sona erdi",
+			fmt.Sprintf("%s aboneliğinizin süresi %s tarihinde doldu. "+
+				"Kullanmaya devam etmek için planınızı yenileyin.", a.PlanName, formatDate(a.PeriodEnd))
+
+	case a.ThresholdPercent >= 100:
+		return models.KindQuotaExhausted,
+			fmt.Sprintf("%s kotanız doldu", capitalize(meterName(a.Meter))),
+			fmt.Sprintf("%s planınızdaki %s kotanızın tamamını kullandınız (%d / %d). "+
+				"Kota %s tarihinde yenilenene kadar yeni istekler reddedilir; planınızı yükselterek hemen devam edebilirsiniz.",
+				a.PlanName, meterName(a.Meter), a.Used, a.Limit, formatDate(a.PeriodEnd))
+
+	default:
+		return models.KindQuotaThreshold,
+			fmt.Sprintf("%s kullanımınız %%%d seviyesine ulaştı", capitalize(meterName(a.Meter)), a.ThresholdPercent),
+			fmt.Sprintf("%s planınızdaki %s kullanımınız kotanın %%%d seviyesine ulaştı (%d / %d). Kota %s tarihinde yenilenir.",
+				a.PlanName, meterName(a.Meter), a.ThresholdPercent, a.Used, a.Limit, formatDate(a.PeriodEnd))
+	}
+}
+
+// emailBody wraps a notification for email.
+func emailBody(username, body, appURL string) string {
+	greeting := "Merhaba,"
+	if username != "" {
+		greeting = fmt.Sprintf("Merhaba %s,", username)
+	}
+	return fmt.Sprintf("%s\n\n%s\n\nHesabınız: %s\n\nBu e-posta otomatik olarak gönderilmiştir.\n", greeting, body, appURL)
+}
+
+func capitalize(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if r == utf8.RuneError {
+		return s
+	}
+	return string(unicode.ToUpper(r)) + s[size:]
+}
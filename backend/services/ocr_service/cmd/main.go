@@ -58,8 +58,16 @@ func main() {
 	}
 	defer consumer.Close()
 
+	// user_deleted consumer (silinen kullanıcının dosyalarını siler)
+	userConsumer, err := events.NewConsumer(cfg.KafkaBrokers, cfg.KafkaTopicUserDeleted, cfg.KafkaGroup+"-user-data")
+	if err != nil {
+		log.Fatalf("failed to create kafka consumer: %v", err)
+	}
+	defer userConsumer.Close()
+
 	// Services
 	ocrSvc := services.NewOCRService(consumer, producer)
+	userDataSvc := services.NewUserDataService(userConsumer, producer, cfg.KafkaTopicUserDataPurged)
 
 	// Handler
 	uploadHandler := handler.NewUploadHandler(producer)
@@ -67,10 +75,11 @@ func main() {
 	// Kafka consumer background loop
 	ctx, cancel := context.WithCancel(context.Background())
 	go ocrSvc.Run(ctx)
+	go userDataSvc.Run(ctx)
 
 	// HTTP server
 	go func() {
-		r := router.SetupRouter(uploadHandler, producer)
+		r := router.SetupRouter(uploadHandler, producer, []byte(cfg.JWTSecret))
 		addr := ":" + cfg.Port
 		log.Printf("✅ OCR Service running on %s", addr)
 		if err := r.Run(addr); err != nil {
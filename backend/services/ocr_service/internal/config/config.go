@@ -10,6 +10,15 @@ type Config struct {
 	Port         string
 	KafkaBrokers []string
 	KafkaGroup   string
+
+	// KafkaTopicUserDeleted triggers the erasure of a deleted user's
+	// uploads, reported on KafkaTopicUserDataPurged.
+	KafkaTopicUserDeleted    string
+	KafkaTopicUserDataPurged string
+
+	// JWTSecret verifies the access tokens of upload requests; the same
+	// secret auth_service signs them with.
+	JWTSecret string
 }
 
 func LoadConfig() *Config {
@@ -28,10 +37,23 @@ func LoadConfig() *Config {
 	if group == "" {
 		group = "ocr-service-group"
 	}
+	deletedTopic := os.Getenv("KAFKA_TOPIC_USER_DELETED")
+	if deletedTopic == "" {
+		deletedTopic = "user_deleted"
+	}
+	purgedTopic := os.Getenv("KAFKA_TOPIC_USER_DATA_PURGED")
+	if purgedTopic == "" {
+		purgedTopic = "user_data_purged"
+	}
 
 	return &Config{
 		Port:         port,
 		KafkaBrokers: []string{brokersEnv},
 		KafkaGroup:   group,
+
+		KafkaTopicUserDeleted:    deletedTopic,
+		KafkaTopicUserDataPurged: purgedTopic,
+
+		JWTSecret: os.Getenv("JWT_SECRET"),
 	}
 }
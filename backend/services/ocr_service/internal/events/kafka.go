@@ -58,6 +58,17 @@ func (c *Consumer) ReadMessage(ctx context.Context) (kafka.Message, error) {
 	return c.reader.ReadMessage(ctx)
 }
 
+// FetchMessage is ReadMessage without the commit: the message is delivered
+// again after a restart unless CommitMessage is called for it.
+func (c *Consumer) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	return c.reader.FetchMessage(ctx)
+}
+
+// CommitMessage commits the offset of a message from FetchMessage.
+func (c *Consumer) CommitMessage(ctx context.Context, msg kafka.Message) error {
+	return c.reader.CommitMessages(ctx, msg)
+}
+
 func (c *Consumer) Close() error {
 	return c.reader.Close()
 }
@@ -9,7 +9,7 @@ import (
 	"path/filepath"
 	"strings"
 
-	"ocr_service/internal/models"
+	"contracts/events"
 
 	"github.com/google/uuid"
 	"github.com/otiai10/gosseract/v2"
@@ -20,7 +20,7 @@ func fileExists(path string) bool {
 	return !os.IsNotExist(err)
 }
 
-func ProcessFile(path string) ([]models.Chunk, error) {
+func ProcessFile(path string) ([]events.Chunk, error) {
 	if !fileExists(path) {
 		return nil, fmt.Errorf("file not found: %s", path)
 	}
@@ -44,7 +44,7 @@ func ProcessFile(path string) ([]models.Chunk, error) {
 	return nil, fmt.Errorf("unsupported file type: %s", ext)
 }
 
-func processPDF(path string) ([]models.Chunk, error) {
+func processPDF(path string) ([]events.Chunk, error) {
 	log.Printf("📄 Processing PDF: %s", filepath.Base(path))
 
 	tmpDir, err := os.MkdirTemp("", "ocr_pages")
@@ -89,7 +89,7 @@ func processPDF(path string) ([]models.Chunk, error) {
 	// ✅ PSM 3: Fully automatic page segmentation
 	client.SetPageSegMode(gosseract.PSM_AUTO)
 
-	var chunks []models.Chunk
+	var chunks []events.Chunk
 	totalText := 0
 
 	for i, img := range files {
@@ -117,7 +117,7 @@ func processPDF(path string) ([]models.Chunk, error) {
 		log.Printf("✅ Page %d: extracted %d characters, created %d chunks", page, len(text), len(subChunks))
 
 		for idx, sc := range subChunks {
-			chunks = append(chunks, models.Chunk{
+			chunks = append(chunks, events.Chunk{
 				ChunkID:     uuid.New().String(),
 				Text:        sc,
 				Page:        page,
@@ -138,7 +138,7 @@ func processPDF(path string) ([]models.Chunk, error) {
 }
 
 // ✅ TEK processImage fonksiyonu (güncellenmiş versiyon)
-func processImage(path string) ([]models.Chunk, error) {
+func processImage(path string) ([]events.Chunk, error) {
 	log.Printf("🖼️ Processing image: %s", filepath.Base(path))
 
 	client := gosseract.NewClient()
@@ -174,10 +174,10 @@ func processImage(path string) ([]models.Chunk, error) {
 	log.Printf("✅ Extracted %d characters from image", len(text))
 
 	parts := splitText(text, 1000)
-	var chunks []models.Chunk
+	var chunks []events.Chunk
 
 	for idx, p := range parts {
-		chunks = append(chunks, models.Chunk{
+		chunks = append(chunks, events.Chunk{
 			ChunkID:     uuid.New().String(),
 			Text:        p,
 			Page:        1,
@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"contracts/auth"
+)
+
+// userIDKey holds the id of the token's user in the gin.Context.
+const userIDKey = "user_id"
+
+// RequireUser lets a request through only with a valid access token (see
+// contracts/auth) and stores its user id for the handlers. A missing or
+// invalid token is answered with 401.
+func RequireUser(secret []byte) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, err := auth.Authenticate(secret, c.Request)
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer realm="api"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+			return
+		}
+		c.Set(userIDKey, claims.UserID())
+		c.Next()
+	}
+}
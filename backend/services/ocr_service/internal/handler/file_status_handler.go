@@ -41,6 +41,14 @@ func GetFileStatus(fileID string) (*FileStatus, bool) {
 	return status, exists
 }
 
+// DeleteFileStatus forgets the status of a file
+func DeleteFileStatus(fileID string) {
+	statusMutex.Lock()
+	defer statusMutex.Unlock()
+
+	delete(fileStatuses, fileID)
+}
+
 // HandleFileStatus is the HTTP handler for checking file status
 func HandleFileStatus(c *gin.Context) {
 	fileID := c.Param("file_id")
@@ -1,20 +1,29 @@
 package handler
 
 import (
-	"encoding/json"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
-	"time"
 
 	"ocr_service/internal/events"
 
+	contracts "contracts/events"
+
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
+// UploadDir is where uploads are stored; files of a known uploader go into
+// a directory of their own so they can be erased with the account.
+const UploadDir = "/tmp/uploads"
+
+// UserUploadDir returns the upload directory of a user.
+func UserUploadDir(userID string) string {
+	return filepath.Join(UploadDir, userID)
+}
+
 type UploadHandler struct {
 	Producer *events.Producer
 }
@@ -31,6 +40,13 @@ func (h *UploadHandler) HandleUpload(c *gin.Context) {
 		return
 	}
 
+	// Uploader is the token's user, metered for the pages and tokens of this file
+	uploaderID := c.GetString(userIDKey)
+	if _, err := uuid.Parse(uploaderID); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token subject"})
+		return
+	}
+
 	// Validate file type
 	ext := strings.ToLower(filepath.Ext(file.Filename))
 	validExts := []string{".pdf", ".png", ".jpg", ".jpeg", ".tiff", ".tif", ".bmp"}
@@ -58,7 +74,7 @@ func (h *UploadHandler) HandleUpload(c *gin.Context) {
 	}
 
 	// Create uploads directory if not exists
-	uploadDir := "/tmp/uploads"
+	uploadDir := UserUploadDir(uploaderID)
 	if _, err := os.Stat(uploadDir); os.IsNotExist(err) {
 		if err := os.MkdirAll(uploadDir, 0755); err != nil {
 			log.Printf("❌ Failed to create upload directory: %v", err)
@@ -82,16 +98,21 @@ func (h *UploadHandler) HandleUpload(c *gin.Context) {
 	SetFileStatus(fileID, "uploaded", 0, "File uploaded successfully, queued for processing")
 
 	// Publish FILE_UPLOADED event
-	event := map[string]interface{}{
-		"event":        "FILE_UPLOADED",
-		"file_id":      fileID,
-		"file_name":    file.Filename,
-		"file_path":    dst,
-		"content_type": file.Header.Get("Content-Type"),
-		"file_size":    file.Size,
-		"timestamp":    time.Now().UTC().Format(time.RFC3339),
+	// The file id doubles as correlation id for the whole OCR → embedding flow.
+	b, err := contracts.Marshal(contracts.ProducerOCR, fileID, &contracts.FileUploaded{
+		FileID:      fileID,
+		FileName:    file.Filename,
+		FilePath:    dst,
+		ContentType: file.Header.Get("Content-Type"),
+		FileSize:    file.Size,
+		UploaderID:  uploaderID,
+	})
+	if err != nil {
+		log.Printf("❌ Failed to build event: %v", err)
+		SetFileStatus(fileID, "failed", 0, "Failed to queue file for processing")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue file"})
+		return
 	}
-	b, _ := json.Marshal(event)
 
 	if err := h.Producer.Publish("file_uploaded", b); err != nil {
 		log.Printf("❌ Failed to publish event: %v", err)
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// StoredFile is an upload kept for its uploader
+type StoredFile struct {
+	FileID     string `json:"file_id"`
+	FileName   string `json:"file_name"`
+	Size       int64  `json:"size"`
+	UploadedAt string `json:"uploaded_at"`
+}
+
+// ListUserFiles returns the files stored for a user, which are those
+// uploaded with their user_id.
+func ListUserFiles(userID string) ([]StoredFile, error) {
+	entries, err := os.ReadDir(UserUploadDir(userID))
+	if os.IsNotExist(err) {
+		return []StoredFile{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]StoredFile, 0, len(entries))
+	for _, e := range entries {
+		// Files are stored as {fileID}_{name}
+		fileID, name, ok := strings.Cut(e.Name(), "_")
+		if !ok || e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, StoredFile{
+			FileID:     fileID,
+			FileName:   name,
+			Size:       info.Size(),
+			UploadedAt: info.ModTime().UTC().Format(time.RFC3339),
+		})
+	}
+	return files, nil
+}
+
+// HandleListUserFiles is the HTTP handler for listing a user's files
+// (internal, used by auth_service's data export)
+func HandleListUserFiles(c *gin.Context) {
+	userID := c.Param("user_id")
+	if _, err := uuid.Parse(userID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+
+	files, err := ListUserFiles(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list files"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"files": files})
+}
+
+// HandleUserFile is the HTTP handler for downloading the original of a
+// user's file (internal, used by auth_service's data export)
+func HandleUserFile(c *gin.Context) {
+	userID := c.Param("user_id")
+	if _, err := uuid.Parse(userID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+	fileID := c.Param("file_id")
+	if _, err := uuid.Parse(fileID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file_id"})
+		return
+	}
+
+	matches, err := filepath.Glob(filepath.Join(UserUploadDir(userID), fileID+"_*"))
+	if err != nil || len(matches) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		return
+	}
+	c.FileAttachment(matches[0], strings.TrimPrefix(filepath.Base(matches[0]), fileID+"_"))
+}
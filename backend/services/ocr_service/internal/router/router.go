@@ -7,7 +7,7 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRouter(uploadHandler *handler.UploadHandler, producer *events.Producer) *gin.Engine {
+func SetupRouter(uploadHandler *handler.UploadHandler, producer *events.Producer, jwtSecret []byte) *gin.Engine {
 	r := gin.Default()
 
 	// ❌ CORS middleware'i SİLDİK - API Gateway halledecek
@@ -21,10 +21,14 @@ func SetupRouter(uploadHandler *handler.UploadHandler, producer *events.Producer
 	})
 
 	// Upload endpoint
-	r.POST("/api/upload", uploadHandler.HandleUpload)
+	r.POST("/api/upload", handler.RequireUser(jwtSecret), uploadHandler.HandleUpload)
 
 	// File status endpoint
-	r.GET("/api/file/status/:file_id", handler.HandleFileStatus)
+	r.GET("/api/file/status/:file_id", handler.RequireUser(jwtSecret), handler.HandleFileStatus)
+
+	// Internal: a user's stored uploads (auth_service data export)
+	r.GET("/internal/users/:user_id/files", handler.HandleListUserFiles)
+	r.GET("/internal/users/:user_id/files/:file_id", handler.HandleUserFile)
 
 	// Internal status update endpoint
 	r.POST("/internal/status", func(c *gin.Context) {
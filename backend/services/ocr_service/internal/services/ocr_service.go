@@ -2,7 +2,6 @@ package services
 
 import (
 	"context"
-	"encoding/json"
 	"log"
 	"os"
 	"time"
@@ -10,7 +9,8 @@ import (
 	"ocr_service/internal/events"
 	"ocr_service/internal/extractor"
 	"ocr_service/internal/handler"
-	"ocr_service/internal/models"
+
+	contracts "contracts/events"
 )
 
 type OCRService struct {
@@ -39,11 +39,16 @@ func (s *OCRService) Run(ctx context.Context) {
 
 			log.Printf("📥 OCR Service: received message on topic %s", msg.Topic)
 
-			var evt models.FileUploadedEvent
-			if err := json.Unmarshal(msg.Value, &evt); err != nil {
+			env, payload, err := contracts.Decode(msg.Value)
+			if err != nil {
 				log.Printf("❌ invalid file_uploaded event: %v", err)
 				continue
 			}
+			evt, ok := payload.(*contracts.FileUploaded)
+			if !ok {
+				log.Printf("❌ unexpected %s event on file_uploaded topic", env.Type)
+				continue
+			}
 
 			log.Printf("📄 Processing file: %s (ID: %s, Path: %s)", evt.FileName, evt.FileID, evt.FilePath)
 
@@ -55,7 +60,7 @@ func (s *OCRService) Run(ctx context.Context) {
 				errMsg := "File not found on disk"
 				log.Printf("❌ %s: %s", errMsg, evt.FilePath)
 				handler.SetFileStatus(evt.FileID, "failed", 0, errMsg)
-				s.publishError(evt.FileID, errMsg)
+				s.publishError(env.CorrelationID, evt.FileID, errMsg)
 				continue
 			}
 
@@ -64,14 +69,14 @@ func (s *OCRService) Run(ctx context.Context) {
 			if err != nil {
 				log.Printf("❌ error processing file %s: %v", evt.FilePath, err)
 				handler.SetFileStatus(evt.FileID, "failed", 0, err.Error())
-				s.publishError(evt.FileID, err.Error())
+				s.publishError(env.CorrelationID, evt.FileID, err.Error())
 				continue
 			}
 
 			if len(chunks) == 0 {
 				log.Printf("⚠️ No text extracted from file %s", evt.FileID)
 				handler.SetFileStatus(evt.FileID, "completed", 0, "No text extracted")
-				s.publishError(evt.FileID, "No text extracted from file")
+				s.publishError(env.CorrelationID, evt.FileID, "No text extracted from file")
 				continue
 			}
 
@@ -81,17 +86,20 @@ func (s *OCRService) Run(ctx context.Context) {
 			handler.SetFileStatus(evt.FileID, "embedding", len(chunks), "OCR completed, sending to embedding service")
 
 			// Publish OCR_PROCESSED event
-			ocrEvt := models.OCRProcessedEvent{
-				Event:       "OCR_PROCESSED",
+			b, err := contracts.Marshal(contracts.ProducerOCR, env.CorrelationID, &contracts.OCRProcessed{
 				FileID:      evt.FileID,
 				FileName:    evt.FileName,
 				ContentType: evt.ContentType,
 				Chunks:      chunks,
 				Language:    "tur",
-				Timestamp:   time.Now().UTC().Format(time.RFC3339),
+				UploaderID:  evt.UploaderID,
+				Pages:       countPages(chunks),
+			})
+			if err != nil {
+				log.Printf("❌ failed to build ocr_processed: %v", err)
+				handler.SetFileStatus(evt.FileID, "failed", len(chunks), "Failed to publish OCR result")
+				continue
 			}
-
-			b, _ := json.Marshal(ocrEvt)
 			if err := s.producer.Publish("ocr_processed", b); err != nil {
 				log.Printf("❌ failed to publish ocr_processed: %v", err)
 				handler.SetFileStatus(evt.FileID, "failed", len(chunks), "Failed to publish OCR result")
@@ -106,14 +114,24 @@ func (s *OCRService) Run(ctx context.Context) {
 	}
 }
 
-func (s *OCRService) publishError(fileID, errorMsg string) {
-	errorEvt := map[string]interface{}{
-		"event":     "OCR_FAILED",
-		"file_id":   fileID,
-		"error":     errorMsg,
-		"timestamp": time.Now().UTC().Format(time.RFC3339),
+// countPages returns the number of distinct pages the chunks came from.
+func countPages(chunks []contracts.Chunk) int {
+	pages := make(map[int]struct{})
+	for _, ch := range chunks {
+		pages[ch.Page] = struct{}{}
+	}
+	return len(pages)
+}
+
+func (s *OCRService) publishError(correlationID, fileID, errorMsg string) {
+	b, err := contracts.Marshal(contracts.ProducerOCR, correlationID, &contracts.OCRFailed{
+		FileID: fileID,
+		Error:  errorMsg,
+	})
+	if err != nil {
+		log.Printf("⚠️ Failed to build error event: %v", err)
+		return
 	}
-	b, _ := json.Marshal(errorEvt)
 	if err := s.producer.Publish("ocr_failed", b); err != nil {
 		log.Printf("⚠️ Failed to publish error event: %v", err)
 	}
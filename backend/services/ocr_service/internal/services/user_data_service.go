@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
+
+	"ocr_service/internal/events"
+	"ocr_service/internal/handler"
+
+	contracts "contracts/events"
+)
+
+// UserDataService erases the uploads of deleted users. Only files uploaded
+// with a user_id are stored per user and can be found.
+type UserDataService struct {
+	consumer    *events.Consumer
+	producer    *events.Producer
+	purgedTopic string
+}
+
+func NewUserDataService(consumer *events.Consumer, producer *events.Producer, purgedTopic string) *UserDataService {
+	return &UserDataService{consumer: consumer, producer: producer, purgedTopic: purgedTopic}
+}
+
+// Run consumes user_deleted, removes the user's upload directory and
+// reports how many files it held with user_data_purged.
+func (s *UserDataService) Run(ctx context.Context) {
+	log.Println("🚀 User data service: starting user_deleted consumer loop")
+	for {
+		msg, err := s.consumer.FetchMessage(ctx)
+		if ctx.Err() != nil {
+			log.Println("🛑 User data service: context done, exiting consumer loop")
+			return
+		}
+		if err != nil {
+			log.Printf("❌ consumer read error: %v", err)
+			time.Sleep(time.Second)
+			continue
+		}
+
+		env, payload, err := contracts.Decode(msg.Value)
+		if err != nil {
+			log.Printf("❌ invalid user_deleted event: %v", err)
+			s.commit(ctx, msg)
+			continue
+		}
+		evt, ok := payload.(*contracts.UserDeleted)
+		if !ok {
+			log.Printf("ℹ️ Ignored %s event on user_deleted topic", env.Type)
+			s.commit(ctx, msg)
+			continue
+		}
+		// The user id becomes a path; never let it point elsewhere.
+		if _, err := uuid.Parse(evt.UserID); err != nil {
+			log.Printf("❌ invalid user_id in user_deleted event: %v", err)
+			s.commit(ctx, msg)
+			continue
+		}
+
+		for ctx.Err() == nil {
+			if err = s.purge(env.CorrelationID, evt); err == nil {
+				break
+			}
+			log.Printf("⚠️ Failed to purge uploads of user %s: %v", evt.UserID, err)
+			time.Sleep(5 * time.Second)
+		}
+		if err != nil {
+			// Stopped before the purge succeeded: left uncommitted, so it
+			// runs again after the restart.
+			return
+		}
+		s.commit(ctx, msg)
+	}
+}
+
+// commit marks msg done. If that fails the event comes again after a
+// restart, which is harmless: purging twice finds nothing the second time.
+func (s *UserDataService) commit(ctx context.Context, msg kafka.Message) {
+	if err := s.consumer.CommitMessage(ctx, msg); err != nil {
+		log.Printf("⚠️ Failed to commit user_deleted offset %d: %v", msg.Offset, err)
+	}
+}
+
+func (s *UserDataService) purge(correlationID string, evt *contracts.UserDeleted) error {
+	dir := handler.UserUploadDir(evt.UserID)
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("read upload directory: %w", err)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("remove upload directory: %w", err)
+	}
+	for _, e := range entries {
+		// Files are stored as {fileID}_{name}
+		if fileID, _, ok := strings.Cut(e.Name(), "_"); ok {
+			handler.DeleteFileStatus(fileID)
+		}
+	}
+
+	b, err := contracts.Marshal(contracts.ProducerOCR, correlationID, &contracts.UserDataPurged{
+		DeletionID:   evt.DeletionID,
+		UserID:       evt.UserID,
+		Service:      contracts.ProducerOCR,
+		ItemsDeleted: len(entries),
+		Detail:       "uploaded files",
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.producer.Publish(s.purgedTopic, b); err != nil {
+		return err
+	}
+	log.Printf("🗑️ User %s deleted: %d uploaded file(s) removed", evt.UserID, len(entries))
+	return nil
+}
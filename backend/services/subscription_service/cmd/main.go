@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/IBM/sarama"
@@ -13,9 +17,12 @@ import (
 	"subscription_service/internal/database"
 	"subscription_service/internal/handler"
 	"subscription_service/internal/migrations"
+	"subscription_service/internal/payment"
 	"subscription_service/internal/repository"
 	"subscription_service/internal/router"
 	"subscription_service/internal/services"
+
+	"contracts/outbox"
 )
 
 // waitForKafka retries connecting to Kafka before giving up
@@ -63,29 +70,78 @@ func main() {
 	}
 
 	// Initialize repository
-	subRepo := repository.NewPostgresSubscriptionRepository(database.DB)
+	subRepo := repository.NewPostgresSubscriptionRepository(database.DB, cfg.KafkaTopicSubscriptionChanged,
+		cfg.KafkaTopicQuotaAlerts, cfg.KafkaTopicUserDataPurged, cfg.QuotaAlertThresholds)
 
 	// Initialize service (Kafka consumer dahil)
 	subService := services.NewUserSubscriptionService(
 		subRepo,
 		cfg.KafkaBrokers,
-		cfg.KafkaTopicUserRegistered,
+		[]string{cfg.KafkaTopicUserRegistered, cfg.KafkaTopicChatMessages, cfg.KafkaTopicEmbeddingStored, cfg.KafkaTopicOrgMembers,
+			cfg.KafkaTopicUserDeleted},
+		cfg.KafkaGroup,
+		cfg.KafkaInitialOffset,
 	)
+	subService.ReservationTTL = cfg.ReservationTTL
+	subService.ReservationMaxTTL = cfg.ReservationMaxTTL
+	subService.Payments = payment.NewStripeProvider(cfg.StripeAPIURL, cfg.StripeSecretKey, cfg.StripeWebhookSecret)
+	subService.CheckoutSuccessURL = cfg.CheckoutSuccessURL
+	subService.CheckoutCancelURL = cfg.CheckoutCancelURL
+	subService.InvoiceIssuer = cfg.InvoiceIssuer
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Start Kafka consumer group for registration, chat, embedding usage and organisation events
+	consumerDone := make(chan struct{})
+	go func() {
+		defer close(consumerDone)
+		if err := subService.StartKafkaConsumer(ctx); err != nil {
+			log.Fatalf("❌ Failed to start Kafka consumer: %v", err)
+		}
+	}()
 
-	// Start Kafka consumer to listen for "user_registered" events
-	if err := subService.StartKafkaConsumer(); err != nil {
-		log.Fatalf("❌ Failed to start Kafka consumer: %v", err)
-	}
+	// Release reservations left behind by callers that never committed
+	go subService.RunReservationSweeper(ctx, cfg.ReservationSweepInterval)
+
+	// Expire / cancel subscriptions whose period is over
+	go subService.RunLifecycleScheduler(ctx, cfg.LifecycleInterval, cfg.PastDueGrace)
+
+	// Reset quotas at each quota period, renew free plans
+	go subService.RunQuotaResetScheduler(ctx, cfg.QuotaResetInterval)
+
+	// Outbox relay: subscription_changed event'lerini Kafka'ya taşır
+	relay := outbox.NewRelay(database.DB, cfg.KafkaBrokers, cfg.OutboxPollInterval, cfg.OutboxBatchSize)
+	relay.Retention = cfg.OutboxRetention
+	relayDone := make(chan struct{})
+	go func() {
+		defer close(relayDone)
+		relay.Run(ctx)
+	}()
 
 	// Initialize HTTP handler
 	subHandler := handler.NewSubscriptionHandler(subService)
 
 	// Setup routes
 	mux := http.NewServeMux()
-	router.SetupSubscriptionRoutes(mux, subHandler)
+	router.SetupSubscriptionRoutes(mux, subHandler, []byte(cfg.JWTSecret))
+	router.SetupAdminRoutes(mux, subHandler, []byte(cfg.JWTSecret))
+
+	server := &http.Server{Addr: ":" + cfg.ServicePort, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		log.Println("🛑 Shutting down Subscription Service...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
 
 	log.Printf("✅ Subscription Service running on :%s", cfg.ServicePort)
-	if err := http.ListenAndServe(":"+cfg.ServicePort, mux); err != nil {
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		log.Fatalf("❌ Failed to start server: %v", err)
 	}
+
+	// Let the consumer group leave cleanly so partitions are reassigned quickly.
+	<-consumerDone
+	<-relayDone
 }
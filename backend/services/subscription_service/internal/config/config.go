@@ -3,8 +3,11 @@ package config
 import (
 	"fmt"
 	"os"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -16,12 +19,50 @@ type Config struct {
 	PostgresPassword string
 	PostgresDB       string
 
-	KafkaBrokers             []string
-	KafkaTopicUserRegistered string
+	KafkaBrokers                  []string
+	KafkaTopicUserRegistered      string
+	KafkaTopicChatMessages        string
+	KafkaTopicEmbeddingStored     string
+	KafkaTopicOrgMembers          string
+	KafkaTopicSubscriptionChanged string
+	KafkaTopicQuotaAlerts         string
+	KafkaTopicUserDeleted         string
+	KafkaTopicUserDataPurged      string
+	KafkaGroup                    string
+	KafkaInitialOffset            string
+
+	ReservationTTL           time.Duration
+	ReservationMaxTTL        time.Duration
+	ReservationSweepInterval time.Duration
+
+	LifecycleInterval  time.Duration
+	PastDueGrace       time.Duration
+	QuotaResetInterval time.Duration
+
+	// QuotaAlertThresholds are usage percentages that trigger a quota_alert,
+	// ascending.
+	QuotaAlertThresholds []int
+
+	OutboxPollInterval time.Duration
+	OutboxBatchSize    int
+	// OutboxRetention is how long published outbox rows are kept.
+	OutboxRetention time.Duration
 
 	ServicePort    string
 	LogLevel       string
 	MigrationsPath string
+
+	// JWTSecret verifies access tokens issued by auth_service; admin
+	// endpoints need a token carrying the matching permission.
+	JWTSecret string
+
+	StripeAPIURL        string
+	StripeSecretKey     string
+	StripeWebhookSecret string
+	CheckoutSuccessURL  string
+	CheckoutCancelURL   string
+
+	InvoiceIssuer string
 }
 
 func LoadConfig() (*Config, error) {
@@ -36,10 +77,47 @@ func LoadConfig() (*Config, error) {
 
 	cfg.KafkaBrokers = parseCSV(getenv("KAFKA_BROKERS", "localhost:9092"))
 	cfg.KafkaTopicUserRegistered = getenv("KAFKA_TOPIC_USER_REGISTERED", "user_registered")
+	cfg.KafkaTopicChatMessages = getenv("KAFKA_TOPIC_CHAT_MESSAGES", "chat_messages")
+	cfg.KafkaTopicEmbeddingStored = getenv("KAFKA_TOPIC_EMBEDDING_STORED", "embedding_stored")
+	cfg.KafkaTopicOrgMembers = getenv("KAFKA_TOPIC_ORG_MEMBERS", "org_members")
+	cfg.KafkaTopicSubscriptionChanged = getenv("KAFKA_TOPIC_SUBSCRIPTION_CHANGED", "subscription_changed")
+	cfg.KafkaTopicQuotaAlerts = getenv("KAFKA_TOPIC_QUOTA_ALERTS", "quota_alerts")
+	cfg.KafkaTopicUserDeleted = getenv("KAFKA_TOPIC_USER_DELETED", "user_deleted")
+	cfg.KafkaTopicUserDataPurged = getenv("KAFKA_TOPIC_USER_DATA_PURGED", "user_data_purged")
+	cfg.KafkaGroup = getenv("KAFKA_GROUP", "subscription-service-group")
+	cfg.KafkaInitialOffset = getenv("KAFKA_INITIAL_OFFSET", "newest")
+
+	cfg.ReservationTTL = time.Duration(getenvInt("RESERVATION_TTL_SECONDS", 120)) * time.Second
+	cfg.ReservationMaxTTL = time.Duration(getenvInt("RESERVATION_MAX_TTL_SECONDS", 600)) * time.Second
+	cfg.ReservationSweepInterval = time.Duration(getenvInt("RESERVATION_SWEEP_INTERVAL_SECONDS", 30)) * time.Second
+
+	cfg.LifecycleInterval = time.Duration(getenvInt("SUBSCRIPTION_LIFECYCLE_INTERVAL_SECONDS", 60)) * time.Second
+	cfg.PastDueGrace = time.Duration(getenvInt("PAST_DUE_GRACE_HOURS", 72)) * time.Hour
+	cfg.QuotaResetInterval = time.Duration(getenvInt("QUOTA_RESET_INTERVAL_SECONDS", 60)) * time.Second
+
+	thresholds, err := parseThresholds(getenv("QUOTA_ALERT_THRESHOLDS", "80,95,100"))
+	if err != nil {
+		return nil, err
+	}
+	cfg.QuotaAlertThresholds = thresholds
+
+	// Outbox relay
+	cfg.OutboxPollInterval = time.Duration(getenvInt("OUTBOX_POLL_INTERVAL_MS", 1000)) * time.Millisecond
+	cfg.OutboxBatchSize = getenvInt("OUTBOX_BATCH_SIZE", 100)
+	cfg.OutboxRetention = time.Duration(getenvInt("OUTBOX_RETENTION_DAYS", 7)) * 24 * time.Hour
 
 	cfg.ServicePort = getenv("SERVICE_PORT", "8081")
 	cfg.LogLevel = getenv("LOG_LEVEL", "info")
 	cfg.MigrationsPath = getenv("MIGRATIONS_PATH", "internal/migrations")
+	cfg.JWTSecret = getenv("JWT_SECRET", "")
+
+	// Payments (Stripe API veya stripe-mock)
+	cfg.StripeAPIURL = getenv("STRIPE_API_URL", "https://api.stripe.com")
+	cfg.StripeSecretKey = getenv("STRIPE_SECRET_KEY", "")
+	cfg.StripeWebhookSecret = getenv("STRIPE_WEBHOOK_SECRET", "")
+	cfg.CheckoutSuccessURL = getenv("CHECKOUT_SUCCESS_URL", "http://localhost:3000/billing/success?session_id={CHECKOUT_SESSION_ID}")
+	cfg.CheckoutCancelURL = getenv("CHECKOUT_CANCEL_URL", "http://localhost:3000/billing/cancel")
+	cfg.InvoiceIssuer = getenv("INVOICE_ISSUER", "Chat Platform")
 
 	if cfg.PostgresHost == "" || cfg.PostgresUser == "" || cfg.PostgresDB == "" {
 		return nil, fmt.Errorf("postgres config incomplete")
@@ -81,3 +159,18 @@ func parseCSV(s string) []string {
 	}
 	return out
 }
+
+// parseThresholds reads a CSV of percentages between 1 and 100 and returns
+// them sorted without duplicates.
+func parseThresholds(s string) ([]int, error) {
+	var out []int
+	for _, p := range parseCSV(s) {
+		t, err := strconv.Atoi(p)
+		if err != nil || t < 1 || t > 100 {
+			return nil, fmt.Errorf("invalid quota alert threshold %q: want 1-100", p)
+		}
+		out = append(out, t)
+	}
+	sort.Ints(out)
+	return slices.Compact(out), nil
+}
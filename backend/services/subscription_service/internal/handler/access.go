@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"contracts/auth"
+)
+
+// accountHandler serves an endpoint of one account: a user, or the pooled
+// subscription of an organisation. rest is the path after the account id.
+type accountHandler func(w http.ResponseWriter, r *http.Request, account uuid.UUID, rest []string)
+
+// ForUser serves {prefix}{account}/... to the user of the request's token,
+// who can read their own account and those of the organisations they own or
+// administer. Wrap it with auth.RequireUser.
+func (h *SubscriptionHandler) ForUser(prefix string, next accountHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/"), "/")
+		account, err := uuid.Parse(parts[0])
+		if err != nil {
+			http.Error(w, "invalid user_id", http.StatusBadRequest)
+			return
+		}
+		if !h.authorize(w, r, account) {
+			return
+		}
+		next(w, r, account, parts[1:])
+	}
+}
+
+// Internal serves the account endpoints to other services, without a token:
+//
+//	GET /internal/users/{user_id}/current
+//	GET /internal/users/{user_id}/quota
+//	GET /internal/users/{user_id}/quota_resets
+//	GET /internal/users/{user_id}/usage[/daily]
+//	GET /internal/users/{user_id}/invoices[/{invoice_id}[/pdf]]
+//
+// The gateway doesn't route /internal.
+func (h *SubscriptionHandler) Internal(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/internal/users/"), "/"), "/")
+	if len(parts) < 2 {
+		http.NotFound(w, r)
+		return
+	}
+	account, err := uuid.Parse(parts[0])
+	if err != nil {
+		http.Error(w, "invalid user_id", http.StatusBadRequest)
+		return
+	}
+
+	switch parts[1] {
+	case "current":
+		h.GetCurrentSubscription(w, r, account, parts[2:])
+	case "quota":
+		h.GetUserQuota(w, r, account, parts[2:])
+	case "quota_resets":
+		h.GetQuotaResets(w, r, account, parts[2:])
+	case "usage":
+		h.Usage(w, r, account, parts[2:])
+	case "invoices":
+		h.Invoices(w, r, account, parts[2:])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// authorize reports whether the token's user may act on account: their own,
+// or an organisation they manage. It writes the error response itself.
+func (h *SubscriptionHandler) authorize(w http.ResponseWriter, r *http.Request, account uuid.UUID) bool {
+	uid, ok := subject(w, r)
+	if !ok {
+		return false
+	}
+	if account == uid {
+		return true
+	}
+	if _, err := h.service.Account(uid, account); err != nil {
+		writeQuotaError(w, err)
+		return false
+	}
+	return true
+}
+
+// subject returns the id of the user the request's token was issued to. It
+// writes the error response itself.
+func subject(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	c, ok := auth.ClaimsFrom(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return uuid.Nil, false
+	}
+	uid, err := uuid.Parse(c.UserID())
+	if err != nil {
+		http.Error(w, "invalid token subject", http.StatusUnauthorized)
+		return uuid.Nil, false
+	}
+	return uid, true
+}
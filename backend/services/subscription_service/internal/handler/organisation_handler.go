@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Organisations serves the pooled quota endpoints of an organisation, for
+// its owners and admins; the acting user is that of the token:
+//
+//	GET /api/subscription/orgs/{org_id}/members                   usage and cap per member
+//	PUT /api/subscription/orgs/{org_id}/members/{member_id}/cap   {"message_cap"}
+//
+// The organisation's subscription itself is read with its id as user_id,
+// e.g. /api/subscription/current/{org_id}.
+func (h *SubscriptionHandler) Organisations(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/subscription/orgs/"), "/"), "/")
+	orgID, err := uuid.Parse(parts[0])
+	if err != nil {
+		http.Error(w, "invalid org_id", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case len(parts) == 2 && parts[1] == "members":
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.listMemberUsage(w, r, orgID)
+	case len(parts) == 4 && parts[1] == "members" && parts[3] == "cap":
+		if r.Method != http.MethodPut {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		memberID, err := uuid.Parse(parts[2])
+		if err != nil {
+			http.Error(w, "invalid member_id", http.StatusBadRequest)
+			return
+		}
+		h.setMemberCap(w, r, orgID, memberID)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *SubscriptionHandler) listMemberUsage(w http.ResponseWriter, r *http.Request, orgID uuid.UUID) {
+	uid, ok := subject(w, r)
+	if !ok {
+		return
+	}
+
+	members, err := h.service.ListMemberUsage(uid, orgID)
+	if err != nil {
+		writeQuotaError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"members": members})
+}
+
+func (h *SubscriptionHandler) setMemberCap(w http.ResponseWriter, r *http.Request, orgID, memberID uuid.UUID) {
+	uid, ok := subject(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		MessageCap *int `json:"message_cap"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.MessageCap != nil && *req.MessageCap < 0 {
+		http.Error(w, "message_cap must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	m, err := h.service.SetMemberCap(uid, orgID, memberID, req.MessageCap)
+	if err != nil {
+		writeQuotaError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, m)
+}
+
+// account resolves whose subscription the request acts on: that of the
+// token's user, or with orgID the organisation's if the user owns or
+// administers it (see services.Account). It writes the error response
+// itself.
+func (h *SubscriptionHandler) account(w http.ResponseWriter, r *http.Request, orgID string) (uuid.UUID, bool) {
+	uid, ok := subject(w, r)
+	if !ok {
+		return uuid.Nil, false
+	}
+	oid := uuid.Nil
+	if orgID != "" {
+		var err error
+		if oid, err = uuid.Parse(orgID); err != nil {
+			http.Error(w, "invalid organisation_id", http.StatusBadRequest)
+			return uuid.Nil, false
+		}
+	}
+
+	account, err := h.service.Account(uid, oid)
+	if err != nil {
+		writeQuotaError(w, err)
+		return uuid.Nil, false
+	}
+	return account, true
+}
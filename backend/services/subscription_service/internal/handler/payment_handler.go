@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"subscription_service/internal/payment"
+	"subscription_service/internal/repository"
+	"subscription_service/internal/services"
+)
+
+// maxWebhookBytes bounds the webhook body read into memory.
+const maxWebhookBytes = 1 << 20
+
+// StartCheckout opens a payment page for a plan:
+// POST /api/subscription/checkout {"plan", "organisation_id"?}
+func (h *SubscriptionHandler) StartCheckout(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		OrganisationID string `json:"organisation_id"`
+		Plan           string `json:"plan"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	uid, ok := h.account(w, r, req.OrganisationID)
+	if !ok {
+		return
+	}
+
+	checkout, err := h.service.StartCheckout(r.Context(), uid, req.Plan)
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, checkout)
+}
+
+// GetCheckout returns the status of a checkout to the user who started it,
+// or for an organisation's checkout to its owners and admins:
+// GET /api/subscription/checkout/{id}
+func (h *SubscriptionHandler) GetCheckout(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(strings.TrimPrefix(r.URL.Path, "/api/subscription/checkout/"))
+	if err != nil {
+		http.Error(w, "invalid checkout id", http.StatusBadRequest)
+		return
+	}
+
+	uid, ok := subject(w, r)
+	if !ok {
+		return
+	}
+	checkout, err := h.service.GetCheckout(id)
+	if err == nil && checkout.UserID != uid {
+		// Someone else's checkout is answered as missing, like invoices.
+		if _, err = h.service.Account(uid, checkout.UserID); err != nil {
+			err = repository.ErrCheckoutNotFound
+		}
+	}
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, checkout)
+}
+
+// PaymentWebhook receives signed events from the payment provider. A non-2xx
+// answer makes the provider retry, so only bad requests and failures worth a
+// retry get one.
+func (h *SubscriptionHandler) PaymentWebhook(w http.ResponseWriter, r *http.Request) {
+	payload, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBytes))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	err = h.service.HandlePaymentWebhook(payload, r.Header)
+	switch {
+	case errors.Is(err, payment.ErrInvalidSignature), errors.Is(err, payment.ErrInvalidPayload):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		writeJSON(w, http.StatusOK, map[string]bool{"received": true})
+	}
+}
+
+func writePaymentError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrCheckoutNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrNoPaymentNeeded):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, services.ErrPaymentProvider):
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	default:
+		writeQuotaError(w, err)
+	}
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"subscription_service/internal/repository"
+	"subscription_service/internal/services"
+
+	"github.com/google/uuid"
+)
+
+// AdminPlans handles /api/subscription/admin/plans (list, create)
+func (h *SubscriptionHandler) AdminPlans(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		plans, err := h.service.ListPlansForAdmin()
+		if err != nil {
+			writePlanError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, plans)
+
+	case http.MethodPost:
+		var in services.PlanInput
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		plan, err := h.service.CreatePlan(in)
+		if err != nil {
+			writePlanError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, plan)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// AdminPlan handles /api/subscription/admin/plans/{id} (get, update, retire)
+func (h *SubscriptionHandler) AdminPlan(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(strings.TrimPrefix(r.URL.Path, "/api/subscription/admin/plans/"))
+	if err != nil {
+		http.Error(w, "invalid plan id", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		plan, err := h.service.GetPlan(id)
+		if err != nil {
+			writePlanError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, plan)
+
+	case http.MethodPut:
+		var in services.PlanInput
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		plan, err := h.service.UpdatePlan(id, in)
+		if err != nil {
+			writePlanError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, plan)
+
+	case http.MethodDelete:
+		// Plans are retired, never deleted: subscriptions still reference them.
+		plan, err := h.service.RetirePlan(id)
+		if err != nil {
+			writePlanError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, plan)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writePlanError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidPlan):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, repository.ErrPlanNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, repository.ErrPlanNameTaken):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
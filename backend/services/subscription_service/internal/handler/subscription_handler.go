@@ -2,12 +2,19 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"strings"
+	"time"
 
+	"subscription_service/internal/models"
+	"subscription_service/internal/repository"
 	"subscription_service/internal/services"
 
 	"github.com/google/uuid"
+
+	"contracts/auth"
 )
 
 type SubscriptionHandler struct {
@@ -38,23 +45,30 @@ func (h *SubscriptionHandler) AssignFreePlan(w http.ResponseWriter, r *http.Requ
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	log.Printf("🛡️ Free plan assigned to %s by admin %s", uid, actorID(r))
 
 	w.WriteHeader(http.StatusCreated)
 	w.Write([]byte(`{"status":"success"}`))
 }
 
-// AssignPlan assigns a specific plan to a user
+// AssignPlan assigns a specific plan to a user or an organisation
 func (h *SubscriptionHandler) AssignPlan(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		UserID string `json:"user_id"`
-		Plan   string `json:"plan"`
+		UserID         string `json:"user_id"`
+		OrganisationID string `json:"organisation_id"`
+		Plan           string `json:"plan"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	uid, err := uuid.Parse(req.UserID)
+	// Admins can put an organisation on a plan directly.
+	account := req.UserID
+	if req.OrganisationID != "" {
+		account = req.OrganisationID
+	}
+	uid, err := uuid.Parse(account)
 	if err != nil {
 		http.Error(w, "invalid user_id", http.StatusBadRequest)
 		return
@@ -64,32 +78,157 @@ func (h *SubscriptionHandler) AssignPlan(w http.ResponseWriter, r *http.Request)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	log.Printf("🛡️ Plan %s assigned to %s by admin %s", req.Plan, uid, actorID(r))
 
 	w.WriteHeader(http.StatusCreated)
 	w.Write([]byte(`{"status":"success"}`))
 }
 
-// GetUserQuota returns remaining quota
-func (h *SubscriptionHandler) GetUserQuota(w http.ResponseWriter, r *http.Request) {
-	parts := strings.Split(r.URL.Path, "/")
-	if len(parts) < 5 {
-		http.Error(w, "user_id required", http.StatusBadRequest)
+// actorID returns the user id of the access token the admin routes verified.
+func actorID(r *http.Request) string {
+	if c, ok := auth.ClaimsFrom(r.Context()); ok {
+		return c.UserID()
+	}
+	return "unknown"
+}
+
+// GetUserQuota returns remaining quota: /api/subscription/quota/{user_id}
+func (h *SubscriptionHandler) GetUserQuota(w http.ResponseWriter, r *http.Request, uid uuid.UUID, rest []string) {
+	if len(rest) > 0 {
+		http.NotFound(w, r)
 		return
 	}
 
-	uid, err := uuid.Parse(parts[4])
+	quota, err := h.service.GetUserQuota(uid)
 	if err != nil {
-		http.Error(w, "invalid user_id", http.StatusBadRequest)
+		writeQuotaError(w, err)
 		return
 	}
 
-	quota, err := h.service.GetUserQuota(uid)
+	json.NewEncoder(w).Encode(map[string]int{"quota": quota})
+}
+
+// GetQuotaResets returns the user's latest quota resets:
+// /api/subscription/quota_resets/{user_id}
+func (h *SubscriptionHandler) GetQuotaResets(w http.ResponseWriter, r *http.Request, uid uuid.UUID, rest []string) {
+	if len(rest) > 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	resets, err := h.service.ListQuotaResets(uid)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"resets": resets})
+}
 
-	json.NewEncoder(w).Encode(map[string]int{"quota": quota})
+// GetMeterUsage returns the current quota period with message, token and
+// page usage per meter: /api/subscription/usage/{user_id}
+func (h *SubscriptionHandler) GetMeterUsage(w http.ResponseWriter, r *http.Request, uid uuid.UUID) {
+	usage, err := h.service.GetMeterUsage(uid)
+	if err != nil {
+		writeQuotaError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, usage)
+}
+
+// GetCurrentSubscription returns the user's current subscription:
+// /api/subscription/current/{user_id}
+func (h *SubscriptionHandler) GetCurrentSubscription(w http.ResponseWriter, r *http.Request, uid uuid.UUID, rest []string) {
+	if len(rest) > 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	sub, err := h.service.GetCurrentSubscription(uid)
+	if err != nil {
+		writeQuotaError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, sub)
+}
+
+// ChangePlan upgrades or downgrades the current subscription of the token's
+// user, or of an organisation they manage:
+// POST /api/subscription/change_plan {"plan", "organisation_id"?}
+func (h *SubscriptionHandler) ChangePlan(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		OrganisationID string `json:"organisation_id"`
+		Plan           string `json:"plan"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	uid, ok := h.account(w, r, req.OrganisationID)
+	if !ok {
+		return
+	}
+
+	sub, proration, err := h.service.ChangePlan(uid, req.Plan)
+	if err != nil {
+		writeQuotaError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"subscription":    sub,
+		"proration_cents": proration,
+	})
+}
+
+// CancelSubscription cancels the current subscription, by default at the
+// end of the period: POST /api/subscription/cancel {"at_period_end"?, "organisation_id"?}
+func (h *SubscriptionHandler) CancelSubscription(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		OrganisationID string `json:"organisation_id"`
+		AtPeriodEnd    *bool  `json:"at_period_end"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	uid, ok := h.account(w, r, req.OrganisationID)
+	if !ok {
+		return
+	}
+
+	atPeriodEnd := req.AtPeriodEnd == nil || *req.AtPeriodEnd
+	sub, err := h.service.CancelSubscription(uid, atPeriodEnd)
+	if err != nil {
+		writeQuotaError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, sub)
+}
+
+// ResumeSubscription revokes a scheduled cancellation:
+// POST /api/subscription/resume {"organisation_id"?}
+func (h *SubscriptionHandler) ResumeSubscription(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		OrganisationID string `json:"organisation_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	uid, ok := h.account(w, r, req.OrganisationID)
+	if !ok {
+		return
+	}
+
+	sub, err := h.service.ResumeSubscription(uid)
+	if err != nil {
+		writeQuotaError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, sub)
 }
 
 // ListPlans lists all subscription plans
@@ -102,22 +241,112 @@ func (h *SubscriptionHandler) ListPlans(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(plans)
 }
 
-// UserEvent logs user event and decrements quota
-func (h *SubscriptionHandler) UserEvent(w http.ResponseWriter, r *http.Request) {
+// ReserveQuota holds quota for a request that is about to run:
+// POST /internal/reservations {"user_id", "reservation_id"?, "amount"?, "ttl_seconds"?}
+func (h *SubscriptionHandler) ReserveQuota(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		UserID string `json:"user_id"`
-		Event  string `json:"event"`
+		UserID        string `json:"user_id"`
+		ReservationID string `json:"reservation_id"`
+		Amount        int    `json:"amount"`
+		TTLSeconds    int    `json:"ttl_seconds"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	if err := h.service.LogEventAndDecrementQuota(req.UserID); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	uid, err := uuid.Parse(req.UserID)
+	if err != nil {
+		http.Error(w, "invalid user_id", http.StatusBadRequest)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(`{"status":"ok"}`))
+	// Callers pass their own id so a retried reserve doesn't hold quota twice.
+	rid := uuid.New()
+	if req.ReservationID != "" {
+		if rid, err = uuid.Parse(req.ReservationID); err != nil {
+			http.Error(w, "invalid reservation_id", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if req.Amount == 0 {
+		req.Amount = 1
+	}
+	if req.Amount < 0 || req.TTLSeconds < 0 {
+		http.Error(w, "amount and ttl_seconds must be positive", http.StatusBadRequest)
+		return
+	}
+
+	// The service caps the TTL; cap the seconds first so they can't overflow.
+	ttl := time.Duration(min(req.TTLSeconds, int(h.service.ReservationMaxTTL/time.Second))) * time.Second
+	res, remaining, err := h.service.ReserveQuota(rid, uid, req.Amount, ttl)
+	if err != nil {
+		writeQuotaError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(struct {
+		*models.QuotaReservation
+		RemainingQuota int `json:"remaining_quota"`
+	}{res, remaining})
+}
+
+// ReservationAction commits or releases a reservation:
+// POST /internal/reservations/{id}/commit or /release
+func (h *SubscriptionHandler) ReservationAction(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/internal/reservations/"), "/"), "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+
+	rid, err := uuid.Parse(parts[0])
+	if err != nil {
+		http.Error(w, "invalid reservation_id", http.StatusBadRequest)
+		return
+	}
+
+	switch parts[1] {
+	case "commit":
+		err = h.service.CommitReservation(rid)
+	case "release":
+		err = h.service.ReleaseReservation(rid)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		writeQuotaError(w, err)
+		return
+	}
+
+	w.Write([]byte(`{"status":"success"}`))
+}
+
+// writeQuotaError maps quota, reservation and lifecycle errors to HTTP statuses.
+func writeQuotaError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidPlan):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, repository.ErrPlanNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, repository.ErrInvalidTransition), errors.Is(err, repository.ErrSubscriptionExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, repository.ErrQuotaExceeded), errors.Is(err, repository.ErrPaymentRequired),
+		errors.Is(err, repository.ErrMemberCapExceeded):
+		http.Error(w, err.Error(), http.StatusPaymentRequired)
+	case errors.Is(err, repository.ErrNoActiveSubscription), errors.Is(err, services.ErrNotOrganisationManager):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, repository.ErrNotOrganisationMember):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, repository.ErrReservationNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, repository.ErrReservationClosed):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
 }
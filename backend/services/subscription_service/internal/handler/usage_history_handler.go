@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"subscription_service/internal/repository"
+	"subscription_service/internal/services"
+
+	"github.com/google/uuid"
+)
+
+// defaultUsageDays is the daily usage range when none is asked for.
+const defaultUsageDays = 30
+
+// Usage serves the usage endpoints:
+//
+//	/api/subscription/usage/{user_id}        current period per meter
+//	/api/subscription/usage/{user_id}/daily  usage per day, ?from=&to= (YYYY-MM-DD)
+func (h *SubscriptionHandler) Usage(w http.ResponseWriter, r *http.Request, uid uuid.UUID, rest []string) {
+	switch {
+	case len(rest) == 0:
+		h.GetMeterUsage(w, r, uid)
+	case len(rest) == 1 && rest[0] == "daily":
+		h.GetDailyUsage(w, r, uid)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// GetDailyUsage returns the user's usage per day and meter
+func (h *SubscriptionHandler) GetDailyUsage(w http.ResponseWriter, r *http.Request, uid uuid.UUID) {
+	var err error
+	to := time.Now().UTC()
+	if v := r.URL.Query().Get("to"); v != "" {
+		if to, err = time.Parse("2006-01-02", v); err != nil {
+			http.Error(w, "invalid to, want YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+	from := to.AddDate(0, 0, -(defaultUsageDays - 1))
+	if v := r.URL.Query().Get("from"); v != "" {
+		if from, err = time.Parse("2006-01-02", v); err != nil {
+			http.Error(w, "invalid from, want YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+
+	days, err := h.service.GetDailyUsage(uid, from, to)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRange) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"from": from.Format("2006-01-02"),
+		"to":   to.Format("2006-01-02"),
+		"days": days,
+	})
+}
+
+// Invoices serves the invoice endpoints:
+//
+//	/api/subscription/invoices/{user_id}                   list
+//	/api/subscription/invoices/{user_id}/{invoice_id}      invoice with lines
+//	/api/subscription/invoices/{user_id}/{invoice_id}/pdf  PDF download
+func (h *SubscriptionHandler) Invoices(w http.ResponseWriter, r *http.Request, uid uuid.UUID, rest []string) {
+	if len(rest) == 0 {
+		invoices, err := h.service.ListInvoices(uid)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"invoices": invoices})
+		return
+	}
+
+	if len(rest) > 2 || (len(rest) == 2 && rest[1] != "pdf") {
+		http.NotFound(w, r)
+		return
+	}
+	id, err := uuid.Parse(rest[0])
+	if err != nil {
+		http.Error(w, "invalid invoice_id", http.StatusBadRequest)
+		return
+	}
+
+	if len(rest) == 1 {
+		inv, err := h.service.GetInvoice(uid, id)
+		if err != nil {
+			writeInvoiceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, inv)
+		return
+	}
+
+	inv, pdf, err := h.service.InvoicePDF(uid, id)
+	if err != nil {
+		writeInvoiceError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.pdf"`, inv.Number))
+	w.Write(pdf)
+}
+
+func writeInvoiceError(w http.ResponseWriter, err error) {
+	if errors.Is(err, repository.ErrInvoiceNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
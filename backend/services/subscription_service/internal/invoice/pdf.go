@@ -0,0 +1,80 @@
+// Package invoice renders invoices as PDF documents.
+package invoice
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/go-pdf/fpdf"
+
+	"subscription_service/internal/models"
+)
+
+// WritePDF renders inv with its lines as an A4 PDF to w, with issuer in the
+// header.
+func WritePDF(w io.Writer, inv *models.Invoice, issuer string) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle("Invoice "+inv.Number, true)
+	pdf.SetMargins(20, 20, 20)
+	pdf.AddPage()
+	// Core fonts are cp1252; translate so non-ASCII plan names still print.
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.CellFormat(0, 10, tr(issuer), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 11)
+	pdf.CellFormat(0, 6, "Invoice "+inv.Number, "", 1, "L", false, 0, "")
+	pdf.Ln(4)
+
+	pdf.SetFont("Helvetica", "", 10)
+	rows := [][2]string{
+		{"Issued", inv.CreatedAt.Format("2006-01-02")},
+		{"Customer", inv.UserID.String()},
+		{"Plan", tr(inv.PlanName)},
+		{"Billing period", fmt.Sprintf("%s - %s", inv.PeriodStart.Format("2006-01-02 15:04"), inv.PeriodEnd.Format("2006-01-02 15:04"))},
+	}
+	for _, row := range rows {
+		pdf.CellFormat(40, 6, row[0], "", 0, "L", false, 0, "")
+		pdf.CellFormat(0, 6, row[1], "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(6)
+
+	widths := []float64{85, 25, 30, 30}
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.SetFillColor(235, 235, 235)
+	for i, h := range []string{"Description", "Quantity", "Unit price", "Amount"} {
+		align := "R"
+		if i == 0 {
+			align = "L"
+		}
+		pdf.CellFormat(widths[i], 8, h, "B", 0, align, true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont("Helvetica", "", 10)
+	for _, l := range inv.Lines {
+		pdf.CellFormat(widths[0], 7, tr(l.Description), "", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[1], 7, fmt.Sprintf("%d", l.Quantity), "", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[2], 7, money(l.UnitAmountCents, inv.Currency), "", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[3], 7, money(l.AmountCents, inv.Currency), "", 1, "R", false, 0, "")
+	}
+	pdf.Ln(2)
+
+	label := widths[0] + widths[1] + widths[2]
+	pdf.CellFormat(label, 7, "Subtotal", "T", 0, "R", false, 0, "")
+	pdf.CellFormat(widths[3], 7, money(inv.SubtotalCents, inv.Currency), "T", 1, "R", false, 0, "")
+	pdf.SetFont("Helvetica", "B", 11)
+	pdf.CellFormat(label, 8, "Total", "", 0, "R", false, 0, "")
+	pdf.CellFormat(widths[3], 8, money(inv.TotalCents, inv.Currency), "", 1, "R", false, 0, "")
+
+	return pdf.Output(w)
+}
+
+// money formats an amount in minor units, e.g. 1999 USD → "19.99 USD".
+func money(cents int64, currency string) string {
+	sign := ""
+	if cents < 0 {
+		sign, cents = "-", -cents
+	}
+	return fmt.Sprintf("%s%d.%02d %s", sign, cents/100, cents%100, currency)
+}
@@ -0,0 +1,19 @@
+-- ---------------------------
+-- Usage ledger (exactly-once quota accounting)
+-- ---------------------------
+
+-- Kod remaining_quota kolonunu kullanıyor ama 001'de tanımlı değildi.
+ALTER TABLE user_subscription ADD COLUMN IF NOT EXISTS remaining_quota INT NOT NULL DEFAULT 0;
+
+-- Her kota düşümü usage_id ile bir kez kaydedilir; aynı id ile gelen
+-- tekrar (retry / duplicate event) PRIMARY KEY sayesinde etkisiz kalır.
+CREATE TABLE IF NOT EXISTS usage_ledger (
+    usage_id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    user_subscription_id UUID NOT NULL REFERENCES user_subscription(id),
+    amount INT NOT NULL CHECK (amount > 0),
+    source VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_usage_ledger_user ON usage_ledger (user_id, created_at);
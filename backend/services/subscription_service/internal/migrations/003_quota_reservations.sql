@@ -0,0 +1,19 @@
+-- ---------------------------
+-- Quota reservations
+-- ---------------------------
+
+-- Rezervasyon kotayı LLM çağrısından önce düşer (hold). commit edilince
+-- usage_ledger'a yazılır; release/expire edilince kota geri verilir.
+CREATE TABLE IF NOT EXISTS quota_reservations (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    user_subscription_id UUID NOT NULL REFERENCES user_subscription(id),
+    amount INT NOT NULL CHECK (amount > 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'reserved',
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_quota_reservations_expiry
+    ON quota_reservations (expires_at) WHERE status = 'reserved';
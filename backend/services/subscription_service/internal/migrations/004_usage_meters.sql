@@ -0,0 +1,44 @@
+-- ---------------------------
+-- Usage meters (token based metering)
+-- ---------------------------
+
+-- Plan başına her ölçüm boyutunun (meter) limiti. Satırı olmayan meter sınırsızdır.
+CREATE TABLE IF NOT EXISTS plan_meter_limits (
+    plan_id UUID NOT NULL REFERENCES subscription_plans(id) ON DELETE CASCADE,
+    meter VARCHAR(50) NOT NULL,
+    limit_amount BIGINT NOT NULL CHECK (limit_amount >= 0),
+    PRIMARY KEY (plan_id, meter)
+);
+
+-- Abonelik dönemi içindeki toplam kullanım.
+CREATE TABLE IF NOT EXISTS subscription_meter_usage (
+    user_subscription_id UUID NOT NULL REFERENCES user_subscription(id),
+    meter VARCHAR(50) NOT NULL,
+    used BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_subscription_id, meter)
+);
+
+-- Her ölçüm (usage_id, meter) ile bir kez kaydedilir; tekrar gelen event etkisiz kalır.
+CREATE TABLE IF NOT EXISTS meter_ledger (
+    usage_id UUID NOT NULL,
+    meter VARCHAR(50) NOT NULL,
+    user_id UUID NOT NULL,
+    user_subscription_id UUID NOT NULL REFERENCES user_subscription(id),
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    source VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (usage_id, meter)
+);
+
+CREATE INDEX IF NOT EXISTS idx_meter_ledger_user ON meter_ledger (user_id, created_at);
+
+-- Free plan limitleri
+INSERT INTO plan_meter_limits (plan_id, meter, limit_amount)
+SELECT p.id, m.meter, m.limit_amount
+FROM subscription_plans p,
+     (VALUES ('prompt_tokens', 500000),
+             ('completion_tokens', 200000),
+             ('embedding_tokens', 1000000),
+             ('pages', 200)) AS m(meter, limit_amount)
+WHERE p.name = 'Free'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,24 @@
+-- ---------------------------
+-- Plan catalogue
+-- ---------------------------
+
+ALTER TABLE subscription_plans ADD COLUMN IF NOT EXISTS price_cents BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE subscription_plans ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'USD';
+-- Abonelik süresi: billing_period_count x billing_period (day, week, month, year)
+ALTER TABLE subscription_plans ADD COLUMN IF NOT EXISTS billing_period VARCHAR(10) NOT NULL DEFAULT 'month';
+ALTER TABLE subscription_plans ADD COLUMN IF NOT EXISTS billing_period_count INT NOT NULL DEFAULT 1;
+-- NULL = sınırsız
+ALTER TABLE subscription_plans ADD COLUMN IF NOT EXISTS max_upload_bytes BIGINT NULL;
+ALTER TABLE subscription_plans ADD COLUMN IF NOT EXISTS max_documents INT NULL;
+-- Boş dizi = tüm modeller
+ALTER TABLE subscription_plans ADD COLUMN IF NOT EXISTS allowed_models TEXT[] NOT NULL DEFAULT '{}';
+-- Emekliye ayrılan plan yeni kullanıcılara atanamaz, mevcut abonelikler sürer.
+ALTER TABLE subscription_plans ADD COLUMN IF NOT EXISTS is_active BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE subscription_plans ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE subscription_plans ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE subscription_plans ADD COLUMN IF NOT EXISTS retired_at TIMESTAMP NULL;
+
+-- Free plan eskiden kodda 5 gün olarak sabitti.
+UPDATE subscription_plans
+SET billing_period = 'day', billing_period_count = 5, max_upload_bytes = 52428800
+WHERE name = 'Free' AND billing_period = 'month' AND billing_period_count = 1 AND max_upload_bytes IS NULL;
@@ -0,0 +1,61 @@
+-- ---------------------------
+-- Subscription lifecycle
+-- ---------------------------
+
+-- trialing → active → past_due → expired, veya cancelled.
+-- Eski kayıtlar yalnızca status kolonu ilk eklendiğinde düzeltilir; sonraki
+-- çalıştırmalarda durum geçişleri zamanlayıcıya aittir.
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'user_subscription' AND column_name = 'status'
+    ) THEN
+        ALTER TABLE user_subscription ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'active';
+
+        -- Süresi geçmiş eski kayıtlar
+        UPDATE user_subscription SET status = 'expired' WHERE end_date < NOW();
+
+        -- Bir kullanıcının aynı anda tek geçerli aboneliği olabilir; eskiden üst
+        -- üste eklenen kayıtlardan en geç biteni kalır.
+        UPDATE user_subscription us
+        SET status = 'cancelled'
+        WHERE us.status = 'active'
+          AND EXISTS (
+              SELECT 1 FROM user_subscription o
+              WHERE o.user_id = us.user_id
+                AND o.status = 'active'
+                AND (o.end_date > us.end_date OR (o.end_date = us.end_date AND o.id > us.id))
+          );
+    END IF;
+END $$;
+
+ALTER TABLE user_subscription ADD COLUMN IF NOT EXISTS cancel_at_period_end BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE user_subscription ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMP NULL;
+ALTER TABLE user_subscription ADD COLUMN IF NOT EXISTS ended_at TIMESTAMP NULL;
+ALTER TABLE user_subscription ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE user_subscription ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+-- Deneme süresi (gün); 0 = deneme yok
+ALTER TABLE subscription_plans ADD COLUMN IF NOT EXISTS trial_days INT NOT NULL DEFAULT 0;
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_user_subscription_current
+    ON user_subscription (user_id) WHERE status IN ('trialing', 'active', 'past_due');
+
+CREATE INDEX IF NOT EXISTS idx_user_subscription_due
+    ON user_subscription (end_date) WHERE status IN ('trialing', 'active', 'past_due');
+
+-- subscription_changed event'leri aynı transaction içinde buraya yazılır,
+-- outbox relay Kafka'ya taşır.
+CREATE TABLE IF NOT EXISTS outbox (
+    id UUID PRIMARY KEY,
+    topic VARCHAR(255) NOT NULL,
+    message_key TEXT NOT NULL DEFAULT '',
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    published_at TIMESTAMP NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox (created_at) WHERE published_at IS NULL;
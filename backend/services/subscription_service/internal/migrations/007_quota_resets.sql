@@ -0,0 +1,43 @@
+-- ---------------------------
+-- Quota reset / rollover
+-- ---------------------------
+
+-- Kota sıfırlama sıklığı (day/week/month/year); NULL = her fatura döneminde bir
+ALTER TABLE subscription_plans ADD COLUMN IF NOT EXISTS quota_reset_period VARCHAR(10) NULL;
+-- Sıfırlamada bir sonraki döneme aktarılabilecek en fazla kullanılmamış mesaj; 0 = aktarım yok
+ALTER TABLE subscription_plans ADD COLUMN IF NOT EXISTS rollover_cap BIGINT NOT NULL DEFAULT 0;
+
+ALTER TABLE user_subscription ADD COLUMN IF NOT EXISTS quota_period_start TIMESTAMP NULL;
+ALTER TABLE user_subscription ADD COLUMN IF NOT EXISTS quota_reset_at TIMESTAMP NULL;
+
+-- Eski kayıtlar tek kota dönemiyle başlar
+UPDATE user_subscription SET quota_period_start = start_date WHERE quota_period_start IS NULL;
+UPDATE user_subscription SET quota_reset_at = end_date WHERE quota_reset_at IS NULL;
+
+ALTER TABLE user_subscription ALTER COLUMN quota_period_start SET NOT NULL;
+ALTER TABLE user_subscription ALTER COLUMN quota_reset_at SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_user_subscription_quota_reset
+    ON user_subscription (quota_reset_at) WHERE status IN ('trialing', 'active');
+
+-- Her sıfırlamanın denetim kaydı; meter_usage sıfırlanmadan önceki meter kullanımıdır
+CREATE TABLE IF NOT EXISTS quota_resets (
+    id UUID PRIMARY KEY,
+    user_subscription_id UUID NOT NULL REFERENCES user_subscription(id),
+    user_id UUID NOT NULL,
+    plan_id UUID NOT NULL REFERENCES subscription_plans(id),
+    reason VARCHAR(20) NOT NULL,
+    period_start TIMESTAMP NOT NULL,
+    period_end TIMESTAMP NOT NULL,
+    previous_remaining INT NOT NULL,
+    held INT NOT NULL DEFAULT 0,
+    carried_over INT NOT NULL DEFAULT 0,
+    forfeited INT NOT NULL DEFAULT 0,
+    granted INT NOT NULL,
+    new_remaining INT NOT NULL,
+    meter_usage JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_subscription_id, period_end)
+);
+
+CREATE INDEX IF NOT EXISTS idx_quota_resets_user ON quota_resets (user_id, created_at DESC);
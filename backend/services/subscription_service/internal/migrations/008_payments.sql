@@ -0,0 +1,36 @@
+-- ---------------------------
+-- Payments
+-- ---------------------------
+
+-- Sağlayıcının ödeme sayfasında yapılan tek bir ödeme. Abonelik yalnızca
+-- sağlayıcı ödemeyi webhook ile onayladığında değişir.
+CREATE TABLE IF NOT EXISTS checkouts (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    plan_id UUID NOT NULL REFERENCES subscription_plans(id),
+    purpose VARCHAR(20) NOT NULL,           -- subscribe, renew, upgrade
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    amount_cents BIGINT NOT NULL CHECK (amount_cents > 0),
+    currency VARCHAR(3) NOT NULL,
+    provider VARCHAR(20) NOT NULL,
+    provider_session_id VARCHAR(255) NULL UNIQUE,
+    checkout_url TEXT NULL,
+    failure_reason TEXT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    paid_at TIMESTAMP NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_checkouts_user ON checkouts (user_id, created_at DESC);
+
+-- İşlenen webhook event'leri; sağlayıcının tekrar gönderdiği event PRIMARY KEY
+-- çakışmasıyla etkisiz kalır.
+CREATE TABLE IF NOT EXISTS payment_events (
+    provider VARCHAR(20) NOT NULL,
+    event_id VARCHAR(255) NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    checkout_id UUID NULL REFERENCES checkouts(id),
+    payload JSONB NOT NULL,
+    received_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (provider, event_id)
+);
@@ -0,0 +1,45 @@
+-- ---------------------------
+-- Usage history and invoices
+-- ---------------------------
+
+-- Fatura kullanım satırları abonelik + dönem aralığına göre okur
+CREATE INDEX IF NOT EXISTS idx_usage_ledger_subscription_time ON usage_ledger (user_subscription_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_meter_ledger_subscription_time ON meter_ledger (user_subscription_id, created_at);
+
+-- Ödenen checkout'un hangi abonelik dönemine ait olduğu (faturadaki plan satırları)
+ALTER TABLE checkouts ADD COLUMN IF NOT EXISTS user_subscription_id UUID NULL REFERENCES user_subscription(id);
+ALTER TABLE checkouts ADD COLUMN IF NOT EXISTS period_start TIMESTAMP NULL;
+
+CREATE SEQUENCE IF NOT EXISTS invoice_number_seq;
+
+-- Kapanan her fatura dönemi için bir fatura
+CREATE TABLE IF NOT EXISTS invoices (
+    id UUID PRIMARY KEY,
+    number VARCHAR(32) UNIQUE NOT NULL,
+    user_id UUID NOT NULL,
+    user_subscription_id UUID NOT NULL REFERENCES user_subscription(id),
+    plan_id UUID NOT NULL REFERENCES subscription_plans(id),
+    plan_name VARCHAR(50) NOT NULL,
+    period_start TIMESTAMP NOT NULL,
+    period_end TIMESTAMP NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    subtotal_cents BIGINT NOT NULL DEFAULT 0,
+    total_cents BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_subscription_id, period_start)
+);
+
+CREATE INDEX IF NOT EXISTS idx_invoices_user ON invoices (user_id, period_start DESC);
+
+-- kind: plan (ödenen tutar) veya usage (dönem kullanımı, plana dahil)
+CREATE TABLE IF NOT EXISTS invoice_lines (
+    invoice_id UUID NOT NULL REFERENCES invoices(id) ON DELETE CASCADE,
+    line_no INT NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    description TEXT NOT NULL,
+    meter VARCHAR(50) NULL,
+    quantity BIGINT NOT NULL,
+    unit_amount_cents BIGINT NOT NULL DEFAULT 0,
+    amount_cents BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (invoice_id, line_no)
+);
@@ -0,0 +1,15 @@
+-- ---------------------------
+-- Quota threshold alerts
+-- ---------------------------
+
+-- Bir meter için her eşik, kota dönemi başına bir kez bildirilir
+CREATE TABLE IF NOT EXISTS quota_alerts (
+    user_subscription_id UUID NOT NULL REFERENCES user_subscription(id),
+    period_start TIMESTAMP NOT NULL,
+    meter VARCHAR(50) NOT NULL,
+    threshold INT NOT NULL,
+    used BIGINT NOT NULL,
+    limit_amount BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_subscription_id, period_start, meter, threshold)
+);
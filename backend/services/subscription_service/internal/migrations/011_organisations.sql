@@ -0,0 +1,19 @@
+-- ---------------------------
+-- Organisation members (auth_service org_members event'lerinden)
+-- ---------------------------
+
+-- Organizasyonun aboneliği, user_id = organisation_id olan user_subscription
+-- satırıdır; üyelerin kullanımı bu havuzdan düşer. message_cap NULL ise
+-- üye havuzun tamamını kullanabilir.
+CREATE TABLE IF NOT EXISTS organisation_members (
+    user_id UUID PRIMARY KEY,
+    organisation_id UUID NOT NULL,
+    role VARCHAR(20) NOT NULL,
+    message_cap INT NULL CHECK (message_cap >= 0),
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_organisation_members_org ON organisation_members (organisation_id);
+
+-- Üye başına kullanım (message_cap kontrolü ve üye listesi)
+CREATE INDEX IF NOT EXISTS idx_usage_ledger_sub_user ON usage_ledger (user_subscription_id, user_id, created_at);
@@ -0,0 +1,4 @@
+-- 012_outbox_retention.sql
+
+-- The relay deletes rows published more than OUTBOX_RETENTION_DAYS ago.
+CREATE INDEX IF NOT EXISTS idx_outbox_published ON outbox (published_at) WHERE published_at IS NOT NULL;
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Checkout statuses.
+const (
+	CheckoutPending = "pending"
+	CheckoutPaid    = "paid"
+	CheckoutFailed  = "failed"
+	CheckoutExpired = "expired"
+)
+
+// What a paid checkout does to the user's subscription.
+const (
+	// CheckoutSubscribe starts a new subscription, replacing a free or
+	// past_due one.
+	CheckoutSubscribe = "subscribe"
+	// CheckoutRenew pays the next period of the current plan.
+	CheckoutRenew = "renew"
+	// CheckoutUpgrade pays the prorated difference to a pricier plan.
+	CheckoutUpgrade = "upgrade"
+)
+
+// Checkout is one payment for a plan, made on the provider's hosted page.
+// The subscription changes only when the provider confirms the payment.
+type Checkout struct {
+	ID                uuid.UUID  `db:"id" json:"checkout_id"`
+	UserID            uuid.UUID  `db:"user_id" json:"user_id"`
+	PlanID            uuid.UUID  `db:"plan_id" json:"plan_id"`
+	Purpose           string     `db:"purpose" json:"purpose"`
+	Status            string     `db:"status" json:"status"`
+	AmountCents       int64      `db:"amount_cents" json:"amount_cents"`
+	Currency          string     `db:"currency" json:"currency"`
+	Provider          string     `db:"provider" json:"provider"`
+	ProviderSessionID *string    `db:"provider_session_id" json:"provider_session_id,omitempty"`
+	URL               *string    `db:"checkout_url" json:"url,omitempty"`
+	FailureReason     *string    `db:"failure_reason" json:"failure_reason,omitempty"`
+	CreatedAt         time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt         time.Time  `db:"updated_at" json:"updated_at"`
+	PaidAt            *time.Time `db:"paid_at" json:"paid_at,omitempty"`
+
+	// UserSubscriptionID and PeriodStart are the subscription period a paid
+	// checkout was applied to; its invoice lists the payment.
+	UserSubscriptionID *uuid.UUID `db:"user_subscription_id" json:"subscription_id,omitempty"`
+	PeriodStart        *time.Time `db:"period_start" json:"period_start,omitempty"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Invoice line kinds.
+const (
+	LinePlan  = "plan"
+	LineUsage = "usage"
+)
+
+// Invoice covers one closed billing period of a subscription: what was paid
+// for it and what was used in it.
+type Invoice struct {
+	ID                 uuid.UUID     `db:"id" json:"id"`
+	Number             string        `db:"number" json:"number"`
+	UserID             uuid.UUID     `db:"user_id" json:"user_id"`
+	UserSubscriptionID uuid.UUID     `db:"user_subscription_id" json:"subscription_id"`
+	PlanID             uuid.UUID     `db:"plan_id" json:"plan_id"`
+	PlanName           string        `db:"plan_name" json:"plan_name"`
+	PeriodStart        time.Time     `db:"period_start" json:"period_start"`
+	PeriodEnd          time.Time     `db:"period_end" json:"period_end"`
+	Currency           string        `db:"currency" json:"currency"`
+	SubtotalCents      int64         `db:"subtotal_cents" json:"subtotal_cents"`
+	TotalCents         int64         `db:"total_cents" json:"total_cents"`
+	CreatedAt          time.Time     `db:"created_at" json:"issued_at"`
+	Lines              []InvoiceLine `db:"-" json:"lines"`
+}
+
+// InvoiceLine is one line of an invoice. Usage lines are included in the
+// plan and carry no amount.
+type InvoiceLine struct {
+	InvoiceID       uuid.UUID `db:"invoice_id" json:"-"`
+	LineNo          int       `db:"line_no" json:"line_no"`
+	Kind            string    `db:"kind" json:"kind"`
+	Description     string    `db:"description" json:"description"`
+	Meter           *string   `db:"meter" json:"meter,omitempty"`
+	Quantity        int64     `db:"quantity" json:"quantity"`
+	UnitAmountCents int64     `db:"unit_amount_cents" json:"unit_amount_cents"`
+	AmountCents     int64     `db:"amount_cents" json:"amount_cents"`
+}
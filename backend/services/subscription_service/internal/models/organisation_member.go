@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OrganisationMember mirrors a membership from auth_service. Members are
+// charged to the organisation's subscription, if it has one, and a member
+// with a MessageCap can use at most that many messages of the pool per
+// quota period.
+type OrganisationMember struct {
+	UserID         uuid.UUID `db:"user_id" json:"user_id"`
+	OrganisationID uuid.UUID `db:"organisation_id" json:"organisation_id"`
+	Role           string    `db:"role" json:"role"`
+	MessageCap     *int      `db:"message_cap" json:"message_cap"`
+	UpdatedAt      time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// MemberUsage is a member's message usage of the organisation's pool in
+// the current quota period.
+type MemberUsage struct {
+	OrganisationMember
+	Used int64 `db:"used" json:"used"`
+}
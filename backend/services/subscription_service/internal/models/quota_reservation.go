@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Reservation statuses.
+const (
+	ReservationReserved  = "reserved"
+	ReservationCommitted = "committed"
+	ReservationReleased  = "released"
+	ReservationExpired   = "expired"
+)
+
+// QuotaReservation holds quota for an in-flight request. Its ID doubles as
+// the usage id recorded in the ledger on commit.
+type QuotaReservation struct {
+	ID                 uuid.UUID `db:"id" json:"reservation_id"`
+	UserID             uuid.UUID `db:"user_id" json:"user_id"`
+	UserSubscriptionID uuid.UUID `db:"user_subscription_id" json:"-"`
+	Amount             int       `db:"amount" json:"amount"`
+	Status             string    `db:"status" json:"status"`
+	ExpiresAt          time.Time `db:"expires_at" json:"expires_at"`
+	CreatedAt          time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt          time.Time `db:"updated_at" json:"-"`
+}
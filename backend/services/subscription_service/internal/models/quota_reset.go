@@ -0,0 +1,36 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Why a quota was reset.
+const (
+	ResetScheduled = "scheduled"
+	ResetRenewal   = "renewal"
+)
+
+// QuotaReset is the audit record of one quota reset. NewRemaining is
+// Granted plus CarriedOver; Forfeited is the unused quota above the rollover
+// cap. Held is what open reservations held at the reset, charged to the
+// period that ended.
+type QuotaReset struct {
+	ID                 uuid.UUID       `db:"id" json:"id"`
+	UserSubscriptionID uuid.UUID       `db:"user_subscription_id" json:"subscription_id"`
+	UserID             uuid.UUID       `db:"user_id" json:"user_id"`
+	PlanID             uuid.UUID       `db:"plan_id" json:"plan_id"`
+	Reason             string          `db:"reason" json:"reason"`
+	PeriodStart        time.Time       `db:"period_start" json:"period_start"`
+	PeriodEnd          time.Time       `db:"period_end" json:"period_end"`
+	PreviousRemaining  int             `db:"previous_remaining" json:"previous_remaining"`
+	Held               int             `db:"held" json:"held"`
+	CarriedOver        int             `db:"carried_over" json:"carried_over"`
+	Forfeited          int             `db:"forfeited" json:"forfeited"`
+	Granted            int             `db:"granted" json:"granted"`
+	NewRemaining       int             `db:"new_remaining" json:"new_remaining"`
+	MeterUsage         json.RawMessage `db:"meter_usage" json:"meter_usage"`
+	CreatedAt          time.Time       `db:"created_at" json:"created_at"`
+}
@@ -1,10 +1,73 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Billing periods a plan can renew on.
+const (
+	BillingDay   = "day"
+	BillingWeek  = "week"
+	BillingMonth = "month"
+	BillingYear  = "year"
+)
 
 // SubscriptionPlan represents a plan like Free, Premium
 type SubscriptionPlan struct {
-	ID          uuid.UUID `db:"id" json:"id"`
-	Name        string    `db:"name" json:"name"`
-	Description string    `db:"description" json:"description"`
+	ID                 uuid.UUID      `db:"id" json:"id"`
+	Name               string         `db:"name" json:"name"`
+	Description        string         `db:"description" json:"description"`
+	PriceCents         int64          `db:"price_cents" json:"price_cents"`
+	Currency           string         `db:"currency" json:"currency"`
+	BillingPeriod      string         `db:"billing_period" json:"billing_period"`
+	BillingPeriodCount int            `db:"billing_period_count" json:"billing_period_count"`
+	MaxUploadBytes     *int64         `db:"max_upload_bytes" json:"max_upload_bytes"`
+	MaxDocuments       *int           `db:"max_documents" json:"max_documents"`
+	AllowedModels      pq.StringArray `db:"allowed_models" json:"allowed_models"`
+	TrialDays          int            `db:"trial_days" json:"trial_days"`
+	QuotaResetPeriod   *string        `db:"quota_reset_period" json:"quota_reset_period"`
+	RolloverCap        int64          `db:"rollover_cap" json:"rollover_cap"`
+	Active             bool           `db:"is_active" json:"active"`
+	CreatedAt          time.Time      `db:"created_at" json:"created_at"`
+	UpdatedAt          time.Time      `db:"updated_at" json:"updated_at"`
+	RetiredAt          *time.Time     `db:"retired_at" json:"retired_at,omitempty"`
+
+	// Quotas is the limit per meter for one billing period; "messages" is
+	// the message count kept in subscription_quotas.
+	Quotas map[string]int64 `db:"-" json:"quotas"`
+}
+
+// PeriodEnd returns when a subscription to the plan started at start ends.
+func (p *SubscriptionPlan) PeriodEnd(start time.Time) time.Time {
+	return AddPeriod(start, p.BillingPeriod, p.BillingPeriodCount)
+}
+
+// NextQuotaReset returns when quota granted at from is reset next. Plans
+// without a quota_reset_period reset once per billing period, at periodEnd;
+// a reset never falls after periodEnd.
+func (p *SubscriptionPlan) NextQuotaReset(from, periodEnd time.Time) time.Time {
+	if p.QuotaResetPeriod == nil {
+		return periodEnd
+	}
+	if next := AddPeriod(from, *p.QuotaResetPeriod, 1); next.Before(periodEnd) {
+		return next
+	}
+	return periodEnd
+}
+
+// AddPeriod adds n billing periods to t.
+func AddPeriod(t time.Time, period string, n int) time.Time {
+	switch period {
+	case BillingDay:
+		return t.AddDate(0, 0, n)
+	case BillingWeek:
+		return t.AddDate(0, 0, 7*n)
+	case BillingYear:
+		return t.AddDate(n, 0, 0)
+	default:
+		return t.AddDate(0, n, 0)
+	}
 }
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UsageEntry is one quota deduction, keyed by the idempotency key of the
+// action that consumed it (e.g. a chat turn).
+type UsageEntry struct {
+	UsageID            uuid.UUID `db:"usage_id" json:"usage_id"`
+	UserID             uuid.UUID `db:"user_id" json:"user_id"`
+	UserSubscriptionID uuid.UUID `db:"user_subscription_id" json:"user_subscription_id"`
+	Amount             int       `db:"amount" json:"amount"`
+	Source             string    `db:"source" json:"source"`
+	CreatedAt          time.Time `db:"created_at" json:"created_at"`
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Usage meters. Each one is limited separately by plan_meter_limits, except
+// messages which is the per-period message count in subscription_quotas.
+const (
+	MeterMessages         = "messages"
+	MeterPromptTokens     = "prompt_tokens"
+	MeterCompletionTokens = "completion_tokens"
+	MeterEmbeddingTokens  = "embedding_tokens"
+	MeterPages            = "pages"
+)
+
+// Meters lists every meter a plan can set a quota for.
+var Meters = []string{MeterMessages, MeterPromptTokens, MeterCompletionTokens, MeterEmbeddingTokens, MeterPages}
+
+// ChatMeters must have room left before a chat turn can be reserved.
+var ChatMeters = []string{MeterPromptTokens, MeterCompletionTokens}
+
+// MeterLimit is a plan's limit for one meter per subscription period.
+type MeterLimit struct {
+	PlanID uuid.UUID `db:"plan_id" json:"plan_id"`
+	Meter  string    `db:"meter" json:"meter"`
+	Limit  int64     `db:"limit_amount" json:"limit"`
+}
+
+// MeterUsage is the usage of one meter in the current subscription period.
+// Limit is nil for meters the plan doesn't limit.
+type MeterUsage struct {
+	Meter     string `db:"meter" json:"meter"`
+	Used      int64  `db:"used" json:"used"`
+	Limit     *int64 `db:"limit_amount" json:"limit"`
+	Remaining *int64 `db:"-" json:"remaining"`
+}
+
+// PeriodUsage is the usage of the current quota period against its limits.
+type PeriodUsage struct {
+	PeriodStart time.Time    `json:"period_start"`
+	PeriodEnd   time.Time    `json:"period_end"`
+	Meters      []MeterUsage `json:"meters"`
+}
+
+// DailyUsage is the usage per meter on one UTC day.
+type DailyUsage struct {
+	Date   string           `json:"date"`
+	Meters map[string]int64 `json:"meters"`
+}
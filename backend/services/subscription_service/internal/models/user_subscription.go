@@ -1,16 +1,56 @@
 package models
 
 import (
+	"math"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// Subscription statuses.
+const (
+	StatusTrialing  = "trialing"
+	StatusActive    = "active"
+	StatusPastDue   = "past_due"
+	StatusCancelled = "cancelled"
+	StatusExpired   = "expired"
+)
+
+// UserSubscription is one subscription period of a user. A user has at most
+// one subscription that is trialing, active or past_due at a time.
 type UserSubscription struct {
-	ID             uuid.UUID `db:"id" json:"id"`
-	UserID         uuid.UUID `db:"user_id" json:"user_id"`
-	SubscriptionID uuid.UUID `db:"subscription_id" json:"subscription_id"`
-	StartDate      time.Time `db:"start_date" json:"start_date"`
-	EndDate        time.Time `db:"end_date" json:"end_date"`
-	RemainingQuota int       `db:"remaining_quota" json:"remaining_quota"`
+	ID                uuid.UUID  `db:"id" json:"id"`
+	UserID            uuid.UUID  `db:"user_id" json:"user_id"`
+	SubscriptionID    uuid.UUID  `db:"subscription_id" json:"subscription_id"`
+	StartDate         time.Time  `db:"start_date" json:"start_date"`
+	EndDate           time.Time  `db:"end_date" json:"end_date"`
+	RemainingQuota    int        `db:"remaining_quota" json:"remaining_quota"`
+	Status            string     `db:"status" json:"status"`
+	CancelAtPeriodEnd bool       `db:"cancel_at_period_end" json:"cancel_at_period_end"`
+	CancelledAt       *time.Time `db:"cancelled_at" json:"cancelled_at,omitempty"`
+	EndedAt           *time.Time `db:"ended_at" json:"ended_at,omitempty"`
+	QuotaPeriodStart  time.Time  `db:"quota_period_start" json:"quota_period_start"`
+	QuotaResetAt      time.Time  `db:"quota_reset_at" json:"quota_reset_at"`
+	CreatedAt         time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt         time.Time  `db:"updated_at" json:"updated_at"`
+}
+
+// IsCurrent reports whether the subscription still grants access.
+func (s *UserSubscription) IsCurrent() bool {
+	return s.Status == StatusTrialing || s.Status == StatusActive || s.Status == StatusPastDue
+}
+
+// PeriodLeft returns the share of the subscription period still ahead, in [0, 1].
+func (s *UserSubscription) PeriodLeft(now time.Time) float64 {
+	total := s.EndDate.Sub(s.StartDate)
+	if total <= 0 {
+		return 0
+	}
+	left := float64(s.EndDate.Sub(now)) / float64(total)
+	return math.Max(0, math.Min(1, left))
+}
+
+// Prorate returns share of amount, rounded to the nearest unit.
+func Prorate(amount int64, share float64) int64 {
+	return int64(math.Round(float64(amount) * share))
 }
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProrate(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount int64
+		share  float64
+		want   int64
+	}{
+		{"whole period", 1999, 1, 1999},
+		{"nothing left", 1999, 0, 0},
+		{"half", 1000, 0.5, 500},
+		{"rounds half up", 999, 0.5, 500},
+		{"rounds down", 1000, 0.3333, 333},
+		{"rounds up", 1000, 0.6667, 667},
+		{"a third of a cent", 1, 0.33, 0},
+		{"free plan", 0, 0.7, 0},
+		{"refund is negative", -1000, 0.25, -250},
+		{"large amount", 999_999_999, 0.1, 100_000_000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Prorate(tt.amount, tt.share); got != tt.want {
+				t.Errorf("Prorate(%d, %v) = %d, want %d", tt.amount, tt.share, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPeriodLeft(t *testing.T) {
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 30)
+	sub := &UserSubscription{StartDate: start, EndDate: end}
+
+	tests := []struct {
+		name string
+		sub  *UserSubscription
+		now  time.Time
+		want float64
+	}{
+		{"at start", sub, start, 1},
+		{"before start", sub, start.Add(-time.Hour), 1},
+		{"a third in", sub, start.AddDate(0, 0, 10), 2.0 / 3},
+		{"halfway", sub, start.AddDate(0, 0, 15), 0.5},
+		{"at end", sub, end, 0},
+		{"after end", sub, end.AddDate(0, 0, 1), 0},
+		{"empty period", &UserSubscription{StartDate: start, EndDate: start}, start, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.sub.PeriodLeft(tt.now)
+			if diff := got - tt.want; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("PeriodLeft() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
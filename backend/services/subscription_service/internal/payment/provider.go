@@ -0,0 +1,68 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Normalised webhook event types. Provider events that don't map to one of
+// these are reported with an empty Type and ignored.
+const (
+	EventPaymentSucceeded = "payment_succeeded"
+	EventPaymentFailed    = "payment_failed"
+	EventCheckoutExpired  = "checkout_expired"
+)
+
+var (
+	// ErrInvalidSignature means a webhook payload wasn't signed with the
+	// configured secret, or the signature is too old.
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	// ErrInvalidPayload means a correctly signed webhook couldn't be decoded.
+	ErrInvalidPayload = errors.New("invalid webhook payload")
+)
+
+// PaymentProvider creates hosted checkout pages and verifies the webhooks
+// the provider sends back about them.
+type PaymentProvider interface {
+	// Name identifies the provider in stored checkouts and events.
+	Name() string
+	// CreateCheckoutSession starts a hosted payment page for req.
+	CreateCheckoutSession(ctx context.Context, req CheckoutRequest) (*CheckoutSession, error)
+	// ParseWebhook verifies the signature of a webhook request and decodes it.
+	ParseWebhook(payload []byte, header http.Header) (*Event, error)
+}
+
+// CheckoutRequest describes one payment. ReferenceID is our checkout id; the
+// provider echoes it back in webhooks.
+type CheckoutRequest struct {
+	ReferenceID string
+	UserID      string
+	PlanID      string
+	Description string
+	AmountCents int64
+	Currency    string
+	SuccessURL  string
+	CancelURL   string
+}
+
+// CheckoutSession is the provider side of a checkout.
+type CheckoutSession struct {
+	ID        string
+	URL       string
+	ExpiresAt *time.Time
+}
+
+// Event is a verified webhook event.
+type Event struct {
+	ID           string
+	Type         string
+	ProviderType string
+	ReferenceID  string
+	SessionID    string
+	PaymentID    string
+	AmountCents  int64
+	Currency     string
+	Payload      []byte
+}
@@ -0,0 +1,207 @@
+package payment
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StripeProvider talks to the Stripe API, or to anything that speaks it
+// such as stripe-mock, through plain HTTP.
+type StripeProvider struct {
+	baseURL       string
+	secretKey     string
+	webhookSecret string
+	// tolerance is how old a webhook signature may be.
+	tolerance time.Duration
+	client    *http.Client
+}
+
+// NewStripeProvider creates a provider for the API at baseURL
+// (https://api.stripe.com in production).
+func NewStripeProvider(baseURL, secretKey, webhookSecret string) *StripeProvider {
+	return &StripeProvider{
+		baseURL:       strings.TrimRight(baseURL, "/"),
+		secretKey:     secretKey,
+		webhookSecret: webhookSecret,
+		tolerance:     5 * time.Minute,
+		client:        &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (p *StripeProvider) Name() string { return "stripe" }
+
+// CreateCheckoutSession creates a one-off payment Checkout Session. The
+// reference id is also sent as the idempotency key, so a retried request
+// returns the same session.
+func (p *StripeProvider) CreateCheckoutSession(ctx context.Context, req CheckoutRequest) (*CheckoutSession, error) {
+	form := url.Values{}
+	form.Set("mode", "payment")
+	form.Set("client_reference_id", req.ReferenceID)
+	form.Set("success_url", req.SuccessURL)
+	form.Set("cancel_url", req.CancelURL)
+	form.Set("line_items[0][quantity]", "1")
+	form.Set("line_items[0][price_data][currency]", strings.ToLower(req.Currency))
+	form.Set("line_items[0][price_data][unit_amount]", strconv.FormatInt(req.AmountCents, 10))
+	form.Set("line_items[0][price_data][product_data][name]", req.Description)
+	form.Set("metadata[checkout_id]", req.ReferenceID)
+	form.Set("metadata[user_id]", req.UserID)
+	form.Set("metadata[plan_id]", req.PlanID)
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/v1/checkout/sessions", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+p.secretKey)
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	httpReq.Header.Set("Idempotency-Key", req.ReferenceID)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("create checkout session: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.Unmarshal(body, &apiErr)
+		return nil, fmt.Errorf("create checkout session: status %d: %s", resp.StatusCode, apiErr.Error.Message)
+	}
+
+	var session struct {
+		ID        string `json:"id"`
+		URL       string `json:"url"`
+		ExpiresAt int64  `json:"expires_at"`
+	}
+	if err := json.Unmarshal(body, &session); err != nil {
+		return nil, fmt.Errorf("decode checkout session: %w", err)
+	}
+
+	out := &CheckoutSession{ID: session.ID, URL: session.URL}
+	if session.ExpiresAt > 0 {
+		t := time.Unix(session.ExpiresAt, 0).UTC()
+		out.ExpiresAt = &t
+	}
+	return out, nil
+}
+
+// ParseWebhook verifies the Stripe-Signature header and maps Checkout
+// Session events to normalised ones.
+func (p *StripeProvider) ParseWebhook(payload []byte, header http.Header) (*Event, error) {
+	if err := verifyStripeSignature(payload, header.Get("Stripe-Signature"), p.webhookSecret, p.tolerance, time.Now()); err != nil {
+		return nil, err
+	}
+
+	var raw struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+		Data struct {
+			Object struct {
+				ID                string            `json:"id"`
+				ClientReferenceID string            `json:"client_reference_id"`
+				PaymentStatus     string            `json:"payment_status"`
+				PaymentIntent     string            `json:"payment_intent"`
+				AmountTotal       int64             `json:"amount_total"`
+				Currency          string            `json:"currency"`
+				Metadata          map[string]string `json:"metadata"`
+			} `json:"object"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+	if raw.ID == "" || raw.Type == "" {
+		return nil, fmt.Errorf("%w: missing id or type", ErrInvalidPayload)
+	}
+
+	obj := raw.Data.Object
+	evt := &Event{
+		ID:           raw.ID,
+		ProviderType: raw.Type,
+		ReferenceID:  obj.ClientReferenceID,
+		SessionID:    obj.ID,
+		PaymentID:    obj.PaymentIntent,
+		AmountCents:  obj.AmountTotal,
+		Currency:     strings.ToUpper(obj.Currency),
+		Payload:      payload,
+	}
+	if evt.ReferenceID == "" {
+		evt.ReferenceID = obj.Metadata["checkout_id"]
+	}
+
+	switch raw.Type {
+	case "checkout.session.completed":
+		// Delayed payment methods complete the session unpaid and report
+		// the outcome with an async_payment event later.
+		if obj.PaymentStatus == "paid" {
+			evt.Type = EventPaymentSucceeded
+		}
+	case "checkout.session.async_payment_succeeded":
+		evt.Type = EventPaymentSucceeded
+	case "checkout.session.async_payment_failed":
+		evt.Type = EventPaymentFailed
+	case "checkout.session.expired":
+		evt.Type = EventCheckoutExpired
+	}
+	return evt, nil
+}
+
+// verifyStripeSignature checks a "t=<unix>,v1=<hex hmac>" header: the HMAC
+// is SHA-256 over "<t>.<payload>" keyed with the webhook secret.
+func verifyStripeSignature(payload []byte, header, secret string, tolerance time.Duration, now time.Time) error {
+	if secret == "" || header == "" {
+		return ErrInvalidSignature
+	}
+
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch k {
+		case "t":
+			timestamp = v
+		case "v1":
+			signatures = append(signatures, v)
+		}
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+	if math.Abs(now.Sub(time.Unix(ts, 0)).Seconds()) > tolerance.Seconds() {
+		return fmt.Errorf("%w: timestamp outside tolerance", ErrInvalidSignature)
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	expected := mac.Sum(nil)
+
+	for _, s := range signatures {
+		sig, err := hex.DecodeString(s)
+		if err == nil && hmac.Equal(sig, expected) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
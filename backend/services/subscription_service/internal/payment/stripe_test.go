@@ -0,0 +1,65 @@
+package payment
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestVerifyStripeSignature(t *testing.T) {
+	const secret = "whsec_test"
+	payload := []byte(`{"id":"evt_1","type":"checkout.session.completed"}`)
+	now := time.Unix(1_700_000_000, 0)
+	tolerance := 5 * time.Minute
+
+	sig := func(secret string, ts int64, payload []byte) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		fmt.Fprintf(mac, "%d.", ts)
+		mac.Write(payload)
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+	valid := sig(secret, now.Unix(), payload)
+
+	tests := []struct {
+		name    string
+		header  string
+		secret  string
+		payload []byte
+		wantErr bool
+	}{
+		{"valid", fmt.Sprintf("t=%d,v1=%s", now.Unix(), valid), secret, payload, false},
+		{"spaces between parts", fmt.Sprintf("t=%d, v1=%s", now.Unix(), valid), secret, payload, false},
+		{"one of several v1 matches", fmt.Sprintf("t=%d,v1=%s,v1=%s", now.Unix(), sig("old", now.Unix(), payload), valid), secret, payload, false},
+		{"v0 ignored", fmt.Sprintf("t=%d,v0=%s,v1=%s", now.Unix(), "00", valid), secret, payload, false},
+		{"within tolerance", fmt.Sprintf("t=%d,v1=%s", now.Unix()-299, sig(secret, now.Unix()-299, payload)), secret, payload, false},
+		{"too old", fmt.Sprintf("t=%d,v1=%s", now.Unix()-301, sig(secret, now.Unix()-301, payload)), secret, payload, true},
+		{"too far ahead", fmt.Sprintf("t=%d,v1=%s", now.Unix()+301, sig(secret, now.Unix()+301, payload)), secret, payload, true},
+		{"other secret", fmt.Sprintf("t=%d,v1=%s", now.Unix(), sig("whsec_other", now.Unix(), payload)), secret, payload, true},
+		{"tampered payload", fmt.Sprintf("t=%d,v1=%s", now.Unix(), valid), secret, []byte(`{"id":"evt_2"}`), true},
+		{"signed with other timestamp", fmt.Sprintf("t=%d,v1=%s", now.Unix(), sig(secret, now.Unix()-1, payload)), secret, payload, true},
+		{"no v1", fmt.Sprintf("t=%d", now.Unix()), secret, payload, true},
+		{"no timestamp", "v1=" + valid, secret, payload, true},
+		{"bad timestamp", "t=abc,v1=" + valid, secret, payload, true},
+		{"not hex", fmt.Sprintf("t=%d,v1=zz", now.Unix()), secret, payload, true},
+		{"empty header", "", secret, payload, true},
+		{"no secret configured", fmt.Sprintf("t=%d,v1=%s", now.Unix(), sig("", now.Unix(), payload)), "", payload, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyStripeSignature(tt.payload, tt.header, tt.secret, tolerance, now)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidSignature) {
+					t.Fatalf("error = %v, want ErrInvalidSignature", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error = %v, want nil", err)
+			}
+		})
+	}
+}
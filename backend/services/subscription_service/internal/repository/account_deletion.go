@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"subscription_service/internal/models"
+
+	"contracts/events"
+	"contracts/outbox"
+)
+
+// CloseUserAccount closes everything a deleted user still has open: the
+// current subscription ends right away, pending checkouts fail and the
+// organisation membership is dropped. The user_data_purged report goes to
+// the outbox in the same transaction, with the number of records closed.
+// Invoices and usage ledgers are kept for bookkeeping. A redelivered event
+// finds nothing left to close and reports again, which auth_service ignores.
+func (r *PostgresSubscriptionRepository) CloseUserAccount(userID uuid.UUID, deletionID, correlationID string) (int, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	items := 0
+	sub, err := currentForUpdate(tx, userID)
+	switch {
+	case err == nil:
+		prevStatus := sub.Status
+		if err := closeBillingPeriod(tx, sub, time.Now().UTC()); err != nil {
+			return 0, err
+		}
+		if err := tx.Get(sub, `
+			UPDATE user_subscription
+			SET status = $2, cancelled_at = COALESCE(cancelled_at, NOW()), ended_at = NOW(), updated_at = NOW()
+			WHERE id = $1
+			RETURNING `+subscriptionColumns, sub.ID, models.StatusCancelled); err != nil {
+			return 0, fmt.Errorf("cancel subscription: %w", err)
+		}
+		if err := r.recordChangeForPlan(tx, sub, change{reason: events.ChangeCancelled, previousStatus: prevStatus}); err != nil {
+			return 0, err
+		}
+		items++
+	case !errors.Is(err, ErrNoActiveSubscription):
+		return 0, err
+	}
+
+	res, err := tx.Exec(`
+		UPDATE checkouts SET status = $2, failure_reason = 'account deleted', updated_at = NOW()
+		WHERE user_id = $1 AND status = $3`,
+		userID, models.CheckoutFailed, models.CheckoutPending)
+	if err != nil {
+		return 0, fmt.Errorf("fail pending checkouts: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	items += int(n)
+
+	res, err = tx.Exec(`DELETE FROM organisation_members WHERE user_id = $1`, userID)
+	if err != nil {
+		return 0, fmt.Errorf("delete organisation membership: %w", err)
+	}
+	n, _ = res.RowsAffected()
+	items += int(n)
+
+	env, err := events.New(events.ProducerSubscription, correlationID, &events.UserDataPurged{
+		DeletionID:   deletionID,
+		UserID:       userID.String(),
+		Service:      events.ProducerSubscription,
+		ItemsDeleted: items,
+		Detail:       "subscriptions, pending checkouts and memberships closed",
+	})
+	if err != nil {
+		return 0, fmt.Errorf("build user_data_purged: %w", err)
+	}
+	if err := outbox.Insert(tx, outbox.Message{
+		Topic:    r.purgedTopic,
+		Key:      userID.String(),
+		Envelope: env,
+	}); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit tx: %w", err)
+	}
+	return items, nil
+}
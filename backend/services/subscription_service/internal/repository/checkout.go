@@ -0,0 +1,293 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"subscription_service/internal/models"
+	"subscription_service/internal/payment"
+
+	"contracts/events"
+)
+
+var (
+	// ErrCheckoutNotFound means no checkout matches the id or provider session.
+	ErrCheckoutNotFound = errors.New("checkout not found")
+	// ErrDuplicateEvent means the webhook event was already processed.
+	ErrDuplicateEvent = errors.New("payment event already processed")
+	// ErrPaymentMismatch means the confirmed amount or currency differs from
+	// the checkout's; the checkout is failed and nothing is activated.
+	ErrPaymentMismatch = errors.New("payment does not match checkout")
+)
+
+const checkoutColumns = `id, user_id, plan_id, purpose, status, amount_cents, currency, provider,
+	provider_session_id, checkout_url, failure_reason, created_at, updated_at, paid_at,
+	user_subscription_id, period_start`
+
+// CreateCheckout stores a pending checkout before the provider is called.
+func (r *PostgresSubscriptionRepository) CreateCheckout(c *models.Checkout) error {
+	err := r.db.Get(c, `
+		INSERT INTO checkouts (id, user_id, plan_id, purpose, status, amount_cents, currency, provider)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING `+checkoutColumns,
+		c.ID, c.UserID, c.PlanID, c.Purpose, models.CheckoutPending, c.AmountCents, c.Currency, c.Provider)
+	if err != nil {
+		return fmt.Errorf("create checkout: %w", err)
+	}
+	return nil
+}
+
+// AttachCheckoutSession records the provider session of a checkout.
+func (r *PostgresSubscriptionRepository) AttachCheckoutSession(id uuid.UUID, sessionID, url string) (*models.Checkout, error) {
+	var c models.Checkout
+	err := r.db.Get(&c, `
+		UPDATE checkouts
+		SET provider_session_id = $2, checkout_url = $3, updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+checkoutColumns,
+		id, sessionID, url)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrCheckoutNotFound
+		}
+		return nil, fmt.Errorf("attach checkout session: %w", err)
+	}
+	return &c, nil
+}
+
+// FailCheckout marks a pending checkout failed, e.g. when the provider
+// couldn't create a session.
+func (r *PostgresSubscriptionRepository) FailCheckout(id uuid.UUID, reason string) error {
+	_, err := r.db.Exec(`
+		UPDATE checkouts
+		SET status = $2, failure_reason = $3, updated_at = NOW()
+		WHERE id = $1 AND status = $4`,
+		id, models.CheckoutFailed, reason, models.CheckoutPending)
+	if err != nil {
+		return fmt.Errorf("fail checkout: %w", err)
+	}
+	return nil
+}
+
+// GetCheckout returns a checkout by id.
+func (r *PostgresSubscriptionRepository) GetCheckout(id uuid.UUID) (*models.Checkout, error) {
+	var c models.Checkout
+	if err := r.db.Get(&c, `SELECT `+checkoutColumns+` FROM checkouts WHERE id = $1`, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrCheckoutNotFound
+		}
+		return nil, fmt.Errorf("get checkout: %w", err)
+	}
+	return &c, nil
+}
+
+// ApplyPaymentEvent records a verified webhook event and applies it to its
+// checkout in one transaction. A confirmed payment activates the plan as the
+// checkout's purpose says; a failed or expired one only closes the checkout.
+// Events for closed checkouts change nothing, and a repeated event id
+// returns ErrDuplicateEvent.
+func (r *PostgresSubscriptionRepository) ApplyPaymentEvent(provider string, evt *payment.Event) (*models.Checkout, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	c, err := checkoutForUpdate(tx, provider, evt)
+	if err != nil && !errors.Is(err, ErrCheckoutNotFound) {
+		return nil, err
+	}
+
+	var checkoutID *uuid.UUID
+	if c != nil {
+		checkoutID = &c.ID
+	}
+	res, err := tx.Exec(`
+		INSERT INTO payment_events (provider, event_id, event_type, checkout_id, payload)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (provider, event_id) DO NOTHING`,
+		provider, evt.ID, evt.ProviderType, checkoutID, evt.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("record payment event: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return c, ErrDuplicateEvent
+	}
+
+	var applyErr error
+	switch {
+	case c == nil:
+		applyErr = ErrCheckoutNotFound
+	case evt.Type == "" || c.Status != models.CheckoutPending:
+	case evt.Type == payment.EventPaymentSucceeded:
+		if evt.AmountCents != c.AmountCents || evt.Currency != c.Currency {
+			applyErr = fmt.Errorf("%w: got %d %s, want %d %s", ErrPaymentMismatch, evt.AmountCents, evt.Currency, c.AmountCents, c.Currency)
+			err = closeCheckout(tx, c, models.CheckoutFailed, applyErr.Error())
+			break
+		}
+		var sub *models.UserSubscription
+		if sub, err = r.activateCheckout(tx, c); err != nil {
+			break
+		}
+		// The payment shows up on the invoice of the period it went to.
+		_, err = tx.Exec(`UPDATE checkouts SET user_subscription_id = $2, period_start = $3 WHERE id = $1`,
+			c.ID, sub.ID, sub.StartDate)
+		if err != nil {
+			err = fmt.Errorf("tag checkout period: %w", err)
+			break
+		}
+		err = closeCheckout(tx, c, models.CheckoutPaid, "")
+	case evt.Type == payment.EventPaymentFailed:
+		err = closeCheckout(tx, c, models.CheckoutFailed, "payment failed")
+	case evt.Type == payment.EventCheckoutExpired:
+		err = closeCheckout(tx, c, models.CheckoutExpired, "")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+	return c, applyErr
+}
+
+// checkoutForUpdate locks the checkout an event refers to, by our reference
+// id or else by the provider's session id.
+func checkoutForUpdate(tx *sqlx.Tx, provider string, evt *payment.Event) (*models.Checkout, error) {
+	var c models.Checkout
+	var err error
+	if id, parseErr := uuid.Parse(evt.ReferenceID); parseErr == nil {
+		err = tx.Get(&c, `SELECT `+checkoutColumns+` FROM checkouts WHERE id = $1 AND provider = $2 FOR UPDATE`, id, provider)
+	} else {
+		err = tx.Get(&c, `SELECT `+checkoutColumns+` FROM checkouts WHERE provider_session_id = $1 AND provider = $2 FOR UPDATE`, evt.SessionID, provider)
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrCheckoutNotFound
+		}
+		return nil, fmt.Errorf("get checkout: %w", err)
+	}
+	return &c, nil
+}
+
+func closeCheckout(tx *sqlx.Tx, c *models.Checkout, status, reason string) error {
+	var failure *string
+	if reason != "" {
+		failure = &reason
+	}
+	err := tx.Get(c, `
+		UPDATE checkouts
+		SET status = $2, failure_reason = $3, updated_at = NOW(),
+			paid_at = CASE WHEN $2 = 'paid' THEN NOW() ELSE paid_at END
+		WHERE id = $1
+		RETURNING `+checkoutColumns,
+		c.ID, status, failure)
+	if err != nil {
+		return fmt.Errorf("close checkout: %w", err)
+	}
+	return nil
+}
+
+// activateCheckout applies a paid checkout to the user's subscription:
+//   - renew on the current plan: the next period is added
+//   - upgrade from an active or trialing paid plan: the plan is changed
+//   - otherwise the current subscription, if any, is ended and a new one
+//     starts now; the replaced one's period is invoiced
+//
+// It returns the subscription the payment went to.
+func (r *PostgresSubscriptionRepository) activateCheckout(tx *sqlx.Tx, c *models.Checkout) (*models.UserSubscription, error) {
+	plan, err := getPlan(tx, `SELECT `+planColumns+` FROM subscription_plans WHERE id=$1`, c.PlanID)
+	if err != nil {
+		return nil, err
+	}
+
+	sub, err := currentForUpdate(tx, c.UserID)
+	if err != nil && !errors.Is(err, ErrNoActiveSubscription) {
+		return nil, err
+	}
+	now := time.Now().UTC()
+
+	switch {
+	case sub != nil && c.Purpose == models.CheckoutRenew && sub.SubscriptionID == plan.ID:
+		return sub, r.renewSubscription(tx, sub, plan, now)
+	case sub != nil && c.Purpose == models.CheckoutUpgrade && sub.SubscriptionID != plan.ID &&
+		(sub.Status == models.StatusActive || sub.Status == models.StatusTrialing):
+		sub, _, err := r.changePlan(tx, c.UserID, plan, true)
+		return sub, err
+	}
+
+	if sub != nil {
+		if err := closeBillingPeriod(tx, sub, now); err != nil {
+			return nil, err
+		}
+		prevStatus := sub.Status
+		err := tx.Get(sub, `
+			UPDATE user_subscription
+			SET status = $2, cancelled_at = COALESCE(cancelled_at, NOW()), ended_at = NOW(), updated_at = NOW()
+			WHERE id = $1
+			RETURNING `+subscriptionColumns, sub.ID, models.StatusCancelled)
+		if err != nil {
+			return nil, fmt.Errorf("end replaced subscription: %w", err)
+		}
+		if err := r.recordChangeForPlan(tx, sub, change{reason: events.ChangeCancelled, previousStatus: prevStatus}); err != nil {
+			return nil, err
+		}
+	}
+
+	next := &models.UserSubscription{
+		ID:               uuid.New(),
+		UserID:           c.UserID,
+		SubscriptionID:   plan.ID,
+		StartDate:        now,
+		EndDate:          plan.PeriodEnd(now),
+		RemainingQuota:   int(plan.Quotas[models.MeterMessages]),
+		Status:           models.StatusActive,
+		QuotaPeriodStart: now,
+	}
+	next.QuotaResetAt = plan.NextQuotaReset(now, next.EndDate)
+	if err := r.createSubscription(tx, next, plan); err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+// renewSubscription adds a paid period to sub. Paid ahead, the period is
+// appended and the quota resets at the old period end as scheduled; paid
+// after the period ended (past_due), the new period starts where the old
+// one ended, or now if that one is over too, with a fresh quota.
+func (r *PostgresSubscriptionRepository) renewSubscription(tx *sqlx.Tx, sub *models.UserSubscription, plan *models.SubscriptionPlan, now time.Time) error {
+	prevStatus := sub.Status
+	start := sub.StartDate
+	end := plan.PeriodEnd(sub.EndDate)
+
+	if !sub.EndDate.After(now) {
+		if err := closeBillingPeriod(tx, sub, sub.EndDate); err != nil {
+			return err
+		}
+		start, end = sub.EndDate, plan.PeriodEnd(sub.EndDate)
+		if !end.After(now) {
+			start, end = now, plan.PeriodEnd(now)
+		}
+		if _, err := resetQuota(tx, sub, plan, start, plan.NextQuotaReset(start, end), models.ResetRenewal); err != nil {
+			return err
+		}
+	}
+
+	err := tx.Get(sub, `
+		UPDATE user_subscription
+		SET start_date = $2, end_date = $3, status = $4,
+			cancel_at_period_end = FALSE, cancelled_at = NULL, updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+subscriptionColumns,
+		sub.ID, start, end, models.StatusActive)
+	if err != nil {
+		return fmt.Errorf("renew subscription: %w", err)
+	}
+	return r.recordChange(tx, sub, plan, change{reason: events.ChangeRenewed, previousStatus: prevStatus})
+}
@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"subscription_service/internal/models"
+)
+
+// ErrInvoiceNotFound means the user has no invoice with that id.
+var ErrInvoiceNotFound = errors.New("invoice not found")
+
+const invoiceColumns = `id, number, user_id, user_subscription_id, plan_id, plan_name, period_start, period_end,
+	currency, subtotal_cents, total_cents, created_at`
+
+// ListInvoices returns the user's invoices without lines, newest period first.
+func (r *PostgresSubscriptionRepository) ListInvoices(userID uuid.UUID, limit int) ([]models.Invoice, error) {
+	invoices := []models.Invoice{}
+	err := r.db.Select(&invoices, `
+		SELECT `+invoiceColumns+`
+		FROM invoices
+		WHERE user_id = $1
+		ORDER BY period_start DESC
+		LIMIT $2`,
+		userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list invoices: %w", err)
+	}
+	return invoices, nil
+}
+
+// GetInvoice returns one of the user's invoices with its lines.
+func (r *PostgresSubscriptionRepository) GetInvoice(userID, invoiceID uuid.UUID) (*models.Invoice, error) {
+	var inv models.Invoice
+	err := r.db.Get(&inv, `SELECT `+invoiceColumns+` FROM invoices WHERE id = $1 AND user_id = $2`, invoiceID, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvoiceNotFound
+		}
+		return nil, fmt.Errorf("get invoice: %w", err)
+	}
+
+	inv.Lines = []models.InvoiceLine{}
+	err = r.db.Select(&inv.Lines, `
+		SELECT invoice_id, line_no, kind, description, meter, quantity, unit_amount_cents, amount_cents
+		FROM invoice_lines
+		WHERE invoice_id = $1
+		ORDER BY line_no`,
+		inv.ID)
+	if err != nil {
+		return nil, fmt.Errorf("get invoice lines: %w", err)
+	}
+	return &inv, nil
+}
+
+// closeBillingPeriod issues the invoice for sub's billing period from its
+// start date to end: one plan line per checkout paid for the period (or a
+// zero line for free and assigned plans) and one usage line per meter used.
+// A period is invoiced once; later calls are no-ops. Call it before sub's
+// period is moved on.
+func closeBillingPeriod(tx *sqlx.Tx, sub *models.UserSubscription, end time.Time) error {
+	if !end.After(sub.StartDate) {
+		return nil
+	}
+
+	// The subscription row is locked by the caller, so checking first keeps
+	// invoice numbers free of gaps.
+	var exists bool
+	err := tx.Get(&exists, `SELECT EXISTS (SELECT 1 FROM invoices WHERE user_subscription_id = $1 AND period_start = $2)`,
+		sub.ID, sub.StartDate)
+	if err != nil {
+		return fmt.Errorf("check invoice: %w", err)
+	}
+	if exists {
+		return nil
+	}
+
+	plan, err := getPlan(tx, `SELECT `+planColumns+` FROM subscription_plans WHERE id=$1`, sub.SubscriptionID)
+	if err != nil {
+		return err
+	}
+
+	var lines []models.InvoiceLine
+	var paid []struct {
+		PlanName    string `db:"plan_name"`
+		Purpose     string `db:"purpose"`
+		AmountCents int64  `db:"amount_cents"`
+	}
+	err = tx.Select(&paid, `
+		SELECT p.name AS plan_name, c.purpose, c.amount_cents
+		FROM checkouts c
+		JOIN subscription_plans p ON p.id = c.plan_id
+		WHERE c.user_subscription_id = $1 AND c.period_start = $2 AND c.status = $3
+		ORDER BY c.paid_at`,
+		sub.ID, sub.StartDate, models.CheckoutPaid)
+	if err != nil {
+		return fmt.Errorf("get paid checkouts: %w", err)
+	}
+	for _, c := range paid {
+		lines = append(lines, models.InvoiceLine{
+			Kind:            models.LinePlan,
+			Description:     fmt.Sprintf("%s plan (%s)", c.PlanName, c.Purpose),
+			Quantity:        1,
+			UnitAmountCents: c.AmountCents,
+			AmountCents:     c.AmountCents,
+		})
+	}
+	if len(lines) == 0 {
+		lines = append(lines, models.InvoiceLine{
+			Kind:        models.LinePlan,
+			Description: fmt.Sprintf("%s plan", plan.Name),
+			Quantity:    1,
+		})
+	}
+
+	var used []struct {
+		Meter  string `db:"meter"`
+		Amount int64  `db:"amount"`
+	}
+	err = tx.Select(&used, `
+		SELECT meter, SUM(amount)::BIGINT AS amount
+		FROM (
+			SELECT $4::VARCHAR AS meter, amount::BIGINT AS amount
+			FROM usage_ledger
+			WHERE user_subscription_id = $1 AND created_at >= $2 AND created_at < $3
+			UNION ALL
+			SELECT meter, amount
+			FROM meter_ledger
+			WHERE user_subscription_id = $1 AND created_at >= $2 AND created_at < $3
+		) u
+		GROUP BY meter
+		ORDER BY meter`,
+		sub.ID, sub.StartDate, end, models.MeterMessages)
+	if err != nil {
+		return fmt.Errorf("get period usage: %w", err)
+	}
+	for _, u := range used {
+		meter := u.Meter
+		lines = append(lines, models.InvoiceLine{
+			Kind:        models.LineUsage,
+			Description: fmt.Sprintf("Usage: %s (included in plan)", u.Meter),
+			Meter:       &meter,
+			Quantity:    u.Amount,
+		})
+	}
+
+	var subtotal int64
+	for _, l := range lines {
+		subtotal += l.AmountCents
+	}
+
+	invoiceID := uuid.New()
+	_, err = tx.Exec(`
+		INSERT INTO invoices (id, number, user_id, user_subscription_id, plan_id, plan_name,
+			period_start, period_end, currency, subtotal_cents, total_cents)
+		VALUES ($1, 'INV-' || to_char(NOW(), 'YYYY') || '-' || lpad(nextval('invoice_number_seq')::TEXT, 6, '0'),
+			$2, $3, $4, $5, $6, $7, $8, $9, $9)`,
+		invoiceID, sub.UserID, sub.ID, plan.ID, plan.Name, sub.StartDate, end, plan.Currency, subtotal)
+	if err != nil {
+		return fmt.Errorf("create invoice: %w", err)
+	}
+
+	for i, l := range lines {
+		_, err := tx.Exec(`
+			INSERT INTO invoice_lines (invoice_id, line_no, kind, description, meter, quantity, unit_amount_cents, amount_cents)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+			invoiceID, i+1, l.Kind, l.Description, l.Meter, l.Quantity, l.UnitAmountCents, l.AmountCents)
+		if err != nil {
+			return fmt.Errorf("create invoice line: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,330 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"subscription_service/internal/models"
+
+	"contracts/events"
+	"contracts/outbox"
+)
+
+// change describes why a subscription changed, for its event.
+type change struct {
+	reason         string
+	previousPlanID uuid.UUID
+	previousStatus string
+	prorationCents int64
+}
+
+// ChangePlan moves the user's current subscription to plan for the rest of
+// the period. The message quota and price are prorated by the share of the
+// period left: an upgrade adds part of the extra quota, a downgrade removes
+// part of it (never below zero). An upgrade that costs money returns
+// ErrPaymentRequired unless paid says the difference was collected (or is
+// waived). It returns the updated subscription and the prorated price
+// difference in cents (negative is a credit).
+func (r *PostgresSubscriptionRepository) ChangePlan(userID uuid.UUID, plan *models.SubscriptionPlan, paid bool) (*models.UserSubscription, int64, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return nil, 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	sub, proration, err := r.changePlan(tx, userID, plan, paid)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, 0, fmt.Errorf("commit tx: %w", err)
+	}
+	return sub, proration, nil
+}
+
+func (r *PostgresSubscriptionRepository) changePlan(tx *sqlx.Tx, userID uuid.UUID, plan *models.SubscriptionPlan, paid bool) (*models.UserSubscription, int64, error) {
+	sub, err := currentForUpdate(tx, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if sub.Status != models.StatusTrialing && sub.Status != models.StatusActive {
+		return nil, 0, fmt.Errorf("%w: %s", ErrInvalidTransition, sub.Status)
+	}
+	if sub.SubscriptionID == plan.ID {
+		return nil, 0, fmt.Errorf("%w: already on plan %s", ErrInvalidTransition, plan.Name)
+	}
+
+	oldPlan, err := getPlan(tx, `SELECT `+planColumns+` FROM subscription_plans WHERE id=$1`, sub.SubscriptionID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	left := sub.PeriodLeft(time.Now().UTC())
+	quotaDelta := models.Prorate(plan.Quotas[models.MeterMessages]-oldPlan.Quotas[models.MeterMessages], left)
+	remaining := int64(sub.RemainingQuota) + quotaDelta
+	if remaining < 0 {
+		remaining = 0
+	}
+	// Nothing is charged for the rest of a trial.
+	var proration int64
+	if sub.Status == models.StatusActive {
+		proration = models.Prorate(plan.PriceCents-oldPlan.PriceCents, left)
+	}
+	if proration > 0 && !paid {
+		return nil, 0, fmt.Errorf("%w: %d %s", ErrPaymentRequired, proration, plan.Currency)
+	}
+
+	prev := *sub
+	err = tx.Get(sub, `
+		UPDATE user_subscription
+		SET subscription_id = $2, remaining_quota = $3, updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+subscriptionColumns,
+		sub.ID, plan.ID, remaining)
+	if err != nil {
+		return nil, 0, fmt.Errorf("change plan: %w", err)
+	}
+
+	err = r.recordChange(tx, sub, plan, change{
+		reason:         events.ChangePlanChanged,
+		previousPlanID: prev.SubscriptionID,
+		prorationCents: proration,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return sub, proration, nil
+}
+
+// CancelSubscription cancels the user's current subscription, either right
+// away or at the end of the period. Scheduling an already scheduled
+// cancellation is a no-op.
+func (r *PostgresSubscriptionRepository) CancelSubscription(userID uuid.UUID, atPeriodEnd bool) (*models.UserSubscription, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	sub, err := currentForUpdate(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if atPeriodEnd && sub.CancelAtPeriodEnd {
+		return sub, nil
+	}
+
+	prevStatus := sub.Status
+	var c change
+	if atPeriodEnd {
+		err = tx.Get(sub, `
+			UPDATE user_subscription
+			SET cancel_at_period_end = TRUE, cancelled_at = NOW(), updated_at = NOW()
+			WHERE id = $1
+			RETURNING `+subscriptionColumns, sub.ID)
+		c = change{reason: events.ChangeCancelScheduled}
+	} else {
+		if err := closeBillingPeriod(tx, sub, time.Now().UTC()); err != nil {
+			return nil, err
+		}
+		err = tx.Get(sub, `
+			UPDATE user_subscription
+			SET status = $2, cancelled_at = COALESCE(cancelled_at, NOW()), ended_at = NOW(), updated_at = NOW()
+			WHERE id = $1
+			RETURNING `+subscriptionColumns, sub.ID, models.StatusCancelled)
+		c = change{reason: events.ChangeCancelled, previousStatus: prevStatus}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cancel subscription: %w", err)
+	}
+
+	if err := r.recordChangeForPlan(tx, sub, c); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+	return sub, nil
+}
+
+// ResumeSubscription revokes a scheduled cancellation. It is a no-op when no
+// cancellation is scheduled.
+func (r *PostgresSubscriptionRepository) ResumeSubscription(userID uuid.UUID) (*models.UserSubscription, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	sub, err := currentForUpdate(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !sub.CancelAtPeriodEnd {
+		return sub, nil
+	}
+
+	err = tx.Get(sub, `
+		UPDATE user_subscription
+		SET cancel_at_period_end = FALSE, cancelled_at = NULL, updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+subscriptionColumns, sub.ID)
+	if err != nil {
+		return nil, fmt.Errorf("resume subscription: %w", err)
+	}
+
+	if err := r.recordChangeForPlan(tx, sub, change{reason: events.ChangeCancelRevoked}); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+	return sub, nil
+}
+
+// AdvanceLifecycle moves up to limit subscriptions whose period is over to
+// their next status and records an event for each:
+//   - cancellation scheduled → cancelled
+//   - paid plan → past_due, then expired once grace has passed unpaid
+//
+// Free plans that aren't cancelled renew instead, see ResetDueQuotas. The
+// period that ended is invoiced; expiry also publishes a quota_alert.
+//
+// Rows are locked with SKIP LOCKED so several instances can run it. It
+// returns the number of subscriptions moved.
+func (r *PostgresSubscriptionRepository) AdvanceLifecycle(grace time.Duration, limit int) (int, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var due []struct {
+		models.UserSubscription
+		PriceCents int64 `db:"price_cents"`
+	}
+	err = tx.Select(&due, `
+		SELECT us.id, us.user_id, us.subscription_id, us.start_date, us.end_date, us.remaining_quota,
+			us.status, us.cancel_at_period_end, us.cancelled_at, us.ended_at, us.quota_period_start, us.quota_reset_at,
+			us.created_at, us.updated_at,
+			p.price_cents
+		FROM user_subscription us
+		JOIN subscription_plans p ON p.id = us.subscription_id
+		WHERE (us.status IN ('trialing', 'active') AND us.end_date < NOW()
+		       AND (p.price_cents > 0 OR us.cancel_at_period_end))
+		   OR (us.status = 'past_due' AND us.end_date < NOW() - make_interval(secs => $1))
+		ORDER BY us.end_date
+		LIMIT $2
+		FOR UPDATE OF us SKIP LOCKED`,
+		grace.Seconds(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("select due subscriptions: %w", err)
+	}
+
+	for i := range due {
+		sub := &due[i].UserSubscription
+		prevStatus := sub.Status
+
+		next, reason := models.StatusExpired, events.ChangeExpired
+		switch {
+		case sub.CancelAtPeriodEnd:
+			next, reason = models.StatusCancelled, events.ChangeCancelled
+		case sub.Status != models.StatusPastDue && due[i].PriceCents > 0:
+			next, reason = models.StatusPastDue, events.ChangePastDue
+		}
+
+		if err := closeBillingPeriod(tx, sub, sub.EndDate); err != nil {
+			return 0, err
+		}
+
+		endedAt := "NOW()"
+		if next == models.StatusPastDue {
+			endedAt = "NULL"
+		}
+		err := tx.Get(sub, `
+			UPDATE user_subscription
+			SET status = $2, ended_at = `+endedAt+`, updated_at = NOW()
+			WHERE id = $1
+			RETURNING `+subscriptionColumns, sub.ID, next)
+		if err != nil {
+			return 0, fmt.Errorf("advance subscription %s: %w", sub.ID, err)
+		}
+
+		if err := r.recordChangeForPlan(tx, sub, change{reason: reason, previousStatus: prevStatus}); err != nil {
+			return 0, err
+		}
+		if next == models.StatusExpired {
+			if err := r.publishExpiryAlert(tx, sub); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit tx: %w", err)
+	}
+	return len(due), nil
+}
+
+// currentForUpdate locks and returns the user's current subscription.
+func currentForUpdate(tx *sqlx.Tx, userID uuid.UUID) (*models.UserSubscription, error) {
+	var sub models.UserSubscription
+	query := `SELECT ` + subscriptionColumns + `
+			  FROM user_subscription
+			  WHERE user_id=$1 AND status IN ` + currentStatuses + `
+			  FOR UPDATE`
+	if err := tx.Get(&sub, query, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoActiveSubscription
+		}
+		return nil, fmt.Errorf("get current subscription: %w", err)
+	}
+	return &sub, nil
+}
+
+// recordChangeForPlan loads the subscription's plan and records the change.
+func (r *PostgresSubscriptionRepository) recordChangeForPlan(tx *sqlx.Tx, sub *models.UserSubscription, c change) error {
+	plan, err := getPlan(tx, `SELECT `+planColumns+` FROM subscription_plans WHERE id=$1`, sub.SubscriptionID)
+	if err != nil {
+		return err
+	}
+	return r.recordChange(tx, sub, plan, c)
+}
+
+// recordChange writes a subscription_changed event to the outbox in tx.
+func (r *PostgresSubscriptionRepository) recordChange(tx *sqlx.Tx, sub *models.UserSubscription, plan *models.SubscriptionPlan, c change) error {
+	evt := &events.SubscriptionChanged{
+		UserID:            sub.UserID.String(),
+		SubscriptionID:    sub.ID.String(),
+		PlanID:            plan.ID.String(),
+		PlanName:          plan.Name,
+		Status:            sub.Status,
+		PreviousStatus:    c.previousStatus,
+		Reason:            c.reason,
+		CurrentPeriodEnd:  sub.EndDate.UTC().Format(time.RFC3339),
+		CancelAtPeriodEnd: sub.CancelAtPeriodEnd,
+		RemainingQuota:    sub.RemainingQuota,
+		ProrationCents:    c.prorationCents,
+	}
+	if c.previousPlanID != uuid.Nil {
+		evt.PreviousPlanID = c.previousPlanID.String()
+	}
+	if c.prorationCents != 0 {
+		evt.Currency = plan.Currency
+	}
+
+	env, err := events.New(events.ProducerSubscription, sub.ID.String(), evt)
+	if err != nil {
+		return fmt.Errorf("build subscription_changed: %w", err)
+	}
+	return outbox.Insert(tx, outbox.Message{
+		Topic:    r.changedTopic,
+		Key:      sub.UserID.String(),
+		Envelope: env,
+	})
+}
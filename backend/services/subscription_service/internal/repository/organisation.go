@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"subscription_service/internal/models"
+
+	"contracts/events"
+)
+
+var (
+	// ErrNotOrganisationMember means the user is not a member of the
+	// organisation.
+	ErrNotOrganisationMember = errors.New("user is not a member of the organisation")
+	// ErrMemberCapExceeded means the member used up the share of the
+	// organisation's quota an owner or admin capped them at.
+	ErrMemberCapExceeded = errors.New("member message cap reached")
+)
+
+const memberColumns = `user_id, organisation_id, role, message_cap, updated_at`
+
+// ApplyMemberChange mirrors an org_member_changed event. Joining another
+// organisation moves the user and drops the old cap; a removal only applies
+// to the organisation it names, so a late removal from a previous
+// organisation doesn't undo a newer membership.
+func (r *PostgresSubscriptionRepository) ApplyMemberChange(orgID, userID uuid.UUID, role, action string) error {
+	var err error
+	if action == events.MemberRemoved {
+		_, err = r.db.Exec(`DELETE FROM organisation_members WHERE user_id = $1 AND organisation_id = $2`, userID, orgID)
+	} else {
+		_, err = r.db.Exec(`
+			INSERT INTO organisation_members (user_id, organisation_id, role, updated_at)
+			VALUES ($1, $2, $3, NOW())
+			ON CONFLICT (user_id) DO UPDATE SET
+				role = EXCLUDED.role,
+				message_cap = CASE WHEN organisation_members.organisation_id = EXCLUDED.organisation_id
+					THEN organisation_members.message_cap END,
+				organisation_id = EXCLUDED.organisation_id,
+				updated_at = NOW()`,
+			userID, orgID, role)
+	}
+	if err != nil {
+		return fmt.Errorf("apply member change: %w", err)
+	}
+	return nil
+}
+
+// DeleteOrganisationMembers drops every membership of the organisation and
+// returns how many there were.
+func (r *PostgresSubscriptionRepository) DeleteOrganisationMembers(orgID uuid.UUID) (int, error) {
+	res, err := r.db.Exec(`DELETE FROM organisation_members WHERE organisation_id = $1`, orgID)
+	if err != nil {
+		return 0, fmt.Errorf("delete organisation members: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// GetOrganisationMember returns the user's membership, or
+// ErrNotOrganisationMember.
+func (r *PostgresSubscriptionRepository) GetOrganisationMember(userID uuid.UUID) (*models.OrganisationMember, error) {
+	var m models.OrganisationMember
+	if err := r.db.Get(&m, `SELECT `+memberColumns+` FROM organisation_members WHERE user_id = $1`, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotOrganisationMember
+		}
+		return nil, fmt.Errorf("get organisation member: %w", err)
+	}
+	return &m, nil
+}
+
+// ListMemberUsage returns the organisation's members with what each used of
+// the pool in the current quota period, held reservations included.
+func (r *PostgresSubscriptionRepository) ListMemberUsage(orgID uuid.UUID) ([]models.MemberUsage, error) {
+	members := []models.MemberUsage{}
+	err := r.db.Select(&members, `
+		SELECT m.user_id, m.organisation_id, m.role, m.message_cap, m.updated_at,
+			COALESCE((SELECT SUM(l.amount) FROM usage_ledger l
+			          WHERE l.user_subscription_id = s.id AND l.user_id = m.user_id
+			            AND l.created_at >= s.quota_period_start), 0)
+			+ COALESCE((SELECT SUM(q.amount) FROM quota_reservations q
+			            WHERE q.user_subscription_id = s.id AND q.user_id = m.user_id AND q.status = $2
+			              AND q.created_at >= s.quota_period_start), 0) AS used
+		FROM organisation_members m
+		LEFT JOIN user_subscription s ON s.user_id = m.organisation_id AND s.status IN `+currentStatuses+`
+		WHERE m.organisation_id = $1
+		ORDER BY CASE m.role WHEN 'owner' THEN 0 WHEN 'admin' THEN 1 ELSE 2 END, m.user_id`,
+		orgID, models.ReservationReserved)
+	if err != nil {
+		return nil, fmt.Errorf("list member usage: %w", err)
+	}
+	return members, nil
+}
+
+// SetMemberCap sets or, with nil, removes the member's message cap.
+func (r *PostgresSubscriptionRepository) SetMemberCap(orgID, userID uuid.UUID, messageCap *int) (*models.OrganisationMember, error) {
+	var m models.OrganisationMember
+	err := r.db.Get(&m, `
+		UPDATE organisation_members SET message_cap = $3, updated_at = NOW()
+		WHERE user_id = $1 AND organisation_id = $2
+		RETURNING `+memberColumns,
+		userID, orgID, messageCap)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotOrganisationMember
+		}
+		return nil, fmt.Errorf("set member cap: %w", err)
+	}
+	return &m, nil
+}
+
+// GetEffectiveQuota returns how many messages the user can still send: the
+// remaining quota of the subscription they are charged to, limited by their
+// member cap.
+func (r *PostgresSubscriptionRepository) GetEffectiveQuota(userID uuid.UUID) (int, error) {
+	subID, messageCap, err := billingSubscription(r.db, userID)
+	if err != nil {
+		return 0, err
+	}
+	var remaining int
+	if err := r.db.Get(&remaining, `SELECT remaining_quota FROM user_subscription WHERE id = $1`, subID); err != nil {
+		return 0, fmt.Errorf("get remaining quota: %w", err)
+	}
+	if messageCap == nil {
+		return remaining, nil
+	}
+	used, err := memberUsed(r.db, subID, userID)
+	if err != nil {
+		return 0, err
+	}
+	return int(min(int64(remaining), max(int64(*messageCap)-used, 0))), nil
+}
+
+// billingSubscription returns the subscription the user's usage is charged
+// to: the pooled subscription of the user's organisation if it has a current
+// one, otherwise the user's own. messageCap is the member's cap on the pool.
+// Inside a transaction the membership row stays locked, so a member's
+// concurrent charges are checked against the cap one at a time.
+func billingSubscription(q sqlx.Queryer, userID uuid.UUID) (subID uuid.UUID, messageCap *int, err error) {
+	var pooled struct {
+		ID         uuid.UUID `db:"id"`
+		MessageCap *int      `db:"message_cap"`
+	}
+	err = sqlx.Get(q, &pooled, `
+		SELECT s.id, m.message_cap
+		FROM organisation_members m
+		JOIN user_subscription s ON s.user_id = m.organisation_id AND s.status IN `+currentStatuses+`
+		WHERE m.user_id = $1
+		FOR UPDATE OF m`, userID)
+	if err == nil {
+		return pooled.ID, pooled.MessageCap, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, nil, fmt.Errorf("get organisation subscription: %w", err)
+	}
+
+	subID, err = activeSubscriptionID(q, userID)
+	return subID, nil, err
+}
+
+// checkMemberCap returns ErrMemberCapExceeded if the user's usage of
+// subscription subID, including what was just charged, is above messageCap.
+func checkMemberCap(q sqlx.Queryer, subID, userID uuid.UUID, messageCap *int) error {
+	if messageCap == nil {
+		return nil
+	}
+	used, err := memberUsed(q, subID, userID)
+	if err != nil {
+		return err
+	}
+	if used > int64(*messageCap) {
+		return fmt.Errorf("%w: %d messages per period", ErrMemberCapExceeded, *messageCap)
+	}
+	return nil
+}
+
+// memberUsed is what the user consumed or holds of subscription subID in its
+// current quota period.
+func memberUsed(q sqlx.Queryer, subID, userID uuid.UUID) (int64, error) {
+	var used int64
+	err := sqlx.Get(q, &used, `
+		SELECT
+			(SELECT COALESCE(SUM(l.amount), 0) FROM usage_ledger l
+			 WHERE l.user_subscription_id = s.id AND l.user_id = $2 AND l.created_at >= s.quota_period_start)
+			+ (SELECT COALESCE(SUM(q.amount), 0) FROM quota_reservations q
+			 WHERE q.user_subscription_id = s.id AND q.user_id = $2 AND q.status = $3
+			   AND q.created_at >= s.quota_period_start)
+		FROM user_subscription s
+		WHERE s.id = $1`,
+		subID, userID, models.ReservationReserved)
+	if err != nil {
+		return 0, fmt.Errorf("get member usage: %w", err)
+	}
+	return used, nil
+}
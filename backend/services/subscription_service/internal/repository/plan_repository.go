@@ -0,0 +1,211 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"subscription_service/internal/models"
+)
+
+var (
+	// ErrPlanNotFound means no plan exists with the given id or name.
+	ErrPlanNotFound = errors.New("plan not found")
+	// ErrPlanNameTaken means another plan already uses the name.
+	ErrPlanNameTaken = errors.New("plan name already exists")
+)
+
+const planColumns = `id, name, description, price_cents, currency, billing_period, billing_period_count,
+	max_upload_bytes, max_documents, allowed_models, trial_days, quota_reset_period, rollover_cap, is_active, created_at, updated_at, retired_at`
+
+// ListPlans returns plans ordered by price, with their quotas. Retired plans
+// are included only when includeRetired is set.
+func (r *PostgresSubscriptionRepository) ListPlans(includeRetired bool) ([]models.SubscriptionPlan, error) {
+	plans := []models.SubscriptionPlan{}
+	query := `SELECT ` + planColumns + ` FROM subscription_plans
+			  WHERE is_active OR $1
+			  ORDER BY price_cents, name`
+	if err := r.db.Select(&plans, query, includeRetired); err != nil {
+		return nil, fmt.Errorf("list plans: %w", err)
+	}
+
+	for i := range plans {
+		if err := loadQuotas(r.db, &plans[i]); err != nil {
+			return nil, err
+		}
+	}
+	return plans, nil
+}
+
+// GetPlanByID returns a plan, retired or not, with its quotas.
+func (r *PostgresSubscriptionRepository) GetPlanByID(id uuid.UUID) (*models.SubscriptionPlan, error) {
+	return getPlan(r.db, `SELECT `+planColumns+` FROM subscription_plans WHERE id=$1`, id)
+}
+
+// CreatePlan inserts plan and its quotas. ID and timestamps are set on plan.
+func (r *PostgresSubscriptionRepository) CreatePlan(plan *models.SubscriptionPlan) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	plan.ID = uuid.New()
+	plan.Active = true
+	query := `
+		INSERT INTO subscription_plans (id, name, description, price_cents, currency, billing_period,
+			billing_period_count, max_upload_bytes, max_documents, allowed_models, trial_days,
+			quota_reset_period, rollover_cap)
+		VALUES (:id, :name, :description, :price_cents, :currency, :billing_period,
+			:billing_period_count, :max_upload_bytes, :max_documents, :allowed_models, :trial_days,
+			:quota_reset_period, :rollover_cap)
+		RETURNING created_at, updated_at`
+	if err := namedGet(tx, plan, query); err != nil {
+		return planWriteError("create plan", err)
+	}
+
+	if err := saveQuotas(tx, plan); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+// UpdatePlan overwrites the definition and quotas of an existing plan.
+// Running subscriptions keep the quota they were assigned.
+func (r *PostgresSubscriptionRepository) UpdatePlan(plan *models.SubscriptionPlan) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE subscription_plans
+		SET name = :name, description = :description, price_cents = :price_cents, currency = :currency,
+			billing_period = :billing_period, billing_period_count = :billing_period_count,
+			max_upload_bytes = :max_upload_bytes, max_documents = :max_documents,
+			allowed_models = :allowed_models, trial_days = :trial_days,
+			quota_reset_period = :quota_reset_period, rollover_cap = :rollover_cap, updated_at = NOW()
+		WHERE id = :id
+		RETURNING is_active, created_at, updated_at, retired_at`
+	if err := namedGet(tx, plan, query); err != nil {
+		return planWriteError("update plan", err)
+	}
+
+	if err := saveQuotas(tx, plan); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+// RetirePlan hides a plan from the catalogue and stops new assignments.
+// Retiring twice keeps the original retired_at.
+func (r *PostgresSubscriptionRepository) RetirePlan(id uuid.UUID) (*models.SubscriptionPlan, error) {
+	_, err := r.db.Exec(`
+		UPDATE subscription_plans
+		SET is_active = FALSE, retired_at = COALESCE(retired_at, NOW()), updated_at = NOW()
+		WHERE id = $1`, id)
+	if err != nil {
+		return nil, fmt.Errorf("retire plan: %w", err)
+	}
+	return r.GetPlanByID(id)
+}
+
+func getPlan(q sqlx.Queryer, query string, arg interface{}) (*models.SubscriptionPlan, error) {
+	var plan models.SubscriptionPlan
+	if err := sqlx.Get(q, &plan, query, arg); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPlanNotFound
+		}
+		return nil, fmt.Errorf("get plan: %w", err)
+	}
+	if err := loadQuotas(q, &plan); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// loadQuotas fills plan.Quotas from subscription_quotas and plan_meter_limits.
+func loadQuotas(q sqlx.Queryer, plan *models.SubscriptionPlan) error {
+	var limits []models.MeterLimit
+	err := sqlx.Select(q, &limits, `
+		SELECT subscription_id AS plan_id, $2::VARCHAR AS meter, quota::BIGINT AS limit_amount
+		FROM subscription_quotas WHERE subscription_id = $1
+		UNION ALL
+		SELECT plan_id, meter, limit_amount
+		FROM plan_meter_limits WHERE plan_id = $1`,
+		plan.ID, models.MeterMessages)
+	if err != nil {
+		return fmt.Errorf("get plan quotas: %w", err)
+	}
+
+	plan.Quotas = make(map[string]int64, len(limits))
+	for _, l := range limits {
+		plan.Quotas[l.Meter] = l.Limit
+	}
+	return nil
+}
+
+// saveQuotas replaces the quotas of plan with plan.Quotas.
+func saveQuotas(tx *sqlx.Tx, plan *models.SubscriptionPlan) error {
+	if _, err := tx.Exec(`DELETE FROM subscription_quotas WHERE subscription_id = $1`, plan.ID); err != nil {
+		return fmt.Errorf("clear plan quota: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM plan_meter_limits WHERE plan_id = $1`, plan.ID); err != nil {
+		return fmt.Errorf("clear plan meter limits: %w", err)
+	}
+
+	for meter, limit := range plan.Quotas {
+		var err error
+		if meter == models.MeterMessages {
+			_, err = tx.Exec(`INSERT INTO subscription_quotas (id, subscription_id, quota) VALUES ($1, $2, $3)`,
+				uuid.New(), plan.ID, limit)
+		} else {
+			_, err = tx.Exec(`INSERT INTO plan_meter_limits (plan_id, meter, limit_amount) VALUES ($1, $2, $3)`,
+				plan.ID, meter, limit)
+		}
+		if err != nil {
+			return fmt.Errorf("save %s quota: %w", meter, err)
+		}
+	}
+	return nil
+}
+
+// namedGet runs a named query returning one row and scans it into arg.
+func namedGet(tx *sqlx.Tx, arg interface{}, query string) error {
+	rows, err := tx.NamedQuery(query, arg)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	return rows.StructScan(arg)
+}
+
+func planWriteError(op string, err error) error {
+	var pqErr *pq.Error
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrPlanNotFound
+	case errors.As(err, &pqErr) && pqErr.Code == "23505":
+		return ErrPlanNameTaken
+	default:
+		return fmt.Errorf("%s: %w", op, err)
+	}
+}
@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"subscription_service/internal/models"
+
+	"contracts/events"
+	"contracts/outbox"
+)
+
+// checkQuotaAlerts records every alert threshold the given meters of
+// subscription subID crossed in its current quota period and publishes a
+// quota_alert for the highest one newly crossed per meter. Meters without a
+// limit are skipped. The messages meter counts what was consumed or is held
+// against everything available in the period, so it reaches 100% exactly
+// when the remaining quota is gone.
+func (r *PostgresSubscriptionRepository) checkQuotaAlerts(tx *sqlx.Tx, subID uuid.UUID, meters []string) error {
+	if len(r.alertThresholds) == 0 || len(meters) == 0 {
+		return nil
+	}
+
+	var sub models.UserSubscription
+	if err := tx.Get(&sub, `SELECT `+subscriptionColumns+` FROM user_subscription WHERE id = $1`, subID); err != nil {
+		return fmt.Errorf("get subscription: %w", err)
+	}
+	plan, err := getPlan(tx, `SELECT `+planColumns+` FROM subscription_plans WHERE id=$1`, sub.SubscriptionID)
+	if err != nil {
+		return err
+	}
+
+	for _, meter := range meters {
+		var used, limit int64
+		if meter == models.MeterMessages {
+			err = tx.Get(&used, `
+				SELECT
+					(SELECT COALESCE(SUM(amount), 0) FROM usage_ledger
+					 WHERE user_subscription_id = $1 AND created_at >= $2)
+					+ (SELECT COALESCE(SUM(amount), 0) FROM quota_reservations
+					 WHERE user_subscription_id = $1 AND status = $3 AND created_at >= $2)`,
+				sub.ID, sub.QuotaPeriodStart, models.ReservationReserved)
+			limit = used + int64(sub.RemainingQuota)
+		} else {
+			var ok bool
+			if limit, ok = plan.Quotas[meter]; !ok {
+				continue
+			}
+			err = tx.Get(&used, `
+				SELECT COALESCE((SELECT used FROM subscription_meter_usage
+				                 WHERE user_subscription_id = $1 AND meter = $2), 0)`,
+				sub.ID, meter)
+		}
+		if err != nil {
+			return fmt.Errorf("get %s usage: %w", meter, err)
+		}
+		if limit <= 0 {
+			continue
+		}
+
+		crossed := 0
+		for _, threshold := range r.alertThresholds {
+			if used*100 < int64(threshold)*limit {
+				break
+			}
+			res, err := tx.Exec(`
+				INSERT INTO quota_alerts (user_subscription_id, period_start, meter, threshold, used, limit_amount)
+				VALUES ($1, $2, $3, $4, $5, $6)
+				ON CONFLICT DO NOTHING`,
+				sub.ID, sub.QuotaPeriodStart, meter, threshold, used, limit)
+			if err != nil {
+				return fmt.Errorf("record quota alert: %w", err)
+			}
+			if n, _ := res.RowsAffected(); n > 0 {
+				crossed = threshold
+			}
+		}
+		if crossed == 0 {
+			continue
+		}
+
+		err = r.publishQuotaAlert(tx, &events.QuotaAlert{
+			UserID:           sub.UserID.String(),
+			SubscriptionID:   sub.ID.String(),
+			PlanName:         plan.Name,
+			Kind:             events.AlertThreshold,
+			Meter:            meter,
+			ThresholdPercent: crossed,
+			Used:             used,
+			Limit:            limit,
+			PeriodEnd:        sub.QuotaResetAt.UTC().Format(time.RFC3339),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// publishExpiryAlert publishes a quota_alert for a subscription that expired.
+func (r *PostgresSubscriptionRepository) publishExpiryAlert(tx *sqlx.Tx, sub *models.UserSubscription) error {
+	plan, err := getPlan(tx, `SELECT `+planColumns+` FROM subscription_plans WHERE id=$1`, sub.SubscriptionID)
+	if err != nil {
+		return err
+	}
+	return r.publishQuotaAlert(tx, &events.QuotaAlert{
+		UserID:         sub.UserID.String(),
+		SubscriptionID: sub.ID.String(),
+		PlanName:       plan.Name,
+		Kind:           events.AlertExpired,
+		PeriodEnd:      sub.EndDate.UTC().Format(time.RFC3339),
+	})
+}
+
+// publishQuotaAlert writes a quota_alert event to the outbox in tx. Alerts of
+// an organisation's subscription go to each of its owners and admins.
+func (r *PostgresSubscriptionRepository) publishQuotaAlert(tx *sqlx.Tx, alert *events.QuotaAlert) error {
+	var managers []string
+	err := tx.Select(&managers, `
+		SELECT user_id FROM organisation_members
+		WHERE organisation_id = $1 AND role IN ($2, $3)
+		ORDER BY user_id`,
+		alert.UserID, events.RoleOwner, events.RoleAdmin)
+	if err != nil {
+		return fmt.Errorf("get organisation managers: %w", err)
+	}
+	if len(managers) == 0 {
+		return r.insertQuotaAlert(tx, alert)
+	}
+
+	for _, userID := range managers {
+		a := *alert
+		a.OrganisationID = alert.UserID
+		a.UserID = userID
+		if err := r.insertQuotaAlert(tx, &a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *PostgresSubscriptionRepository) insertQuotaAlert(tx *sqlx.Tx, alert *events.QuotaAlert) error {
+	env, err := events.New(events.ProducerSubscription, alert.SubscriptionID, alert)
+	if err != nil {
+		return fmt.Errorf("build quota_alert: %w", err)
+	}
+	return outbox.Insert(tx, outbox.Message{
+		Topic:    r.alertTopic,
+		Key:      alert.UserID,
+		Envelope: env,
+	})
+}
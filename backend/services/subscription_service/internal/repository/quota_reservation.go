@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"subscription_service/internal/models"
+)
+
+// activeSubscriptionID returns the user's current subscription.
+func activeSubscriptionID(q sqlx.Queryer, userID uuid.UUID) (uuid.UUID, error) {
+	var subID uuid.UUID
+	query := `SELECT id FROM user_subscription WHERE user_id=$1 AND status IN ` + currentStatuses
+	if err := sqlx.Get(q, &subID, query, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return uuid.Nil, ErrNoActiveSubscription
+		}
+		return uuid.Nil, fmt.Errorf("get active subscription: %w", err)
+	}
+	return subID, nil
+}
+
+// ReserveQuota deducts amount from the subscription the user is charged to
+// (see billingSubscription) and holds it
+// under reservationID until it is committed, released or expires after ttl.
+// Reserving an id twice returns the existing reservation without deducting
+// again. It also returns the quota left after the hold.
+func (r *PostgresSubscriptionRepository) ReserveQuota(reservationID, userID uuid.UUID, amount int, ttl time.Duration) (*models.QuotaReservation, int, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return nil, 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	subID, messageCap, err := billingSubscription(tx, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	res := &models.QuotaReservation{}
+	err = tx.Get(res, `
+		INSERT INTO quota_reservations (id, user_id, user_subscription_id, amount, status, expires_at)
+		VALUES ($1, $2, $3, $4, $5, NOW() + make_interval(secs => $6))
+		ON CONFLICT (id) DO NOTHING
+		RETURNING id, user_id, user_subscription_id, amount, status, expires_at, created_at, updated_at`,
+		reservationID, userID, subID, amount, models.ReservationReserved, ttl.Seconds())
+	if errors.Is(err, sql.ErrNoRows) {
+		// Retried reserve: report the reservation that already exists.
+		if err := tx.Get(res, `SELECT id, user_id, user_subscription_id, amount, status, expires_at, created_at, updated_at
+			FROM quota_reservations WHERE id=$1`, reservationID); err != nil {
+			return nil, 0, fmt.Errorf("get reservation: %w", err)
+		}
+		var remaining int
+		if err := tx.Get(&remaining, `SELECT remaining_quota FROM user_subscription WHERE id=$1`, res.UserSubscriptionID); err != nil {
+			return nil, 0, fmt.Errorf("get remaining quota: %w", err)
+		}
+		return res, remaining, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("insert reservation: %w", err)
+	}
+
+	if err := checkMemberCap(tx, subID, userID, messageCap); err != nil {
+		return nil, 0, err
+	}
+
+	// Token usage is only known after the call, so a turn is refused once
+	// any chat meter has reached its limit.
+	meter, err := exhaustedMeter(tx, subID, models.ChatMeters)
+	if err != nil {
+		return nil, 0, err
+	}
+	if meter != "" {
+		return nil, 0, fmt.Errorf("%w: %s limit reached", ErrQuotaExceeded, meter)
+	}
+
+	var remaining int
+	err = tx.Get(&remaining, `
+		UPDATE user_subscription
+		SET remaining_quota = remaining_quota - $2
+		WHERE id = $1 AND remaining_quota >= $2
+		RETURNING remaining_quota`,
+		subID, amount)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, 0, ErrQuotaExceeded
+		}
+		return nil, 0, fmt.Errorf("hold quota: %w", err)
+	}
+	if err := r.checkQuotaAlerts(tx, subID, []string{models.MeterMessages}); err != nil {
+		return nil, 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, 0, fmt.Errorf("commit tx: %w", err)
+	}
+	return res, remaining, nil
+}
+
+// CommitReservation turns the held quota into a usage ledger entry keyed by
+// the reservation id. Committing twice is a no-op.
+func (r *PostgresSubscriptionRepository) CommitReservation(reservationID uuid.UUID) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	committed, err := commitReserved(tx, reservationID, "reservation")
+	if err != nil {
+		return err
+	}
+	if !committed {
+		return reservationState(tx, reservationID, models.ReservationCommitted)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+// ReleaseReservation gives the held quota back. Releasing a reservation that
+// was already released or expired is a no-op. Quota held before the last
+// quota reset isn't refunded; it belonged to the previous period.
+func (r *PostgresSubscriptionRepository) ReleaseReservation(reservationID uuid.UUID) error {
+	res, err := r.db.Exec(`
+		WITH released AS (
+			UPDATE quota_reservations
+			SET status = $2, updated_at = NOW()
+			WHERE id = $1 AND status = $3
+			RETURNING user_subscription_id, amount, created_at
+		)
+		UPDATE user_subscription us
+		SET remaining_quota = us.remaining_quota + released.amount
+		FROM released
+		WHERE us.id = released.user_subscription_id
+		  AND released.created_at >= us.quota_period_start`,
+		reservationID, models.ReservationReleased, models.ReservationReserved)
+	if err != nil {
+		return fmt.Errorf("release reservation: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return reservationState(r.db, reservationID, models.ReservationReleased, models.ReservationExpired)
+	}
+	return nil
+}
+
+// ExpireReservations releases every reservation past its expiry, e.g. when
+// chat_service crashed mid-request. It returns the number of subscriptions
+// that got quota back.
+func (r *PostgresSubscriptionRepository) ExpireReservations() (int, error) {
+	res, err := r.db.Exec(`
+		WITH expired AS (
+			UPDATE quota_reservations
+			SET status = $1, updated_at = NOW()
+			WHERE status = $2 AND expires_at < NOW()
+			RETURNING user_subscription_id, amount, created_at
+		), refunds AS (
+			SELECT e.user_subscription_id, SUM(e.amount) AS amount
+			FROM expired e
+			JOIN user_subscription s ON s.id = e.user_subscription_id
+			WHERE e.created_at >= s.quota_period_start
+			GROUP BY e.user_subscription_id
+		)
+		UPDATE user_subscription us
+		SET remaining_quota = us.remaining_quota + refunds.amount
+		FROM refunds
+		WHERE us.id = refunds.user_subscription_id`,
+		models.ReservationExpired, models.ReservationReserved)
+	if err != nil {
+		return 0, fmt.Errorf("expire reservations: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// commitReserved marks an open reservation committed and writes its ledger
+// entry. It reports false when there is no open reservation with that id.
+func commitReserved(tx *sqlx.Tx, reservationID uuid.UUID, source string) (bool, error) {
+	var held models.QuotaReservation
+	err := tx.Get(&held, `
+		UPDATE quota_reservations
+		SET status = $2, updated_at = NOW()
+		WHERE id = $1 AND status = $3
+		RETURNING id, user_id, user_subscription_id, amount, status, expires_at, created_at, updated_at`,
+		reservationID, models.ReservationCommitted, models.ReservationReserved)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("commit reservation: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO usage_ledger (usage_id, user_id, user_subscription_id, amount, source)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (usage_id) DO NOTHING`,
+		held.ID, held.UserID, held.UserSubscriptionID, held.Amount, source)
+	if err != nil {
+		return false, fmt.Errorf("insert usage: %w", err)
+	}
+	return true, nil
+}
+
+// reservationState maps a reservation that could not change state to an
+// error: nil if it is already in one of the wanted states.
+func reservationState(q sqlx.Queryer, reservationID uuid.UUID, ok ...string) error {
+	var status string
+	if err := sqlx.Get(q, &status, `SELECT status FROM quota_reservations WHERE id=$1`, reservationID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrReservationNotFound
+		}
+		return fmt.Errorf("get reservation: %w", err)
+	}
+	for _, s := range ok {
+		if status == s {
+			return nil
+		}
+	}
+	return ErrReservationClosed
+}
@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"subscription_service/internal/models"
+
+	"contracts/events"
+)
+
+// ResetDueQuotas resets the quota of up to limit trialing or active
+// subscriptions whose quota period is over. A reset inside the billing
+// period only starts a new quota period; one at the end of the billing
+// period also renews free plans that aren't cancelled (paid plans renew on
+// payment, cancelled ones are ended by AdvanceLifecycle). Rows are locked
+// with SKIP LOCKED so several instances can run it. It returns the number of
+// subscriptions reset.
+func (r *PostgresSubscriptionRepository) ResetDueQuotas(limit int) (int, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var due []models.UserSubscription
+	err = tx.Select(&due, `
+		SELECT `+subscriptionColumns+`
+		FROM user_subscription us
+		WHERE us.status IN ('trialing', 'active') AND us.quota_reset_at <= NOW()
+		  AND (us.quota_reset_at < us.end_date
+		       OR (NOT us.cancel_at_period_end AND EXISTS (
+		           SELECT 1 FROM subscription_plans p WHERE p.id = us.subscription_id AND p.price_cents = 0)))
+		ORDER BY us.quota_reset_at
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED`,
+		limit)
+	if err != nil {
+		return 0, fmt.Errorf("select due quota resets: %w", err)
+	}
+
+	for i := range due {
+		sub := &due[i]
+		plan, err := getPlan(tx, `SELECT `+planColumns+` FROM subscription_plans WHERE id=$1`, sub.SubscriptionID)
+		if err != nil {
+			return 0, err
+		}
+
+		at := sub.QuotaResetAt
+		if at.Before(sub.EndDate) {
+			if _, err := resetQuota(tx, sub, plan, at, plan.NextQuotaReset(at, sub.EndDate), models.ResetScheduled); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		// Renewal: the next billing period starts where this one ended.
+		if err := closeBillingPeriod(tx, sub, sub.EndDate); err != nil {
+			return 0, err
+		}
+		prevStatus := sub.Status
+		start := sub.EndDate
+		end := plan.PeriodEnd(start)
+		if _, err := resetQuota(tx, sub, plan, start, plan.NextQuotaReset(start, end), models.ResetRenewal); err != nil {
+			return 0, err
+		}
+		err = tx.Get(sub, `
+			UPDATE user_subscription
+			SET start_date = $2, end_date = $3, status = $4, updated_at = NOW()
+			WHERE id = $1
+			RETURNING `+subscriptionColumns,
+			sub.ID, start, end, models.StatusActive)
+		if err != nil {
+			return 0, fmt.Errorf("renew subscription %s: %w", sub.ID, err)
+		}
+		if err := r.recordChange(tx, sub, plan, change{reason: events.ChangeRenewed, previousStatus: prevStatus}); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit tx: %w", err)
+	}
+	return len(due), nil
+}
+
+// ListQuotaResets returns the user's latest quota resets, newest first.
+func (r *PostgresSubscriptionRepository) ListQuotaResets(userID uuid.UUID, limit int) ([]models.QuotaReset, error) {
+	resets := []models.QuotaReset{}
+	err := r.db.Select(&resets, `
+		SELECT id, user_subscription_id, user_id, plan_id, reason, period_start, period_end,
+			previous_remaining, held, carried_over, forfeited, granted, new_remaining, meter_usage, created_at
+		FROM quota_resets
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2`,
+		userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list quota resets: %w", err)
+	}
+	return resets, nil
+}
+
+// resetQuota starts a new quota period for sub at the given time: the
+// message quota goes back to the plan's, plus unused quota up to the plan's
+// rollover cap, and every meter starts from zero. The reset is recorded in
+// quota_resets with the meter usage of the period that ended; sub is
+// updated in place.
+func resetQuota(tx *sqlx.Tx, sub *models.UserSubscription, plan *models.SubscriptionPlan, at, next time.Time, reason string) (*models.QuotaReset, error) {
+	reset := &models.QuotaReset{
+		ID:                 uuid.New(),
+		UserSubscriptionID: sub.ID,
+		UserID:             sub.UserID,
+		PlanID:             plan.ID,
+		Reason:             reason,
+		PeriodStart:        sub.QuotaPeriodStart,
+		PeriodEnd:          at,
+		PreviousRemaining:  sub.RemainingQuota,
+		Granted:            int(plan.Quotas[models.MeterMessages]),
+	}
+	reset.CarriedOver = max(0, min(sub.RemainingQuota, int(plan.RolloverCap)))
+	reset.Forfeited = max(0, sub.RemainingQuota-reset.CarriedOver)
+	reset.NewRemaining = reset.Granted + reset.CarriedOver
+
+	err := tx.Get(&reset.Held, `
+		SELECT COALESCE(SUM(amount), 0) FROM quota_reservations
+		WHERE user_subscription_id = $1 AND status = $2`,
+		sub.ID, models.ReservationReserved)
+	if err != nil {
+		return nil, fmt.Errorf("get held quota: %w", err)
+	}
+	err = tx.Get(&reset.MeterUsage, `
+		SELECT COALESCE(jsonb_object_agg(meter, used), '{}'::jsonb)
+		FROM subscription_meter_usage WHERE user_subscription_id = $1`,
+		sub.ID)
+	if err != nil {
+		return nil, fmt.Errorf("get meter usage: %w", err)
+	}
+
+	_, err = tx.NamedExec(`
+		INSERT INTO quota_resets (id, user_subscription_id, user_id, plan_id, reason, period_start, period_end,
+			previous_remaining, held, carried_over, forfeited, granted, new_remaining, meter_usage)
+		VALUES (:id, :user_subscription_id, :user_id, :plan_id, :reason, :period_start, :period_end,
+			:previous_remaining, :held, :carried_over, :forfeited, :granted, :new_remaining, :meter_usage)`,
+		reset)
+	if err != nil {
+		return nil, fmt.Errorf("record quota reset: %w", err)
+	}
+
+	if _, err := tx.Exec(`UPDATE subscription_meter_usage SET used = 0 WHERE user_subscription_id = $1`, sub.ID); err != nil {
+		return nil, fmt.Errorf("reset meters: %w", err)
+	}
+	err = tx.Get(sub, `
+		UPDATE user_subscription
+		SET remaining_quota = $2, quota_period_start = $3, quota_reset_at = $4, updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+subscriptionColumns,
+		sub.ID, reset.NewRemaining, at, next)
+	if err != nil {
+		return nil, fmt.Errorf("reset quota: %w", err)
+	}
+	return reset, nil
+}
@@ -1,38 +1,118 @@
 package repository
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 
 	"subscription_service/internal/models"
+	"subscription_service/internal/payment"
+
+	"contracts/events"
 )
 
 type SubscriptionRepository interface {
 	GetPlanByName(name string) (*models.SubscriptionPlan, error)
+	GetPlanByID(id uuid.UUID) (*models.SubscriptionPlan, error)
+	ListPlans(includeRetired bool) ([]models.SubscriptionPlan, error)
+	CreatePlan(plan *models.SubscriptionPlan) error
+	UpdatePlan(plan *models.SubscriptionPlan) error
+	RetirePlan(id uuid.UUID) (*models.SubscriptionPlan, error)
 	GetQuotaByPlanID(planID uuid.UUID) (*models.SubscriptionQuota, error)
-	AssignPlanToUser(userID uuid.UUID, planID uuid.UUID, start, end time.Time) (*models.UserSubscription, error)
-	AssignPlanToUserWithQuota(userID uuid.UUID, planID uuid.UUID, start, end time.Time, quota int) (*models.UserSubscription, error)
+	CreateSubscription(sub *models.UserSubscription, plan *models.SubscriptionPlan) error
 	GetUserSubscription(userID uuid.UUID) (*models.UserSubscription, error)
+	ChangePlan(userID uuid.UUID, plan *models.SubscriptionPlan, paid bool) (*models.UserSubscription, int64, error)
+	CancelSubscription(userID uuid.UUID, atPeriodEnd bool) (*models.UserSubscription, error)
+	ResumeSubscription(userID uuid.UUID) (*models.UserSubscription, error)
+	AdvanceLifecycle(grace time.Duration, limit int) (int, error)
+	ResetDueQuotas(limit int) (int, error)
+	ListQuotaResets(userID uuid.UUID, limit int) ([]models.QuotaReset, error)
+	ApplyMemberChange(orgID, userID uuid.UUID, role, action string) error
+	DeleteOrganisationMembers(orgID uuid.UUID) (int, error)
+	GetOrganisationMember(userID uuid.UUID) (*models.OrganisationMember, error)
+	CloseUserAccount(userID uuid.UUID, deletionID, correlationID string) (int, error)
+	ListMemberUsage(orgID uuid.UUID) ([]models.MemberUsage, error)
+	SetMemberCap(orgID, userID uuid.UUID, messageCap *int) (*models.OrganisationMember, error)
+	GetEffectiveQuota(userID uuid.UUID) (int, error)
+	HasSubscription(userID uuid.UUID) (bool, error)
+	ConsumeQuota(usageID, userID uuid.UUID, amount int, source string) (int, error)
+	ReserveQuota(reservationID, userID uuid.UUID, amount int, ttl time.Duration) (*models.QuotaReservation, int, error)
+	CommitReservation(reservationID uuid.UUID) error
+	ReleaseReservation(reservationID uuid.UUID) error
+	ExpireReservations() (int, error)
+	RecordMeterUsage(usageID, userID uuid.UUID, amounts map[string]int64, source string) error
+	GetMeterUsage(userID uuid.UUID) (*models.PeriodUsage, error)
+	GetDailyUsage(userID uuid.UUID, from, to time.Time) ([]models.DailyUsage, error)
+	ListInvoices(userID uuid.UUID, limit int) ([]models.Invoice, error)
+	GetInvoice(userID, invoiceID uuid.UUID) (*models.Invoice, error)
+	CreateCheckout(c *models.Checkout) error
+	AttachCheckoutSession(id uuid.UUID, sessionID, url string) (*models.Checkout, error)
+	FailCheckout(id uuid.UUID, reason string) error
+	GetCheckout(id uuid.UUID) (*models.Checkout, error)
+	ApplyPaymentEvent(provider string, evt *payment.Event) (*models.Checkout, error)
 }
 
+var (
+	// ErrNoActiveSubscription means the user has no trialing, active or
+	// past_due subscription.
+	ErrNoActiveSubscription = errors.New("no active subscription")
+	// ErrQuotaExceeded means the remaining quota is lower than the amount.
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	// ErrDuplicateUsage means the usage id was already recorded; nothing changed.
+	ErrDuplicateUsage = errors.New("usage already recorded")
+	// ErrReservationNotFound means no reservation exists with the given id.
+	ErrReservationNotFound = errors.New("reservation not found")
+	// ErrReservationClosed means the reservation was released or expired and
+	// can no longer be committed (or was committed and can't be released).
+	ErrReservationClosed = errors.New("reservation already closed")
+	// ErrSubscriptionExists means the user already has a current subscription.
+	ErrSubscriptionExists = errors.New("user already has a subscription")
+	// ErrInvalidTransition means the subscription's status doesn't allow the change.
+	ErrInvalidTransition = errors.New("subscription status does not allow this change")
+	// ErrPaymentRequired means the change costs money and must go through checkout.
+	ErrPaymentRequired = errors.New("payment required")
+)
+
 type PostgresSubscriptionRepository struct {
 	db *sqlx.DB
+	// changedTopic receives subscription_changed events through the outbox.
+	changedTopic string
+	// alertTopic receives quota_alert events for the usage percentages in
+	// alertThresholds (ascending).
+	alertTopic      string
+	alertThresholds []int
+	// purgedTopic receives the user_data_purged report of a deleted user.
+	purgedTopic string
 }
 
-func NewPostgresSubscriptionRepository(db *sqlx.DB) *PostgresSubscriptionRepository {
-	return &PostgresSubscriptionRepository{db: db}
+func NewPostgresSubscriptionRepository(db *sqlx.DB, changedTopic, alertTopic, purgedTopic string, alertThresholds []int) *PostgresSubscriptionRepository {
+	return &PostgresSubscriptionRepository{
+		db:              db,
+		changedTopic:    changedTopic,
+		alertTopic:      alertTopic,
+		alertThresholds: alertThresholds,
+		purgedTopic:     purgedTopic,
+	}
 }
 
+// currentStatuses are the statuses that still grant access; a user has at
+// most one subscription in them (uq_user_subscription_current).
+const currentStatuses = `('trialing', 'active', 'past_due')`
+
+const subscriptionColumns = `id, user_id, subscription_id, start_date, end_date, remaining_quota,
+	status, cancel_at_period_end, cancelled_at, ended_at, quota_period_start, quota_reset_at, created_at, updated_at`
+
 func (r *PostgresSubscriptionRepository) GetPlanByName(name string) (*models.SubscriptionPlan, error) {
-	var plan models.SubscriptionPlan
-	query := `SELECT id, name, description FROM subscription_plans WHERE name=$1 LIMIT 1`
-	if err := r.db.Get(&plan, query, name); err != nil {
+	plan, err := getPlan(r.db, `SELECT `+planColumns+` FROM subscription_plans WHERE name=$1 LIMIT 1`, name)
+	if err != nil {
 		return nil, fmt.Errorf("get plan by name: %w", err)
 	}
-	return &plan, nil
+	return plan, nil
 }
 
 func (r *PostgresSubscriptionRepository) GetQuotaByPlanID(planID uuid.UUID) (*models.SubscriptionQuota, error) {
@@ -44,55 +124,138 @@ func (r *PostgresSubscriptionRepository) GetQuotaByPlanID(planID uuid.UUID) (*mo
 	return &quota, nil
 }
 
-func (r *PostgresSubscriptionRepository) AssignPlanToUser(userID, planID uuid.UUID, start, end time.Time) (*models.UserSubscription, error) {
-	return r.AssignPlanToUserWithQuota(userID, planID, start, end, 0)
-}
-
-func (r *PostgresSubscriptionRepository) AssignPlanToUserWithQuota(userID, planID uuid.UUID, start, end time.Time, quota int) (*models.UserSubscription, error) {
-	sub := &models.UserSubscription{
-		ID:             uuid.New(),
-		UserID:         userID,
-		SubscriptionID: planID,
-		StartDate:      start,
-		EndDate:        end,
-		RemainingQuota: quota,
+// CreateSubscription inserts sub as the user's current subscription and
+// records a subscription_changed event. It returns ErrSubscriptionExists if
+// the user already has one.
+func (r *PostgresSubscriptionRepository) CreateSubscription(sub *models.UserSubscription, plan *models.SubscriptionPlan) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
 	}
+	defer tx.Rollback()
 
-	query := `
-		INSERT INTO user_subscription (id, user_id, subscription_id, start_date, end_date, remaining_quota)
-		VALUES (:id, :user_id, :subscription_id, :start_date, :end_date, :remaining_quota)
-		RETURNING id, start_date, end_date, remaining_quota
-	`
-
-	rows, err := r.db.NamedQuery(query, sub)
-	if err != nil {
-		return nil, fmt.Errorf("assign plan to user: %w", err)
+	if err := r.createSubscription(tx, sub, plan); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
 	}
-	defer rows.Close()
+	return nil
+}
 
-	if rows.Next() {
-		var id uuid.UUID
-		var s, e time.Time
-		var rq int
-		if err := rows.Scan(&id, &s, &e, &rq); err != nil {
-			return nil, fmt.Errorf("scan user_subscription: %w", err)
+func (r *PostgresSubscriptionRepository) createSubscription(tx *sqlx.Tx, sub *models.UserSubscription, plan *models.SubscriptionPlan) error {
+	query := `
+		INSERT INTO user_subscription (id, user_id, subscription_id, start_date, end_date, remaining_quota, status,
+			quota_period_start, quota_reset_at)
+		VALUES (:id, :user_id, :subscription_id, :start_date, :end_date, :remaining_quota, :status,
+			:quota_period_start, :quota_reset_at)
+		RETURNING ` + subscriptionColumns
+	if err := namedGet(tx, sub, query); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrSubscriptionExists
 		}
-		sub.ID = id
-		sub.StartDate = s
-		sub.EndDate = e
-		sub.RemainingQuota = rq
+		return fmt.Errorf("create subscription: %w", err)
 	}
-
-	return sub, nil
+	return r.recordChange(tx, sub, plan, change{reason: events.ChangeCreated})
 }
 
+// GetUserSubscription returns the user's current subscription.
 func (r *PostgresSubscriptionRepository) GetUserSubscription(userID uuid.UUID) (*models.UserSubscription, error) {
 	var sub models.UserSubscription
-	query := `SELECT id, user_id, subscription_id, start_date, end_date, remaining_quota 
-			  FROM user_subscription 
-			  WHERE user_id=$1 AND end_date >= NOW() LIMIT 1`
+	query := `SELECT ` + subscriptionColumns + `
+			  FROM user_subscription
+			  WHERE user_id=$1 AND status IN ` + currentStatuses
 	if err := r.db.Get(&sub, query, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoActiveSubscription
+		}
 		return nil, fmt.Errorf("get user subscription: %w", err)
 	}
 	return &sub, nil
 }
+
+// HasSubscription reports whether the user was ever assigned a plan, expired or not.
+func (r *PostgresSubscriptionRepository) HasSubscription(userID uuid.UUID) (bool, error) {
+	var exists bool
+	query := `SELECT EXISTS (SELECT 1 FROM user_subscription WHERE user_id=$1)`
+	if err := r.db.Get(&exists, query, userID); err != nil {
+		return false, fmt.Errorf("check user subscription: %w", err)
+	}
+	return exists, nil
+}
+
+// ConsumeQuota deducts amount from the subscription the user is charged to
+// (see billingSubscription) and records
+// the deduction in usage_ledger under usageID, in one transaction. The ledger
+// insert runs first so a repeated usageID is detected even when the quota has
+// since run out; the decrement is conditional, so concurrent requests can
+// never push remaining_quota below zero. It returns the remaining quota.
+func (r *PostgresSubscriptionRepository) ConsumeQuota(usageID, userID uuid.UUID, amount int, source string) (int, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Quota held by a reservation with the same id was already deducted;
+	// committing it only records the ledger entry.
+	committed, err := commitReserved(tx, usageID, source)
+	if err != nil {
+		return 0, err
+	}
+	if committed {
+		var remaining int
+		query := `SELECT s.remaining_quota FROM quota_reservations q
+			JOIN user_subscription s ON s.id = q.user_subscription_id WHERE q.id=$1`
+		if err := tx.Get(&remaining, query, usageID); err != nil {
+			return 0, fmt.Errorf("get remaining quota: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return 0, fmt.Errorf("commit tx: %w", err)
+		}
+		return remaining, nil
+	}
+
+	subID, messageCap, err := billingSubscription(tx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := tx.Exec(`
+		INSERT INTO usage_ledger (usage_id, user_id, user_subscription_id, amount, source)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (usage_id) DO NOTHING`,
+		usageID, userID, subID, amount, source)
+	if err != nil {
+		return 0, fmt.Errorf("insert usage: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return 0, ErrDuplicateUsage
+	}
+	if err := checkMemberCap(tx, subID, userID, messageCap); err != nil {
+		return 0, err
+	}
+
+	var remaining int
+	err = tx.Get(&remaining, `
+		UPDATE user_subscription
+		SET remaining_quota = remaining_quota - $2
+		WHERE id = $1 AND remaining_quota >= $2
+		RETURNING remaining_quota`,
+		subID, amount)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrQuotaExceeded
+		}
+		return 0, fmt.Errorf("decrement quota: %w", err)
+	}
+	if err := r.checkQuotaAlerts(tx, subID, []string{models.MeterMessages}); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit tx: %w", err)
+	}
+	return remaining, nil
+}
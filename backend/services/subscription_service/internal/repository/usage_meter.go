@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"subscription_service/internal/models"
+)
+
+// RecordMeterUsage adds the amount of each meter to the subscription the
+// user is charged to and writes one meter_ledger row per (usageID, meter). Meters
+// already recorded under usageID are skipped; if nothing new was recorded it
+// returns ErrDuplicateUsage. Usage is recorded even past the plan limit
+// because the work was already done; the limit blocks the next reservation.
+func (r *PostgresSubscriptionRepository) RecordMeterUsage(usageID, userID uuid.UUID, amounts map[string]int64, source string) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	subID, _, err := billingSubscription(tx, userID)
+	if err != nil {
+		return err
+	}
+
+	// Fixed order so concurrent writers lock usage rows the same way.
+	meters := make([]string, 0, len(amounts))
+	for m, amount := range amounts {
+		if amount > 0 {
+			meters = append(meters, m)
+		}
+	}
+	sort.Strings(meters)
+
+	var recorded []string
+	for _, meter := range meters {
+		res, err := tx.Exec(`
+			INSERT INTO meter_ledger (usage_id, meter, user_id, user_subscription_id, amount, source)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (usage_id, meter) DO NOTHING`,
+			usageID, meter, userID, subID, amounts[meter], source)
+		if err != nil {
+			return fmt.Errorf("insert meter usage: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			continue
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO subscription_meter_usage (user_subscription_id, meter, used)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (user_subscription_id, meter)
+			DO UPDATE SET used = subscription_meter_usage.used + EXCLUDED.used`,
+			subID, meter, amounts[meter])
+		if err != nil {
+			return fmt.Errorf("update meter usage: %w", err)
+		}
+		recorded = append(recorded, meter)
+	}
+
+	if len(meters) > 0 && len(recorded) == 0 {
+		return ErrDuplicateUsage
+	}
+	if err := r.checkQuotaAlerts(tx, subID, recorded); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+// GetMeterUsage returns the current quota period of the subscription the
+// user is charged to, with every limited or used meter. Messages come from
+// usage_ledger; their remaining count is the subscription's quota, which
+// includes rolled over messages and excludes held reservations.
+func (r *PostgresSubscriptionRepository) GetMeterUsage(userID uuid.UUID) (*models.PeriodUsage, error) {
+	subID, _, err := billingSubscription(r.db, userID)
+	if err != nil {
+		return nil, err
+	}
+	var sub models.UserSubscription
+	if err := r.db.Get(&sub, `SELECT `+subscriptionColumns+` FROM user_subscription WHERE id=$1`, subID); err != nil {
+		return nil, fmt.Errorf("get subscription: %w", err)
+	}
+
+	messages := models.MeterUsage{Meter: models.MeterMessages}
+	err = r.db.QueryRow(`
+		SELECT
+			(SELECT COALESCE(SUM(amount), 0) FROM usage_ledger
+			 WHERE user_subscription_id = $1 AND created_at >= $2),
+			(SELECT quota::BIGINT FROM subscription_quotas WHERE subscription_id = $3)`,
+		sub.ID, sub.QuotaPeriodStart, sub.SubscriptionID).Scan(&messages.Used, &messages.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("get message usage: %w", err)
+	}
+	remaining := int64(sub.RemainingQuota)
+	messages.Remaining = &remaining
+
+	usage := []models.MeterUsage{}
+	err = r.db.Select(&usage, `
+		SELECT m.meter, COALESCE(u.used, 0) AS used, l.limit_amount
+		FROM (
+			SELECT meter FROM plan_meter_limits WHERE plan_id = $1
+			UNION
+			SELECT meter FROM subscription_meter_usage WHERE user_subscription_id = $2
+		) m
+		LEFT JOIN plan_meter_limits l ON l.plan_id = $1 AND l.meter = m.meter
+		LEFT JOIN subscription_meter_usage u ON u.user_subscription_id = $2 AND u.meter = m.meter
+		ORDER BY m.meter`,
+		sub.SubscriptionID, sub.ID)
+	if err != nil {
+		return nil, fmt.Errorf("get meter usage: %w", err)
+	}
+
+	for i := range usage {
+		if usage[i].Limit == nil {
+			continue
+		}
+		remaining := *usage[i].Limit - usage[i].Used
+		if remaining < 0 {
+			remaining = 0
+		}
+		usage[i].Remaining = &remaining
+	}
+
+	return &models.PeriodUsage{
+		PeriodStart: sub.QuotaPeriodStart,
+		PeriodEnd:   sub.QuotaResetAt,
+		Meters:      append([]models.MeterUsage{messages}, usage...),
+	}, nil
+}
+
+// GetDailyUsage returns the user's usage per UTC day and meter in [from, to),
+// across subscriptions. For an organisation id it is the usage of all members
+// charged to the organisation. Days without usage are left out.
+func (r *PostgresSubscriptionRepository) GetDailyUsage(userID uuid.UUID, from, to time.Time) ([]models.DailyUsage, error) {
+	var rows []struct {
+		Day    time.Time `db:"day"`
+		Meter  string    `db:"meter"`
+		Amount int64     `db:"amount"`
+	}
+	err := r.db.Select(&rows, `
+		SELECT day, meter, SUM(amount)::BIGINT AS amount
+		FROM (
+			SELECT date_trunc('day', created_at) AS day, $4::VARCHAR AS meter, amount::BIGINT AS amount
+			FROM usage_ledger
+			WHERE (user_id = $1 OR user_subscription_id IN (SELECT id FROM user_subscription WHERE user_id = $1))
+			  AND created_at >= $2 AND created_at < $3
+			UNION ALL
+			SELECT date_trunc('day', created_at), meter, amount
+			FROM meter_ledger
+			WHERE (user_id = $1 OR user_subscription_id IN (SELECT id FROM user_subscription WHERE user_id = $1))
+			  AND created_at >= $2 AND created_at < $3
+		) u
+		GROUP BY day, meter
+		ORDER BY day, meter`,
+		userID, from, to, models.MeterMessages)
+	if err != nil {
+		return nil, fmt.Errorf("get daily usage: %w", err)
+	}
+
+	days := []models.DailyUsage{}
+	for _, row := range rows {
+		date := row.Day.Format("2006-01-02")
+		if len(days) == 0 || days[len(days)-1].Date != date {
+			days = append(days, models.DailyUsage{Date: date, Meters: map[string]int64{}})
+		}
+		days[len(days)-1].Meters[row.Meter] = row.Amount
+	}
+	return days, nil
+}
+
+// exhaustedMeter returns the first of meters whose usage reached the plan
+// limit of subscription subID, or "" if all have room left.
+func exhaustedMeter(q sqlx.Queryer, subID uuid.UUID, meters []string) (string, error) {
+	var meter string
+	err := sqlx.Get(q, &meter, `
+		SELECT l.meter
+		FROM user_subscription s
+		JOIN plan_meter_limits l ON l.plan_id = s.subscription_id
+		LEFT JOIN subscription_meter_usage u ON u.user_subscription_id = s.id AND u.meter = l.meter
+		WHERE s.id = $1 AND l.meter = ANY($2) AND COALESCE(u.used, 0) >= l.limit_amount
+		ORDER BY l.meter LIMIT 1`,
+		subID, pq.Array(meters))
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("check meter limits: %w", err)
+	}
+	return meter, nil
+}
@@ -0,0 +1,36 @@
+package router
+
+import (
+	"net/http"
+
+	"subscription_service/internal/handler"
+
+	"contracts/auth"
+)
+
+// SetupAdminRoutes registers the admin API under /api/subscription/admin/.
+// Every route needs an access token carrying its permission (see
+// contracts/auth); an empty secret rejects them all.
+func SetupAdminRoutes(mux *http.ServeMux, h *handler.SubscriptionHandler, jwtSecret []byte) {
+	// Free plan ata
+	mux.HandleFunc("/api/subscription/admin/assign_free", auth.Require(jwtSecret, auth.PermSubscriptionsAssign, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			h.AssignFreePlan(w, r)
+			return
+		}
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	// Belirli plan ata (ödeme olmadan)
+	mux.HandleFunc("/api/subscription/admin/assign_subscription", auth.Require(jwtSecret, auth.PermSubscriptionsAssign, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			h.AssignPlan(w, r)
+			return
+		}
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	// Plan kataloğu
+	mux.HandleFunc("/api/subscription/admin/plans", auth.Require(jwtSecret, auth.PermPlansManage, h.AdminPlans))
+	mux.HandleFunc("/api/subscription/admin/plans/", auth.Require(jwtSecret, auth.PermPlansManage, h.AdminPlan))
+}
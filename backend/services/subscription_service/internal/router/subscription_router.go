@@ -2,36 +2,125 @@ package router
 
 import (
 	"net/http"
+
 	"subscription_service/internal/handler"
+
+	"contracts/auth"
 )
 
-func SetupSubscriptionRoutes(mux *http.ServeMux, h *handler.SubscriptionHandler) {
-	// Free plan ata
-	mux.HandleFunc("/api/subscription/assign_free", func(w http.ResponseWriter, r *http.Request) {
+// SetupSubscriptionRoutes registers the user API under /api/subscription/ and
+// the endpoints other services call under /internal/. User routes that act
+// on an account need an access token of that user (see contracts/auth).
+func SetupSubscriptionRoutes(mux *http.ServeMux, h *handler.SubscriptionHandler, jwtSecret []byte) {
+	// Health check (API gateway'in instance kontrolü)
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"ok","service":"subscription_service"}`))
+	})
+
+	// Ücretli plan için ödeme sayfası aç (token gerekir)
+	mux.HandleFunc("/api/subscription/checkout", auth.RequireUser(jwtSecret, func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodPost {
-			h.AssignFreePlan(w, r)
+			h.StartCheckout(w, r)
 			return
 		}
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-	})
+	}))
+
+	// Ödeme durumu (token gerekir; yalnızca checkout'un sahibi)
+	mux.HandleFunc("/api/subscription/checkout/", auth.RequireUser(jwtSecret, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			h.GetCheckout(w, r)
+			return
+		}
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}))
 
-	// Belirli plan ata
-	mux.HandleFunc("/api/subscription/assign_subscription", func(w http.ResponseWriter, r *http.Request) {
+	// Ödeme sağlayıcısı webhook'u (imza doğrulanır)
+	mux.HandleFunc("/api/subscription/payments/webhook", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodPost {
-			h.AssignPlan(w, r)
+			h.PaymentWebhook(w, r)
 			return
 		}
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 	})
 
-	// Kullanıcı quota
-	mux.HandleFunc("/api/subscription/quota/", func(w http.ResponseWriter, r *http.Request) {
+	// Kullanıcının güncel aboneliği (token gerekir)
+	current := h.ForUser("/api/subscription/current/", h.GetCurrentSubscription)
+	mux.HandleFunc("/api/subscription/current/", auth.RequireUser(jwtSecret, func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodGet {
-			h.GetUserQuota(w, r)
+			current(w, r)
 			return
 		}
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-	})
+	}))
+
+	// Plan yükselt / düşür, kalan süreye göre oranlanır (token gerekir)
+	mux.HandleFunc("/api/subscription/change_plan", auth.RequireUser(jwtSecret, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			h.ChangePlan(w, r)
+			return
+		}
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	// İptal, varsayılan dönem sonunda (token gerekir)
+	mux.HandleFunc("/api/subscription/cancel", auth.RequireUser(jwtSecret, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			h.CancelSubscription(w, r)
+			return
+		}
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	// Planlanmış iptali geri al (token gerekir)
+	mux.HandleFunc("/api/subscription/resume", auth.RequireUser(jwtSecret, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			h.ResumeSubscription(w, r)
+			return
+		}
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	// Kullanıcı quota (token gerekir)
+	quota := h.ForUser("/api/subscription/quota/", h.GetUserQuota)
+	mux.HandleFunc("/api/subscription/quota/", auth.RequireUser(jwtSecret, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			quota(w, r)
+			return
+		}
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	// Kullanıcının güncel dönem ve günlük kullanımı (token gerekir)
+	usage := h.ForUser("/api/subscription/usage/", h.Usage)
+	mux.HandleFunc("/api/subscription/usage/", auth.RequireUser(jwtSecret, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			usage(w, r)
+			return
+		}
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	// Faturalar: liste, detay, PDF (token gerekir)
+	invoices := h.ForUser("/api/subscription/invoices/", h.Invoices)
+	mux.HandleFunc("/api/subscription/invoices/", auth.RequireUser(jwtSecret, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			invoices(w, r)
+			return
+		}
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	// Kota sıfırlama geçmişi (token gerekir)
+	quotaResets := h.ForUser("/api/subscription/quota_resets/", h.GetQuotaResets)
+	mux.HandleFunc("/api/subscription/quota_resets/", auth.RequireUser(jwtSecret, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			quotaResets(w, r)
+			return
+		}
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}))
 
 	// Tüm planlar
 	mux.HandleFunc("/api/subscription/plans", func(w http.ResponseWriter, r *http.Request) {
@@ -42,12 +131,27 @@ func SetupSubscriptionRoutes(mux *http.ServeMux, h *handler.SubscriptionHandler)
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 	})
 
-	// Kullanıcı event
-	mux.HandleFunc("/api/subscription/event", func(w http.ResponseWriter, r *http.Request) {
+	// Kota rezervasyonu (LLM çağrısı öncesi; yalnızca chat_service, gateway yönlendirmez)
+	mux.HandleFunc("/internal/reservations", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodPost {
-			h.UserEvent(w, r)
+			h.ReserveQuota(w, r)
 			return
 		}
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 	})
+
+	// Rezervasyonu onayla / bırak
+	mux.HandleFunc("/internal/reservations/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			h.ReservationAction(w, r)
+			return
+		}
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	})
+
+	// Organizasyon havuz kotası: üye kullanımı ve üye limitleri (token gerekir; owner/admin)
+	mux.HandleFunc("/api/subscription/orgs/", auth.RequireUser(jwtSecret, h.Organisations))
+
+	// Yalnızca servisler arası (chat_service kotası, auth_service veri dışa aktarımı); gateway yönlendirmez
+	mux.HandleFunc("/internal/users/", h.Internal)
 }
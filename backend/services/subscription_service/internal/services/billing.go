@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"subscription_service/internal/models"
+	"subscription_service/internal/payment"
+	"subscription_service/internal/repository"
+)
+
+var (
+	// ErrNoPaymentNeeded means the requested plan change costs nothing and
+	// is done with ChangePlan (or, for free plans, assigned directly).
+	ErrNoPaymentNeeded = errors.New("no payment needed")
+	// ErrPaymentProvider means the payment provider rejected or didn't
+	// answer a request.
+	ErrPaymentProvider = errors.New("payment provider error")
+)
+
+// StartCheckout opens a hosted payment page for planName. What is charged
+// depends on the user's current subscription:
+//   - none, free or past_due on another plan: the full price of a new subscription
+//   - the same plan: the full price of the next period (renewal)
+//   - another paid plan: the prorated price difference (upgrade)
+//
+// Nothing changes on the subscription until the provider confirms the
+// payment through the webhook.
+func (s *UserSubscriptionService) StartCheckout(ctx context.Context, userID uuid.UUID, planName string) (*models.Checkout, error) {
+	plan, err := s.subRepo.GetPlanByName(planName)
+	if err != nil {
+		return nil, err
+	}
+	if !plan.Active {
+		return nil, fmt.Errorf("%w: plan %s is retired", ErrInvalidPlan, planName)
+	}
+	if plan.PriceCents == 0 {
+		return nil, fmt.Errorf("%w: plan %s is free", ErrNoPaymentNeeded, planName)
+	}
+
+	c := &models.Checkout{
+		ID:          uuid.New(),
+		UserID:      userID,
+		PlanID:      plan.ID,
+		Purpose:     models.CheckoutSubscribe,
+		AmountCents: plan.PriceCents,
+		Currency:    plan.Currency,
+		Provider:    s.Payments.Name(),
+	}
+
+	sub, err := s.subRepo.GetUserSubscription(userID)
+	switch {
+	case errors.Is(err, repository.ErrNoActiveSubscription):
+	case err != nil:
+		return nil, err
+	case sub.SubscriptionID == plan.ID:
+		c.Purpose = models.CheckoutRenew
+	case sub.Status != models.StatusPastDue:
+		current, err := s.subRepo.GetPlanByID(sub.SubscriptionID)
+		if err != nil {
+			return nil, err
+		}
+		if current.PriceCents > 0 {
+			// Same proration as ChangePlan; nothing is charged for a trial.
+			c.Purpose = models.CheckoutUpgrade
+			c.AmountCents = 0
+			if sub.Status == models.StatusActive {
+				c.AmountCents = models.Prorate(plan.PriceCents-current.PriceCents, sub.PeriodLeft(time.Now().UTC()))
+			}
+			if c.AmountCents <= 0 {
+				return nil, fmt.Errorf("%w: use change_plan to move to %s", ErrNoPaymentNeeded, planName)
+			}
+		}
+	}
+
+	if err := s.subRepo.CreateCheckout(c); err != nil {
+		return nil, err
+	}
+
+	session, err := s.Payments.CreateCheckoutSession(ctx, payment.CheckoutRequest{
+		ReferenceID: c.ID.String(),
+		UserID:      userID.String(),
+		PlanID:      plan.ID.String(),
+		Description: fmt.Sprintf("%s plan (%s)", plan.Name, c.Purpose),
+		AmountCents: c.AmountCents,
+		Currency:    c.Currency,
+		SuccessURL:  s.CheckoutSuccessURL,
+		CancelURL:   s.CheckoutCancelURL,
+	})
+	if err != nil {
+		if failErr := s.subRepo.FailCheckout(c.ID, err.Error()); failErr != nil {
+			log.Printf("⚠️ Failed to mark checkout %s failed: %v", c.ID, failErr)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrPaymentProvider, err)
+	}
+
+	c, err = s.subRepo.AttachCheckoutSession(c.ID, session.ID, session.URL)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("💳 Checkout %s started: user=%s plan=%s purpose=%s amount=%d %s",
+		c.ID, userID, plan.Name, c.Purpose, c.AmountCents, c.Currency)
+	return c, nil
+}
+
+// GetCheckout returns a checkout by id
+func (s *UserSubscriptionService) GetCheckout(id uuid.UUID) (*models.Checkout, error) {
+	return s.subRepo.GetCheckout(id)
+}
+
+// HandlePaymentWebhook verifies a provider webhook and applies it. Only
+// signature and payload errors, and failures worth a provider retry, are
+// returned; events that can't or needn't be applied are logged and
+// acknowledged.
+func (s *UserSubscriptionService) HandlePaymentWebhook(payload []byte, header http.Header) error {
+	evt, err := s.Payments.ParseWebhook(payload, header)
+	if err != nil {
+		return err
+	}
+
+	c, err := s.subRepo.ApplyPaymentEvent(s.Payments.Name(), evt)
+	switch {
+	case errors.Is(err, repository.ErrDuplicateEvent):
+		log.Printf("ℹ️ Payment event %s already processed", evt.ID)
+	case errors.Is(err, repository.ErrCheckoutNotFound):
+		log.Printf("⚠️ Payment event %s (%s) has no matching checkout", evt.ID, evt.ProviderType)
+	case errors.Is(err, repository.ErrPaymentMismatch):
+		log.Printf("⚠️ Payment event %s rejected: %v", evt.ID, err)
+	case err != nil:
+		return err
+	case evt.Type == "":
+		log.Printf("ℹ️ Ignored payment event %s (%s)", evt.ID, evt.ProviderType)
+	default:
+		log.Printf("💳 Checkout %s is %s (event %s)", c.ID, c.Status, evt.ProviderType)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+
+	"subscription_service/internal/models"
+	"subscription_service/internal/repository"
+
+	"contracts/events"
+	"contracts/kafka"
+)
+
+// ErrNotOrganisationManager means only the organisation's owner and admins
+// can do this.
+var ErrNotOrganisationManager = errors.New("only organisation owners and admins can do this")
+
+// Account returns whose subscription a request of userID acts on: the
+// user's own, or with orgID the organisation's pooled subscription, which
+// only its owners and admins manage.
+func (s *UserSubscriptionService) Account(userID, orgID uuid.UUID) (uuid.UUID, error) {
+	if orgID == uuid.Nil {
+		return userID, nil
+	}
+	if err := s.requireManager(userID, orgID); err != nil {
+		return uuid.Nil, err
+	}
+	return orgID, nil
+}
+
+// ListMemberUsage returns each member's usage of the organisation's pool
+// and cap, for its owners and admins.
+func (s *UserSubscriptionService) ListMemberUsage(userID, orgID uuid.UUID) ([]models.MemberUsage, error) {
+	if err := s.requireManager(userID, orgID); err != nil {
+		return nil, err
+	}
+	return s.subRepo.ListMemberUsage(orgID)
+}
+
+// SetMemberCap limits how many of the pool's messages memberID can use per
+// quota period; nil removes the cap.
+func (s *UserSubscriptionService) SetMemberCap(userID, orgID, memberID uuid.UUID, messageCap *int) (*models.OrganisationMember, error) {
+	if err := s.requireManager(userID, orgID); err != nil {
+		return nil, err
+	}
+	m, err := s.subRepo.SetMemberCap(orgID, memberID, messageCap)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("🎚️ Message cap of user %s in organisation %s set to %v", memberID, orgID, messageCap)
+	return m, nil
+}
+
+func (s *UserSubscriptionService) requireManager(userID, orgID uuid.UUID) error {
+	m, err := s.subRepo.GetOrganisationMember(userID)
+	if errors.Is(err, repository.ErrNotOrganisationMember) {
+		return ErrNotOrganisationManager
+	}
+	if err != nil {
+		return err
+	}
+	if m.OrganisationID != orgID || (m.Role != events.RoleOwner && m.Role != events.RoleAdmin) {
+		return ErrNotOrganisationManager
+	}
+	return nil
+}
+
+// handleMemberChanged mirrors a membership from auth_service.
+func (s *UserSubscriptionService) handleMemberChanged(evt *events.OrgMemberChanged) error {
+	orgID, err := uuid.Parse(evt.OrganisationID)
+	if err != nil {
+		return fmt.Errorf("%w: invalid organisation_id: %v", kafka.ErrInvalidMessage, err)
+	}
+	userID, err := uuid.Parse(evt.UserID)
+	if err != nil {
+		return fmt.Errorf("%w: invalid user_id: %v", kafka.ErrInvalidMessage, err)
+	}
+	if err := s.subRepo.ApplyMemberChange(orgID, userID, evt.Role, evt.Action); err != nil {
+		return err
+	}
+	log.Printf("👥 Organisation %s: user %s %s (%s)", orgID, userID, evt.Action, evt.Role)
+	return nil
+}
+
+// handleOrganisationDeleted ends the organisation's subscription right away
+// and drops its members, who are charged to their own subscriptions again.
+func (s *UserSubscriptionService) handleOrganisationDeleted(evt *events.OrganisationDeleted) error {
+	orgID, err := uuid.Parse(evt.OrganisationID)
+	if err != nil {
+		return fmt.Errorf("%w: invalid organisation_id: %v", kafka.ErrInvalidMessage, err)
+	}
+
+	_, err = s.subRepo.CancelSubscription(orgID, false)
+	if err != nil && !errors.Is(err, repository.ErrNoActiveSubscription) {
+		return fmt.Errorf("failed to cancel organisation subscription: %w", err)
+	}
+	n, err := s.subRepo.DeleteOrganisationMembers(orgID)
+	if err != nil {
+		return err
+	}
+	log.Printf("🗑️ Organisation %s deleted: subscription ended, %d member(s) dropped", orgID, n)
+	return nil
+}
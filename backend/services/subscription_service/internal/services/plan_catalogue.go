@@ -0,0 +1,181 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"subscription_service/internal/models"
+)
+
+// ErrInvalidPlan means a plan definition failed validation.
+var ErrInvalidPlan = errors.New("invalid plan")
+
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// PlanInput is the admin-editable part of a plan.
+type PlanInput struct {
+	Name               string           `json:"name"`
+	Description        string           `json:"description"`
+	PriceCents         int64            `json:"price_cents"`
+	Currency           string           `json:"currency"`
+	BillingPeriod      string           `json:"billing_period"`
+	BillingPeriodCount int              `json:"billing_period_count"`
+	MaxUploadBytes     *int64           `json:"max_upload_bytes"`
+	MaxDocuments       *int             `json:"max_documents"`
+	AllowedModels      []string         `json:"allowed_models"`
+	TrialDays          int              `json:"trial_days"`
+	QuotaResetPeriod   *string          `json:"quota_reset_period"`
+	RolloverCap        int64            `json:"rollover_cap"`
+	Quotas             map[string]int64 `json:"quotas"`
+}
+
+// ListPlansForAdmin lists every plan including retired ones
+func (s *UserSubscriptionService) ListPlansForAdmin() ([]models.SubscriptionPlan, error) {
+	return s.subRepo.ListPlans(true)
+}
+
+// GetPlan returns a plan by id
+func (s *UserSubscriptionService) GetPlan(id uuid.UUID) (*models.SubscriptionPlan, error) {
+	return s.subRepo.GetPlanByID(id)
+}
+
+// CreatePlan validates and stores a new plan
+func (s *UserSubscriptionService) CreatePlan(in PlanInput) (*models.SubscriptionPlan, error) {
+	plan := &models.SubscriptionPlan{}
+	if err := in.apply(plan); err != nil {
+		return nil, err
+	}
+	if err := s.subRepo.CreatePlan(plan); err != nil {
+		return nil, err
+	}
+	log.Printf("🆕 Plan created: %s (%s)", plan.Name, plan.ID)
+	return plan, nil
+}
+
+// UpdatePlan replaces the definition of an existing plan
+func (s *UserSubscriptionService) UpdatePlan(id uuid.UUID, in PlanInput) (*models.SubscriptionPlan, error) {
+	plan := &models.SubscriptionPlan{ID: id}
+	if err := in.apply(plan); err != nil {
+		return nil, err
+	}
+	if err := s.subRepo.UpdatePlan(plan); err != nil {
+		return nil, err
+	}
+	log.Printf("✏️ Plan updated: %s (%s)", plan.Name, plan.ID)
+	return plan, nil
+}
+
+// RetirePlan stops a plan from being assigned; existing subscriptions run on
+func (s *UserSubscriptionService) RetirePlan(id uuid.UUID) (*models.SubscriptionPlan, error) {
+	plan, err := s.subRepo.RetirePlan(id)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("📦 Plan retired: %s (%s)", plan.Name, plan.ID)
+	return plan, nil
+}
+
+// apply validates the input and copies it onto plan.
+func (in PlanInput) apply(plan *models.SubscriptionPlan) error {
+	name := strings.TrimSpace(in.Name)
+	if name == "" || len(name) > 50 {
+		return fmt.Errorf("%w: name must be 1-50 characters", ErrInvalidPlan)
+	}
+	if in.PriceCents < 0 {
+		return fmt.Errorf("%w: price_cents must not be negative", ErrInvalidPlan)
+	}
+
+	currency := strings.ToUpper(in.Currency)
+	if currency == "" {
+		currency = "USD"
+	}
+	if !currencyPattern.MatchString(currency) {
+		return fmt.Errorf("%w: currency must be an ISO 4217 code", ErrInvalidPlan)
+	}
+
+	if !isPeriod(in.BillingPeriod) {
+		return fmt.Errorf("%w: billing_period must be day, week, month or year", ErrInvalidPlan)
+	}
+	count := in.BillingPeriodCount
+	if count == 0 {
+		count = 1
+	}
+	if count < 0 {
+		return fmt.Errorf("%w: billing_period_count must be positive", ErrInvalidPlan)
+	}
+
+	if in.QuotaResetPeriod != nil && !isPeriod(*in.QuotaResetPeriod) {
+		return fmt.Errorf("%w: quota_reset_period must be day, week, month or year", ErrInvalidPlan)
+	}
+	if in.RolloverCap < 0 {
+		return fmt.Errorf("%w: rollover_cap must not be negative", ErrInvalidPlan)
+	}
+
+	if in.TrialDays < 0 {
+		return fmt.Errorf("%w: trial_days must not be negative", ErrInvalidPlan)
+	}
+	if in.MaxUploadBytes != nil && *in.MaxUploadBytes <= 0 {
+		return fmt.Errorf("%w: max_upload_bytes must be positive", ErrInvalidPlan)
+	}
+	if in.MaxDocuments != nil && *in.MaxDocuments < 0 {
+		return fmt.Errorf("%w: max_documents must not be negative", ErrInvalidPlan)
+	}
+
+	// Message quota is what reservations hold, so every plan needs one.
+	if _, ok := in.Quotas[models.MeterMessages]; !ok {
+		return fmt.Errorf("%w: quotas.%s is required", ErrInvalidPlan, models.MeterMessages)
+	}
+	for meter, limit := range in.Quotas {
+		if !isMeter(meter) {
+			return fmt.Errorf("%w: unknown meter %q", ErrInvalidPlan, meter)
+		}
+		if limit < 0 {
+			return fmt.Errorf("%w: quota for %s must not be negative", ErrInvalidPlan, meter)
+		}
+	}
+
+	allowed := pq.StringArray{}
+	for _, m := range in.AllowedModels {
+		if m = strings.TrimSpace(m); m != "" {
+			allowed = append(allowed, m)
+		}
+	}
+
+	plan.Name = name
+	plan.Description = in.Description
+	plan.PriceCents = in.PriceCents
+	plan.Currency = currency
+	plan.BillingPeriod = in.BillingPeriod
+	plan.BillingPeriodCount = count
+	plan.MaxUploadBytes = in.MaxUploadBytes
+	plan.MaxDocuments = in.MaxDocuments
+	plan.AllowedModels = allowed
+	plan.TrialDays = in.TrialDays
+	plan.QuotaResetPeriod = in.QuotaResetPeriod
+	plan.RolloverCap = in.RolloverCap
+	plan.Quotas = in.Quotas
+	return nil
+}
+
+func isPeriod(name string) bool {
+	switch name {
+	case models.BillingDay, models.BillingWeek, models.BillingMonth, models.BillingYear:
+		return true
+	}
+	return false
+}
+
+func isMeter(name string) bool {
+	for _, m := range models.Meters {
+		if m == name {
+			return true
+		}
+	}
+	return false
+}
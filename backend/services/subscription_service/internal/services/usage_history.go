@@ -0,0 +1,53 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"subscription_service/internal/invoice"
+	"subscription_service/internal/models"
+)
+
+// maxUsageDays bounds one daily usage query.
+const maxUsageDays = 366
+
+// ErrInvalidRange means a usage history range is reversed or too long.
+var ErrInvalidRange = errors.New("invalid date range")
+
+// GetDailyUsage returns the user's usage per day and meter from the start of
+// day from up to and including day to (UTC).
+func (s *UserSubscriptionService) GetDailyUsage(userID uuid.UUID, from, to time.Time) ([]models.DailyUsage, error) {
+	from = from.UTC().Truncate(24 * time.Hour)
+	end := to.UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	if !end.After(from) || end.Sub(from) > maxUsageDays*24*time.Hour {
+		return nil, fmt.Errorf("%w: from must not be after to and the range at most %d days", ErrInvalidRange, maxUsageDays)
+	}
+	return s.subRepo.GetDailyUsage(userID, from, end)
+}
+
+// ListInvoices returns the user's latest invoices without lines
+func (s *UserSubscriptionService) ListInvoices(userID uuid.UUID) ([]models.Invoice, error) {
+	return s.subRepo.ListInvoices(userID, 100)
+}
+
+// GetInvoice returns one of the user's invoices with its lines
+func (s *UserSubscriptionService) GetInvoice(userID, invoiceID uuid.UUID) (*models.Invoice, error) {
+	return s.subRepo.GetInvoice(userID, invoiceID)
+}
+
+// InvoicePDF renders one of the user's invoices as PDF.
+func (s *UserSubscriptionService) InvoicePDF(userID, invoiceID uuid.UUID) (*models.Invoice, []byte, error) {
+	inv, err := s.subRepo.GetInvoice(userID, invoiceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	var buf bytes.Buffer
+	if err := invoice.WritePDF(&buf, inv, s.InvoiceIssuer); err != nil {
+		return nil, nil, fmt.Errorf("render invoice %s: %w", inv.Number, err)
+	}
+	return inv, buf.Bytes(), nil
+}
@@ -1,7 +1,8 @@
 package services
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -9,23 +10,46 @@ import (
 	"github.com/IBM/sarama"
 	"github.com/google/uuid"
 
-	"subscription_service/internal/database"
 	"subscription_service/internal/models"
+	"subscription_service/internal/payment"
 	"subscription_service/internal/repository"
+
+	"contracts/events"
+	"contracts/kafka"
 )
 
 type UserSubscriptionService struct {
-	subRepo      repository.SubscriptionRepository
-	KafkaBrokers []string
-	KafkaTopic   string
+	subRepo            repository.SubscriptionRepository
+	KafkaBrokers       []string
+	KafkaTopics        []string
+	KafkaGroup         string
+	KafkaInitialOffset string
+
+	// ReservationTTL is used when a reserve request doesn't ask for its own;
+	// longer requests are cut to ReservationMaxTTL.
+	ReservationTTL    time.Duration
+	ReservationMaxTTL time.Duration
+
+	// Payments creates checkouts and verifies their webhooks; the URLs are
+	// where the provider sends the user back to.
+	Payments           payment.PaymentProvider
+	CheckoutSuccessURL string
+	CheckoutCancelURL  string
+
+	// InvoiceIssuer is printed in the header of invoice PDFs.
+	InvoiceIssuer string
 }
 
 // NewUserSubscriptionService constructs a new service
-func NewUserSubscriptionService(repo repository.SubscriptionRepository, brokers []string, topic string) *UserSubscriptionService {
+func NewUserSubscriptionService(repo repository.SubscriptionRepository, brokers, topics []string, group, initialOffset string) *UserSubscriptionService {
 	return &UserSubscriptionService{
-		subRepo:      repo,
-		KafkaBrokers: brokers,
-		KafkaTopic:   topic,
+		subRepo:            repo,
+		KafkaBrokers:       brokers,
+		KafkaTopics:        topics,
+		KafkaGroup:         group,
+		KafkaInitialOffset: initialOffset,
+		ReservationTTL:     2 * time.Minute,
+		ReservationMaxTTL:  10 * time.Minute,
 	}
 }
 
@@ -36,161 +60,417 @@ func (s *UserSubscriptionService) AssignFreePlanToUser(userID uuid.UUID) error {
 		return fmt.Errorf("failed to get Free plan: %w", err)
 	}
 
-	quotaObj, err := s.subRepo.GetQuotaByPlanID(plan.ID)
-	if err != nil {
-		return fmt.Errorf("failed to get Free plan quota: %w", err)
-	}
-	quota := quotaObj.Quota
-
-	start := time.Now().UTC()
-	end := start.AddDate(0, 0, 5) // Free plan 5 gün
-
-	_, err = s.subRepo.AssignPlanToUserWithQuota(userID, plan.ID, start, end, quota)
-	if err != nil {
+	if err := s.assignPlan(userID, plan); err != nil {
 		return fmt.Errorf("failed to assign Free plan: %w", err)
 	}
-
-	log.Printf("✅ Free plan assigned to user %s with %d quota", userID.String(), quota)
 	return nil
 }
 
-// AssignPlanToUserByName assigns a specific plan to a user using DB quota
+// AssignPlanToUserByName puts a user on a specific plan without payment; it
+// is an admin override. A user who already has a subscription is moved as
+// with ChangePlan, with any price difference waived.
 func (s *UserSubscriptionService) AssignPlanToUserByName(userID uuid.UUID, planName string) error {
 	plan, err := s.subRepo.GetPlanByName(planName)
 	if err != nil {
 		return fmt.Errorf("plan not found: %w", err)
 	}
+	if !plan.Active {
+		return fmt.Errorf("%w: plan %s is retired", ErrInvalidPlan, planName)
+	}
 
-	quotaObj, err := s.subRepo.GetQuotaByPlanID(plan.ID)
+	err = s.assignPlan(userID, plan)
+	if errors.Is(err, repository.ErrSubscriptionExists) {
+		_, _, err = s.changePlan(userID, planName, true)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to get plan quota: %w", err)
+		return fmt.Errorf("failed to assign plan: %w", err)
 	}
-	quota := quotaObj.Quota
+	return nil
+}
 
-	start := time.Now().UTC()
-	end := start.AddDate(0, 0, 30) // Örn: 30 gün
+// assignPlan starts a subscription to plan with its message quota, in trial
+// if the plan has trial days.
+func (s *UserSubscriptionService) assignPlan(userID uuid.UUID, plan *models.SubscriptionPlan) error {
+	quota := int(plan.Quotas[models.MeterMessages])
+
+	sub := &models.UserSubscription{
+		ID:             uuid.New(),
+		UserID:         userID,
+		SubscriptionID: plan.ID,
+		StartDate:      time.Now().UTC(),
+		RemainingQuota: quota,
+		Status:         models.StatusActive,
+	}
+	sub.EndDate = plan.PeriodEnd(sub.StartDate)
+	if plan.TrialDays > 0 {
+		sub.Status = models.StatusTrialing
+		sub.EndDate = sub.StartDate.AddDate(0, 0, plan.TrialDays)
+	}
+	sub.QuotaPeriodStart = sub.StartDate
+	sub.QuotaResetAt = plan.NextQuotaReset(sub.StartDate, sub.EndDate)
 
-	_, err = s.subRepo.AssignPlanToUserWithQuota(userID, plan.ID, start, end, quota)
-	if err != nil {
-		return fmt.Errorf("failed to assign plan: %w", err)
+	if err := s.subRepo.CreateSubscription(sub, plan); err != nil {
+		return err
 	}
 
-	log.Printf("✅ Plan %s assigned to user %s with %d quota", planName, userID.String(), quota)
+	log.Printf("✅ Plan %s assigned to user %s (%s until %s) with %d quota",
+		plan.Name, userID, sub.Status, sub.EndDate.Format(time.RFC3339), quota)
 	return nil
 }
 
-// GetUserQuota returns remaining quota of a user
-func (s *UserSubscriptionService) GetUserQuota(userID uuid.UUID) (int, error) {
-	sub, err := s.subRepo.GetUserSubscription(userID)
-	if err != nil {
-		return 0, err
-	}
-	return sub.RemainingQuota, nil
+// GetCurrentSubscription returns the user's trialing, active or past_due subscription
+func (s *UserSubscriptionService) GetCurrentSubscription(userID uuid.UUID) (*models.UserSubscription, error) {
+	return s.subRepo.GetUserSubscription(userID)
 }
 
-// LogEventAndDecrementQuota logs user events and decreases quota
-func (s *UserSubscriptionService) LogEventAndDecrementQuota(userIDStr string) error {
-	userID, err := uuid.Parse(userIDStr)
-	if err != nil {
-		return fmt.Errorf("invalid user_id: %w", err)
-	}
+// ChangePlan upgrades or downgrades the user's current subscription for the
+// rest of the period and returns the prorated price difference in cents.
+// Changes that cost money return repository.ErrPaymentRequired; they go
+// through StartCheckout instead.
+func (s *UserSubscriptionService) ChangePlan(userID uuid.UUID, planName string) (*models.UserSubscription, int64, error) {
+	return s.changePlan(userID, planName, false)
+}
 
-	sub, err := s.subRepo.GetUserSubscription(userID)
+func (s *UserSubscriptionService) changePlan(userID uuid.UUID, planName string, paid bool) (*models.UserSubscription, int64, error) {
+	plan, err := s.subRepo.GetPlanByName(planName)
 	if err != nil {
-		return fmt.Errorf("get user subscription: %w", err)
+		return nil, 0, err
+	}
+	if !plan.Active {
+		return nil, 0, fmt.Errorf("%w: plan %s is retired", ErrInvalidPlan, planName)
 	}
 
-	if sub.RemainingQuota <= 0 {
-		return fmt.Errorf("quota exceeded")
+	sub, proration, err := s.subRepo.ChangePlan(userID, plan, paid)
+	if err != nil {
+		return nil, 0, err
 	}
+	log.Printf("🔁 User %s moved to plan %s: remaining=%d proration=%d %s",
+		userID, plan.Name, sub.RemainingQuota, proration, plan.Currency)
+	return sub, proration, nil
+}
 
-	sub.RemainingQuota -= 1
-	query := `UPDATE user_subscription SET remaining_quota=$1 WHERE id=$2`
-	if _, err := database.DB.Exec(query, sub.RemainingQuota, sub.ID); err != nil {
-		return fmt.Errorf("decrement quota: %w", err)
+// CancelSubscription cancels now or schedules the cancellation for the end
+// of the current period
+func (s *UserSubscriptionService) CancelSubscription(userID uuid.UUID, atPeriodEnd bool) (*models.UserSubscription, error) {
+	sub, err := s.subRepo.CancelSubscription(userID, atPeriodEnd)
+	if err != nil {
+		return nil, err
 	}
+	log.Printf("🛑 Subscription %s of user %s cancelled (at_period_end=%t)", sub.ID, userID, atPeriodEnd)
+	return sub, nil
+}
 
-	return nil
+// ResumeSubscription revokes a scheduled cancellation
+func (s *UserSubscriptionService) ResumeSubscription(userID uuid.UUID) (*models.UserSubscription, error) {
+	return s.subRepo.ResumeSubscription(userID)
 }
 
-// ListAllPlans lists all subscription plans
-func (s *UserSubscriptionService) ListAllPlans() ([]models.SubscriptionPlan, error) {
-	query := `SELECT id, name, description FROM subscription_plans`
-	rows, err := database.DB.Queryx(query)
-	if err != nil {
-		return nil, fmt.Errorf("list all plans: %w", err)
+// RunLifecycleScheduler moves subscriptions whose period ended to their next
+// status every interval until ctx is cancelled. Paid subscriptions stay
+// past_due for grace before they expire.
+func (s *UserSubscriptionService) RunLifecycleScheduler(ctx context.Context, interval, grace time.Duration) {
+	const batch = 100
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				n, err := s.subRepo.AdvanceLifecycle(grace, batch)
+				if err != nil {
+					log.Printf("⚠️ Subscription lifecycle run failed: %v", err)
+					break
+				}
+				if n > 0 {
+					log.Printf("⏰ %d subscription(s) moved to their next status", n)
+				}
+				if n < batch {
+					break
+				}
+			}
+		}
 	}
-	defer rows.Close()
+}
 
-	var plans []models.SubscriptionPlan
-	for rows.Next() {
-		var p models.SubscriptionPlan
-		if err := rows.StructScan(&p); err != nil {
-			return nil, fmt.Errorf("scan plan: %w", err)
+// RunQuotaResetScheduler resets the quota of subscriptions whose quota
+// period is over, and renews free plans at the end of their billing period,
+// every interval until ctx is cancelled.
+func (s *UserSubscriptionService) RunQuotaResetScheduler(ctx context.Context, interval time.Duration) {
+	const batch = 100
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				n, err := s.subRepo.ResetDueQuotas(batch)
+				if err != nil {
+					log.Printf("⚠️ Quota reset run failed: %v", err)
+					break
+				}
+				if n > 0 {
+					log.Printf("🔄 Quota reset for %d subscription(s)", n)
+				}
+				if n < batch {
+					break
+				}
+			}
 		}
-		plans = append(plans, p)
 	}
+}
 
-	return plans, nil
+// ListQuotaResets returns the user's latest quota resets
+func (s *UserSubscriptionService) ListQuotaResets(userID uuid.UUID) ([]models.QuotaReset, error) {
+	return s.subRepo.ListQuotaResets(userID, 50)
 }
 
-// StartKafkaConsumer starts a Kafka consumer to handle registration and chat events
-func (s *UserSubscriptionService) StartKafkaConsumer() error {
-	config := sarama.NewConfig()
-	config.Consumer.Return.Errors = true
-	config.Version = sarama.V2_8_0_0
+// GetUserQuota returns how many messages a user can still send: the
+// remaining quota of their own subscription or, for organisation members,
+// of the organisation's pool, limited by their member cap
+func (s *UserSubscriptionService) GetUserQuota(userID uuid.UUID) (int, error) {
+	return s.subRepo.GetEffectiveQuota(userID)
+}
 
-	master, err := sarama.NewConsumer(s.KafkaBrokers, config)
+// ConsumeQuota deducts amount for the action identified by usageID. It is
+// the only place quota is spent; a repeated usageID is a no-op that returns
+// repository.ErrDuplicateUsage.
+func (s *UserSubscriptionService) ConsumeQuota(usageID, userID uuid.UUID, amount int, source string) (int, error) {
+	remaining, err := s.subRepo.ConsumeQuota(usageID, userID, amount, source)
 	if err != nil {
-		return fmt.Errorf("failed to start Kafka consumer: %w", err)
+		return 0, err
 	}
+	log.Printf("📉 Quota consumed: user=%s usage=%s amount=%d remaining=%d", userID, usageID, amount, remaining)
+	return remaining, nil
+}
 
-	consumer, err := master.ConsumePartition(s.KafkaTopic, 0, sarama.OffsetNewest)
+// ReserveQuota holds amount of the user's quota for an in-flight request.
+// The reservation expires after ttl (ReservationTTL when zero, at most
+// ReservationMaxTTL) unless it is committed or released first.
+func (s *UserSubscriptionService) ReserveQuota(reservationID, userID uuid.UUID, amount int, ttl time.Duration) (*models.QuotaReservation, int, error) {
+	if ttl <= 0 {
+		ttl = s.ReservationTTL
+	}
+	ttl = min(ttl, s.ReservationMaxTTL)
+	res, remaining, err := s.subRepo.ReserveQuota(reservationID, userID, amount, ttl)
 	if err != nil {
-		return fmt.Errorf("failed to consume partition: %w", err)
+		return nil, 0, err
 	}
+	log.Printf("🔒 Quota reserved: user=%s reservation=%s amount=%d remaining=%d", userID, res.ID, res.Amount, remaining)
+	return res, remaining, nil
+}
 
-	log.Printf("🎧 Kafka consumer started for topic: %s", s.KafkaTopic)
+// CommitReservation charges a reservation to the usage ledger.
+func (s *UserSubscriptionService) CommitReservation(reservationID uuid.UUID) error {
+	if err := s.subRepo.CommitReservation(reservationID); err != nil {
+		return err
+	}
+	log.Printf("✅ Reservation committed: %s", reservationID)
+	return nil
+}
 
-	go func() {
-		for msg := range consumer.Messages() {
-			log.Printf("📥 Kafka message received: %s", string(msg.Value))
+// ReleaseReservation returns the quota held by a reservation.
+func (s *UserSubscriptionService) ReleaseReservation(reservationID uuid.UUID) error {
+	if err := s.subRepo.ReleaseReservation(reservationID); err != nil {
+		return err
+	}
+	log.Printf("↩️ Reservation released: %s", reservationID)
+	return nil
+}
 
-			var event struct {
-				Type    string `json:"type"`
-				UserID  string `json:"user_id"`
-				Email   string `json:"email,omitempty"`
-				Message string `json:"message,omitempty"`
-			}
-			if err := json.Unmarshal(msg.Value, &event); err != nil {
-				log.Printf("⚠️ Failed to unmarshal event: %v", err)
+// RunReservationSweeper releases expired reservations every interval until
+// ctx is cancelled, so quota held by a crashed caller comes back.
+func (s *UserSubscriptionService) RunReservationSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := s.subRepo.ExpireReservations()
+			if err != nil {
+				log.Printf("⚠️ Reservation sweep failed: %v", err)
 				continue
 			}
+			if n > 0 {
+				log.Printf("⌛ Expired reservations refunded to %d subscription(s)", n)
+			}
+		}
+	}
+}
 
-			switch event.Type {
-			case "user_registered":
-				// Auth service’den gelen event → Free plan ata
-				uid, err := uuid.Parse(event.UserID)
-				if err != nil {
-					log.Printf("⚠️ Invalid UUID in event: %v", err)
-					continue
-				}
-				if err := s.AssignFreePlanToUser(uid); err != nil {
-					log.Printf("⚠️ Failed to assign Free plan: %v", err)
-				}
+// RecordMeterUsage meters token and page usage. Duplicates and users without
+// an active subscription are logged and skipped because the work is done.
+func (s *UserSubscriptionService) RecordMeterUsage(usageID, userID uuid.UUID, amounts map[string]int64, source string) error {
+	err := s.subRepo.RecordMeterUsage(usageID, userID, amounts, source)
+	switch {
+	case errors.Is(err, repository.ErrDuplicateUsage):
+		log.Printf("ℹ️ Meter usage %s already recorded, skipping", usageID)
+	case errors.Is(err, repository.ErrNoActiveSubscription):
+		log.Printf("⚠️ Cannot meter usage %s for user %s: %v", usageID, userID, err)
+	case err != nil:
+		return fmt.Errorf("failed to record meter usage: %w", err)
+	default:
+		log.Printf("📊 Meter usage recorded: user=%s usage=%s %v", userID, usageID, amounts)
+	}
+	return nil
+}
 
-			case "chat_completed":
-				// Chat service’den gelen event → Kota azalt
-				log.Printf("💬 Chat completed for user %s, decreasing quota...", event.UserID)
-				if err := s.LogEventAndDecrementQuota(event.UserID); err != nil {
-					log.Printf("⚠️ Failed to decrease quota: %v", err)
-				}
+// GetMeterUsage returns used and remaining amounts per meter in the current
+// quota period
+func (s *UserSubscriptionService) GetMeterUsage(userID uuid.UUID) (*models.PeriodUsage, error) {
+	return s.subRepo.GetMeterUsage(userID)
+}
+
+// ListAllPlans lists the plans that can be subscribed to, with quotas
+func (s *UserSubscriptionService) ListAllPlans() ([]models.SubscriptionPlan, error) {
+	return s.subRepo.ListPlans(false)
+}
+
+// StartKafkaConsumer joins the subscription consumer group and handles
+// registration, usage and organisation events until ctx is cancelled.
+func (s *UserSubscriptionService) StartKafkaConsumer(ctx context.Context) error {
+	return kafka.RunConsumerGroup(
+		ctx,
+		s.KafkaBrokers,
+		s.KafkaGroup,
+		s.KafkaTopics,
+		kafka.ParseInitialOffset(s.KafkaInitialOffset),
+		s.handleKafkaMessage,
+	)
+}
+
+// handleKafkaMessage processes one event. Returned errors are retried by the
+// consumer, so handlers must be safe to run more than once per event.
+func (s *UserSubscriptionService) handleKafkaMessage(msg *sarama.ConsumerMessage) error {
+	log.Printf("📥 Kafka message received: %s", string(msg.Value))
+
+	env, payload, err := events.Decode(msg.Value)
+	if errors.Is(err, events.ErrUnknownEvent) {
+		log.Printf("ℹ️ Ignored unknown event: %v", err)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", kafka.ErrInvalidMessage, err)
+	}
+
+	switch evt := payload.(type) {
+	case *events.UserRegistered:
+		// Auth service’den gelen event → Free plan ata
+		uid, err := uuid.Parse(evt.UserID)
+		if err != nil {
+			return fmt.Errorf("%w: invalid user_id: %v", kafka.ErrInvalidMessage, err)
+		}
 
-			default:
-				log.Printf("ℹ️ Ignored unknown event type: %s", event.Type)
+		// Redelivered event: the plan was already assigned on a previous attempt.
+		exists, err := s.subRepo.HasSubscription(uid)
+		if err != nil {
+			return err
+		}
+		if exists {
+			log.Printf("ℹ️ User %s already has a subscription, skipping Free plan", uid)
+			return nil
+		}
+		if err := s.AssignFreePlanToUser(uid); err != nil {
+			return fmt.Errorf("failed to assign Free plan: %w", err)
+		}
+
+	case *events.ChatCompleted:
+		// Chat service’den gelen event → Kota azalt (usage_id ile tam bir kez)
+		uid, err := uuid.Parse(evt.UserID)
+		if err != nil {
+			return fmt.Errorf("%w: invalid user_id: %v", kafka.ErrInvalidMessage, err)
+		}
+		usageID, err := uuid.Parse(evt.UsageID)
+		if err != nil {
+			usageID, err = uuid.Parse(env.ID)
+			if err != nil {
+				return fmt.Errorf("%w: invalid event id: %v", kafka.ErrInvalidMessage, err)
 			}
 		}
-	}()
 
+		_, err = s.ConsumeQuota(usageID, uid, 1, events.TypeChatCompleted)
+		switch {
+		case errors.Is(err, repository.ErrDuplicateUsage):
+			log.Printf("ℹ️ Usage %s already counted, skipping", usageID)
+		case errors.Is(err, repository.ErrQuotaExceeded), errors.Is(err, repository.ErrNoActiveSubscription),
+			errors.Is(err, repository.ErrMemberCapExceeded):
+			// The answer was already delivered; nothing left to deduct from.
+			log.Printf("⚠️ Cannot charge usage %s for user %s: %v", usageID, uid, err)
+		case err != nil:
+			return fmt.Errorf("failed to consume quota: %w", err)
+		}
+
+		// Token meters have their own ledger, so they are recorded even when
+		// the message was already counted on a previous delivery.
+		if err := s.RecordMeterUsage(usageID, uid, map[string]int64{
+			models.MeterPromptTokens:     int64(evt.PromptTokens),
+			models.MeterCompletionTokens: int64(evt.CompletionTokens),
+		}, events.TypeChatCompleted); err != nil {
+			return err
+		}
+
+	case *events.EmbeddingStored:
+		// Embedding service’den gelen event → yükleyenin token/sayfa kullanımı
+		if evt.UploaderID == "" {
+			log.Printf("ℹ️ File %s has no uploader, usage not metered", evt.FileID)
+			return nil
+		}
+		uid, err := uuid.Parse(evt.UploaderID)
+		if err != nil {
+			return fmt.Errorf("%w: invalid uploader_id: %v", kafka.ErrInvalidMessage, err)
+		}
+		// A file is metered once, however often its event is redelivered.
+		fileID, err := uuid.Parse(evt.FileID)
+		if err != nil {
+			return fmt.Errorf("%w: invalid file_id: %v", kafka.ErrInvalidMessage, err)
+		}
+
+		if err := s.RecordMeterUsage(fileID, uid, map[string]int64{
+			models.MeterEmbeddingTokens: int64(evt.EmbeddedTokens),
+			models.MeterPages:           int64(evt.Pages),
+		}, events.TypeEmbeddingStored); err != nil {
+			return err
+		}
+
+	case *events.OrgMemberChanged:
+		// Auth service’den gelen event → üyeliği yansıt (havuz kota, üye limiti)
+		return s.handleMemberChanged(evt)
+
+	case *events.OrganisationDeleted:
+		// Organizasyon silindi → aboneliğini bitir, üyeleri bırak
+		return s.handleOrganisationDeleted(evt)
+
+	case *events.UserDeleted:
+		// Hesap silindi → aboneliği kapat, üyeliği bırak, rapor gönder
+		return s.handleUserDeleted(env.CorrelationID, evt)
+
+	default:
+		log.Printf("ℹ️ Ignored event type: %s", env.Type)
+	}
+	return nil
+}
+
+// handleUserDeleted closes what a deleted user has open here and reports it
+// to auth_service.
+func (s *UserSubscriptionService) handleUserDeleted(correlationID string, evt *events.UserDeleted) error {
+	userID, err := uuid.Parse(evt.UserID)
+	if err != nil {
+		return fmt.Errorf("%w: invalid user_id: %v", kafka.ErrInvalidMessage, err)
+	}
+	n, err := s.subRepo.CloseUserAccount(userID, evt.DeletionID, correlationID)
+	if err != nil {
+		return err
+	}
+	log.Printf("🗑️ User %s deleted: %d record(s) closed", userID, n)
 	return nil
 }
@@ -2,6 +2,9 @@ import React from "react";
 import { Routes, Route, Navigate } from "react-router-dom";
 import Login from "./pages/Login";
 import Register from "./pages/Register";
+import VerifyEmail from "./pages/VerifyEmail";
+import ResetPassword from "./pages/ResetPassword";
+import OidcCallback from "./pages/OidcCallback";
 import ChatApp from "./pages/ChatApp";
 import { useAuth } from "./contexts/AuthContext";
 
@@ -16,6 +19,9 @@ export default function App() {
     <Routes>
       <Route path="/login" element={<Login />} />
       <Route path="/register" element={<Register />} />
+      <Route path="/verify-email" element={<VerifyEmail />} />
+      <Route path="/reset-password" element={<ResetPassword />} />
+      <Route path="/oidc/callback" element={<OidcCallback />} />
       <Route
         path="/app"
         element={
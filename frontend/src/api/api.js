@@ -2,7 +2,7 @@ import axios from "axios";
 
 // 🌐 API Gateway adresini .env'den al
 // Eğer tanımlı değilse 127.0.0.1 kullan (Docker uyumlu)
-const API_BASE = import.meta.env.VITE_API_BASE_URL || "http://127.0.0.1:8085";
+export const API_BASE = import.meta.env.VITE_API_BASE_URL || "http://127.0.0.1:8085";
 
 const api = axios.create({
   baseURL: API_BASE,
@@ -0,0 +1,90 @@
+import api from "./api.js";
+
+// 🔔 Notification Service (gateway üzerinden)
+
+// Yeni bildirimleri SSE ile dinler. EventSource Authorization başlığı
+// gönderemediği için akış fetch ile okunur; koparsa retry süresi sonra yeniden
+// bağlanır ve Last-Event-ID ile kaçırılanları alır. Kapatmak için dönen
+// fonksiyonu çağır.
+export function subscribeNotifications(userId, onNotification) {
+  const url = `${api.defaults.baseURL}/api/notifications/stream/${userId}`;
+  const controller = new AbortController();
+  const seen = new Set();
+  let lastEventId = "";
+  let retry = 5000;
+
+  // Tek bir SSE bloğunu ("id: ...\nevent: ...\ndata: ...") işler
+  const handleBlock = (block) => {
+    let event = "message";
+    let id = "";
+    const data = [];
+    for (const line of block.split("\n")) {
+      const i = line.indexOf(":");
+      if (i === 0) continue; // yorum (keep-alive)
+      const field = i < 0 ? line : line.slice(0, i);
+      const value = i < 0 ? "" : line.slice(i + 1).replace(/^ /, "");
+      if (field === "id") id = value;
+      else if (field === "event") event = value;
+      else if (field === "data") data.push(value);
+      else if (field === "retry" && /^\d+$/.test(value)) retry = Number(value);
+    }
+    if (id) lastEventId = id;
+    if (event !== "notification" || data.length === 0) return;
+
+    // Yeniden bağlanınca aynı bildirim iki kez gelebilir
+    if (seen.has(id)) return;
+    seen.add(id);
+    try {
+      onNotification(JSON.parse(data.join("\n")));
+    } catch (err) {
+      console.error("❌ Bildirim okunamadı:", err);
+    }
+  };
+
+  const connect = async () => {
+    while (!controller.signal.aborted) {
+      try {
+        const headers = { Accept: "text/event-stream" };
+        const token = localStorage.getItem("chatapp_token");
+        if (token) headers.Authorization = `Bearer ${token}`;
+        if (lastEventId) headers["Last-Event-ID"] = lastEventId;
+
+        const res = await fetch(url, { headers, signal: controller.signal });
+        // Oturum bitti; yeniden denemenin anlamı yok
+        if (res.status === 401 || res.status === 403) return;
+        if (!res.ok) throw new Error(`HTTP ${res.status}`);
+
+        const reader = res.body.pipeThrough(new TextDecoderStream()).getReader();
+        let buf = "";
+        for (;;) {
+          const { value, done } = await reader.read();
+          if (done) break;
+          buf += value.replace(/\r\n?/g, "\n");
+          let i;
+          while ((i = buf.indexOf("\n\n")) >= 0) {
+            handleBlock(buf.slice(0, i));
+            buf = buf.slice(i + 2);
+          }
+        }
+      } catch (err) {
+        if (controller.signal.aborted) return;
+        console.warn("⚠️ Bildirim akışı koptu, yeniden bağlanılıyor:", err);
+      }
+      await new Promise((resolve) => setTimeout(resolve, retry));
+    }
+  };
+  connect();
+
+  return () => controller.abort();
+}
+
+export async function fetchUnreadNotifications(userId) {
+  const res = await api.get(`/api/notifications/${userId}`, {
+    params: { unread: true },
+  });
+  return res.data?.notifications || [];
+}
+
+export function markNotificationRead(userId, id) {
+  return api.post(`/api/notifications/${userId}/${id}/read`);
+}
@@ -51,7 +51,6 @@ export default function ChatWindow({
 
     try {
       const payload = {
-        user_id: user.id,
         message: text,
         conversation_id: conversation.id,
       };
@@ -84,11 +83,10 @@ export default function ChatWindow({
     }
   };
 
-  // ✅ YENİ: İlk init mesajı gönder (user_id ve conversation_id kaydetmek için)
+  // ✅ YENİ: İlk init mesajı gönder (conversation_id kaydetmek için; kullanıcı token'dan alınır)
   const sendInitialMessage = async (fileId, fileName) => {
     try {
       await api.post("/api/chat", {
-        user_id: user.id,
         message: `_file_init_${fileName}`,
         conversation_id: conversation.id,
         file_id: fileId,
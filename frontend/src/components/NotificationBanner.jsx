@@ -0,0 +1,54 @@
+import React, { useEffect, useState } from "react";
+import {
+  subscribeNotifications,
+  fetchUnreadNotifications,
+  markNotificationRead,
+} from "../api/notifications.js";
+import "../styles/NotificationBanner.css";
+
+// Kota uyarılarını sayfanın üstünde gösterir
+export default function NotificationBanner({ userId }) {
+  const [notifications, setNotifications] = useState([]);
+
+  useEffect(() => {
+    if (!userId) return;
+
+    const add = (n) =>
+      setNotifications((prev) =>
+        prev.some((p) => p.id === n.id) ? prev : [n, ...prev]
+      );
+
+    fetchUnreadNotifications(userId)
+      .then((list) => list.forEach(add))
+      .catch((err) => console.error("❌ Bildirimler alınamadı:", err));
+
+    return subscribeNotifications(userId, add);
+  }, [userId]);
+
+  const dismiss = async (id) => {
+    setNotifications((prev) => prev.filter((n) => n.id !== id));
+    try {
+      await markNotificationRead(userId, id);
+    } catch (err) {
+      console.error("❌ Bildirim okundu işaretlenemedi:", err);
+    }
+  };
+
+  if (notifications.length === 0) return null;
+
+  return (
+    <div className="notification-stack">
+      {notifications.map((n) => (
+        <div key={n.id} className={`notification notification-${n.kind}`}>
+          <div className="notification-text">
+            <strong>{n.title}</strong>
+            <p>{n.body}</p>
+          </div>
+          <button className="notification-close" onClick={() => dismiss(n.id)}>
+            ✕
+          </button>
+        </div>
+      ))}
+    </div>
+  );
+}
@@ -16,26 +16,58 @@ export function AuthProvider({ children }) {
     }
   });
 
+  const saveSession = (session) => {
+    // ✅ backend { token, token_type, expires_at, user } döner; user rolleri de içerir
+    const userData = session.user;
+
+    // LocalStorage’a kaydet
+    localStorage.setItem("chatapp_token", session.token);
+    localStorage.setItem("chatapp_user", JSON.stringify(userData));
+
+    setUser(userData);
+    return userData;
+  };
+
   const login = async (email, password) => {
     try {
       const res = await api.post("/api/auth/login", { email, password });
 
-      // ✅ backend token yerine direkt user dönüyor, onu yakalayalım
-      const userData = res.data.user || res.data;
-      const token = res.data.token || "dummy-token"; // token yoksa geçici bir değer
-
-      // LocalStorage’a kaydet
-      localStorage.setItem("chatapp_token", token);
-      localStorage.setItem("chatapp_user", JSON.stringify(userData));
-
-      setUser(userData);
-      return userData;
+      // 2FA açıksa token yerine { mfa_required, mfa_token } döner
+      if (res.data.mfa_required) {
+        return { mfaRequired: true, mfaToken: res.data.mfa_token };
+      }
+      return saveSession(res.data);
     } catch (error) {
       console.error("❌ Login hatası:", error);
       throw error;
     }
   };
 
+  // 2FA: authenticator kodu veya kurtarma kodu ile girişi tamamla
+  const completeMfa = async (mfaToken, code) => {
+    const res = await api.post("/api/auth/login/2fa", { mfa_token: mfaToken, code });
+    return saveSession(res.data);
+  };
+
+  // OIDC girişi: token callback'te gelir, kullanıcı bilgisi token'ın içindedir
+  const loginWithToken = (token) => {
+    const payload = JSON.parse(
+      atob(token.split(".")[1].replace(/-/g, "+").replace(/_/g, "/"))
+    );
+    const userData = {
+      id: payload.sub,
+      username: payload.username,
+      email: payload.email,
+      roles: payload.roles,
+    };
+
+    localStorage.setItem("chatapp_token", token);
+    localStorage.setItem("chatapp_user", JSON.stringify(userData));
+
+    setUser(userData);
+    return userData;
+  };
+
   const register = async (username, email, password) => {
     const res = await api.post("/api/auth/register", {
       username,
@@ -52,7 +84,7 @@ export function AuthProvider({ children }) {
   };
 
   return (
-    <AuthContext.Provider value={{ user, login, register, logout }}>
+    <AuthContext.Provider value={{ user, login, completeMfa, loginWithToken, register, logout }}>
       {children}
     </AuthContext.Provider>
   );
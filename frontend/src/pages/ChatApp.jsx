@@ -2,6 +2,7 @@ import React, { useEffect, useState } from "react";
 import { v4 as uuidv4 } from "uuid";
 import Sidebar from "../components/Sidebar.jsx";
 import ChatWindow from "../components/ChatWindow.jsx";
+import NotificationBanner from "../components/NotificationBanner.jsx";
 import "../styles/ChatApp.css";
 import api from "../api/api.js";
 import { useAuth } from "../contexts/AuthContext.jsx";
@@ -100,6 +101,8 @@ export default function ChatApp() {
 
   return (
     <div className="chat-app">
+      <NotificationBanner userId={user?.id} />
+
       <Sidebar
         conversations={conversations}
         activeConversation={activeConversation}
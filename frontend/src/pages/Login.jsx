@@ -1,15 +1,45 @@
 import React, { useEffect, useState } from "react";
-import { useNavigate } from "react-router-dom";
+import { useLocation, useNavigate, useSearchParams } from "react-router-dom";
+import api, { API_BASE } from "../api/api.js";
 import { useAuth } from "../contexts/AuthContext.jsx";
 import "../styles/Auth.css";
 
+const OIDC_ERRORS = {
+  denied: "Giriş sağlayıcıda iptal edildi.",
+  expired: "Giriş zaman aşımına uğradı, tekrar dene.",
+  email_unverified: "Sağlayıcı e-posta adresini doğrulamamış.",
+  failed: "Sağlayıcı ile giriş başarısız oldu.",
+};
+
+// Çok fazla başarısız denemede backend 429 ve Retry-After (saniye) döner
+const throttledMessage = (error) => {
+  const seconds = Number(error.response.headers["retry-after"]) || 60;
+  return seconds >= 120
+    ? `Çok fazla başarısız deneme. ${Math.ceil(seconds / 60)} dakika sonra tekrar dene.`
+    : `Çok fazla başarısız deneme. ${seconds} saniye sonra tekrar dene.`;
+};
+
 export default function Login() {
-  const { login, user } = useAuth();
+  const { login, completeMfa, user } = useAuth();
   const navigate = useNavigate();
+  const location = useLocation();
+  // 2FA: şifreden (veya OIDC'den) sonra kod istenir
+  const [mfaToken, setMfaToken] = useState(location.state?.mfaToken ?? null);
+  const [code, setCode] = useState("");
   const [email, setEmail] = useState("");
   const [password, setPassword] = useState("");
   const [err, setErr] = useState(null);
   const [loading, setLoading] = useState(false);
+  const [providers, setProviders] = useState([]);
+  const [params] = useSearchParams();
+  const oidcError = OIDC_ERRORS[params.get("oidc_error")] ?? (params.get("oidc_error") && OIDC_ERRORS.failed);
+
+  useEffect(() => {
+    api
+      .get("/api/auth/oidc/providers")
+      .then((res) => setProviders(res.data))
+      .catch(() => setProviders([]));
+  }, []);
 
   // Eğer kullanıcı zaten varsa otomatik yönlendir
   useEffect(() => {
@@ -24,21 +54,75 @@ export default function Login() {
     setErr(null);
     try {
       // login fonksiyonu setUser çağırıyor
-      await login(email, password);
+      const result = await login(email, password);
+      if (result?.mfaRequired) {
+        setMfaToken(result.mfaToken);
+        return;
+      }
       // ekstra güvenlik: login başarılıysa navigate et
       navigate("/app", { replace: true });
     } catch (error) {
-      setErr(error.response?.data?.error || error.message);
+      if (error.response?.status === 429) {
+        setErr(throttledMessage(error));
+      } else if (error.response?.status === 403) {
+        setErr("E-posta adresin doğrulanmamış. Gelen kutundaki bağlantıyı aç.");
+      } else {
+        setErr(error.response?.data?.error || error.message);
+      }
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  const submitCode = async (e) => {
+    e.preventDefault();
+    setLoading(true);
+    setErr(null);
+    try {
+      await completeMfa(mfaToken, code.trim());
+      navigate("/app", { replace: true });
+    } catch (error) {
+      if (error.response?.status === 429) {
+        setErr(throttledMessage(error));
+      } else if (error.response?.status === 400) {
+        setErr("Kod geçersiz. Tekrar dene.");
+      } else {
+        setMfaToken(null);
+        setErr("Doğrulama süresi doldu, tekrar giriş yap.");
+      }
     } finally {
       setLoading(false);
     }
   };
 
+  if (mfaToken) {
+    return (
+      <div className="auth-container">
+        <form className="auth-form" onSubmit={submitCode}>
+          <h2>İki Adımlı Doğrulama</h2>
+          {err && <div className="error">{err}</div>}
+          <div className="info">
+            Authenticator uygulamandaki 6 haneli kodu veya bir kurtarma kodunu gir.
+          </div>
+          <input
+            placeholder="Kod"
+            value={code}
+            onChange={(e) => setCode(e.target.value)}
+            autoComplete="one-time-code"
+            required
+          />
+          <button type="submit">{loading ? "Bekleyin..." : "Doğrula"}</button>
+        </form>
+      </div>
+    );
+  }
+
   return (
     <div className="auth-container">
       <form className="auth-form" onSubmit={submit}>
         <h2>Giriş Yap</h2>
         {err && <div className="error">{err}</div>}
+        {!err && oidcError && <div className="error">{oidcError}</div>}
         <input
           placeholder="Email"
           value={email}
@@ -54,7 +138,17 @@ export default function Login() {
           required
         />
         <button type="submit">{loading ? "Bekleyin..." : "Giriş Yap"}</button>
+        {providers.map((p) => (
+          <a
+            key={p.name}
+            className="oidc-button"
+            href={`${API_BASE}/api/auth/oidc/${p.name}/login`}
+          >
+            {p.display_name} ile giriş yap
+          </a>
+        ))}
         <a href="/register">Hesap oluştur</a>
+        <a href="/reset-password">Şifremi unuttum</a>
       </form>
     </div>
   );
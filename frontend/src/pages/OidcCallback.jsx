@@ -0,0 +1,52 @@
+import React, { useEffect, useState } from "react";
+import { useNavigate } from "react-router-dom";
+import { useAuth } from "../contexts/AuthContext.jsx";
+import "../styles/Auth.css";
+
+export default function OidcCallback() {
+  const { loginWithToken } = useAuth();
+  const navigate = useNavigate();
+  const [failed, setFailed] = useState(false);
+  // auth_service token'ı URL fragment'ında gönderir (sunucuya gitmez)
+  const [fragment] = useState(
+    () => new URLSearchParams(window.location.hash.slice(1))
+  );
+  const token = fragment.get("token");
+  const mfaToken = fragment.get("mfa_token");
+
+  useEffect(() => {
+    window.history.replaceState(null, "", window.location.pathname);
+    // 2FA açıksa kod login sayfasında istenir
+    if (mfaToken) {
+      navigate("/login", { replace: true, state: { mfaToken } });
+      return;
+    }
+    if (!token) {
+      setFailed(true);
+      return;
+    }
+    try {
+      loginWithToken(token);
+      navigate("/app", { replace: true });
+    } catch {
+      setFailed(true);
+    }
+    // eslint-disable-next-line react-hooks/exhaustive-deps
+  }, [token, mfaToken]);
+
+  return (
+    <div className="auth-container">
+      <div className="auth-form">
+        <h2>Giriş</h2>
+        {failed ? (
+          <>
+            <div className="error">Giriş tamamlanamadı.</div>
+            <a href="/login">Tekrar dene</a>
+          </>
+        ) : (
+          <div className="info">Giriş yapılıyor...</div>
+        )}
+      </div>
+    </div>
+  );
+}
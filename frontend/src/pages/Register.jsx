@@ -1,16 +1,15 @@
 import React, { useState } from "react";
-import { useNavigate } from "react-router-dom";
 import { useAuth } from "../contexts/AuthContext.jsx";
 import "../styles/Auth.css";
 
 export default function Register() {
   const { register } = useAuth();
-  const navigate = useNavigate();
   const [username, setUsername] = useState("");
   const [email, setEmail] = useState("");
   const [password, setPassword] = useState("");
   const [err, setErr] = useState(null);
   const [loading, setLoading] = useState(false);
+  const [registered, setRegistered] = useState(false);
 
   const submit = async (e) => {
     e.preventDefault();
@@ -18,14 +17,29 @@ export default function Register() {
     setErr(null);
     try {
       await register(username, email, password);
-      navigate("/login");
+      // Giriş için önce e-postadaki doğrulama bağlantısı açılmalı
+      setRegistered(true);
     } catch (error) {
-      setErr(error.response?.data?.error || error.message);
+      setErr(error.response?.data?.error || error.response?.data || error.message);
     } finally {
       setLoading(false);
     }
   };
 
+  if (registered) {
+    return (
+      <div className="auth-container">
+        <div className="auth-form">
+          <h2>E-postanı Kontrol Et</h2>
+          <div className="info">
+            {email} adresine bir doğrulama bağlantısı gönderdik. Giriş yapmadan önce bağlantıyı aç.
+          </div>
+          <a href="/login">Girişe dön</a>
+        </div>
+      </div>
+    );
+  }
+
   return (
     <div className="auth-container">
       <form className="auth-form" onSubmit={submit}>
@@ -49,6 +63,7 @@ export default function Register() {
           value={password}
           onChange={(e) => setPassword(e.target.value)}
           type="password"
+          minLength={8}
           required
         />
         <button type="submit">{loading ? "Bekleyin..." : "Kayıt Ol"}</button>
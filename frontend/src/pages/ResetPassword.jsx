@@ -0,0 +1,70 @@
+import React, { useState } from "react";
+import { useNavigate, useSearchParams } from "react-router-dom";
+import api from "../api/api.js";
+import "../styles/Auth.css";
+
+// Token yoksa sıfırlama bağlantısı istenir, varsa yeni şifre belirlenir
+export default function ResetPassword() {
+  const [params] = useSearchParams();
+  const token = params.get("token");
+  const navigate = useNavigate();
+  const [email, setEmail] = useState("");
+  const [password, setPassword] = useState("");
+  const [sent, setSent] = useState(false);
+  const [err, setErr] = useState(null);
+  const [loading, setLoading] = useState(false);
+
+  const submit = async (e) => {
+    e.preventDefault();
+    setLoading(true);
+    setErr(null);
+    try {
+      if (token) {
+        await api.post("/api/auth/password/reset", { token, password });
+        navigate("/login", { replace: true });
+      } else {
+        await api.post("/api/auth/password/forgot", { email });
+        setSent(true);
+      }
+    } catch (error) {
+      setErr(error.response?.data?.error || error.response?.data || error.message);
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  return (
+    <div className="auth-container">
+      <form className="auth-form" onSubmit={submit}>
+        <h2>Şifre Sıfırlama</h2>
+        {err && <div className="error">{err}</div>}
+        {token ? (
+          <input
+            placeholder="Yeni şifre (en az 8 karakter)"
+            value={password}
+            onChange={(e) => setPassword(e.target.value)}
+            type="password"
+            minLength={8}
+            required
+          />
+        ) : sent ? (
+          <div className="info">Adres kayıtlıysa sıfırlama bağlantısı gönderildi.</div>
+        ) : (
+          <input
+            placeholder="Email"
+            value={email}
+            onChange={(e) => setEmail(e.target.value)}
+            type="email"
+            required
+          />
+        )}
+        {!sent && (
+          <button type="submit">
+            {loading ? "Bekleyin..." : token ? "Şifreyi Güncelle" : "Bağlantı Gönder"}
+          </button>
+        )}
+        <a href="/login">Girişe dön</a>
+      </form>
+    </div>
+  );
+}
@@ -0,0 +1,64 @@
+import React, { useEffect, useState } from "react";
+import { useSearchParams } from "react-router-dom";
+import api from "../api/api.js";
+import "../styles/Auth.css";
+
+export default function VerifyEmail() {
+  const [params] = useSearchParams();
+  const token = params.get("token");
+  const [status, setStatus] = useState("pending");
+  const [email, setEmail] = useState("");
+  const [resent, setResent] = useState(false);
+
+  // Bağlantıdaki token tek kullanımlık; sayfa açılınca bir kez doğrula
+  useEffect(() => {
+    if (!token) {
+      setStatus("failed");
+      return;
+    }
+    api
+      .post("/api/auth/verify-email", { token })
+      .then(() => setStatus("verified"))
+      .catch(() => setStatus("failed"));
+  }, [token]);
+
+  const resend = async (e) => {
+    e.preventDefault();
+    await api.post("/api/auth/verify-email/resend", { email });
+    setResent(true);
+  };
+
+  return (
+    <div className="auth-container">
+      <form className="auth-form" onSubmit={resend}>
+        <h2>E-posta Doğrulama</h2>
+        {status === "pending" && <div className="info">Doğrulanıyor...</div>}
+        {status === "verified" && (
+          <>
+            <div className="info">E-posta adresin doğrulandı.</div>
+            <a href="/login">Giriş yap</a>
+          </>
+        )}
+        {status === "failed" && (
+          <>
+            <div className="error">Bağlantı geçersiz veya süresi dolmuş.</div>
+            {resent ? (
+              <div className="info">Adres doğrulama bekliyorsa yeni bağlantı gönderildi.</div>
+            ) : (
+              <>
+                <input
+                  placeholder="Email"
+                  value={email}
+                  onChange={(e) => setEmail(e.target.value)}
+                  type="email"
+                  required
+                />
+                <button type="submit">Yeni bağlantı gönder</button>
+              </>
+            )}
+          </>
+        )}
+      </form>
+    </div>
+  );
+}